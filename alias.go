@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/themoonbear/gvt/gbvendor"
+)
+
+var cmdAlias = &Command{
+	Name:      "alias",
+	UsageLine: "alias list | add alias importpath | remove alias",
+	Short:     "manage short names of dependencies",
+	Long: `alias manages the short names that update and delete accept in place of
+the import path of a dependency, such as yaml for gopkg.in/yaml.v2.
+
+Aliases are recorded in the manifest and must be unique. They may also be
+set when fetching, see gvt fetch -dep-alias.
+
+Subcommands:
+	list
+		print every alias and the import path it names.
+	add alias importpath
+		name the dependency at importpath alias, replacing its previous
+		alias if any.
+	remove alias
+		remove alias.
+
+`,
+	Run: func(args []string) error {
+		if len(args) == 0 {
+			return fmt.Errorf("alias: subcommand missing")
+		}
+		m, err := vendor.ReadManifest(manifestFile())
+		if err != nil {
+			return fmt.Errorf("could not load manifest: %v", err)
+		}
+		switch sub := args[0]; {
+		case sub == "list" && len(args) == 1:
+			w := tabwriter.NewWriter(os.Stdout, 1, 2, 1, ' ', 0)
+			for _, d := range m.Dependencies {
+				if d.Alias != "" {
+					fmt.Fprintf(w, "%s\t%s\n", d.Alias, d.Importpath)
+				}
+			}
+			return w.Flush()
+		case sub == "add" && len(args) == 3:
+			if err := m.SetAlias(args[2], args[1]); err != nil {
+				return err
+			}
+		case sub == "remove" && len(args) == 2:
+			path := m.Resolve(args[1])
+			if path == args[1] {
+				return fmt.Errorf("no dependency is aliased %s", args[1])
+			}
+			if err := m.SetAlias(path, ""); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("alias: expected list, add alias importpath or remove alias")
+		}
+		return writeManifest(m)
+	},
+}
@@ -14,6 +14,25 @@ The commands are:
         update      update a local dependency
         list        list dependencies one per line
         delete      delete a local dependency
+        prune       trim vendored dependencies
+        cache-key   print a cache key for the vendored dependencies
+        verify      check the vendored dependencies
+        status      compare the manifest with the vendor directory
+        import      adopt a vendor directory written by go mod vendor
+        fmt         rewrite the manifest in canonical form
+        sbom        print a software bill of materials
+        licenses    list the license files of the dependencies
+        alias       manage short names of dependencies
+        render      render a document from the manifest
+        graph       print the import graph of the vendored packages
+        promote     vendor a quarantined dependency
+        reject      discard a quarantined dependency
+
+Every command accepts -vendor-dir dir, also read from $GVT_VENDOR_DIR, to
+vendor into dir instead of ./vendor, such as third_party/vendor in a
+monorepo. A relative dir is relative to the current directory, and the
+manifest is kept next to it, in third_party/manifest. With -g, dependencies
+still go to $GOPATH/src.
 
 Use "gvt help [command]" for more information about a command.
 
@@ -21,13 +40,17 @@ Use "gvt help [command]" for more information about a command.
 Fetch a remote dependency
 
 Usage:
-        gvt fetch [-branch branch] [-revision rev | -tag tag] [-precaire] [-no-recurse] importpath
+        gvt fetch [-branch branch] [-revision rev | -tag tag | -date YYYY-MM-DD] [-precaire] [-no-recurse] [-g] importpath...
 
 fetch vendors an upstream import path.
 
 The import path may include a url scheme. This may be useful when fetching dependencies
 from private repositories that cannot be probed.
 
+Several import paths may be given, they are fetched in turn. Those already
+vendored are skipped and listed at the end. -branch, -tag, -revision and
+the other flags describing a single dependency cannot be used then.
+
 Flags:
 	-branch branch
 		fetch from the named branch. Will also be used by gvt update.
@@ -36,16 +59,505 @@ Flags:
 		do not fetch recursively.
 	-tag tag
 		fetch the specified tag.
+	-tag-prefix prefix
+		for monorepos tagging their components like foo/v1.2.3: fetch
+		the tag with the highest semantic version among those starting
+		with prefix, compared without it, so foo/v1.10.0 is newer than
+		foo/v1.9.0 and v2.0.0 is ignored. With -tag, fetch that tag under
+		the prefix, -tag v1.2.3 -tag-prefix foo/ fetching foo/v1.2.3.
+		The full tag is recorded in the manifest.
 	-revision rev
 		fetch the specific revision from the branch or repository.
 		If no revision supplied, the latest available will be fetched.
+	-date YYYY-MM-DD
+		fetch the branch, given with -branch or the default one, as it
+		was at the start of the date, UTC: its last revision committed
+		before then, following the first parent of merges. The revision
+		is recorded as with -revision. Only supported for git
+		repositories, and cannot be used with -tag or -revision.
 	-precaire
 		allow the use of insecure protocols.
+	-proxy URL
+		send the HTTP and HTTPS requests, those of the VCS tools
+		included, through the proxy at URL. Defaults to the usual
+		HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment.
+	-ca-cert file
+		trust the PEM encoded certificates in file, such as the private
+		CA of an internal git server, along with those of the system.
+		Not supported by the hg and bzr clones.
+	-g global
+		install package in go env $GOPATH
+	-from path
+		for machines without network access, check out the git
+		repository at path, a local clone or a git bundle, also given as
+		a file:// URL, instead of the remote one. The import path must be
+		that of the root of the repository. Recursive dependencies are
+		still fetched from their remotes, see -no-recurse.
+	-repository url
+		with -from, the canonical remote recorded in the manifest, so that
+		gvt restore works from a machine with network access. Defaults
+		to the origin remote of a local clone, and is required for a
+		bundle.
+	-branch-tracking-file file
+		read branch pins from file, one "prefix -> branch" per line, e.g.
+		"golang.org/x/* -> release-branch.go1.21". The most specific matching
+		prefix is used for every fetched path, including recursive ones,
+		unless -branch, -tag or -revision is supplied.
+	-record-build-constraints
+		record in the manifest the build tags, GOOS and GOARCH values
+		constraining the files of each fetched dependency.
+	-dedupe-transitive
+		after fetching recursively, drop the manifest entries whose tree is
+		already vendored as part of another entry of the same repository
+		at the same revision, then merge the sibling entries of a same
+		repository and revision, such as golang.org/x/net/context and
+		golang.org/x/net/http2, into one of their parent directory trimmed
+		to their packages.
+	-print-plan-json
+		resolve the import path, and its dependencies unless -no-recurse is
+		given, and print as JSON every clone and copy fetch would perform,
+		with the resolved revisions and estimated sizes. Nothing is vendored.
+	-dry-run
+		log each import path fetch would vendor with the repository deduced
+		for it and the revision it would be fetched at, following the
+		pins, alternates and branch rules, without writing anything.
+		The revisions of the import paths given are resolved with git
+		ls-remote, without cloning. Their dependencies are only known
+		once a tree is checked out: they are listed from scratch clones,
+		removed afterwards and never copied into the vendor directory.
+		With -no-recurse nothing is cloned.
+	-list-vcs-tags
+		print the tags of the repository of the import path, from the
+		highest semantic version to the lowest, and its branches, then
+		exit without fetching, to choose a -tag or a -branch. Only git
+		repositories can list them without a clone.
+	-json
+		print -list-vcs-tags as a JSON object with the repository and
+		its tags and branches.
+	-apply plan
+		vendor exactly the revisions listed in a plan printed by
+		-print-plan-json, with the copy policies, such as -no-tests or
+		-strip-binaries, it was planned with. Takes no import path.
+	-strip-binaries
+		leave out of the vendored tree the binary files, such as prebuilt
+		executables, images or archives, that are not needed to build.
+		Files referenced by //go:embed and cgo objects are kept. The
+		stripped files and the size below are recorded in the manifest,
+		and update strips the binary files of the new revision again.
+	-strip-binaries-size bytes
+		only strip binary files larger than this size. Defaults to 1024.
+	-max-redirects N
+		follow at most N redirects when probing vanity import paths for
+		their go-import metadata, logging each one. Defaults to 10.
+	-depth N
+		clone N commits of the history of git repositories fetched at their
+		latest revision or at a -tag, 0 for the whole history. Defaults to
+		1. -revision ignores it, as a shallow clone may not reach the
+		revision, see -allow-shallow-revision-fallback. Other VCS always
+		clone the whole history.
+	-allow-shallow-revision-fallback
+		when -revision is given, start from a shallow git clone and deepen
+		it until the revision is found, falling back to a full clone.
+		Enabled by default, use -allow-shallow-revision-fallback=false to
+		always clone the whole history.
+	-max-history-size bytes
+		refuse to fully clone a git repository, as -revision may need,
+		when the objects of its history, measured once fetched, are
+		larger than bytes; the clone is then removed. Fetching the latest revision or a -tag only needs a shallow
+		clone and is not limited, unless -depth is 0. Defaults to 0, no
+		limit.
+	-per-dep-log-level prefix=level
+		log the import paths matching prefix at level, one of error, info
+		or debug, instead of the default info. debug shows every step of
+		the fetch. May be repeated, the longest matching prefix wins.
+	-v
+		verbose: log every step, such as the URLs cloned, the revisions
+		resolved and the number of files and bytes copied, like
+		-per-dep-log-level at debug for every import path.
+	-q
+		quiet: only report errors, like -per-dep-log-level at error for
+		every import path. -per-dep-log-level overrides both.
+	-print-cache-key
+		print the cache key of the resulting manifest to stdout, see
+		gvt help cache-key.
+	-abort-on-missing-license
+		refuse to vendor a dependency, recursive ones included, when neither
+		its tree nor the root of its repository has a license file.
+	-license-allowlist prefixes
+		comma separated import path prefixes, such as known internal
+		packages, exempt from -abort-on-missing-license.
+	-prefix-strip-host
+		vendor every dependency, recursive ones included, without the host
+		element of its import path, so github.com/foo/bar is copied to
+		vendor/foo/bar. The original import path is recorded in the
+		manifest as origin, next to the repository, and used by restore
+		and update. Code importing the dependency must be rewritten to
+		use the new path.
+	-record-parent
+		record in the manifest, as parents, the dependencies importing each
+		dependency fetched recursively.
+	-quarantine
+		copy the dependency into vendor/.quarantine instead, recording it
+		as pending there rather than in the manifest, so it can be reviewed
+		before being trusted. gvt promote then moves it into the vendor
+		directory and the manifest, and gvt reject discards it. Implies
+		-no-recurse: the dependencies of a quarantined one are recorded
+		as unresolved when it is promoted, see gvt restore -resolve-missing.
+	-record-direct-imports
+		record in the manifest, as imports, the packages of the other
+		vendored dependencies each dependency imports directly, tests
+		excluded, so the import graph is known without parsing the
+		vendored code. Every dependency is recorded, as fetching one
+		can add edges to the others, and gvt update keeps them current.
+	-two-phase
+		first download the import path and, unless -no-recurse is given,
+		all its recursive dependencies, then install them all at once. A
+		network failure while downloading leaves the vendor directory
+		untouched. A failure while installing removes the installed trees,
+		leaves the manifest unchanged and keeps the downloads, whose
+		location is logged. Cannot be used with -quarantine,
+		-lazy-recursion or -refuse-downgrade.
+	-atomic-manifest-and-tree
+		like -two-phase, but also stage the new manifest and, holding a
+		lock on it, rename it into place with the vendored trees, so that
+		either all of them are applied or none is. Trees replaced by the
+		fetch are restored if any rename fails.
+	-probe-cache file
+		remember in file, for -probe-cache-ttl, the repository that the
+		go-import metadata of each vanity import path points to, so later
+		fetches skip probing it. Defaults to gvt/probe-cache in the user
+		cache directory.
+	-probe-cache-ttl duration
+		how long a cached resolution is trusted. Defaults to 24h.
+	-no-probe-cache
+		neither read nor write the probe cache.
+	-refresh-probe-cache
+		probe every vanity import path again, replacing its cached
+		resolution.
+	-network-test
+		only check, without cloning, that the host of the import path and
+		that of the repository it resolves to can be reached, printing
+		whether each is. http is also tried with -precaire.
+	-network-test-timeout duration
+		how long each -network-test check, resolving the repository
+		included, may take. Defaults to 10s.
+	-rollback-on-partial-manifest
+		if the fetch fails, recursive dependencies included, restore the
+		manifest as it was and remove every directory vendored by this
+		run, leaving the project exactly as before. Enabled by default,
+		use -rollback-on-partial-manifest=false to keep what was fetched.
+	-split-large-repos
+		when the import path is a directory of a larger git repository,
+		only download and check out that directory and the files at the
+		root of the repository, using a partial clone and sparse-checkout.
+		Falls back to a full checkout if either is not supported.
+	-lazy-recursion
+		only fetch the dependencies imported by the import path, not the
+		dependencies of those. Their missing imports are recorded in the
+		manifest as unresolved, to be fetched later by
+		gvt restore -resolve-missing.
+	-emit-sbom file
+		after fetching, write to file a software bill of materials of the
+		vendored dependencies, see gvt help sbom.
+	-sbom-format format
+		the format of -emit-sbom, cyclonedx, the default, or spdx.
+	-dump-graph file
+		after fetching, write to file the import graph of the vendored
+		packages in Graphviz DOT format, see gvt help graph.
+	-normalize-line-endings
+		convert the CRLF line endings of the text files of each fetched
+		dependency to LF. Binary files and the files .gitattributes marks
+		as eol=crlf, -text or binary are kept as they are. Recorded in the
+		manifest, so restore and update convert them too.
+	-replace old=new
+		fetch the import paths at or below old, recursive ones included,
+		from new instead, such as a fork, still vendoring them under old.
+		The replacement is recorded in the manifest and used by restore
+		and update. May be repeated, the longest matching old wins.
+	-resolve-replace-chains
+		when the replaced path matches another -replace rule, replace it
+		in turn until no rule matches, so a -> b and b -> c fetch a from
+		c. A cycle of rules is an error.
+	-preserve-existing-unlisted
+		keep the files found in the vendor directory of the dependency but
+		not upstream, such as local patches, instead of deleting them when
+		the dependency is copied again by update or restore. Files also
+		present upstream are overwritten. Recorded in the manifest.
+	-record-readme-excerpt
+		record in the manifest, as description, the first paragraph of the
+		README of each fetched dependency, or of its repository, as plain
+		text of at most 200 characters. See gvt list -describe.
+	-verify-import-path-match
+		after copying each dependency, warn about the vendored packages
+		whose canonical import comment, as in package foo // import "x",
+		declares another import path than the one they are vendored as.
+	-strict
+		refuse to vendor a dependency failing -verify-import-path-match
+		instead of warning.
+	-repo-url-template prefix=template
+		build the repository URL of the import paths at or below prefix,
+		recursive ones included, from template instead of probing them,
+		such as for self-hosted servers without go-import meta tags. In
+		template, {host} is the first element of the import path, and
+		{org} and {repo} the first and second elements after prefix:
+		git.corp=ssh://git@git.corp/{org}/{repo}.git fetches
+		git.corp/team/proj/pkg from the pkg directory of
+		ssh://git@git.corp/team/proj.git. Without a scheme, https, git,
+		ssh and http are tried like for github.com. May be repeated, the
+		longest matching prefix wins.
+	-repo-url-template-file file
+		read more templates from file, one "prefix -> template" per line,
+		lines starting with # being ignored. Defaults to
+		gvt/repo-url-templates in the user config directory, if it exists.
+	-isolate-gopath
+		resolve the recursive dependencies with an empty temporary GOPATH,
+		so that only the vendor directory and the standard library are
+		seen, never the packages installed in the real GOPATH. -g still
+		installs in the real GOPATH.
+	-isolate-network dir
+		for tests of gvt: deduce and check out every repository from the
+		fixtures in dir instead of the network, without VCS tools. Each
+		repository is a directory of dir named after the import path of
+		its root, such as dir/example.com/repo, holding the files of its
+		only revision, recorded in a .fixture-revision file. The features
+		needing more than a checkout, such as -tag, -list-vcs-tags or
+		-verify-against-proxy, are not available.
+	-record-commit-date
+		record in the manifest, as commitdate, when the fetched revision
+		of each dependency was committed, to tell how old it is
+		independently of when it was fetched. gvt update refreshes it.
+		Supported for git and hg repositories.
+	-record-go-version
+		record in the manifest, as goversion, the go directive of the
+		go.mod of each dependency, so that gvt list -go-version can tell
+		the dependencies needing a newer Go than the one installed.
+	-record-file-count
+		record in the manifest, as filecount and bytes, the number and
+		total size of the files vendored for each dependency, so that
+		gvt list -footprint reports them without walking the vendor
+		directory. gvt update refreshes them.
+	-record-license
+		record in the manifest, as license, the license file of each
+		dependency, its LICENSE, LICENCE or COPYING file, with the SPDX
+		identifier guessed from its text when it is one of the usual
+		licenses. For a dependency fetched from a directory of its
+		repository, the license of the nearest directory above it is
+		used, and copied into the vendored tree. The license file is
+		kept whatever -no-tests, -trim-to-packages or -strip-binaries
+		leave out. gvt list -licenses prints them, gvt update refreshes
+		them.
+	-record-default-branch
+		record in the manifest, as defaultbranch, the branch the HEAD of
+		the repository of each dependency points to, such as main, even
+		when fetching a tag, a revision or another branch, so that gvt
+		update -to-default-branch can follow it later. Dependencies whose
+		repository cannot tell it, such as hg and bzr ones, are fetched
+		without it, with a warning.
+	-min-go-version-guard
+		fail when the go directive of the go.mod of a dependency, the
+		closest to the fetched directory, requires a newer Go than the
+		go command in PATH, as go env GOVERSION reports it, or, without
+		one, the Go gvt was built with, naming the dependency and both
+		versions, rather than vendoring code that will not compile.
+	-allow-newer-go
+		make -min-go-version-guard warn and fetch the dependency anyway.
+		gvt update refreshes it.
+	-chmod-normalize
+		give every vendored file mode 0644, or 0755 if the VCS records it
+		as executable, and every directory mode 0755, whatever the umask,
+		so that the modes of the vendor tree are the same on every
+		machine. Recorded in the manifest, so that restore and update do
+		the same.
+	-source-date-epoch seconds
+		set the modification time of every vendored file and directory to
+		seconds since the Unix epoch, so that the vendor tree is byte for
+		byte identical whenever and wherever it is fetched, for
+		reproducible builds. Defaults to the SOURCE_DATE_EPOCH environment
+		variable; if neither is set modification times are left alone.
+		Recorded in the manifest, so that restore and update do the same.
+	-trim-to-packages list
+		only vendor the listed packages of the dependency, directories
+		relative to its import path separated by commas, "." being the
+		dependency itself, along with the packages of the dependency they
+		import, directly or indirectly, for any platform. Everything else
+		but license files and the files embedded by the kept packages is
+		left out. Recorded in the manifest as packages, so that restore
+		and update trim the dependency the same way, see gvt help prune.
+	-package-whitelist list
+		like -trim-to-packages, vendor only the listed packages, and the
+		packages of the dependency they import, directly or indirectly,
+		which are logged. Only their imports are fetched recursively.
+		Cannot be used with -trim-to-packages.
+	-strict-revision
+		after checking out the -revision given, make sure the working copy
+		really is at that revision, or a full revision it abbreviates, and
+		fail otherwise, instead of recording whatever the repository
+		checked out.
+	-allow-empty-repo
+		when the repository has no commit yet, like a freshly created
+		one, record the dependency in the manifest without a revision
+		and without vendoring any file, warning about it, instead of
+		failing. restore skips such entries, and update fills them in
+		once the repository has commits.
+	-no-tests
+		leave the _test.go files and the testdata directories out of
+		the vendored trees, which the go tool does not need to build
+		the packages. Files embedded by the non-test files are kept.
+		Recorded in the manifest, so that restore and update leave them
+		out too. VCS metadata, such as .git, .hg or .bzr, is never
+		copied, like every hidden file.
+	-symlink-policy policy
+		what to do with the symlinks of the fetched trees: skip, the
+		default, leaves them out; deref copies in place of each one the
+		file or directory it points to. Fetches fail when a symlink
+		points outside of its repository, or to a directory holding
+		it. Recorded in the manifest, so that restore and update copy
+		the trees the same way.
+	-symlink-resolve-depth N
+		with -symlink-policy deref, follow chains of at most N symlinks
+		pointing to one another, failing with a clean error on longer
+		chains and on loops. Defaults to 8. Recorded in the manifest.
+	-submodules
+		check out the git submodules of each fetched repository,
+		recursively, at the commits its revision records, and vendor
+		their files as part of its tree. The submodules and their
+		commits are recorded in the manifest, so that restore and update
+		check them out too. Without it, the submodules of a repository
+		are left out, with a warning, as its packages may not build.
+	-extract-cgo-deps
+		scan the cgo preambles of the packages of each dependency, and
+		the C sources next to them, for quoted #include directives, and
+		make sure the included files are copied even if -strip-binaries,
+		gvt prune -keep-min or the skipping of hidden files would leave
+		them out.
+		Includes are looked up next to the including file and in the -I
+		directories of the #cgo directives. Recorded in the manifest, so
+		that restore and update copy them too.
+	-refuse-downgrade
+		re-fetch the dependency even if it is already vendored, at the
+		requested branch, tag or revision, but refuse to do so when the
+		new revision is an ancestor of the recorded one, that is older,
+		printing the commit dates of both. Guards against pinning an old
+		revision by mistake. Supported for git repositories.
+	-force
+		with -refuse-downgrade, only warn about a downgrade and fetch the
+		older revision anyway.
+	-keep-first-wins
+		when a dependency is asked for at different revisions, by the
+		manifest, the command line or the vendor/manifest of the vendored
+		dependencies, as with two dependencies pinning a common one at
+		incompatible revisions, report it and keep the revision fetched
+		first. This is the default.
+	-error-on-conflict
+		fail instead, after fetching, when a dependency is asked for at
+		different revisions. Same as -keep-first-wins=false.
+	-fail-fast
+		stop at the first recursive dependency that fails to fetch.
+		Enabled by default. With -fail-fast=false each failure is logged
+		and the failed import path skipped, the remaining dependencies are
+		fetched, and the failures are reported together at the end. What
+		was fetched is kept, even with -rollback-on-partial-manifest.
+		Import loops between vendored packages also fail the fetch, unless
+		-fail-fast=false, which logs them and skips the import closing
+		the loop.
+	-retries N
+		retry a checkout failing because of the network, such as a DNS
+		failure or a connection reset, up to N times, waiting 2s before
+		the first retry and twice as long before each next one. Other
+		failures, such as a revision not found, are not retried. After
+		the last retry, its error is reported. Defaults to 2.
+	-verify-against-proxy
+		compare each fetched dependency with the module zip the first
+		proxy of GOPROXY, proxy.golang.org by default, serves for the
+		same revision, and warn about the files added, modified or
+		removed: a difference means the repository does not serve what
+		the Go module ecosystem knows as that version. Dependencies
+		which are not in a module, or not on the proxy, are not verified.
+	-on-missing-vcs error|skip|prompt
+		what to do when the binary of the VCS of a dependency, git, hg
+		or bzr, is not installed. error, the default, fails naming the
+		missing tool. skip leaves the dependency out and records it as
+		unresolved in the manifest entries importing it, for gvt restore
+		to fetch later. prompt asks to install it and retry, or to skip it.
+		Does not apply to -two-phase.
+	-prefetch-manifest
+		when a fetched dependency is itself a gvt project, with a
+		vendor/manifest or a manifest at its root, fetch the recursive
+		dependencies it lists at the revisions it pins instead of their
+		latest one, so that gvt projects propagate their pins. The first
+		pin found for a dependency wins. Dependencies without a pin are
+		discovered and fetched as usual.
+	-follow-gomod-require
+		when a fetched dependency has a go.mod, fetch the recursive
+		dependencies its require directives list at the versions they
+		require instead of their latest one: the tag of a version, the
+		revision of a pseudo-version. The first version found for a module
+		wins, after the pins of -prefetch-manifest. Recursive dependencies
+		not required are fetched at their latest revision as usual.
+	-report-duplicates-across-repos
+		after fetching, report the dependencies of the manifest whose
+		vendored trees are identical, by checksum, to a dependency
+		vendored from another repository, such as a fork and its
+		original, which is likely a mistake. Nothing is removed.
+	-error-on-duplicates
+		make -report-duplicates-across-repos fail the fetch when it finds
+		any.
+	-j N
+		fetch up to N missing recursive dependencies at once, 1 by default.
+		Only independent import paths are fetched together: never one
+		below another, nor two of the same repository, so no repository
+		is cloned twice. The manifest is still updated one dependency at
+		a time.
+	-report file
+		write to file a JSON array of the dependencies this run added to
+		the manifest, recursive ones included, with their importpath,
+		repository, revision and branch. Written even if the fetch
+		fails, listing those recorded until then, none once rolled back,
+		see -rollback-on-partial-manifest.
+	-emit-metrics file
+		write to file a JSON array of the timelines of the fetches of
+		this run, recursive ones included: for each dependency, the
+		start, in RFC 3339 format, and the duration in seconds of its
+		phases, deduce, clone, checkout, copy and checksum, to tell
+		whether the network or the disk is the bottleneck. The clone
+		and checkout of the VCS other than git are timed as a single
+		checkout phase. Written even if the fetch fails. Cannot be used
+		with -two-phase.
+	-retry-alternate-url [prefix=]url
+		when the repository of the import path, or of those at or below
+		prefix, recursive dependencies included, cannot be found or
+		checked out, try the mirror at url instead. May be repeated, the
+		alternates are tried in the order given. The manifest records
+		the repository actually fetched from, which restore and update
+		then use. Without prefix, url is a mirror of the only import
+		path given.
+	-output-template-file template:out
+		after fetching, render the Go template in the file template over
+		the manifest into the file out, such as DEPENDENCIES.md, see
+		gvt help render. May be repeated.
+	-dep-alias alias
+		record alias as the short name of the fetched dependency, which
+		gvt update and gvt delete accept in place of its import path.
+		Aliases must be unique, see gvt help alias.
+	-merge-manifest file
+		add to the project manifest the dependencies of the manifest in
+		file, such as that of another project, and vendor them at their
+		recorded revisions. Takes no import path. Import paths recorded
+		in both manifests at different revisions are conflicts, reported
+		and resolved according to -on-conflict.
+	-on-conflict policy
+		how -merge-manifest resolves conflicts: mine keeps the project
+		revision, theirs takes the one of file, and error, the default,
+		fails leaving the project untouched.
+	-merge-materialize
+		vendor the dependencies added or replaced by -merge-manifest.
+		Enabled by default, use -merge-materialize=false to only update
+		the manifest.
 
 Restore dependencies from manifest
 
 Usage:
-        gvt restore [-precaire] [-connections N]
+        gvt restore [-precaire] [-connections N] [-g] [-only prefix] [-verify-only-changed [-full]]
 
 restore fetches the dependencies listed in the manifest.
 
@@ -53,21 +565,75 @@ It's meant for workflows that don't include checking in to VCS the vendored
 source, for example if .gitignore includes lines like
 
     vendor/**
-    !vendor/manifest
 
 Note that such a setup requires "gvt restore" to build the source, relies on
 the availability of the dependencies repositories and breaks "go get".
 
+Each dependency is checked out at the revision recorded in the manifest.
+restore fails if its repository no longer has that revision, for example
+after a force push, rather than vendoring another one.
+
 Flags:
 	-precaire
 		allow the use of insecure protocols.
+	-proxy URL
+		send the HTTP and HTTPS requests, those of the VCS tools
+		included, through the proxy at URL. Defaults to the usual
+		HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment.
+	-ca-cert file
+		trust the PEM encoded certificates in file, such as the private
+		CA of an internal git server, along with those of the system.
+		Not supported by the hg and bzr clones.
+	-v
+		verbose: log every step, such as the URLs cloned, the revisions
+		resolved and the number of files and bytes copied.
+	-q
+		quiet: only report errors.
 	-connections
 		count of parallel download connections.
+	-g global
+		install package in go env $GOPATH
+	-max-concurrent-clones-per-host N
+		download at most N dependencies from the same host at a time.
+		Defaults to -connections. When a host rate limits the downloads,
+		with HTTP 429 or a rate limit error, its concurrency is halved and
+		gvt waits before the next download from it, longer each time, then
+		retries. Successful downloads gradually bring the concurrency back.
+	-allow-shallow-revision-fallback
+		start from shallow git clones, deepened until the recorded revision
+		is found. Enabled by default.
+	-only prefix
+		only restore the dependencies at or below prefix, along with the
+		dependencies recorded by gvt fetch -record-parent as imported by
+		them, leaving the others untouched. The skipped dependencies are
+		logged.
+	-resolve-missing
+		after restoring, fetch recursively the imports that
+		gvt fetch -lazy-recursion or gvt promote recorded as unresolved,
+		completing the dependency graph.
+	-post-restore-verify
+		after restoring, check that every restored tree matches the
+		checksum recorded when it was fetched, failing if any does not.
+		Dependencies fetched before checksums were recorded cannot be
+		verified and are logged.
+	-verify-build
+		with -post-restore-verify, also run go build ./... in the project
+		and fail if it does not build.
+	-verify-only-changed
+		skip the dependencies whose manifest entry is the same as at the
+		last restore run with -verify-only-changed or -full, and whose
+		tree is still vendored: only the others are fetched again and,
+		with -post-restore-verify, checked against their checksums. The
+		entries are recorded in vendor/.restore-state once the restore
+		succeeds. The first run restores everything.
+	-full
+		restore and verify every dependency, as without
+		-verify-only-changed, and record the state it uses.
 
 Update a local dependency
 
 Usage:
-        gvt update [ -all | importpath ]
+        gvt update [ -all | -g| importpath | alias ] [-batch-commit] [-to-default-branch]
 
 update replaces the source with the latest available from the head of the fetched branch.
 
@@ -84,29 +650,357 @@ Flags:
 		update all dependencies in the manifest.
 	-precaire
 		allow the use of insecure protocols.
+	-proxy URL
+		send the HTTP and HTTPS requests, those of the VCS tools
+		included, through the proxy at URL. Defaults to the usual
+		HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment.
+	-ca-cert file
+		trust the PEM encoded certificates in file, such as the private
+		CA of an internal git server, along with those of the system.
+		Not supported by the hg and bzr clones.
+	-v
+		verbose: log every step, such as the URLs cloned, the revisions
+		resolved and the number of files and bytes copied.
+	-q
+		quiet: only report errors.
+	-g global
+		install package in go env $GOPATH
+	-branch-tracking-file file
+		read branch pins from file, see gvt help fetch. A matching rule
+		replaces the recorded branch of dependencies fetched by branch.
+	-since-tag
+		print the upstream commits between the recorded revision and the
+		new one, one per line, to help documenting the update. Only
+		supported for git repositories.
+	-changelog file
+		append the -since-tag changelog to file instead of printing it.
+	-output-template-file template:out
+		after updating, render template into out, see gvt help render.
+	-refuse-downgrade
+		refuse to update a dependency when the new head of its branch is
+		an ancestor of the recorded revision, as after a force push,
+		printing the commit dates of both. Supported for git repositories.
+	-force
+		with -refuse-downgrade, only warn about a downgrade.
+	-batch-commit
+		after updating each dependency, commit its vendored tree and
+		the manifest in the git repository of the project, as "bump
+		importpath to revision", so that the history has one commit
+		per dependency and can be bisected. The working tree must be
+		clean to start with. Dependencies left unchanged are not
+		committed, and a failing commit stops the update.
+	-to-default-branch
+		update the dependencies fetched with -record-default-branch to
+		the head of the default branch of their repository, asked again
+		so that a renamed one is followed, rather than to their recorded
+		branch, a tag or revision they were fetched at included. The new
+		branch is recorded. The other dependencies are updated as usual.
 
 List dependencies one per line
 
 Usage:
-        gvt list [-f format]
+        gvt list [-f format | -json | -describe | -go-version | -footprint | -licenses] [-unused [-json]]
 
-list formats the contents of the manifest file.
+list formats the contents of the manifest file, one dependency per line
+sorted by import path.
 
 Flags:
 	-f
-		controls the template used for printing each manifest entry. If not supplied
+		controls the text/template used for printing each manifest entry. If not supplied
 		the default value is "{{.Importpath}}\t{{.Repository}}{{.Path}}\t{{.Branch}}\t{{.Revision}}"
+		For example: gvt list -f '{{.Importpath}} {{.Revision}}'
+	-unused
+		only list, with their size in bytes, the dependencies that no package
+		of the project imports, directly or indirectly. Every Go file of the
+		project is considered, whatever its build tags. Nothing is removed,
+		see gvt help prune.
+	-json
+		print the whole manifest as JSON, or with -unused the -unused report.
+	-describe
+		list each dependency with the README excerpt recorded by
+		gvt fetch -record-readme-excerpt. Shorthand for
+		-f "{{.Importpath}}\t{{.Description}}".
+	-go-version
+		list each dependency with the Go version recorded by
+		gvt fetch -record-go-version, followed by "needs newer Go" when
+		it is newer than the go command, see go env GOVERSION.
+	-footprint
+		list each dependency with the number of files and the size in
+		bytes recorded by gvt fetch -record-file-count, "unknown" if
+		none was, followed by the total of those known. The vendor
+		directory is not read.
+	-licenses
+		list each dependency with the SPDX identifier and the file of
+		the license recorded by gvt fetch -record-license, "unknown"
+		when the identifier could not be guessed or nothing was
+		recorded. The columns are separated by tabs, to paste them into
+		a spreadsheet. See also gvt help licenses.
 
 Delete a local dependency
 
 Usage:
-        gvt delete [-all] importpath
+        gvt delete [-all | -g] importpath | alias
 
-delete removes a dependency from the vendor directory and the manifest
+delete removes a dependency from the vendor directory and the manifest,
+along with the parent directories it leaves empty, such as github.com/foo.
+The manifest is only updated once the files are removed.
 
 Flags:
 	-all
-		remove all dependencies
+		remove all dependencies, and the whole vendor directory. With -g
+		only the directories of the dependencies are removed from $GOPATH.
+	-g global
+		install package in go env $GOPATH
+
+Trim vendored dependencies
+
+Usage:
+        gvt prune [-keep-min] [-dry-run]
+
+prune trims the vendor directory down to what the project needs.
+
+The packages of the project, that is everything outside the vendor
+directory, are walked to find which vendored packages they import,
+directly or indirectly. The dependencies none of them reach, such as
+those fetched recursively for an importer since removed, are deleted
+from the vendor directory and the manifest, see gvt help delete. A
+dependency imported only by another dependency the project imports is
+kept.
+
+Flags:
+	-keep-min
+		within each imported dependency, remove the packages that are not
+		reachable and every file that is not needed to build the reachable
+		ones, such as docs, examples and tests. License files are kept.
+		The kept packages are recorded in the manifest, so that restore
+		and update reproduce the minimal tree.
+	-dry-run
+		log the dependencies that would be deleted, and with -keep-min
+		trimmed, leaving the vendor directory and the manifest untouched.
+
+Print a cache key for the vendored dependencies
+
+Usage:
+        gvt cache-key
+
+cache-key prints a hash of the manifest suitable as a CI cache key.
+
+The key only depends on what is vendored, not on the order of the manifest
+entries or when they were fetched, so it changes exactly when a dependency
+is added, removed, moved to another revision or copied with other policies,
+such as -no-tests or -symlink-policy.
+
+Check the vendored dependencies
+
+Usage:
+        gvt verify [-against manifest] [-parallel [-j N]] [-diff] [-json]
+
+verify checks the vendored dependencies and exits non-zero on any problem.
+
+Without -against, verify recomputes the checksum of the vendored tree of
+every dependency and reports those differing from the checksum recorded in
+the manifest (mismatch), and those without a recorded checksum
+(unverifiable), which do not fail the command. The report is sorted by
+import path. The comparison is local: nothing is fetched unless -diff is
+given.
+
+Flags:
+	-against manifest
+		compare the manifest with a reference one, such as the manifest of
+		another project kept in lockstep, and report every dependency
+		vendored at another revision, only in the reference (missing) or
+		only in the manifest (extra). Nothing is modified.
+	-parallel
+		hash the vendored trees concurrently, which is faster on multi-core
+		machines with fast disks. The report is the same.
+	-j N
+		the number of trees hashed at once with -parallel. Defaults to the
+		number of CPUs.
+	-diff
+		for each mismatched dependency, fetch the recorded revision again
+		to list the files modified, added or removed since it was
+		vendored, as by a hand edit. A dependency which cannot be fetched
+		is reported with the error, and the others are still listed.
+	-json
+		print the report as JSON.
+
+Compare the manifest with the vendor directory
+
+Usage:
+        gvt status [-g] [-json]
+
+status compares the dependencies listed in the manifest with the vendor
+directory and exits non-zero if they drifted apart, reporting, sorted by
+import path:
+
+	missing      dependencies of the manifest without files on disk
+	untracked    directories on disk no dependency of the manifest covers
+	unconfirmed  dependencies whose tree cannot be confirmed to be that of
+	             their recorded revision: without a revision or a checksum
+	             recorded, or not matching the checksum
+
+Nothing is modified and nothing is fetched: see gvt verify to list the
+files changed in a tree, and gvt restore to fetch the missing ones.
+
+Flags:
+	-g
+		compare with the dependencies installed in go env $GOPATH.
+	-json
+		print the status as JSON.
+
+Adopt a vendor directory written by go mod vendor
+
+Usage:
+        gvt import -from-modules-txt file [-verify] [-precaire]
+
+import records in the manifest the modules vendored by go mod vendor, as
+listed by its vendor/modules.txt, to move a project from module vendoring
+to gvt without fetching its dependencies again.
+
+Each module becomes a dependency trimmed to the packages modules.txt lists,
+see gvt help prune, at the revision its version stands for: that of a
+pseudo-version, or that of the tag of the version, which is checked out
+to resolve it. A replaced module records the module path replacing it.
+The vendored files are left untouched.
+
+The modules that cannot be mapped, like those replaced by a directory or
+whose version cannot be found upstream, are reported and left out of the
+manifest, and import exits non-zero.
+
+Flags:
+	-from-modules-txt file
+		the modules.txt to import, usually vendor/modules.txt.
+	-verify
+		compare the vendored files of each module with those of its
+		revision, and report the modules whose files were modified or
+		are not upstream as unmapped.
+	-precaire
+		allow the use of insecure protocols.
+
+Rewrite the manifest in canonical form
+
+Usage:
+        gvt fmt
+
+fmt reads the manifest and writes it back in the canonical form every gvt
+command writes it in: dependencies sorted by import path, their lists
+sorted, indented with tabs, at the latest manifest version. It normalizes
+a manifest edited by hand or written by an older gvt in a single commit.
+Nothing is fetched and the vendor directory is left untouched.
+
+Print a software bill of materials
+
+Usage:
+        gvt sbom [-format cyclonedx|spdx] [-o file]
+
+sbom prints a software bill of materials listing every vendored dependency
+with its import path, repository, revision and license files.
+
+Flags:
+	-format format
+		the document format, cyclonedx for CycloneDX 1.5 JSON, the default,
+		or spdx for SPDX 2.3 JSON.
+	-o file
+		write the document to file instead of stdout.
+
+List the license files of the dependencies
+
+Usage:
+        gvt licenses [-report-dedup] [-json]
+
+licenses lists the license files, such as LICENSE, COPYING or NOTICE, found
+at the root of the vendored tree of each dependency. Nothing is modified.
+
+Flags:
+	-report-dedup
+		group the license files by content instead: each byte-identical
+		text is listed once, by checksum, with the files and dependencies
+		holding it, the texts shared by the most dependencies first, so
+		that a review only reads each text once.
+	-json
+		print the report as JSON.
+
+Manage short names of dependencies
+
+Usage:
+        gvt alias list | add alias importpath | remove alias
+
+alias manages the short names that update and delete accept in place of
+the import path of a dependency, such as yaml for gopkg.in/yaml.v2.
+
+Aliases are recorded in the manifest and must be unique. They may also be
+set when fetching, see gvt fetch -dep-alias.
+
+Subcommands:
+	list
+		print every alias and the import path it names.
+	add alias importpath
+		name the dependency at importpath alias, replacing its previous
+		alias if any.
+	remove alias
+		remove alias.
+
+Render a document from the manifest
+
+Usage:
+        gvt render template [out]
+
+render executes the Go text/template in the template file over the manifest
+and writes the result to out, or stdout if out is not given. It keeps
+documents such as DEPENDENCIES.md in sync with what is vendored.
+
+The template is passed the manifest, whose Dependencies are sorted by import
+path and have the fields recorded in the manifest, such as Importpath,
+Repository, Revision, Branch and Path:
+
+	{{range .Dependencies}}- {{.Importpath}} at {{.Revision}}
+	{{end}}
+
+See also gvt fetch -output-template-file, rendering a template after every
+fetch.
+
+Print the import graph of the vendored packages
+
+Usage:
+        gvt graph [-o file]
+
+graph prints the import graph of the vendored packages in Graphviz DOT
+format, ready to be rendered, for example with dot -Tsvg.
+
+Each vendored package is a node, filled with a color per dependency and
+grouped with the other packages of its dependency. Each import of another
+vendored package is an edge, red when it is part of an import cycle. The
+standard library is left out.
+
+Flags:
+	-o file
+		write the graph to file instead of stdout.
+
+Vendor a quarantined dependency
+
+Usage:
+        gvt promote [-g] importpath...
+
+promote moves dependencies quarantined by gvt fetch -quarantine into the
+vendor directory and records them in the manifest, once they have been
+reviewed in vendor/.quarantine. The imports they leave missing are recorded
+as unresolved, for gvt restore -resolve-missing to fetch.
+
+Flags:
+	-g
+		use the dependencies quarantined in go env $GOPATH.
+
+Discard a quarantined dependency
+
+Usage:
+        gvt reject [-g] importpath...
+
+reject removes dependencies quarantined by gvt fetch -quarantine, leaving
+the vendor directory and the manifest untouched.
+
+Flags:
+	-g
+		use the dependencies quarantined in go env $GOPATH.
 
 */
 package main
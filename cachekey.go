@@ -0,0 +1,37 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/themoonbear/gvt/gbvendor"
+)
+
+var cmdCacheKey = &Command{
+	Name:      "cache-key",
+	UsageLine: "cache-key",
+	Short:     "print a cache key for the vendored dependencies",
+	Long: `cache-key prints a hash of the manifest suitable as a CI cache key.
+
+The key only depends on what is vendored, not on the order of the manifest
+entries or when they were fetched, so it changes exactly when a dependency
+is added, removed, moved to another revision or copied with other policies,
+such as -no-tests or -symlink-policy.
+
+`,
+	Run: func(args []string) error {
+		if len(args) != 0 {
+			return fmt.Errorf("cache-key takes no arguments")
+		}
+		return printCacheKey()
+	},
+}
+
+// printCacheKey prints the cache key of the manifest to stdout.
+func printCacheKey() error {
+	m, err := vendor.ReadManifest(manifestFile())
+	if err != nil {
+		return fmt.Errorf("could not load manifest: %v", err)
+	}
+	fmt.Println(m.CacheKey())
+	return nil
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/constabulary/gb/fileutils"
+	"github.com/themoonbear/gvt/gbvendor"
+)
+
+// copyDependency copies the tree of dep from src to dst, applying the
+// copy policies recorded in dep so that restore and update reproduce
+// what fetch vendored.
+func copyDependency(dst, src string, dep vendor.Dependency) error {
+	var skip func(string, os.FileInfo) bool
+	if len(dep.Stripped) > 0 {
+		skip = vendor.SkipFiles(dep.Stripped)
+	}
+	if dep.NoTests {
+		skip = vendor.SkipTests(skip)
+	}
+	skip, err := vendor.ProtectEmbedded(src, skip)
+	if err != nil {
+		return err
+	}
+	if dep.Symlinks == vendor.SymlinksDeref {
+		depth := dep.SymlinkDepth
+		if depth == 0 {
+			depth = vendor.DefaultSymlinkDepth
+		}
+		if err := vendor.CopytreeDeref(dst, src, skip, depth); err != nil {
+			return err
+		}
+	} else if err := vendor.Copytree(dst, src, skip); err != nil {
+		return err
+	}
+	if len(dep.Packages) > 0 {
+		if err := trimDependency(dst, dep); err != nil {
+			return fmt.Errorf("dependency could not be trimmed: %v", err)
+		}
+	}
+	if dep.License != nil {
+		root := src
+		if dep.Path != "" {
+			root = strings.TrimSuffix(src, filepath.Clean(filepath.FromSlash(dep.Path)))
+		}
+		if err := vendor.CopyLicense(dst, root, src, dep.License); err != nil {
+			return fmt.Errorf("license could not be copied: %v", err)
+		}
+	}
+	if dep.ExtractCgo {
+		files, err := vendor.RestoreCgoFiles(dst, src)
+		if err != nil {
+			return fmt.Errorf("cgo includes could not be copied: %v", err)
+		}
+		for _, f := range files {
+			debugf(dep.Importpath, "kept %s, included by cgo", f)
+		}
+	}
+	if dep.NormalizeEOL {
+		files, err := vendor.NormalizeLineEndings(dst, src)
+		if err != nil {
+			return fmt.Errorf("line endings could not be normalized: %v", err)
+		}
+		for _, f := range files {
+			debugf(dep.Importpath, "converted %s to LF line endings", f)
+		}
+	}
+	if dep.NormalizeModes {
+		if err := vendor.NormalizeModes(dst, src); err != nil {
+			return fmt.Errorf("permissions could not be normalized: %v", err)
+		}
+	}
+	if dep.SourceDateEpoch != nil {
+		if err := vendor.SetMtimes(dst, time.Unix(*dep.SourceDateEpoch, 0)); err != nil {
+			return fmt.Errorf("modification times could not be set: %v", err)
+		}
+	}
+	return nil
+}
+
+// debugCopied logs, at levelDebug, how many files and bytes were copied
+// to dst, the vendored tree of importpath, the nested trees left out.
+func debugCopied(importpath, dst string, nested []string) {
+	if levelFor(importpath) < levelDebug {
+		return
+	}
+	if files, size, err := vendor.TreeStats(dst, nested); err == nil {
+		debugf(importpath, "copied %d files, %d bytes to %s", files, size, dst)
+	}
+}
+
+// submodules checks out the git submodules of wc, the checkout of path,
+// if checkout is set, and returns them. Otherwise it warns about the
+// submodules left out.
+func submodules(path string, wc vendor.WorkingCopy, checkout bool) ([]vendor.Submodule, error) {
+	if !vendor.HasSubmodules(wc.Dir()) {
+		return nil, nil
+	}
+	if !checkout {
+		logf(path, "warning: the git submodules of %s are left out, its packages may not build without them; use fetch -submodules to vendor them", path)
+		return nil, nil
+	}
+	subs, err := vendor.CheckoutSubmodules(wc)
+	if err != nil {
+		return nil, fmt.Errorf("could not check out the submodules of %s: %v", path, err)
+	}
+	for _, s := range subs {
+		debugf(path, "checked out submodule %s at %s", s.Path, s.Revision)
+	}
+	return subs, nil
+}
+
+// restoreSubmodules checks out in wc, its checkout, the submodules
+// recorded for dep, failing unless they are at the recorded commits.
+func restoreSubmodules(wc vendor.WorkingCopy, dep vendor.Dependency) error {
+	if len(dep.Submodules) == 0 {
+		return nil
+	}
+	subs, err := submodules(dep.Importpath, wc, true)
+	if err != nil {
+		return err
+	}
+	got := make(map[string]string)
+	for _, s := range subs {
+		got[s.Path] = s.Revision
+	}
+	for _, s := range dep.Submodules {
+		switch rev, ok := got[s.Path]; {
+		case !ok:
+			return fmt.Errorf("submodule %s of %s not found at %s", s.Path, dep.Importpath, dep.Revision)
+		case rev != s.Revision:
+			return fmt.Errorf("submodule %s of %s is at %s, %s recorded", s.Path, dep.Importpath, rev, s.Revision)
+		}
+		delete(got, s.Path)
+	}
+	for p := range got {
+		return fmt.Errorf("submodule %s of %s is not recorded", p, dep.Importpath)
+	}
+	return nil
+}
+
+// trimDependency trims the vendored tree of dep at dst to dep.Packages,
+// keeping the files included by cgo if dep.ExtractCgo.
+func trimDependency(dst string, dep vendor.Dependency) error {
+	var keep map[string]bool
+	if dep.ExtractCgo {
+		var err error
+		if keep, err = vendor.CgoFiles(dst); err != nil {
+			return err
+		}
+	}
+	return vendor.TrimPackagesKeeping(dst, dep.Packages, keep)
+}
+
+// clearDependency removes the vendored tree of dep at dst before it is
+// copied again. Dependencies fetched with -preserve-existing-unlisted
+// keep the files not overwritten by the new copy.
+func clearDependency(dst string, dep vendor.Dependency) error {
+	if dep.PreserveUnlisted {
+		return nil
+	}
+	if err := fileutils.RemoveAll(dst); err != nil {
+		return fmt.Errorf("dependency could not be deleted: %v", err)
+	}
+	return nil
+}
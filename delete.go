@@ -21,13 +21,16 @@ func addDeleteFlags(fs *flag.FlagSet) {
 
 var cmdDelete = &Command{
 	Name:      "delete",
-	UsageLine: "delete [-all | -g] importpath",
+	UsageLine: "delete [-all | -g] importpath | alias",
 	Short:     "delete a local dependency",
-	Long: `delete removes a dependency from the vendor directory and the manifest
+	Long: `delete removes a dependency from the vendor directory and the manifest,
+along with the parent directories it leaves empty, such as github.com/foo.
+The manifest is only updated once the files are removed.
 
 Flags:
 	-all
-		remove all dependencies
+		remove all dependencies, and the whole vendor directory. With -g
+		only the directories of the dependencies are removed from $GOPATH.
 	-g global
 		install package in go env $GOPATH
 
@@ -49,7 +52,10 @@ Flags:
 			dependencies = make([]vendor.Dependency, len(m.Dependencies))
 			copy(dependencies, m.Dependencies)
 		} else {
-			p := args[0]
+			p := m.Resolve(args[0])
+			if !m.HasImportpath(p) {
+				return fmt.Errorf("delete: %s is not vendored", p)
+			}
 			dependency, err := m.GetDependencyForImportpath(p)
 			if err != nil {
 				return fmt.Errorf("could not get dependency: %v", err)
@@ -57,6 +63,7 @@ Flags:
 			dependencies = append(dependencies, dependency)
 		}
 
+		vdir := vendorDir(global)
 		for _, d := range dependencies {
 			path := d.Importpath
 
@@ -64,12 +71,20 @@ Flags:
 				return fmt.Errorf("dependency could not be deleted: %v", err)
 			}
 
-			if err := fileutils.RemoveAll(filepath.Join(vendorDir(global), filepath.FromSlash(path))); err != nil {
-				// TODO(dfc) need to apply vendor.cleanpath here to remove indermediate directories.
+			dir := filepath.Join(vdir, filepath.FromSlash(path))
+			if err := fileutils.RemoveAll(dir); err != nil {
+				return fmt.Errorf("dependency could not be deleted: %v", err)
+			}
+			if err := vendor.CleanPath(filepath.Dir(dir), vdir); err != nil {
 				return fmt.Errorf("dependency could not be deleted: %v", err)
 			}
 		}
-		return vendor.WriteManifest(manifestFile(), m)
+		if deleteAll && !global {
+			if err := fileutils.RemoveAll(vdir); err != nil {
+				return fmt.Errorf("vendor directory could not be deleted: %v", err)
+			}
+		}
+		return writeManifest(m)
 	},
 	AddFlags: addDeleteFlags,
 }
@@ -1,48 +1,329 @@
 package main
 
 import (
+	"bufio"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"go/build"
-	"log"
+	"io/ioutil"
 	"net/url"
+	"os"
 	"path/filepath"
 	"runtime"
 	"sort"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/constabulary/gb/fileutils"
 	"github.com/themoonbear/gvt/gbvendor"
 )
 
-var (
+// fetchOptions are the flags of a fetch, which the fetches of its
+// recursive dependencies, its plans and gvt restore -resolve-missing
+// read through the fetchOptions they are given.
+type fetchOptions struct {
 	branch    string
 	revision  string // revision (commit)
 	tag       string
+	tagPrefix string // only consider the tags starting with it, compared without it
+	fetchDate string // fetch the revision -branch was at on this YYYY-MM-DD date
 	noRecurse bool
 	insecure  bool // Allow the use of insecure protocols
+	global    bool // install package in go env $GOPATH
+
+	httpProxy  string // proxy of the HTTP requests and the VCS tools
+	caCertFile string // certificates trusted along with the system ones
+
+	fromLocal      string // local clone or bundle checked out instead of the remote
+	fromRepository string // remote recorded for fromLocal
+
+	branchTrackingFile string             // file mapping import path prefixes to branches
+	branchRules        vendor.BranchRules // rules read from branchTrackingFile
+
+	recordBuildConstraints bool // record the build constraints of each dependency
+	dedupeTransitive       bool // collapse dependencies vendored twice after recursion
+
+	printPlanJSON bool   // print the fetch plan instead of executing it
+	dryRun        bool   // only log what would be fetched, without vendoring
+	applyPlanFile string // execute a previously printed plan
+
+	listVCSTags bool // print the tags and branches of the repository instead of fetching
+	vcsTagsJSON bool // print -list-vcs-tags as JSON
+
+	stripBinaries     bool  // leave large binary files out of the vendored tree
+	stripBinariesSize int64 // size above which binary files are stripped
+
+	printCacheKeyAfter bool // print the manifest cache key after fetching
+
+	abortOnMissingLicense bool   // refuse dependencies without a license file
+	licenseAllowlist      string // comma separated prefixes exempt from the license check
+
+	prefixStripHost bool // vendor dependencies without their host element
+
+	twoPhase              bool // download every dependency before installing any
+	atomicManifestAndTree bool // rename the trees and the manifest into place together
+
+	recordParent        bool // record which dependencies pulled in a recursive one
+	recordDirectImports bool // record the packages of other dependencies each one imports
+
+	fetchJobs int // recursive dependencies fetched at once
+
+	noProbeCache bool // probe vanity import paths every time
+
+	networkTestOnly    bool          // only check the hosts involved can be reached
+	networkTestTimeout time.Duration // timeout of each reachability check
+
+	rollbackOnPartialManifest bool // undo a failed fetch
+
+	splitLargeRepos bool // only check out the fetched directory of a repository
+
+	lazyRecursion bool // only fetch the direct dependencies
+
+	quarantine bool // stage the fetched dependency for review instead of vendoring it
+
+	emitSBOMFile string // write an SBOM after fetching
+	sbomFormat   string // format of emitSBOMFile
+
+	dumpGraphFile string // write the DOT import graph after fetching
+
+	reportFile string // write the dependencies fetched as JSON
+
+	emitMetricsFile string // write the timings of the phases of each fetch as JSON
+
+	normalizeLineEndings bool // convert CRLF to LF in text files
+
+	preserveExistingUnlisted bool // keep local files in the vendored trees
+
+	recordReadmeExcerpt bool // record the start of the README of each dependency
+
+	verifyImportPathMatch bool // check the import comments of the vendored packages
+	strict                bool // fail instead of warning on mismatching import comments
+
+	depAlias string // alias of the fetched dependency
+
+	outputTemplates templateOutputs // documents rendered after fetching
+
+	repoURLTemplateFile string // file of persistent repository URL templates
+
+	isolateGOPATH bool // discover packages without the real GOPATH
 
-	recurse bool // should we fetch recursively
-	global bool // install package in go env $GOPATH
+	isolateNetwork string // fixtures served instead of the network
+
+	recordCommitDate bool // record the committer date of each revision
+
+	recordGoVersion bool // record the go directive of the go.mod of each dependency
+	recordFileCount bool // record the number and size of the files vendored
+	recordLicense   bool // record the license file and SPDX identifier of each dependency
+
+	recordDefaultBranch bool // record the default branch of the repository of each dependency
+
+	minGoVersionGuard bool // refuse dependencies requiring a newer Go than the go command
+	allowNewerGo      bool // only warn about them
+
+	verifyAgainstProxy bool // compare each dependency with the module zip of GOPROXY
+
+	extractCgoDeps bool // always copy the files included by cgo packages
+	noTests        bool // leave out the test files and testdata directories
+
+	symlinkPolicy string // skip or deref the symlinks of the fetched trees
+	symlinkDepth  int    // symlinks followed in a chain with -symlink-policy deref
+
+	fetchSubmodules bool // check out and vendor the git submodules of the fetched repositories
+
+	strictRevision bool // fail if -revision was not the one checked out
+	allowEmptyRepo bool // record repositories without commit as placeholders
+
+	trimToPackages   string // comma separated packages the fetched dependency is trimmed to
+	packageWhitelist string // comma separated packages, the only ones of the dependency vendored
+
+	chmodNormalize bool // give the vendored files canonical permissions
+
+	sourceDateEpoch int64  // modification time of the vendored files, -1 for SOURCE_DATE_EPOCH
+	epoch           *int64 // resolved sourceDateEpoch, nil to keep modification times
+
+	refuseDowngrade bool   // refuse revisions older than the recorded ones
+	force           bool   // downgrade anyway with -refuse-downgrade
+	refetch         string // import path re-fetched with -refuse-downgrade
+
+	keepFirstWins   bool // keep the first revision of conflicting transitive dependencies
+	errorOnConflict bool // fail on conflicting transitive dependencies
+
+	failFast bool // stop at the first recursive dependency failing to fetch
+
+	retries int // checkouts retried after a network failure
+
+	onMissingVCS string // what to do with dependencies whose VCS is not installed
+
+	prefetchManifest   bool // adopt the pins of the manifests of upstream gvt projects
+	followGoModRequire bool // adopt the versions required by the go.mod of the dependencies fetched
+
+	reportDuplicates  bool // report identical trees vendored from different repositories
+	errorOnDuplicates bool // fail on identical trees vendored from different repositories
+
+	mergeManifestFile string // merge the dependencies of another manifest
+	onConflict        string // how -merge-manifest resolves conflicts
+	mergeMaterialize  bool   // vendor the merged dependencies
+
+	replaceRules         replaceFlag   // import paths fetched in place of others
+	alternateURLs        alternateFlag // repositories tried when the deduced one fails
+	resolveReplaceChains bool          // follow replacements of replacements
+}
+
+// the state of a fetch run, shared by the fetches of its dependencies.
+var (
+	fetchOpts *fetchOptions // the flags of gvt fetch, set by addFetchFlags
+
+	buildContext = &build.Default // discovers the packages, isolated by -isolate-gopath
+
+	parents map[string][]string // dependencies importing each one being fetched, guarded by manifestMu
+	created []string            // directories vendored by this run
+
+	fetched   []vendor.Dependency // recorded by this run, guarded by manifestMu
+	timelines []fetchTimeline     // of the fetches of this run, guarded by manifestMu
+
+	requests     vendor.RevisionRequests     // revisions each dependency was asked for
+	failures     = make(vendor.Failures)     // recursive dependencies skipped by -fail-fast=false, reported once by Run
+	pins         vendor.Pins                 // pins found by -prefetch-manifest and -follow-gomod-require
+	stdin        = bufio.NewReader(os.Stdin) // answers to the -on-missing-vcs prompt
+	retryBackoff = 2 * time.Second           // wait before the first retry, doubled each time
+
+	inflight   vendor.FetchGroup // dedupes concurrent fetches of an import path
+	manifestMu sync.Mutex        // serialises manifest updates
 )
 
+// newFetchOptions returns the options of a fetch given no flag, as gvt
+// restore -resolve-missing fetches.
+func newFetchOptions() *fetchOptions {
+	o := new(fetchOptions)
+	o.addFlags(flag.NewFlagSet("fetch", flag.ContinueOnError))
+	return o
+}
+
 func addFetchFlags(fs *flag.FlagSet) {
-	fs.StringVar(&branch, "branch", "", "branch of the package")
-	fs.StringVar(&revision, "revision", "", "revision of the package")
-	fs.StringVar(&tag, "tag", "", "tag of the package")
-	fs.BoolVar(&noRecurse, "no-recurse", false, "do not fetch recursively")
-	fs.BoolVar(&insecure, "precaire", false, "allow the use of insecure protocols")
-	fs.BoolVar(&global, "g", false, "install package in go env $GOPATH")
+	fetchOpts = new(fetchOptions)
+	fetchOpts.addFlags(fs)
+	fs.IntVar(&vendor.MaxRedirects, "max-redirects", 10, "maximum number of redirects followed when probing import paths")
+	fs.IntVar(&vendor.CloneDepth, "depth", 1, "number of commits of history git clones of the latest revision or a -tag fetch, 0 for the whole history")
+	fs.BoolVar(&vendor.ShallowRevisionFallback, "allow-shallow-revision-fallback", true, "clone shallow and deepen until -revision is found")
+	fs.Int64Var(&vendor.MaxHistorySize, "max-history-size", 0, "refuse full git clones of repositories larger than this many bytes, 0 for no limit")
+	fs.Var(&perDepLogLevels, "per-dep-log-level", "prefix=level log level for matching import paths, repeatable")
+	addVerbosityFlags(fs)
+	fs.StringVar(&vendor.ProbeCacheFile, "probe-cache", defaultProbeCache(), "file caching the resolution of vanity import paths")
+	fs.DurationVar(&vendor.ProbeCacheTTL, "probe-cache-ttl", 24*time.Hour, "how long a cached resolution is trusted")
+	fs.BoolVar(&vendor.RefreshProbeCache, "refresh-probe-cache", false, "probe again and replace the cached resolutions")
+	fs.Var(&vendor.RepoURLTemplates, "repo-url-template", "prefix=template repository URL of the import paths below prefix, repeatable")
+}
+
+// addFlags adds the flags of fetch setting o, the default value of each
+// set as it is added.
+func (o *fetchOptions) addFlags(fs *flag.FlagSet) {
+	o.replaceRules = replaceFlag{}
+	fs.StringVar(&o.branch, "branch", "", "branch of the package")
+	fs.StringVar(&o.revision, "revision", "", "revision of the package")
+	fs.StringVar(&o.tag, "tag", "", "tag of the package")
+	fs.StringVar(&o.tagPrefix, "tag-prefix", "", "fetch the latest semver tag starting with this prefix, or -tag under it")
+	fs.StringVar(&o.fetchDate, "date", "", "fetch the last revision of the branch committed before this YYYY-MM-DD date, UTC")
+	fs.BoolVar(&o.noRecurse, "no-recurse", false, "do not fetch recursively")
+	fs.StringVar(&o.fromLocal, "from", "", "check out the local git clone or bundle at this path instead of the remote repository")
+	fs.StringVar(&o.fromRepository, "repository", "", "with -from, the remote repository recorded in the manifest")
+	fs.BoolVar(&o.insecure, "precaire", false, "allow the use of insecure protocols")
+	fs.StringVar(&o.httpProxy, "proxy", "", "HTTP proxy URL of the requests and clones, defaults to $HTTPS_PROXY and $HTTP_PROXY")
+	fs.StringVar(&o.caCertFile, "ca-cert", "", "PEM file of the certificates trusted along with the system ones")
+	fs.BoolVar(&o.global, "g", false, "install package in go env $GOPATH")
+	fs.StringVar(&o.branchTrackingFile, "branch-tracking-file", "", "file mapping import path prefixes to branches")
+	fs.BoolVar(&o.recordBuildConstraints, "record-build-constraints", false, "record the build constraints of each dependency")
+	fs.BoolVar(&o.dedupeTransitive, "dedupe-transitive", false, "collapse dependencies already vendored as part of another one")
+	fs.BoolVar(&o.printPlanJSON, "print-plan-json", false, "print the operations fetch would perform as JSON")
+	fs.BoolVar(&o.dryRun, "dry-run", false, "log the import paths that would be fetched and their repositories, cloning only to list the dependencies, not at all with -no-recurse")
+	fs.BoolVar(&o.listVCSTags, "list-vcs-tags", false, "print the tags and branches of the repository of the import path, without fetching")
+	fs.BoolVar(&o.vcsTagsJSON, "json", false, "print -list-vcs-tags as JSON")
+	fs.StringVar(&o.applyPlanFile, "apply", "", "execute a plan printed by -print-plan-json")
+	fs.BoolVar(&o.stripBinaries, "strip-binaries", false, "leave binary files out of the vendored tree")
+	fs.Int64Var(&o.stripBinariesSize, "strip-binaries-size", defaultStripBinariesSize, "size in bytes above which binary files are stripped")
+	fs.BoolVar(&o.printCacheKeyAfter, "print-cache-key", false, "print the manifest cache key after fetching")
+	fs.BoolVar(&o.abortOnMissingLicense, "abort-on-missing-license", false, "refuse to vendor dependencies without a license file")
+	fs.StringVar(&o.licenseAllowlist, "license-allowlist", "", "comma separated import path prefixes exempt from -abort-on-missing-license")
+	fs.BoolVar(&o.prefixStripHost, "prefix-strip-host", false, "vendor dependencies without their host, github.com/foo/bar as foo/bar")
+	fs.BoolVar(&o.recordParent, "record-parent", false, "record in the manifest which dependencies import each recursive one")
+	fs.BoolVar(&o.atomicManifestAndTree, "atomic-manifest-and-tree", false, "stage the trees and the manifest and rename them into place together, implies -two-phase")
+	fs.BoolVar(&o.twoPhase, "two-phase", false, "download every dependency before installing any")
+	fs.BoolVar(&o.networkTestOnly, "network-test", false, "only check that the hosts involved in the fetch can be reached")
+	fs.DurationVar(&o.networkTestTimeout, "network-test-timeout", 10*time.Second, "timeout of each -network-test check")
+	fs.BoolVar(&o.noProbeCache, "no-probe-cache", false, "do not use the probe cache")
+	fs.BoolVar(&o.recordReadmeExcerpt, "record-readme-excerpt", false, "record the first paragraph of the README of each dependency")
+	fs.BoolVar(&o.preserveExistingUnlisted, "preserve-existing-unlisted", false, "keep the files of a vendored tree missing upstream when it is copied again")
+	fs.Var(o.replaceRules, "replace", "old=new fetches import paths below old from new instead, repeatable")
+	fs.Var(&o.alternateURLs, "retry-alternate-url", "[prefix=]url of a mirror tried when the repository fails, repeatable")
+	fs.BoolVar(&o.resolveReplaceChains, "resolve-replace-chains", false, "follow -replace rules applying to the replaced path")
+	fs.BoolVar(&o.normalizeLineEndings, "normalize-line-endings", false, "convert CRLF line endings of text files to LF")
+	fs.StringVar(&o.emitSBOMFile, "emit-sbom", "", "write a software bill of materials to file after fetching")
+	fs.StringVar(&o.dumpGraphFile, "dump-graph", "", "write the import graph of the vendored packages to file in DOT format after fetching")
+	fs.StringVar(&o.reportFile, "report", "", "write the dependencies fetched by this run to file as JSON, even if the fetch fails")
+	fs.StringVar(&o.emitMetricsFile, "emit-metrics", "", "write to file as JSON how long each phase of the fetch of each dependency took")
+	fs.StringVar(&o.sbomFormat, "sbom-format", vendor.SBOMCycloneDX, "format of -emit-sbom, cyclonedx or spdx")
+	fs.BoolVar(&o.lazyRecursion, "lazy-recursion", false, "only fetch the dependencies imported directly, deferring theirs")
+	fs.BoolVar(&o.splitLargeRepos, "split-large-repos", false, "only check out the directory of the import path when it is below the repository root")
+	fs.BoolVar(&o.verifyImportPathMatch, "verify-import-path-match", false, "warn when the import comment of a vendored package disagrees with its import path")
+	fs.BoolVar(&o.strict, "strict", false, "make -verify-import-path-match fail instead of warning")
+	fs.StringVar(&o.repoURLTemplateFile, "repo-url-template-file", defaultRepoURLTemplateFile(), "file of repository URL templates, one \"prefix -> template\" per line")
+	fs.StringVar(&o.isolateNetwork, "isolate-network", "", "serve the repositories from the fixtures in this directory instead of the network")
+	fs.BoolVar(&o.isolateGOPATH, "isolate-gopath", false, "discover packages with an empty temporary GOPATH instead of the real one")
+	fs.BoolVar(&o.recordCommitDate, "record-commit-date", false, "record the committer date of the fetched revision of each dependency")
+	fs.BoolVar(&o.recordGoVersion, "record-go-version", false, "record the Go version required by the go.mod of each dependency")
+	fs.BoolVar(&o.recordFileCount, "record-file-count", false, "record the number and total size of the files vendored for each dependency")
+	fs.BoolVar(&o.recordLicense, "record-license", false, "record the license file of each dependency, with the SPDX identifier guessed from it")
+	fs.BoolVar(&o.recordDefaultBranch, "record-default-branch", false, "record the default branch of the repository of each dependency, whatever is checked out")
+	fs.BoolVar(&o.minGoVersionGuard, "min-go-version-guard", false, "refuse dependencies whose go.mod requires a newer Go than the go command")
+	fs.BoolVar(&o.allowNewerGo, "allow-newer-go", false, "make -min-go-version-guard warn instead of failing")
+	fs.BoolVar(&o.chmodNormalize, "chmod-normalize", false, "give the vendored files mode 0644, or 0755 if executable, and the directories 0755")
+	fs.Int64Var(&o.sourceDateEpoch, "source-date-epoch", -1, "set the modification time of the vendored files to seconds since the Unix epoch, defaults to $SOURCE_DATE_EPOCH")
+	fs.StringVar(&o.trimToPackages, "trim-to-packages", "", "comma separated packages of the dependency to vendor, with the packages of the same repository they import")
+	fs.StringVar(&o.packageWhitelist, "package-whitelist", "", "comma separated packages of the dependency to vendor, with those of the same repository they import, and no other")
+	fs.BoolVar(&o.strictRevision, "strict-revision", false, "fail unless the revision checked out is exactly the one given with -revision")
+	fs.BoolVar(&o.allowEmptyRepo, "allow-empty-repo", false, "record a repository without any commit as an entry without revision nor files")
+	fs.BoolVar(&o.noTests, "no-tests", false, "leave out the _test.go files and the testdata directories")
+	fs.StringVar(&o.symlinkPolicy, "symlink-policy", vendor.SymlinksSkip, "skip the symlinks of the fetched trees, or deref them to copy what they point to")
+	fs.IntVar(&o.symlinkDepth, "symlink-resolve-depth", vendor.DefaultSymlinkDepth, "with -symlink-policy deref, the longest chain of symlinks followed")
+	fs.BoolVar(&o.fetchSubmodules, "submodules", false, "check out the git submodules of the fetched repositories and vendor their files with the tree")
+	fs.BoolVar(&o.extractCgoDeps, "extract-cgo-deps", false, "always copy the local files included by the cgo preambles and C sources")
+	fs.BoolVar(&o.refuseDowngrade, "refuse-downgrade", false, "re-fetch an already vendored dependency, refusing a revision older than the recorded one")
+	fs.BoolVar(&o.force, "force", false, "let -refuse-downgrade fetch an older revision anyway")
+	fs.BoolVar(&o.keepFirstWins, "keep-first-wins", true, "report transitive dependencies asked for at different revisions and keep the first one fetched")
+	fs.BoolVar(&o.errorOnConflict, "error-on-conflict", false, "fail when transitive dependencies are asked for at different revisions")
+	fs.BoolVar(&o.prefetchManifest, "prefetch-manifest", false, "fetch the recursive dependencies at the revisions pinned by the manifest of the gvt projects fetched")
+	fs.BoolVar(&o.followGoModRequire, "follow-gomod-require", false, "fetch the recursive dependencies at the versions required by the go.mod of the dependencies fetched")
+	fs.BoolVar(&o.reportDuplicates, "report-duplicates-across-repos", false, "report identical trees vendored from different repositories")
+	fs.BoolVar(&o.errorOnDuplicates, "error-on-duplicates", false, "make -report-duplicates-across-repos fail the fetch")
+	fs.IntVar(&o.fetchJobs, "j", 1, "number of recursive dependencies fetched at once")
+	fs.IntVar(&o.retries, "retries", 2, "times a checkout failing because of the network is retried")
+	fs.BoolVar(&o.failFast, "fail-fast", true, "stop at the first recursive dependency failing to fetch")
+	fs.BoolVar(&o.quarantine, "quarantine", false, "stage the dependency in vendor/.quarantine until gvt promote or gvt reject")
+	fs.BoolVar(&o.recordDirectImports, "record-direct-imports", false, "record in the manifest the packages of other dependencies each dependency imports")
+	fs.BoolVar(&o.verifyAgainstProxy, "verify-against-proxy", false, "warn if a dependency differs from the module zip served by GOPROXY")
+	fs.StringVar(&o.onMissingVCS, "on-missing-vcs", vendor.FailOnMissingVCS, "what to do with dependencies whose VCS is not installed, error, skip or prompt")
+	fs.Var(&o.outputTemplates, "output-template-file", "template:out renders template over the manifest into out after fetching, repeatable")
+	fs.StringVar(&o.depAlias, "dep-alias", "", "short name update and delete accept in place of the import path")
+	fs.StringVar(&o.mergeManifestFile, "merge-manifest", "", "add the dependencies of another manifest to the project one")
+	fs.StringVar(&o.onConflict, "on-conflict", vendor.FailOnConflict, "resolution of -merge-manifest conflicts, mine, theirs or error")
+	fs.BoolVar(&o.mergeMaterialize, "merge-materialize", true, "vendor the dependencies added by -merge-manifest")
+	fs.BoolVar(&o.rollbackOnPartialManifest, "rollback-on-partial-manifest", true, "restore the manifest and vendor directory if the fetch fails")
 }
 
 var cmdFetch = &Command{
 	Name:      "fetch",
-	UsageLine: "fetch [-branch branch] [-revision rev | -tag tag] [-precaire] [-no-recurse] [-g] importpath",
+	UsageLine: "fetch [-branch branch] [-revision rev | -tag tag | -date YYYY-MM-DD] [-precaire] [-no-recurse] [-g] importpath...",
 	Short:     "fetch a remote dependency",
 	Long: `fetch vendors an upstream import path.
 
 The import path may include a url scheme. This may be useful when fetching dependencies
 from private repositories that cannot be probed.
 
+Several import paths may be given, they are fetched in turn. Those already
+vendored are skipped and listed at the end. -branch, -tag, -revision and
+the other flags describing a single dependency cannot be used then.
+
 Flags:
 	-branch branch
 		fetch from the named branch. Will also be used by gvt update.
@@ -51,153 +332,2331 @@ Flags:
 		do not fetch recursively.
 	-tag tag
 		fetch the specified tag.
+	-tag-prefix prefix
+		for monorepos tagging their components like foo/v1.2.3: fetch
+		the tag with the highest semantic version among those starting
+		with prefix, compared without it, so foo/v1.10.0 is newer than
+		foo/v1.9.0 and v2.0.0 is ignored. With -tag, fetch that tag under
+		the prefix, -tag v1.2.3 -tag-prefix foo/ fetching foo/v1.2.3.
+		The full tag is recorded in the manifest.
 	-revision rev
 		fetch the specific revision from the branch or repository.
 		If no revision supplied, the latest available will be fetched.
+	-date YYYY-MM-DD
+		fetch the branch, given with -branch or the default one, as it
+		was at the start of the date, UTC: its last revision committed
+		before then, following the first parent of merges. The revision
+		is recorded as with -revision. Only supported for git
+		repositories, and cannot be used with -tag or -revision.
 	-precaire
 		allow the use of insecure protocols.
+	-proxy URL
+		send the HTTP and HTTPS requests, those of the VCS tools
+		included, through the proxy at URL. Defaults to the usual
+		HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment.
+	-ca-cert file
+		trust the PEM encoded certificates in file, such as the private
+		CA of an internal git server, along with those of the system.
+		Not supported by the hg and bzr clones.
 	-g global
 		install package in go env $GOPATH
+	-from path
+		for machines without network access, check out the git
+		repository at path, a local clone or a git bundle, also given as
+		a file:// URL, instead of the remote one. The import path must be
+		that of the root of the repository. Recursive dependencies are
+		still fetched from their remotes, see -no-recurse.
+	-repository url
+		with -from, the canonical remote recorded in the manifest, so that
+		gvt restore works from a machine with network access. Defaults
+		to the origin remote of a local clone, and is required for a
+		bundle.
+	-branch-tracking-file file
+		read branch pins from file, one "prefix -> branch" per line, e.g.
+		"golang.org/x/* -> release-branch.go1.21". The most specific matching
+		prefix is used for every fetched path, including recursive ones,
+		unless -branch, -tag or -revision is supplied.
+	-record-build-constraints
+		record in the manifest the build tags, GOOS and GOARCH values
+		constraining the files of each fetched dependency.
+	-dedupe-transitive
+		after fetching recursively, drop the manifest entries whose tree is
+		already vendored as part of another entry of the same repository
+		at the same revision, then merge the sibling entries of a same
+		repository and revision, such as golang.org/x/net/context and
+		golang.org/x/net/http2, into one of their parent directory trimmed
+		to their packages.
+	-print-plan-json
+		resolve the import path, and its dependencies unless -no-recurse is
+		given, and print as JSON every clone and copy fetch would perform,
+		with the resolved revisions and estimated sizes. Nothing is vendored.
+	-dry-run
+		log each import path fetch would vendor with the repository deduced
+		for it and the revision it would be fetched at, following the
+		pins, alternates and branch rules, without writing anything.
+		The revisions of the import paths given are resolved with git
+		ls-remote, without cloning. Their dependencies are only known
+		once a tree is checked out: they are listed from scratch clones,
+		removed afterwards and never copied into the vendor directory.
+		With -no-recurse nothing is cloned.
+	-list-vcs-tags
+		print the tags of the repository of the import path, from the
+		highest semantic version to the lowest, and its branches, then
+		exit without fetching, to choose a -tag or a -branch. Only git
+		repositories can list them without a clone.
+	-json
+		print -list-vcs-tags as a JSON object with the repository and
+		its tags and branches.
+	-apply plan
+		vendor exactly the revisions listed in a plan printed by
+		-print-plan-json, with the copy policies, such as -no-tests or
+		-strip-binaries, it was planned with. Takes no import path.
+	-strip-binaries
+		leave out of the vendored tree the binary files, such as prebuilt
+		executables, images or archives, that are not needed to build.
+		Files referenced by //go:embed and cgo objects are kept. The
+		stripped files and the size below are recorded in the manifest,
+		and update strips the binary files of the new revision again.
+	-strip-binaries-size bytes
+		only strip binary files larger than this size. Defaults to 1024.
+	-max-redirects N
+		follow at most N redirects when probing vanity import paths for
+		their go-import metadata, logging each one. Defaults to 10.
+	-depth N
+		clone N commits of the history of git repositories fetched at their
+		latest revision or at a -tag, 0 for the whole history. Defaults to
+		1. -revision ignores it, as a shallow clone may not reach the
+		revision, see -allow-shallow-revision-fallback. Other VCS always
+		clone the whole history.
+	-allow-shallow-revision-fallback
+		when -revision is given, start from a shallow git clone and deepen
+		it until the revision is found, falling back to a full clone.
+		Enabled by default, use -allow-shallow-revision-fallback=false to
+		always clone the whole history.
+	-max-history-size bytes
+		refuse to fully clone a git repository, as -revision may need,
+		when the objects of its history, measured once fetched, are
+		larger than bytes; the clone is then removed. Fetching the latest revision or a -tag only needs a shallow
+		clone and is not limited, unless -depth is 0. Defaults to 0, no
+		limit.
+	-per-dep-log-level prefix=level
+		log the import paths matching prefix at level, one of error, info
+		or debug, instead of the default info. debug shows every step of
+		the fetch. May be repeated, the longest matching prefix wins.
+	-v
+		verbose: log every step, such as the URLs cloned, the revisions
+		resolved and the number of files and bytes copied, like
+		-per-dep-log-level at debug for every import path.
+	-q
+		quiet: only report errors, like -per-dep-log-level at error for
+		every import path. -per-dep-log-level overrides both.
+	-print-cache-key
+		print the cache key of the resulting manifest to stdout, see
+		gvt help cache-key.
+	-abort-on-missing-license
+		refuse to vendor a dependency, recursive ones included, when neither
+		its tree nor the root of its repository has a license file.
+	-license-allowlist prefixes
+		comma separated import path prefixes, such as known internal
+		packages, exempt from -abort-on-missing-license.
+	-prefix-strip-host
+		vendor every dependency, recursive ones included, without the host
+		element of its import path, so github.com/foo/bar is copied to
+		vendor/foo/bar. The original import path is recorded in the
+		manifest as origin, next to the repository, and used by restore
+		and update. Code importing the dependency must be rewritten to
+		use the new path.
+	-record-parent
+		record in the manifest, as parents, the dependencies importing each
+		dependency fetched recursively.
+	-quarantine
+		copy the dependency into vendor/.quarantine instead, recording it
+		as pending there rather than in the manifest, so it can be reviewed
+		before being trusted. gvt promote then moves it into the vendor
+		directory and the manifest, and gvt reject discards it. Implies
+		-no-recurse: the dependencies of a quarantined one are recorded
+		as unresolved when it is promoted, see gvt restore -resolve-missing.
+	-record-direct-imports
+		record in the manifest, as imports, the packages of the other
+		vendored dependencies each dependency imports directly, tests
+		excluded, so the import graph is known without parsing the
+		vendored code. Every dependency is recorded, as fetching one
+		can add edges to the others, and gvt update keeps them current.
+	-two-phase
+		first download the import path and, unless -no-recurse is given,
+		all its recursive dependencies, then install them all at once. A
+		network failure while downloading leaves the vendor directory
+		untouched. A failure while installing removes the installed trees,
+		leaves the manifest unchanged and keeps the downloads, whose
+		location is logged. Cannot be used with -quarantine,
+		-lazy-recursion or -refuse-downgrade.
+	-atomic-manifest-and-tree
+		like -two-phase, but also stage the new manifest and, holding a
+		lock on it, rename it into place with the vendored trees, so that
+		either all of them are applied or none is. Trees replaced by the
+		fetch are restored if any rename fails.
+	-probe-cache file
+		remember in file, for -probe-cache-ttl, the repository that the
+		go-import metadata of each vanity import path points to, so later
+		fetches skip probing it. Defaults to gvt/probe-cache in the user
+		cache directory.
+	-probe-cache-ttl duration
+		how long a cached resolution is trusted. Defaults to 24h.
+	-no-probe-cache
+		neither read nor write the probe cache.
+	-refresh-probe-cache
+		probe every vanity import path again, replacing its cached
+		resolution.
+	-network-test
+		only check, without cloning, that the host of the import path and
+		that of the repository it resolves to can be reached, printing
+		whether each is. http is also tried with -precaire.
+	-network-test-timeout duration
+		how long each -network-test check, resolving the repository
+		included, may take. Defaults to 10s.
+	-rollback-on-partial-manifest
+		if the fetch fails, recursive dependencies included, restore the
+		manifest as it was and remove every directory vendored by this
+		run, leaving the project exactly as before. Enabled by default,
+		use -rollback-on-partial-manifest=false to keep what was fetched.
+	-split-large-repos
+		when the import path is a directory of a larger git repository,
+		only download and check out that directory and the files at the
+		root of the repository, using a partial clone and sparse-checkout.
+		Falls back to a full checkout if either is not supported.
+	-lazy-recursion
+		only fetch the dependencies imported by the import path, not the
+		dependencies of those. Their missing imports are recorded in the
+		manifest as unresolved, to be fetched later by
+		gvt restore -resolve-missing.
+	-emit-sbom file
+		after fetching, write to file a software bill of materials of the
+		vendored dependencies, see gvt help sbom.
+	-sbom-format format
+		the format of -emit-sbom, cyclonedx, the default, or spdx.
+	-dump-graph file
+		after fetching, write to file the import graph of the vendored
+		packages in Graphviz DOT format, see gvt help graph.
+	-normalize-line-endings
+		convert the CRLF line endings of the text files of each fetched
+		dependency to LF. Binary files and the files .gitattributes marks
+		as eol=crlf, -text or binary are kept as they are. Recorded in the
+		manifest, so restore and update convert them too.
+	-replace old=new
+		fetch the import paths at or below old, recursive ones included,
+		from new instead, such as a fork, still vendoring them under old.
+		The replacement is recorded in the manifest and used by restore
+		and update. May be repeated, the longest matching old wins.
+	-resolve-replace-chains
+		when the replaced path matches another -replace rule, replace it
+		in turn until no rule matches, so a -> b and b -> c fetch a from
+		c. A cycle of rules is an error.
+	-preserve-existing-unlisted
+		keep the files found in the vendor directory of the dependency but
+		not upstream, such as local patches, instead of deleting them when
+		the dependency is copied again by update or restore. Files also
+		present upstream are overwritten. Recorded in the manifest.
+	-record-readme-excerpt
+		record in the manifest, as description, the first paragraph of the
+		README of each fetched dependency, or of its repository, as plain
+		text of at most 200 characters. See gvt list -describe.
+	-verify-import-path-match
+		after copying each dependency, warn about the vendored packages
+		whose canonical import comment, as in package foo // import "x",
+		declares another import path than the one they are vendored as.
+	-strict
+		refuse to vendor a dependency failing -verify-import-path-match
+		instead of warning.
+	-repo-url-template prefix=template
+		build the repository URL of the import paths at or below prefix,
+		recursive ones included, from template instead of probing them,
+		such as for self-hosted servers without go-import meta tags. In
+		template, {host} is the first element of the import path, and
+		{org} and {repo} the first and second elements after prefix:
+		git.corp=ssh://git@git.corp/{org}/{repo}.git fetches
+		git.corp/team/proj/pkg from the pkg directory of
+		ssh://git@git.corp/team/proj.git. Without a scheme, https, git,
+		ssh and http are tried like for github.com. May be repeated, the
+		longest matching prefix wins.
+	-repo-url-template-file file
+		read more templates from file, one "prefix -> template" per line,
+		lines starting with # being ignored. Defaults to
+		gvt/repo-url-templates in the user config directory, if it exists.
+	-isolate-gopath
+		resolve the recursive dependencies with an empty temporary GOPATH,
+		so that only the vendor directory and the standard library are
+		seen, never the packages installed in the real GOPATH. -g still
+		installs in the real GOPATH.
+	-isolate-network dir
+		for tests of gvt: deduce and check out every repository from the
+		fixtures in dir instead of the network, without VCS tools. Each
+		repository is a directory of dir named after the import path of
+		its root, such as dir/example.com/repo, holding the files of its
+		only revision, recorded in a .fixture-revision file. The features
+		needing more than a checkout, such as -tag, -list-vcs-tags or
+		-verify-against-proxy, are not available.
+	-record-commit-date
+		record in the manifest, as commitdate, when the fetched revision
+		of each dependency was committed, to tell how old it is
+		independently of when it was fetched. gvt update refreshes it.
+		Supported for git and hg repositories.
+	-record-go-version
+		record in the manifest, as goversion, the go directive of the
+		go.mod of each dependency, so that gvt list -go-version can tell
+		the dependencies needing a newer Go than the one installed.
+	-record-file-count
+		record in the manifest, as filecount and bytes, the number and
+		total size of the files vendored for each dependency, so that
+		gvt list -footprint reports them without walking the vendor
+		directory. gvt update refreshes them.
+	-record-license
+		record in the manifest, as license, the license file of each
+		dependency, its LICENSE, LICENCE or COPYING file, with the SPDX
+		identifier guessed from its text when it is one of the usual
+		licenses. For a dependency fetched from a directory of its
+		repository, the license of the nearest directory above it is
+		used, and copied into the vendored tree. The license file is
+		kept whatever -no-tests, -trim-to-packages or -strip-binaries
+		leave out. gvt list -licenses prints them, gvt update refreshes
+		them.
+	-record-default-branch
+		record in the manifest, as defaultbranch, the branch the HEAD of
+		the repository of each dependency points to, such as main, even
+		when fetching a tag, a revision or another branch, so that gvt
+		update -to-default-branch can follow it later. Dependencies whose
+		repository cannot tell it, such as hg and bzr ones, are fetched
+		without it, with a warning.
+	-min-go-version-guard
+		fail when the go directive of the go.mod of a dependency, the
+		closest to the fetched directory, requires a newer Go than the
+		go command in PATH, as go env GOVERSION reports it, or, without
+		one, the Go gvt was built with, naming the dependency and both
+		versions, rather than vendoring code that will not compile.
+	-allow-newer-go
+		make -min-go-version-guard warn and fetch the dependency anyway.
+		gvt update refreshes it.
+	-chmod-normalize
+		give every vendored file mode 0644, or 0755 if the VCS records it
+		as executable, and every directory mode 0755, whatever the umask,
+		so that the modes of the vendor tree are the same on every
+		machine. Recorded in the manifest, so that restore and update do
+		the same.
+	-source-date-epoch seconds
+		set the modification time of every vendored file and directory to
+		seconds since the Unix epoch, so that the vendor tree is byte for
+		byte identical whenever and wherever it is fetched, for
+		reproducible builds. Defaults to the SOURCE_DATE_EPOCH environment
+		variable; if neither is set modification times are left alone.
+		Recorded in the manifest, so that restore and update do the same.
+	-trim-to-packages list
+		only vendor the listed packages of the dependency, directories
+		relative to its import path separated by commas, "." being the
+		dependency itself, along with the packages of the dependency they
+		import, directly or indirectly, for any platform. Everything else
+		but license files and the files embedded by the kept packages is
+		left out. Recorded in the manifest as packages, so that restore
+		and update trim the dependency the same way, see gvt help prune.
+	-package-whitelist list
+		like -trim-to-packages, vendor only the listed packages, and the
+		packages of the dependency they import, directly or indirectly,
+		which are logged. Only their imports are fetched recursively.
+		Cannot be used with -trim-to-packages.
+	-strict-revision
+		after checking out the -revision given, make sure the working copy
+		really is at that revision, or a full revision it abbreviates, and
+		fail otherwise, instead of recording whatever the repository
+		checked out.
+	-allow-empty-repo
+		when the repository has no commit yet, like a freshly created
+		one, record the dependency in the manifest without a revision
+		and without vendoring any file, warning about it, instead of
+		failing. restore skips such entries, and update fills them in
+		once the repository has commits.
+	-no-tests
+		leave the _test.go files and the testdata directories out of
+		the vendored trees, which the go tool does not need to build
+		the packages. Files embedded by the non-test files are kept.
+		Recorded in the manifest, so that restore and update leave them
+		out too. VCS metadata, such as .git, .hg or .bzr, is never
+		copied, like every hidden file.
+	-symlink-policy policy
+		what to do with the symlinks of the fetched trees: skip, the
+		default, leaves them out; deref copies in place of each one the
+		file or directory it points to. Fetches fail when a symlink
+		points outside of its repository, or to a directory holding
+		it. Recorded in the manifest, so that restore and update copy
+		the trees the same way.
+	-symlink-resolve-depth N
+		with -symlink-policy deref, follow chains of at most N symlinks
+		pointing to one another, failing with a clean error on longer
+		chains and on loops. Defaults to 8. Recorded in the manifest.
+	-submodules
+		check out the git submodules of each fetched repository,
+		recursively, at the commits its revision records, and vendor
+		their files as part of its tree. The submodules and their
+		commits are recorded in the manifest, so that restore and update
+		check them out too. Without it, the submodules of a repository
+		are left out, with a warning, as its packages may not build.
+	-extract-cgo-deps
+		scan the cgo preambles of the packages of each dependency, and
+		the C sources next to them, for quoted #include directives, and
+		make sure the included files are copied even if -strip-binaries,
+		gvt prune -keep-min or the skipping of hidden files would leave
+		them out.
+		Includes are looked up next to the including file and in the -I
+		directories of the #cgo directives. Recorded in the manifest, so
+		that restore and update copy them too.
+	-refuse-downgrade
+		re-fetch the dependency even if it is already vendored, at the
+		requested branch, tag or revision, but refuse to do so when the
+		new revision is an ancestor of the recorded one, that is older,
+		printing the commit dates of both. Guards against pinning an old
+		revision by mistake. Supported for git repositories.
+	-force
+		with -refuse-downgrade, only warn about a downgrade and fetch the
+		older revision anyway.
+	-keep-first-wins
+		when a dependency is asked for at different revisions, by the
+		manifest, the command line or the vendor/manifest of the vendored
+		dependencies, as with two dependencies pinning a common one at
+		incompatible revisions, report it and keep the revision fetched
+		first. This is the default.
+	-error-on-conflict
+		fail instead, after fetching, when a dependency is asked for at
+		different revisions. Same as -keep-first-wins=false.
+	-fail-fast
+		stop at the first recursive dependency that fails to fetch.
+		Enabled by default. With -fail-fast=false each failure is logged
+		and the failed import path skipped, the remaining dependencies are
+		fetched, and the failures are reported together at the end. What
+		was fetched is kept, even with -rollback-on-partial-manifest.
+		Import loops between vendored packages also fail the fetch, unless
+		-fail-fast=false, which logs them and skips the import closing
+		the loop.
+	-retries N
+		retry a checkout failing because of the network, such as a DNS
+		failure or a connection reset, up to N times, waiting 2s before
+		the first retry and twice as long before each next one. Other
+		failures, such as a revision not found, are not retried. After
+		the last retry, its error is reported. Defaults to 2.
+	-verify-against-proxy
+		compare each fetched dependency with the module zip the first
+		proxy of GOPROXY, proxy.golang.org by default, serves for the
+		same revision, and warn about the files added, modified or
+		removed: a difference means the repository does not serve what
+		the Go module ecosystem knows as that version. Dependencies
+		which are not in a module, or not on the proxy, are not verified.
+	-on-missing-vcs error|skip|prompt
+		what to do when the binary of the VCS of a dependency, git, hg
+		or bzr, is not installed. error, the default, fails naming the
+		missing tool. skip leaves the dependency out and records it as
+		unresolved in the manifest entries importing it, for gvt restore
+		to fetch later. prompt asks to install it and retry, or to skip it.
+		Does not apply to -two-phase.
+	-prefetch-manifest
+		when a fetched dependency is itself a gvt project, with a
+		vendor/manifest or a manifest at its root, fetch the recursive
+		dependencies it lists at the revisions it pins instead of their
+		latest one, so that gvt projects propagate their pins. The first
+		pin found for a dependency wins. Dependencies without a pin are
+		discovered and fetched as usual.
+	-follow-gomod-require
+		when a fetched dependency has a go.mod, fetch the recursive
+		dependencies its require directives list at the versions they
+		require instead of their latest one: the tag of a version, the
+		revision of a pseudo-version. The first version found for a module
+		wins, after the pins of -prefetch-manifest. Recursive dependencies
+		not required are fetched at their latest revision as usual.
+	-report-duplicates-across-repos
+		after fetching, report the dependencies of the manifest whose
+		vendored trees are identical, by checksum, to a dependency
+		vendored from another repository, such as a fork and its
+		original, which is likely a mistake. Nothing is removed.
+	-error-on-duplicates
+		make -report-duplicates-across-repos fail the fetch when it finds
+		any.
+	-j N
+		fetch up to N missing recursive dependencies at once, 1 by default.
+		Only independent import paths are fetched together: never one
+		below another, nor two of the same repository, so no repository
+		is cloned twice. The manifest is still updated one dependency at
+		a time.
+	-report file
+		write to file a JSON array of the dependencies this run added to
+		the manifest, recursive ones included, with their importpath,
+		repository, revision and branch. Written even if the fetch
+		fails, listing those recorded until then, none once rolled back,
+		see -rollback-on-partial-manifest.
+	-emit-metrics file
+		write to file a JSON array of the timelines of the fetches of
+		this run, recursive ones included: for each dependency, the
+		start, in RFC 3339 format, and the duration in seconds of its
+		phases, deduce, clone, checkout, copy and checksum, to tell
+		whether the network or the disk is the bottleneck. The clone
+		and checkout of the VCS other than git are timed as a single
+		checkout phase. Written even if the fetch fails. Cannot be used
+		with -two-phase.
+	-retry-alternate-url [prefix=]url
+		when the repository of the import path, or of those at or below
+		prefix, recursive dependencies included, cannot be found or
+		checked out, try the mirror at url instead. May be repeated, the
+		alternates are tried in the order given. The manifest records
+		the repository actually fetched from, which restore and update
+		then use. Without prefix, url is a mirror of the only import
+		path given.
+	-output-template-file template:out
+		after fetching, render the Go template in the file template over
+		the manifest into the file out, such as DEPENDENCIES.md, see
+		gvt help render. May be repeated.
+	-dep-alias alias
+		record alias as the short name of the fetched dependency, which
+		gvt update and gvt delete accept in place of its import path.
+		Aliases must be unique, see gvt help alias.
+	-merge-manifest file
+		add to the project manifest the dependencies of the manifest in
+		file, such as that of another project, and vendor them at their
+		recorded revisions. Takes no import path. Import paths recorded
+		in both manifests at different revisions are conflicts, reported
+		and resolved according to -on-conflict.
+	-on-conflict policy
+		how -merge-manifest resolves conflicts: mine keeps the project
+		revision, theirs takes the one of file, and error, the default,
+		fails leaving the project untouched.
+	-merge-materialize
+		vendor the dependencies added or replaced by -merge-manifest.
+		Enabled by default, use -merge-materialize=false to only update
+		the manifest.
 
 `,
-	Run: func(args []string) error {
+	Run: func(args []string) (err error) {
+		o := fetchOpts
+		if o.noProbeCache {
+			vendor.ProbeCacheFile = ""
+		}
+		if o.isolateNetwork != "" {
+			vendor.DefaultFetcher = &vendor.FixtureFetcher{Root: o.isolateNetwork}
+		}
+		if err := setVerbosity(); err != nil {
+			return fmt.Errorf("fetch: %v", err)
+		}
+		cleanup, err := vendor.SetTransport(o.httpProxy, o.caCertFile)
+		if err != nil {
+			return fmt.Errorf("fetch: %v", err)
+		}
+		defer cleanup()
+		if err := o.loadBranchRules(); err != nil {
+			return err
+		}
+		if err := o.loadRepoURLTemplates(); err != nil {
+			return err
+		}
+		if err := o.loadSourceDateEpoch(); err != nil {
+			return err
+		}
+		if o.isolateGOPATH {
+			ctx, cleanup, err := vendor.IsolateGOPATH()
+			if err != nil {
+				return err
+			}
+			buildContext = ctx
+			defer func() {
+				buildContext = &build.Default
+				cleanup()
+			}()
+		}
+		if o.applyPlanFile != "" {
+			if len(args) != 0 {
+				return fmt.Errorf("fetch: -apply takes no import path")
+			}
+			return o.applyPlan(o.applyPlanFile)
+		}
+		if o.mergeManifestFile != "" {
+			if len(args) != 0 {
+				return fmt.Errorf("fetch: -merge-manifest takes no import path")
+			}
+			return o.mergeManifest(o.mergeManifestFile)
+		}
 		switch len(args) {
 		case 0:
 			return fmt.Errorf("fetch: import path missing")
-		case 1:
-			path := args[0]
-			recurse = !noRecurse
-			return fetch(path, recurse, global)
 		default:
-			return fmt.Errorf("more than one import path supplied")
+			if err := o.checkMultipleFetch(args); err != nil {
+				return err
+			}
+			for _, path := range args {
+				if _, err := stripscheme(path); err != nil {
+					return fmt.Errorf("fetch: %v", err)
+				}
+			}
+			path := args[0]
+			recurse := !o.noRecurse && !o.quarantine
+			if o.fromRepository != "" && o.fromLocal == "" {
+				return fmt.Errorf("fetch: -repository requires -from")
+			}
+			if o.trimToPackages != "" && o.packageWhitelist != "" {
+				return fmt.Errorf("fetch: -package-whitelist cannot be used with -trim-to-packages")
+			}
+			if o.fetchDate != "" {
+				if o.tag != "" || o.revision != "" {
+					return fmt.Errorf("fetch: -date cannot be used with -tag or -revision")
+				}
+				if _, err := time.Parse(fetchDateLayout, o.fetchDate); err != nil {
+					return fmt.Errorf("fetch: -date %q is not a YYYY-MM-DD date", o.fetchDate)
+				}
+			}
+			if o.twoPhase || o.atomicManifestAndTree {
+				switch {
+				case o.quarantine:
+					return fmt.Errorf("fetch: -quarantine cannot be used with -two-phase")
+				case o.lazyRecursion:
+					return fmt.Errorf("fetch: -lazy-recursion cannot be used with -two-phase")
+				case o.refuseDowngrade:
+					return fmt.Errorf("fetch: -refuse-downgrade cannot be used with -two-phase, which only fetches what is not vendored")
+				}
+			}
+			switch {
+			case o.symlinkPolicy != vendor.SymlinksSkip && o.symlinkPolicy != vendor.SymlinksDeref:
+				return fmt.Errorf("fetch: unknown -symlink-policy %q, expected %s or %s", o.symlinkPolicy, vendor.SymlinksSkip, vendor.SymlinksDeref)
+			case o.symlinkDepth < 1:
+				return fmt.Errorf("fetch: -symlink-resolve-depth must be at least 1")
+			case o.symlinkDepth != vendor.DefaultSymlinkDepth && o.symlinkPolicy != vendor.SymlinksDeref:
+				return fmt.Errorf("fetch: -symlink-resolve-depth requires -symlink-policy %s", vendor.SymlinksDeref)
+			}
+			if o.emitMetricsFile != "" && (o.twoPhase || o.atomicManifestAndTree) {
+				return fmt.Errorf("fetch: -emit-metrics cannot be used with -two-phase")
+			}
+			if o.networkTestOnly {
+				return o.networkTest(path)
+			}
+			if o.listVCSTags {
+				return o.printVCSTags(path)
+			}
+			if o.dryRun {
+				return o.dryRunFetch(args, recurse)
+			}
+			if o.printPlanJSON {
+				plan, err := o.planFetch(path, recurse)
+				if err != nil {
+					return err
+				}
+				buf, err := json.MarshalIndent(plan, "", "\t")
+				if err != nil {
+					return err
+				}
+				_, err = fmt.Fprintf(os.Stdout, "%s\n", buf)
+				return err
+			}
+			if o.depAlias != "" {
+				if err := o.checkDepAlias(path); err != nil {
+					return err
+				}
+			}
+			if err := loadRequests(); err != nil {
+				return err
+			}
+			if o.refuseDowngrade {
+				var err error
+				if o.refetch, err = o.vendoredPath(path); err != nil {
+					return err
+				}
+			}
+			fetched = nil
+			failures = make(vendor.Failures)
+			if o.reportFile != "" {
+				defer func() {
+					if rerr := writeFetchReport(o.reportFile); err == nil {
+						err = rerr
+					}
+				}()
+			}
+			timelines = nil
+			if o.emitMetricsFile != "" {
+				defer func() {
+					if merr := writeMetrics(o.emitMetricsFile); err == nil {
+						err = merr
+					}
+				}()
+			}
+			fetchFn := o.fetch
+			switch {
+			case o.twoPhase || o.atomicManifestAndTree:
+				fetchFn = o.twoPhaseFetch
+			case o.rollbackOnPartialManifest:
+				fetchFn = o.fetchWithRollback
+			}
+			var already []string
+			for _, path := range args {
+				err := fetchFn(path, recurse)
+				switch {
+				case err == AlreadyErr && len(args) > 1:
+					already = append(already, path)
+				case err != nil && err != vendor.ErrSkippedVCS:
+					return err
+				}
+			}
+			if len(args) > 1 {
+				infof("fetched %d of %d import paths", len(args)-len(already), len(args))
+				if len(already) > 0 {
+					infof("already vendored: %s", strings.Join(already, ", "))
+				}
+			}
+			if o.depAlias != "" {
+				if err := o.setDepAlias(path); err != nil {
+					return err
+				}
+			}
+			if err := o.reportConflicts(); err != nil {
+				return err
+			}
+			if o.recordDirectImports {
+				if err := recordImports(o.global); err != nil {
+					return err
+				}
+			}
+			if o.reportDuplicates {
+				if err := o.reportDuplicateTrees(); err != nil {
+					return err
+				}
+			}
+			if err := o.outputTemplates.render(); err != nil {
+				return err
+			}
+			if o.emitSBOMFile != "" {
+				if err := emitSBOM(o.emitSBOMFile, o.sbomFormat, o.global); err != nil {
+					return err
+				}
+			}
+			if o.dumpGraphFile != "" {
+				if err := dumpGraph(o.dumpGraphFile, o.global); err != nil {
+					return err
+				}
+			}
+			if o.printCacheKeyAfter {
+				if err := printCacheKey(); err != nil {
+					return err
+				}
+			}
+			if len(failures) > 0 {
+				return failures
+			}
+			return nil
 		}
 	},
 	AddFlags: addFetchFlags,
 }
 
-var AlreadyErr = fmt.Errorf("alread vendored")
+// checkMultipleFetch rejects the flags applying to a single import path
+// when several are fetched at once.
+func (o *fetchOptions) checkMultipleFetch(args []string) error {
+	if len(args) < 2 {
+		return nil
+	}
+	single := map[string]bool{
+		"branch":            o.branch != "",
+		"tag":               o.tag != "",
+		"revision":          o.revision != "",
+		"tag-prefix":        o.tagPrefix != "",
+		"date":              o.fetchDate != "",
+		"from":              o.fromLocal != "",
+		"trim-to-packages":  o.trimToPackages != "",
+		"package-whitelist": o.packageWhitelist != "",
+		"dep-alias":         o.depAlias != "",
+		"refuse-downgrade":  o.refuseDowngrade,
+		"print-plan-json":   o.printPlanJSON,
+		"network-test":      o.networkTestOnly,
+		"list-vcs-tags":     o.listVCSTags,
+	}
+	for _, u := range o.alternateURLs {
+		if u.Prefix == "" {
+			// which of the import paths would it be a mirror of?
+			single["retry-alternate-url without prefix"] = true
+		}
+	}
+	var set []string
+	for name, ok := range single {
+		if ok {
+			set = append(set, "-"+name)
+		}
+	}
+	if len(set) > 0 {
+		sort.Strings(set)
+		return fmt.Errorf("fetch: %s cannot be used with more than one import path", strings.Join(set, ", "))
+	}
+	return nil
+}
+
+// defaultStripBinariesSize is the size above which -strip-binaries strips
+// binary files, and that update assumes for the manifests listing stripped
+// files without the size.
+const defaultStripBinariesSize = 1024
+
+// readmeExcerptLen is the maximum length of a recorded README excerpt.
+const readmeExcerptLen = 200
+
+// readmeExcerpt returns the excerpt of the README of the dependency at
+// src, or of the repository checked out in root if it has none.
+func readmeExcerpt(src, root string) (string, error) {
+	for _, dir := range []string{src, root} {
+		excerpt, err := vendor.ReadmeExcerpt(dir, readmeExcerptLen)
+		if err != nil || excerpt != "" {
+			return excerpt, err
+		}
+	}
+	return "", nil
+}
+
+var AlreadyErr = fmt.Errorf("alread vendored")
+
+func (o *fetchOptions) fetch(path string, recurse bool) error {
+	m, err := vendor.ReadManifest(manifestFile())
+	if err != nil {
+		return fmt.Errorf("could not load manifest: %v", err)
+	}
+
+	// strip of any scheme portion from the path, it is already
+	// encoded in the repo.
+	stripped, err := stripscheme(path)
+	if err != nil {
+		return err
+	}
+
+	remote := path
+	if len(o.replaceRules) > 0 {
+		remote, err = vendor.Replacements(o.replaceRules).Resolve(stripped, o.resolveReplaceChains)
+		if err != nil {
+			return err
+		}
+	}
+
+	// checked before deducing the repository, so fetching what is
+	// already vendored needs no network.
+	importpath, err := o.vendoredPath(stripped)
+	if err != nil {
+		return err
+	}
+
+	var old *vendor.Dependency
+	if m.HasImportpath(importpath) {
+		if importpath != o.refetch {
+			logf(stripped, "%s is already vendored", importpath)
+			return AlreadyErr
+		}
+		d, err := m.GetDependencyForImportpath(importpath)
+		if err != nil {
+			return err
+		}
+		old = &d
+	}
+	if o.quarantine {
+		p, err := vendor.Pending(vendorDir(o.global))
+		if err != nil {
+			return err
+		}
+		if p.HasImportpath(importpath) {
+			return fmt.Errorf("%s is already quarantined, see gvt help promote and gvt help reject", importpath)
+		}
+	}
+
+	timeline := o.newTimeline(importpath)
+	start := time.Now()
+	var (
+		repo  vendor.RemoteRepo
+		extra string
+	)
+	err = vendor.HandleMissingVCS(o.onMissingVCS, stripped, stdin, os.Stderr, func() (err error) {
+		if o.fromLocal != "" {
+			repo, err = vendor.LocalRepo(o.fromLocal, o.fromRepository)
+			return err
+		}
+		repo, extra, err = vendor.DeduceRemoteRepo(remote, o.insecure)
+		return err
+	})
+	if err == vendor.ErrSkippedVCS {
+		return skipMissingVCS(stripped)
+	}
+	alts := o.alternateURLs.lookup(stripped)
+	if err != nil && len(alts) > 0 {
+		logf(stripped, "%v, trying the alternate URLs", err)
+		repo, extra, alts, err = o.alternateRepo(stripped, alts, extra)
+	}
+	if err != nil {
+		return err
+	}
+	timeline.since("deduce", start)
+
+	path = stripped
+	if remote, err = stripscheme(remote); err != nil {
+		return err
+	}
+
+	debugf(path, "deduced repository %s, path %q", repo.URL(), extra)
+	checkoutRev, checkoutTag := pinnedRevision(path, extra, o.branch, o.tag, o.revision)
+	if o.tagPrefix != "" {
+		if checkoutTag, err = o.prefixedTag(path, repo, o.tag); err != nil {
+			return err
+		}
+	}
+	checkoutBranch := o.trackedBranch(path, o.branch, checkoutTag, checkoutRev)
+	debugf(path, "checking out branch %q, tag %q, revision %q", checkoutBranch, checkoutTag, checkoutRev)
+	start = time.Now()
+	var wc vendor.WorkingCopy
+	wc, repo, extra, err = o.checkoutAlternates(stripped, repo, extra, alts, checkoutBranch, checkoutTag, checkoutRev)
+	if err != nil {
+		return err
+	}
+	defer wc.Destroy()
+	timeline.checkout(wc, start)
+
+	if err := o.checkStrictRevision(path, wc); err != nil {
+		return err
+	}
+	if err := o.checkoutDate(path, wc); err != nil {
+		return err
+	}
+	subs, err := submodules(path, wc, o.fetchSubmodules)
+	if err != nil {
+		return err
+	}
+
+	rev, err := wc.Revision()
+	if err == vendor.ErrEmptyRepo {
+		return o.fetchEmptyRepo(path, importpath, repo, checkoutBranch, extra)
+	}
+	if err != nil {
+		return err
+	}
+
+	wcBranch, err := wc.Branch()
+	if err != nil {
+		return err
+	}
+	debugf(path, "checked out revision %s on branch %s into %s", rev, wcBranch, wc.Dir())
+
+	var replaced *replacement
+	if old != nil {
+		if replaced, err = o.replaceVendored(*old, wc); err != nil {
+			return err
+		}
+		defer func() {
+			// the new revision was not recorded, put the old one back.
+			if replaced != nil {
+				if err := replaced.restore(); err != nil {
+					errorf("could not restore %s: %v", old.Importpath, err)
+				}
+			}
+		}()
+	}
+
+	dep := vendor.Dependency{
+		Importpath: importpath,
+		Repository: repo.URL(),
+		Revision:   rev,
+		Branch:     wcBranch,
+		Tag:        checkoutTag,
+		Path:       extra,
+	}
+	if importpath != path {
+		dep.Origin = path
+	}
+	dep.Submodules = subs
+	if remote != path {
+		logf(path, "fetching %s in place of %s", remote, path)
+		dep.Replacement = remote
+	}
+	if o.recordDefaultBranch {
+		if dep.DefaultBranch, err = defaultBranch(path, repo); err != nil {
+			return err
+		}
+	}
+
+	dst := filepath.Join(vendorDir(o.global), dep.Importpath)
+	if o.quarantine {
+		dst = filepath.Join(vendor.QuarantineDir(vendorDir(o.global)), dep.Importpath)
+	}
+	src := filepath.Join(wc.Dir(), dep.Path)
+
+	o.copyPolicy().apply(&dep)
+	if err := o.recordCheckout(path, &dep, wc, src); err != nil {
+		return err
+	}
+
+	if o.trimToPackages != "" {
+		dep.Packages, err = vendor.PackageClosure(src, path, strings.Split(o.trimToPackages, ","))
+		if err != nil {
+			return err
+		}
+		debugf(path, "trimming to packages %s", strings.Join(dep.Packages, ","))
+	}
+	if o.packageWhitelist != "" {
+		var added []string
+		dep.Packages, added, err = vendor.WhitelistPackages(src, path, strings.Split(o.packageWhitelist, ","))
+		if err != nil {
+			return err
+		}
+		for _, p := range added {
+			logf(path, "also vendoring %s, imported by the whitelisted packages", p)
+		}
+		debugf(path, "vendoring only the packages %s", strings.Join(dep.Packages, ","))
+	}
+
+	debugf(path, "copying %s to %s", src, dst)
+	if _, err := os.Stat(dst); os.IsNotExist(err) && replaced == nil {
+		manifestMu.Lock()
+		created = append(created, dst)
+		manifestMu.Unlock()
+	}
+	start = time.Now()
+	if err := copyDependency(dst, src, dep); err != nil {
+		return err
+	}
+	timeline.since("copy", start)
+	debugCopied(path, dst, m.Nested(dep))
+	start = time.Now()
+	if dep.Checksum, err = vendor.TreeChecksum(dst, m.Nested(dep)); err != nil {
+		return err
+	}
+	timeline.since("checksum", start)
+	if err := o.checkVendored(path, dst, src, wc.Dir(), &dep, m.Nested(dep)); err != nil {
+		return err
+	}
+
+	timeline.done()
+	if o.quarantine {
+		if err := vendor.AddPending(vendorDir(o.global), dep); err != nil {
+			return err
+		}
+		logf(path, "quarantined %s in %s, see gvt help promote", path, dst)
+		return nil
+	}
+
+	if err := recordDependency(dep); err != nil {
+		return err
+	}
+	if replaced != nil {
+		err := replaced.done()
+		replaced = nil
+		if err != nil {
+			return err
+		}
+	}
+	if err := recordRequests(dep, dst, parentsFor(path)); err != nil {
+		return err
+	}
+	if err := o.adoptPins(path, src, wc.Dir()); err != nil {
+		return err
+	}
+
+	if !recurse {
+		return nil
+	}
+
+	// if we are recursing, overwrite branch, tag and revision
+	// values so recursive fetching checks out from HEAD.
+	o = o.recursive()
+
+	if o.lazyRecursion {
+		return o.fetchDirect(importpath)
+	}
+
+	skipped := vendor.Failures{}   // left unresolved by -on-missing-vcs
+	loops := make(map[string]bool) // import loops already reported
+ForLoop:
+	for done := false; !done; {
+
+		m, dsm, err := loadVendored(o.global)
+		if err != nil {
+			return err
+		}
+
+		is, ok := dsm[filepath.Join(vendorDir(o.global), importpath)]
+		if !ok {
+			return fmt.Errorf("unable to locate depset for %q", path)
+		}
+
+		missing, err := findMissing(pkgs(is.Pkgs), dsm)
+		if err != nil {
+			if o.failFast {
+				return err
+			}
+			if !loops[err.Error()] {
+				infof("warning: %v, skipping it", err)
+				loops[err.Error()] = true
+			}
+		}
+
+		// sort keys in ascending order, so the shortest missing import path
+		// with be fetched first.
+		keys := keys(missing)
+		sort.Strings(keys)
+		keys = skipped.Skip(failures.Skip(keys))
+		switch len(keys) {
+		case 0:
+			done = true
+		default:
+			batch := keys[:1]
+			if o.fetchJobs > 1 {
+				batch = o.independentKeys(keys, o.fetchJobs)
+			}
+			for _, pkg := range batch {
+				logf(pkg, "fetching recursive dependency %s", pkg)
+				setParents(pkg, parentsOf(m, dsm, pkg))
+			}
+			already := 0
+			for i, err := range o.fetchAll(batch) {
+				if err == nil {
+					continue
+				}
+				if err == AlreadyErr {
+					already++
+					continue
+				}
+				if err == vendor.ErrSkippedVCS {
+					skipped[batch[i]] = err
+					continue
+				}
+				if o.failFast {
+					return err
+				}
+				logf(batch[i], "%s: %v, skipping it", batch[i], err)
+				failures[batch[i]] = err
+			}
+			if already == len(batch) {
+				break ForLoop
+			}
+		}
+	}
+
+	if o.dedupeTransitive {
+		return collapseNested(o.global)
+	}
+	return nil
+}
+
+// recursive returns the options the dependencies of a fetched one are
+// fetched with: from HEAD, whole, and from their own repository.
+func (o *fetchOptions) recursive() *fetchOptions {
+	r := *o
+	r.branch = ""
+	r.tag = ""
+	r.tagPrefix = ""
+	r.fetchDate = ""
+	r.revision = ""
+	r.trimToPackages = ""
+	r.packageWhitelist = ""
+	r.fromLocal = ""
+	r.alternateURLs.dropUnprefixed()
+	return &r
+}
+
+// recordCheckout records in dep what the flags ask about wc, the checkout
+// of path, and src, the tree vendored from it, stripping the binary files
+// if the copy policy of dep says so, and refuses it if
+// -min-go-version-guard does.
+func (o *fetchOptions) recordCheckout(path string, dep *vendor.Dependency, wc vendor.WorkingCopy, src string) (err error) {
+	if o.recordParent {
+		dep.Parents = parentsFor(path)
+	}
+	if o.recordCommitDate {
+		if dep.CommitDate, err = commitDate(wc); err != nil {
+			return err
+		}
+	}
+	if o.recordGoVersion {
+		if dep.GoVersion, err = goModVersion(src, wc.Dir()); err != nil {
+			return err
+		}
+	}
+	if o.minGoVersionGuard {
+		if err := o.checkGoVersion(path, src, wc.Dir()); err != nil {
+			return err
+		}
+	}
+	if o.verifyAgainstProxy {
+		compareWithProxy(path, src, wc.Dir(), dep.Revision)
+	}
+	if o.recordLicense {
+		if dep.License, err = vendor.DetectLicense(wc.Dir(), src); err != nil {
+			return err
+		}
+		if dep.License == nil {
+			logf(path, "no license file found for %s", path)
+		}
+	}
+	if dep.StripBinaries {
+		if dep.Stripped, err = vendor.BinaryFiles(src, dep.StripBinariesSize); err != nil {
+			return err
+		}
+		for _, f := range dep.Stripped {
+			logf(path, "stripping binary file %s/%s", path, f)
+		}
+	}
+	return nil
+}
+
+// checkVendored records in dep what the flags ask about dst, the tree of
+// path copied from src in root, nested the trees vendored inside it. If
+// -abort-on-missing-license or -verify-import-path-match -strict refuses
+// it, dst is removed.
+func (o *fetchOptions) checkVendored(path, dst, src, root string, dep *vendor.Dependency, nested []string) (err error) {
+	if o.recordFileCount {
+		if dep.FileCount, dep.Bytes, err = vendor.TreeStats(dst, nested); err != nil {
+			return err
+		}
+	}
+
+	if o.abortOnMissingLicense && !o.licenseAllowed(path) {
+		found, err := hasLicense(dst, root)
+		if err != nil {
+			return err
+		}
+		if !found {
+			if err := fileutils.RemoveAll(dst); err != nil {
+				return err
+			}
+			return fmt.Errorf("%s has no license file, refusing to vendor it", path)
+		}
+	}
+
+	if o.verifyImportPathMatch {
+		mismatches, err := vendor.ImportMismatches(dst, dep.Importpath)
+		if err != nil {
+			return err
+		}
+		for _, m := range mismatches {
+			logf(path, "warning: %s is vendored as %s but its import comment declares %s", path, m.Importpath, m.Canonical)
+		}
+		if o.strict && len(mismatches) > 0 {
+			if err := fileutils.RemoveAll(dst); err != nil {
+				return err
+			}
+			return fmt.Errorf("%s: import comment of %s does not match its import path", path, mismatches[0].Importpath)
+		}
+	}
+
+	if o.recordReadmeExcerpt {
+		if dep.Description, err = readmeExcerpt(src, root); err != nil {
+			return err
+		}
+	}
+
+	if o.recordBuildConstraints {
+		if dep.BuildConstraints, err = vendor.BuildConstraints(dst); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// pinnedRevision returns the revision and tag to check out path at: those
+// asked for, or, if none is, those pinned by an upstream manifest or go.mod.
+func pinnedRevision(path, extra, branch, tag, revision string) (string, string) {
+	pin, ok := pins.Lookup(path)
+	if !ok || branch != "" || tag != "" || revision != "" {
+		return revision, tag
+	}
+	// the pins of a go.mod carry no repository.
+	switch {
+	case pin.Revision != "" && pin.Repository == "":
+		logf(path, "fetching %s at revision %s, required by an upstream go.mod", path, pin.Revision)
+		return pin.Revision, tag
+	case pin.Revision != "":
+		logf(path, "fetching %s at revision %s, pinned by an upstream manifest", path, pin.Revision)
+		return pin.Revision, tag
+	default:
+		tag = vendor.ModuleTag(pin.Importpath, strings.TrimSuffix(path, extra), pin.Tag)
+		logf(path, "fetching %s at tag %s, required by an upstream go.mod", path, tag)
+		return revision, tag
+	}
+}
+
+// adoptPins adds the pins of the manifest or the go.mod of the dependency
+// path checked out in root, with -prefetch-manifest and
+// -follow-gomod-require.
+func (o *fetchOptions) adoptPins(path, src, root string) error {
+	if o.prefetchManifest {
+		for _, dir := range []string{src, root} {
+			upstream, err := vendor.UpstreamManifest(dir)
+			if err != nil {
+				return fmt.Errorf("could not load the manifest of %s: %v", path, err)
+			}
+			if upstream != nil {
+				debugf(path, "adopting the pins of %d dependencies", len(upstream.Dependencies))
+				pins.Add(upstream)
+				break
+			}
+		}
+	}
+	if o.followGoModRequire {
+		reqs, err := goModRequires(src, root)
+		if err != nil {
+			return fmt.Errorf("could not load the go.mod of %s: %v", path, err)
+		}
+		debugf(path, "adopting the versions of %d required modules", len(reqs))
+		pins.AddRequires(reqs)
+	}
+	return nil
+}
+
+// checkoutAlternates checks out branch, tag or revision of repo, retrying
+// network failures, then from the alternates of alts until one succeeds.
+// It returns the repository checked out and the path of extra inside it.
+func (o *fetchOptions) checkoutAlternates(path string, repo vendor.RemoteRepo, extra string, alts []alternateURL, branch, tag, revision string) (wc vendor.WorkingCopy, _ vendor.RemoteRepo, _ string, err error) {
+	checkout := func() error {
+		return vendor.Retry(o.retries, retryBackoff, func() (err error) {
+			if sc, ok := repo.(vendor.SparseCheckouter); ok && o.splitLargeRepos && extra != "" {
+				debugf(path, "checking out only %s", extra)
+				wc, err = sc.SparseCheckout(branch, tag, revision, strings.TrimPrefix(extra, "/"))
+			} else {
+				wc, err = repo.Checkout(branch, tag, revision)
+			}
+			return err
+		}, func(err error, wait time.Duration) {
+			logf(path, "%s: %v, retrying in %v", repo.URL(), err, wait)
+		})
+	}
+	err = checkout()
+	for err != nil && len(alts) > 0 {
+		logf(path, "%s: %v, trying the alternate URLs", repo.URL(), err)
+		alt, altExtra, rest, aerr := o.alternateRepo(path, alts, extra)
+		if aerr != nil {
+			break
+		}
+		repo, extra, alts = alt, altExtra, rest
+		err = checkout()
+	}
+	return wc, repo, extra, err
+}
+
+// copyPolicy is how a tree is copied into the vendor directory, as the
+// manifest records it for copyDependency, and a fetch plan for -apply.
+type copyPolicy struct {
+	NoTests           bool   `json:"notests,omitempty"`
+	NormalizeEOL      bool   `json:"normalizeeol,omitempty"`
+	ExtractCgo        bool   `json:"extractcgo,omitempty"`
+	Symlinks          string `json:"symlinks,omitempty"`
+	SymlinkDepth      int    `json:"symlinkdepth,omitempty"`
+	SourceDateEpoch   *int64 `json:"sourcedateepoch,omitempty"`
+	NormalizeModes    bool   `json:"chmodnormalize,omitempty"`
+	PreserveUnlisted  bool   `json:"preserveunlisted,omitempty"`
+	StripBinaries     bool   `json:"stripbinaries,omitempty"`
+	StripBinariesSize int64  `json:"stripbinariessize,omitempty"`
+}
+
+// copyPolicy returns the copy policy of the flags.
+func (o *fetchOptions) copyPolicy() copyPolicy {
+	p := copyPolicy{
+		NoTests:          o.noTests,
+		NormalizeEOL:     o.normalizeLineEndings,
+		ExtractCgo:       o.extractCgoDeps,
+		SourceDateEpoch:  o.epoch,
+		NormalizeModes:   o.chmodNormalize,
+		PreserveUnlisted: o.preserveExistingUnlisted,
+	}
+	if o.symlinkPolicy == vendor.SymlinksDeref {
+		p.Symlinks, p.SymlinkDepth = o.symlinkPolicy, o.symlinkDepth
+	}
+	if o.stripBinaries {
+		p.StripBinaries, p.StripBinariesSize = true, o.stripBinariesSize
+	}
+	return p
+}
+
+// apply records p in dep.
+func (p copyPolicy) apply(dep *vendor.Dependency) {
+	dep.NoTests = p.NoTests
+	dep.NormalizeEOL = p.NormalizeEOL
+	dep.ExtractCgo = p.ExtractCgo
+	dep.Symlinks, dep.SymlinkDepth = p.Symlinks, p.SymlinkDepth
+	dep.SourceDateEpoch = p.SourceDateEpoch
+	dep.NormalizeModes = p.NormalizeModes
+	dep.PreserveUnlisted = p.PreserveUnlisted
+	dep.StripBinaries, dep.StripBinariesSize = p.StripBinaries, p.StripBinariesSize
+}
+
+// skipMissingVCS records path, which -on-missing-vcs skipped, as unresolved
+// in the manifest entries of the dependencies importing it.
+func skipMissingVCS(path string) error {
+	ps := parentsFor(path)
+	if len(ps) == 0 {
+		logf(path, "skipping %s, its VCS is not installed", path)
+		return vendor.ErrSkippedVCS
+	}
+	manifestMu.Lock()
+	defer manifestMu.Unlock()
+	m, err := vendor.ReadManifest(manifestFile())
+	if err != nil {
+		return err
+	}
+	importers := make(map[string]bool)
+	for _, p := range ps {
+		importers[p] = true
+	}
+next:
+	for i, d := range m.Dependencies {
+		if !importers[d.Importpath] {
+			continue
+		}
+		for _, u := range d.Unresolved {
+			if u == path {
+				continue next
+			}
+		}
+		m.Dependencies[i].Unresolved = append(d.Unresolved, path)
+	}
+	logf(path, "skipping %s, its VCS is not installed, recorded as unresolved", path)
+	if err := writeManifest(m); err != nil {
+		return err
+	}
+	return vendor.ErrSkippedVCS
+}
+
+// recordImports records the DirectImports of every dependency in the
+// manifest, which the dependencies just fetched change.
+func recordImports(global bool) error {
+	m, dsm, err := loadVendored(global)
+	if err != nil {
+		return err
+	}
+	for i, d := range m.Dependencies {
+		m.Dependencies[i].Imports = vendor.DirectImports(dsm, d.Source())
+	}
+	return writeManifest(m)
+}
+
+// compareWithProxy warns about the files of src, the directory of path in
+// the working copy at dir checked out at rev, differing from the module
+// zip the GOPROXY serves for the same revision. Paths which are not in a
+// module or not on the proxy are not verified.
+func compareWithProxy(path, src, dir, rev string) {
+	proxy, ok := vendor.ProxyURL()
+	if !ok {
+		logf(path, "warning: GOPROXY lists no proxy, %s not verified against it", path)
+		return
+	}
+	// the module is the closest directory with a go.mod.
+	root := src
+	for {
+		if _, err := os.Stat(filepath.Join(root, "go.mod")); err == nil || root == dir || len(root) < len(dir) {
+			break
+		}
+		root = filepath.Dir(root)
+	}
+	sub, err := filepath.Rel(root, src)
+	if err != nil {
+		logf(path, "warning: %s not verified against %s: %v", path, proxy, err)
+		return
+	}
+	version, changes, err := vendor.CompareWithProxy(proxy, root, filepath.ToSlash(sub), rev)
+	switch err {
+	case nil:
+	case vendor.ErrNotModule:
+		logf(path, "%s is not in a module, not verified against %s", path, proxy)
+		return
+	case vendor.ErrNotOnProxy:
+		logf(path, "%s at %s is not on %s, not verified against it", path, rev, proxy)
+		return
+	default:
+		logf(path, "warning: %s not verified against %s: %v", path, proxy, err)
+		return
+	}
+	if len(changes) == 0 {
+		logf(path, "%s matches %s on %s", path, version, proxy)
+		return
+	}
+	logf(path, "warning: %s differs from %s on %s:", path, version, proxy)
+	for _, c := range changes {
+		logf(path, "	%s %s", c.Change, c.Path)
+	}
+}
+
+// prefixedTag returns the tag of repo fetched with -tag-prefix: tag under
+// the prefix if given, otherwise the latest one, see vendor.LatestTag.
+func (o *fetchOptions) prefixedTag(path string, repo vendor.RemoteRepo, tag string) (string, error) {
+	if tag != "" {
+		return o.tagPrefix + strings.TrimPrefix(tag, o.tagPrefix), nil
+	}
+	if o.revision != "" {
+		return "", fmt.Errorf("-tag-prefix cannot be used with -revision")
+	}
+	tl, ok := repo.(vendor.TagLister)
+	if !ok {
+		return "", fmt.Errorf("-tag-prefix: cannot list the tags of %s", repo.URL())
+	}
+	tags, err := tl.Tags()
+	if err != nil {
+		return "", fmt.Errorf("could not list the tags of %s: %v", repo.URL(), err)
+	}
+	latest, ok := vendor.LatestTag(tags, o.tagPrefix)
+	if !ok {
+		return "", fmt.Errorf("no tag of %s matches %sv<major>.<minor>.<patch>", repo.URL(), o.tagPrefix)
+	}
+	logf(path, "fetching %s at tag %s", path, latest)
+	return latest, nil
+}
+
+// defaultBranch returns the default branch of repo, the repository of
+// path, or "" with a warning if it cannot tell it.
+func defaultBranch(path string, repo vendor.RemoteRepo) (string, error) {
+	db, ok := repo.(vendor.DefaultBrancher)
+	if !ok {
+		logf(path, "warning: cannot tell the default branch of %s", repo.URL())
+		return "", nil
+	}
+	b, err := db.DefaultBranch()
+	if err != nil {
+		return "", fmt.Errorf("could not tell the default branch of %s: %v", repo.URL(), err)
+	}
+	debugf(path, "default branch of %s is %s", repo.URL(), b)
+	return b, nil
+}
+
+// printVCSTags prints the tags and branches of the repository of path.
+func (o *fetchOptions) printVCSTags(path string) error {
+	stripped, err := stripscheme(path)
+	if err != nil {
+		return err
+	}
+	repo, _, err := vendor.DeduceRemoteRepo(path, o.insecure)
+	if err != nil {
+		return err
+	}
+	tl, ok := repo.(vendor.TagLister)
+	bl, ok2 := repo.(vendor.BranchLister)
+	if !ok || !ok2 {
+		return fmt.Errorf("fetch: cannot list the tags and branches of %s", repo.URL())
+	}
+	tags, err := tl.Tags()
+	if err != nil {
+		return fmt.Errorf("could not list the tags of %s: %v", repo.URL(), err)
+	}
+	branches, err := bl.Branches()
+	if err != nil {
+		return fmt.Errorf("could not list the branches of %s: %v", repo.URL(), err)
+	}
+	vendor.SortTags(tags)
+	sort.Strings(branches)
+
+	if o.vcsTagsJSON {
+		buf, err := json.MarshalIndent(struct {
+			Importpath string   `json:"importpath"`
+			Repository string   `json:"repository"`
+			Tags       []string `json:"tags"`
+			Branches   []string `json:"branches"`
+		}{stripped, repo.URL(), tags, branches}, "", "\t")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(os.Stdout, "%s\n", buf)
+		return err
+	}
+	fmt.Printf("tags of %s:\n", repo.URL())
+	for _, t := range tags {
+		fmt.Printf("\t%s\n", t)
+	}
+	fmt.Printf("branches of %s:\n", repo.URL())
+	for _, b := range branches {
+		fmt.Printf("\t%s\n", b)
+	}
+	return nil
+}
+
+// loadSourceDateEpoch resolves -source-date-epoch into epoch.
+func (o *fetchOptions) loadSourceDateEpoch() error {
+	if o.sourceDateEpoch >= 0 {
+		o.epoch = &o.sourceDateEpoch
+		return nil
+	}
+	e, ok, err := vendor.SourceDateEpoch()
+	if ok {
+		o.epoch = &e
+	}
+	return err
+}
+
+// setParents records ps as the dependencies importing pkg.
+func setParents(pkg string, ps []string) {
+	manifestMu.Lock()
+	defer manifestMu.Unlock()
+	if parents == nil {
+		parents = make(map[string][]string)
+	}
+	parents[pkg] = ps
+}
+
+// parentsFor returns the dependencies importing pkg, nil for the import
+// path given on the command line.
+func parentsFor(pkg string) []string {
+	manifestMu.Lock()
+	defer manifestMu.Unlock()
+	return parents[pkg]
+}
+
+// independentKeys returns up to n of keys, sorted missing import paths,
+// which can be fetched concurrently: none is below another one, which
+// fetching the latter may vendor, and no two are in the same repository,
+// which would be cloned twice.
+func (o *fetchOptions) independentKeys(keys []string, n int) []string {
+	var batch []string
+	roots := make(map[string]bool)
+next:
+	for _, k := range keys {
+		if len(batch) == n {
+			break
+		}
+		for _, b := range batch {
+			if strings.HasPrefix(k, b+"/") {
+				continue next
+			}
+		}
+		root := k
+		if _, extra, err := vendor.DeduceRemoteRepo(k, o.insecure); err == nil {
+			if s, err := stripscheme(k); err == nil {
+				root = strings.TrimSuffix(s, extra)
+			}
+		}
+		if roots[root] {
+			continue
+		}
+		roots[root] = true
+		batch = append(batch, k)
+	}
+	return batch
+}
+
+// fetchAll fetches the recursive dependencies in pkgs concurrently and
+// returns the error of each.
+func (o *fetchOptions) fetchAll(pkgs []string) []error {
+	errs := make([]error, len(pkgs))
+	if len(pkgs) == 1 {
+		errs[0], _ = inflight.Do(pkgs[0], func() error { return o.fetch(pkgs[0], false) })
+		return errs
+	}
+	var wg sync.WaitGroup
+	for i, pkg := range pkgs {
+		wg.Add(1)
+		go func(i int, pkg string) {
+			defer wg.Done()
+			errs[i], _ = inflight.Do(pkg, func() error { return o.fetch(pkg, false) })
+		}(i, pkg)
+	}
+	wg.Wait()
+	return errs
+}
+
+// commitDate returns the committer date of the revision of wc, or "" if
+// its VCS does not tell.
+func commitDate(wc vendor.WorkingCopy) (string, error) {
+	cd, ok := wc.(vendor.CommitDater)
+	if !ok {
+		return "", nil
+	}
+	date, err := cd.CommitDate()
+	if err != nil {
+		return "", fmt.Errorf("could not determine the commit date: %v", err)
+	}
+	return date.UTC().Format(time.RFC3339), nil
+}
+
+// recordDependency adds dep to the manifest on disk, which may have been
+// updated by a concurrent fetch since it was read.
+func recordDependency(dep vendor.Dependency) error {
+	manifestMu.Lock()
+	defer manifestMu.Unlock()
+	m, err := vendor.ReadManifest(manifestFile())
+	if err != nil {
+		return err
+	}
+	if err := m.AddDependency(dep); err != nil {
+		return err
+	}
+	if err := writeManifest(m); err != nil {
+		return err
+	}
+	fetched = append(fetched, dep)
+	return nil
+}
+
+// fetchReportEntry is an entry of the -report file.
+type fetchReportEntry struct {
+	Importpath string `json:"importpath"`
+	Repository string `json:"repository"`
+	Revision   string `json:"revision"`
+	Branch     string `json:"branch"`
+}
+
+// writeFetchReport writes to file the dependencies recorded by this run,
+// in the order they were, as a JSON array.
+func writeFetchReport(file string) error {
+	manifestMu.Lock()
+	defer manifestMu.Unlock()
+	entries := make([]fetchReportEntry, 0, len(fetched))
+	for _, d := range fetched {
+		entries = append(entries, fetchReportEntry{d.Importpath, d.Repository, d.Revision, d.Branch})
+	}
+	buf, err := json.MarshalIndent(entries, "", "\t")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(file, append(buf, '\n'), 0644)
+}
+
+// fetchTimeline is the -emit-metrics timeline of the fetch of a
+// dependency.
+type fetchTimeline struct {
+	Importpath string       `json:"importpath"`
+	Phases     []fetchPhase `json:"phases"`
+}
+
+// fetchPhase is a timed phase of a fetch.
+type fetchPhase struct {
+	Name    string    `json:"name"`
+	Start   time.Time `json:"start"`
+	Seconds float64   `json:"seconds"`
+}
+
+// newTimeline returns the timeline of the fetch of importpath, nil
+// without -emit-metrics, which the methods of fetchTimeline ignore.
+func (o *fetchOptions) newTimeline(importpath string) *fetchTimeline {
+	if o.emitMetricsFile == "" {
+		return nil
+	}
+	return &fetchTimeline{Importpath: importpath, Phases: []fetchPhase{}}
+}
+
+// since records the phase name, from start to now.
+func (t *fetchTimeline) since(name string, start time.Time) {
+	if t != nil {
+		t.add(name, start, time.Since(start))
+	}
+}
+
+func (t *fetchTimeline) add(name string, start time.Time, d time.Duration) {
+	t.Phases = append(t.Phases, fetchPhase{name, start.UTC(), d.Seconds()})
+}
+
+// checkout records the checkout of wc, from start to now, as the phases
+// wc timed if it did.
+func (t *fetchTimeline) checkout(wc vendor.WorkingCopy, start time.Time) {
+	if t == nil {
+		return
+	}
+	if pt, ok := wc.(vendor.PhaseTimer); ok && len(pt.Phases()) > 0 {
+		for _, p := range pt.Phases() {
+			t.add(p.Name, p.Start, p.Duration)
+		}
+		return
+	}
+	t.since("checkout", start)
+}
+
+// done adds t to the timelines of this run.
+func (t *fetchTimeline) done() {
+	if t == nil {
+		return
+	}
+	manifestMu.Lock()
+	defer manifestMu.Unlock()
+	timelines = append(timelines, *t)
+}
+
+// writeMetrics writes to file the timelines of the fetches of this run,
+// in the order they completed, as a JSON array.
+func writeMetrics(file string) error {
+	manifestMu.Lock()
+	defer manifestMu.Unlock()
+	list := timelines
+	if list == nil {
+		list = []fetchTimeline{}
+	}
+	buf, err := json.MarshalIndent(list, "", "\t")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(file, append(buf, '\n'), 0644)
+}
+
+// fetchEmptyRepo records, with -allow-empty-repo, a placeholder entry
+// without revision nor files for the repository of path, which has no
+// commit yet.
+func (o *fetchOptions) fetchEmptyRepo(path, importpath string, repo vendor.RemoteRepo, branch, extra string) error {
+	if !o.allowEmptyRepo {
+		return fmt.Errorf("%s: %s has no commit, use -allow-empty-repo to record it anyway", path, repo.URL())
+	}
+	logf(importpath, "warning: %s has no commit, recording %s without revision nor files", repo.URL(), importpath)
+	return recordDependency(vendor.Dependency{
+		Importpath: importpath,
+		Repository: repo.URL(),
+		Branch:     branch,
+		Path:       extra,
+	})
+}
+
+// checkStrictRevision fails, with -strict-revision, if wc is not at the
+// revision requested for path.
+func (o *fetchOptions) checkStrictRevision(path string, wc vendor.WorkingCopy) error {
+	if !o.strictRevision || o.revision == "" {
+		return nil
+	}
+	if err := vendor.CheckRevision(wc, o.revision); err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+	return nil
+}
+
+// fetchDateLayout is the layout of -date.
+const fetchDateLayout = "2006-01-02"
+
+// checkoutDate moves wc, checked out for path, back to the revision its
+// branch was at on -date.
+func (o *fetchOptions) checkoutDate(path string, wc vendor.WorkingCopy) error {
+	if o.fetchDate == "" {
+		return nil
+	}
+	day, err := time.Parse(fetchDateLayout, o.fetchDate)
+	if err != nil {
+		return err
+	}
+	if err := vendor.CheckoutDate(wc, day); err != nil {
+		return fmt.Errorf("%s: -date %s: %v", path, o.fetchDate, err)
+	}
+	return nil
+}
+
+// checkDowngrade fails if wc holds a revision older than old, the one
+// recorded for importpath, only warning with -force.
+func (o *fetchOptions) checkDowngrade(importpath string, wc vendor.WorkingCopy, old string) error {
+	err := vendor.CheckDowngrade(importpath, wc, old)
+	if _, ok := err.(*vendor.DowngradeError); ok {
+		if !o.force {
+			return fmt.Errorf("%v, use -force to downgrade anyway", err)
+		}
+		logf(importpath, "warning: %v, downgrading anyway", err)
+		return nil
+	}
+	return err
+}
+
+// replacement is a dependency re-fetched with -refuse-downgrade, whose
+// vendored tree is kept in a staging directory until its new revision is
+// recorded.
+type replacement struct {
+	old   vendor.Dependency
+	dst   string // vendored tree of old
+	stage string // staging directory holding the tree of old
+}
+
+// replaceVendored moves old out of the vendor directory, into a staging
+// directory, and the manifest, unless wc holds an older revision. Once the
+// new revision is copied and recorded, done removes the staged tree;
+// until then restore puts old back.
+func (o *fetchOptions) replaceVendored(old vendor.Dependency, wc vendor.WorkingCopy) (*replacement, error) {
+	if err := o.checkDowngrade(old.Importpath, wc, old.Revision); err != nil {
+		return nil, err
+	}
+
+	manifestMu.Lock()
+	defer manifestMu.Unlock()
+	m, err := vendor.ReadManifest(manifestFile())
+	if err != nil {
+		return nil, err
+	}
+	if err := m.RemoveDependency(old); err != nil {
+		return nil, err
+	}
+	vdir := vendorDir(o.global)
+	if err := os.MkdirAll(vdir, 0755); err != nil {
+		return nil, err
+	}
+	stage, err := ioutil.TempDir(vdir, ".gvt-replace-")
+	if err != nil {
+		return nil, err
+	}
+	r := &replacement{old, filepath.Join(vdir, filepath.FromSlash(old.Importpath)), stage}
+	if old.PreserveUnlisted {
+		// the new revision is copied over the old tree, keep a copy.
+		err = vendor.Copytree(r.saved(), r.dst, nil)
+	} else {
+		err = os.Rename(r.dst, r.saved())
+	}
+	if err != nil && !os.IsNotExist(err) {
+		fileutils.RemoveAll(stage)
+		return nil, err
+	}
+	if err := writeManifest(m); err != nil {
+		r.restoreTree()
+		return nil, err
+	}
+	return r, nil
+}
+
+// saved returns where the tree of r.old is staged.
+func (r *replacement) saved() string { return filepath.Join(r.stage, "tree") }
 
-func fetch(path string, recurse, global bool) error {
-	m, err := vendor.ReadManifest(manifestFile())
-	if err != nil {
-		return fmt.Errorf("could not load manifest: %v", err)
+// restoreTree moves the staged tree back in place of whatever was copied.
+func (r *replacement) restoreTree() error {
+	defer fileutils.RemoveAll(r.stage)
+	if _, err := os.Stat(r.saved()); os.IsNotExist(err) {
+		return nil
 	}
+	if err := fileutils.RemoveAll(r.dst); err != nil {
+		return err
+	}
+	return os.Rename(r.saved(), r.dst)
+}
 
-	repo, extra, err := vendor.DeduceRemoteRepo(path, insecure)
+// restore puts the tree and the manifest entry of r.old back.
+func (r *replacement) restore() error {
+	if err := r.restoreTree(); err != nil {
+		return err
+	}
+	manifestMu.Lock()
+	defer manifestMu.Unlock()
+	m, err := vendor.ReadManifest(manifestFile())
 	if err != nil {
 		return err
 	}
+	if m.HasImportpath(r.old.Importpath) {
+		return nil
+	}
+	if err := m.AddDependency(r.old); err != nil {
+		return err
+	}
+	return writeManifest(m)
+}
 
-	// strip of any scheme portion from the path, it is already
-	// encoded in the repo.
-	path = stripscheme(path)
+// done removes the staged tree of r.old, replaced for good.
+func (r *replacement) done() error { return fileutils.RemoveAll(r.stage) }
+
+// loadRequests starts collecting the revisions asked for by this fetch
+// with those of the manifest.
+func loadRequests() error {
+	m, err := vendor.ReadManifest(manifestFile())
+	if err != nil {
+		return fmt.Errorf("could not load manifest: %v", err)
+	}
+	requests = vendor.RevisionRequests{}
+	requests.AddManifest("the manifest", m)
+	return nil
+}
 
-	if m.HasImportpath(path) {
-		log.Printf("%s is already vendored", path)
-		return AlreadyErr
+// recordRequests records the revision dep, imported by parents, was
+// fetched at, and those pinned by the vendor/manifest of its tree at dst.
+func recordRequests(dep vendor.Dependency, dst string, parents []string) error {
+	if requests == nil {
+		return nil
+	}
+	var nested *vendor.Manifest
+	if _, err := os.Stat(filepath.Join(dst, "vendor", "manifest")); err == nil {
+		if nested, err = vendor.ReadVendoredManifest(filepath.Join(dst, "vendor", "manifest")); err != nil {
+			return fmt.Errorf("could not load manifest of %s: %v", dep.Importpath, err)
+		}
+	}
+	manifestMu.Lock()
+	defer manifestMu.Unlock()
+	by := "the command line"
+	if len(parents) > 0 {
+		by = strings.Join(parents, ", ")
 	}
+	requests.Add(dep.Importpath, by, dep.Revision)
+	if nested != nil {
+		requests.AddManifest(dep.Importpath, nested)
+	}
+	return nil
+}
 
-	wc, err := repo.Checkout(branch, tag, revision)
+// reportConflicts logs the dependencies asked for at different
+// revisions, failing with -error-on-conflict.
+func (o *fetchOptions) reportConflicts() error {
+	conflicts := requests.Conflicts()
+	for _, c := range conflicts {
+		logf(c.Importpath, "conflict: %s asked for at different revisions, keeping the first one:", c.Importpath)
+		for _, r := range c.Requests {
+			logf(c.Importpath, "	%s by %s", r.Revision, r.By)
+		}
+	}
+	if (o.errorOnConflict || !o.keepFirstWins) && len(conflicts) > 0 {
+		return fmt.Errorf("%d dependencies asked for at different revisions", len(conflicts))
+	}
+	return nil
+}
 
+// reportDuplicateTrees logs the identical trees vendored from different
+// repositories, failing with -error-on-duplicates. The trees fetched
+// before checksums were recorded are hashed.
+func (o *fetchOptions) reportDuplicateTrees() error {
+	m, err := vendor.ReadManifest(manifestFile())
 	if err != nil {
 		return err
 	}
+	deps := make([]vendor.Dependency, len(m.Dependencies))
+	copy(deps, m.Dependencies)
+	for i, d := range deps {
+		if d.Checksum != "" {
+			continue
+		}
+		deps[i].Checksum, err = vendor.TreeChecksum(filepath.Join(vendorDir(o.global), filepath.FromSlash(d.Importpath)), m.Nested(d))
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	dups := vendor.Duplicates(deps)
+	for _, dup := range dups {
+		infof("duplicate: identical trees vendored from different repositories:")
+		for _, d := range dup.Dependencies {
+			infof("	%s from %s", d.Importpath, d.Repository)
+		}
+	}
+	if o.errorOnDuplicates && len(dups) > 0 {
+		return fmt.Errorf("%d trees vendored from different repositories", len(dups))
+	}
+	return nil
+}
 
-	rev, err := wc.Revision()
-	if err != nil {
+// fetchWithRollback is fetch leaving the manifest and the vendor directory
+// as they were if it fails.
+func (o *fetchOptions) fetchWithRollback(path string, recurse bool) error {
+	snapshot, err := ioutil.ReadFile(manifestFile())
+	if err != nil && !os.IsNotExist(err) {
 		return err
 	}
+	created = nil
+	recorded := len(fetched)
+	ferr := o.fetch(path, recurse)
+	if ferr == nil || ferr == AlreadyErr || ferr == vendor.ErrSkippedVCS {
+		return ferr
+	}
 
-	branch, err := wc.Branch()
+	infof("fetch failed, rolling back")
+	fetched = fetched[:recorded]
+	for i := len(created) - 1; i >= 0; i-- {
+		if err := fileutils.RemoveAll(created[i]); err != nil {
+			return fmt.Errorf("%v, rollback failed: %v", ferr, err)
+		}
+		if err := vendor.CleanPath(filepath.Dir(created[i]), vendorDir(o.global)); err != nil {
+			return fmt.Errorf("%v, rollback failed: %v", ferr, err)
+		}
+	}
+	if snapshot == nil {
+		err = os.Remove(manifestFile())
+		if os.IsNotExist(err) {
+			err = nil
+		}
+	} else {
+		err = ioutil.WriteFile(manifestFile(), snapshot, 0644)
+	}
 	if err != nil {
-		return err
+		return fmt.Errorf("%v, rollback failed: %v", ferr, err)
 	}
+	return ferr
+}
 
-	dep := vendor.Dependency{
-		Importpath: path,
-		Repository: repo.URL(),
-		Revision:   rev,
-		Branch:     branch,
-		Path:       extra,
+// replaceFlag is a repeatable old=new flag collecting replace rules.
+type replaceFlag vendor.Replacements
+
+func (r replaceFlag) String() string {
+	var s []string
+	for old, new := range r {
+		s = append(s, old+"="+new)
 	}
+	sort.Strings(s)
+	return strings.Join(s, ",")
+}
 
-	if err := m.AddDependency(dep); err != nil {
-		return err
+func (r replaceFlag) Set(v string) error {
+	i := strings.Index(v, "=")
+	if i < 1 || i == len(v)-1 {
+		return fmt.Errorf("expected old=new, got %q", v)
 	}
+	r[strings.TrimSuffix(v[:i], "/")] = strings.TrimSuffix(v[i+1:], "/")
+	return nil
+}
 
-	dst := filepath.Join(vendorDir(global), dep.Importpath)
-	src := filepath.Join(wc.Dir(), dep.Path)
+// alternateFlag lists the repository URLs of -retry-alternate-url.
+type alternateFlag []alternateURL
 
-	if err := fileutils.Copypath(dst, src); err != nil {
-		return err
-	}
+// alternateURL is the URL of a mirror of the repository of the import
+// paths at or below Prefix, or of the fetched import path without Prefix.
+type alternateURL struct {
+	Prefix, URL string
+}
 
-	if err := vendor.WriteManifest(manifestFile(), m); err != nil {
-		return err
+func (a *alternateFlag) String() string {
+	var s []string
+	for _, u := range *a {
+		if u.Prefix != "" {
+			s = append(s, u.Prefix+"="+u.URL)
+		} else {
+			s = append(s, u.URL)
+		}
 	}
+	return strings.Join(s, ",")
+}
 
-	if err := wc.Destroy(); err != nil {
-		return err
+func (a *alternateFlag) Set(v string) error {
+	u := alternateURL{URL: v}
+	// the prefix is an import path, without the colon of a scheme.
+	if i := strings.Index(v, "="); i > 0 && !strings.Contains(v[:i], ":") {
+		u = alternateURL{strings.TrimSuffix(v[:i], "/"), v[i+1:]}
+	}
+	if u.URL == "" {
+		return fmt.Errorf("expected [prefix=]url, got %q", v)
 	}
+	*a = append(*a, u)
+	return nil
+}
 
-	if !recurse {
-		return nil
+// lookup returns, in the order given, the alternates applying to path.
+func (a alternateFlag) lookup(path string) []alternateURL {
+	var alts []alternateURL
+	for _, u := range a {
+		if u.Prefix == "" || path == u.Prefix || strings.HasPrefix(path, u.Prefix+"/") {
+			alts = append(alts, u)
+		}
 	}
+	return alts
+}
 
-	// if we are recursing, overwrite branch, tag and revision
-	// values so recursive fetching checks out from HEAD.
-	branch = ""
-	tag = ""
-	revision = ""
+// dropUnprefixed removes the alternates without prefix, which only apply
+// to the import path given on the command line.
+func (a *alternateFlag) dropUnprefixed() {
+	var kept alternateFlag
+	for _, u := range *a {
+		if u.Prefix != "" {
+			kept = append(kept, u)
+		}
+	}
+	*a = kept
+}
 
-ForLoop:
-	for done := false; !done; {
+// alternateRepo returns the repository at the first URL of alts that can
+// be reached, the path of path inside it, extra for an alternate without
+// prefix, and the alternates left to try.
+func (o *fetchOptions) alternateRepo(path string, alts []alternateURL, extra string) (vendor.RemoteRepo, string, []alternateURL, error) {
+	var err error
+	for i, u := range alts {
+		var repo vendor.RemoteRepo
+		if repo, err = vendor.RepoAt(u.URL, o.insecure); err != nil {
+			logf(path, "alternate URL %s: %v", u.URL, err)
+			continue
+		}
+		logf(path, "fetching %s from the alternate URL %s", path, repo.URL())
+		if u.Prefix != "" {
+			extra = strings.TrimPrefix(path, u.Prefix)
+		}
+		return repo, extra, alts[i+1:], nil
+	}
+	return nil, "", nil, fmt.Errorf("%s: no alternate URL could be reached: %v", path, err)
+}
 
-		paths := []struct {
-			Root, Prefix string
-		}{
-			{filepath.Join(runtime.GOROOT(), "src"), ""},
+// recordedRepo returns the repository d was fetched from and the path of d
+// inside it. That is the one deduced from its import path, unless the
+// manifest records another repository, such as an alternate URL, which
+// is used instead while it can be reached.
+func recordedRepo(d vendor.Dependency, insecure bool) (vendor.RemoteRepo, string, error) {
+	repo, extra, err := vendor.DeduceRemoteRepo(d.Remote(), insecure, d.Repository)
+	if d.Repository == "" || err == nil && repo.URL() == d.Repository {
+		return repo, extra, err
+	}
+	alt, aerr := vendor.RepoAt(d.Repository, insecure)
+	if aerr != nil {
+		if err != nil {
+			return nil, "", err
 		}
-		m, err := vendor.ReadManifest(manifestFile())
+		logf(d.Importpath, "recorded repository %s: %v, using %s", d.Repository, aerr, repo.URL())
+		return repo, extra, nil
+	}
+	logf(d.Importpath, "fetching %s from the recorded repository %s", d.Importpath, alt.URL())
+	return alt, d.Path, nil
+}
+
+// loadVendored returns the manifest and the depsets of the standard
+// library and of every vendored dependency.
+func loadVendored(global bool) (*vendor.Manifest, map[string]*vendor.Depset, error) {
+	m, err := vendor.ReadManifest(manifestFile())
+	if err != nil {
+		return nil, nil, err
+	}
+	paths := []struct {
+		Root, Prefix string
+	}{
+		{filepath.Join(runtime.GOROOT(), "src"), ""},
+	}
+	for _, d := range m.Dependencies {
+		// load relocated dependencies under their original import
+		// path, which is how their own packages import each other.
+		paths = append(paths, struct{ Root, Prefix string }{filepath.Join(vendorDir(global), filepath.FromSlash(d.Importpath)), filepath.FromSlash(d.Source())})
+	}
+	dsm, err := vendor.LoadPathsContext(buildContext, paths...)
+	return m, dsm, err
+}
+
+// fetchDirect fetches the dependencies imported by the packages vendored
+// at importpath, but not theirs: the imports they leave missing are
+// recorded as unresolved in the manifest instead.
+func (o *fetchOptions) fetchDirect(importpath string) error {
+	before, err := vendor.ReadManifest(manifestFile())
+	if err != nil {
+		return err
+	}
+	skipped := vendor.Failures{} // left unresolved by -on-missing-vcs
+	for {
+		m, dsm, err := loadVendored(o.global)
 		if err != nil {
 			return err
 		}
-		for _, d := range m.Dependencies {
-			paths = append(paths, struct{ Root, Prefix string }{filepath.Join(vendorDir(global), filepath.FromSlash(d.Importpath)), filepath.FromSlash(d.Importpath)})
+		is, ok := dsm[filepath.Join(vendorDir(o.global), importpath)]
+		if !ok {
+			return fmt.Errorf("unable to locate depset for %q", importpath)
+		}
+		missing := skipped.Skip(directMissing(is, dsm))
+		if len(missing) == 0 {
+			break
+		}
+		pkg := missing[0]
+		logf(pkg, "fetching direct dependency %s", pkg)
+		setParents(pkg, parentsOf(m, dsm, pkg))
+		err, _ = inflight.Do(pkg, func() error { return o.fetch(pkg, false) })
+		if err == AlreadyErr {
+			break
+		}
+		if err == vendor.ErrSkippedVCS {
+			skipped[pkg] = err
+			continue
 		}
-
-		dsm, err := vendor.LoadPaths(paths...)
 		if err != nil {
 			return err
 		}
+	}
 
-		is, ok := dsm[filepath.Join(vendorDir(global), path)]
-		if !ok {
-			return fmt.Errorf("unable to locate depset for %q", path)
+	manifestMu.Lock()
+	defer manifestMu.Unlock()
+	m, dsm, err := loadVendored(o.global)
+	if err != nil {
+		return err
+	}
+	for i, d := range m.Dependencies {
+		// only the dependencies fetched above are incomplete.
+		if d.Importpath == importpath || before.HasImportpath(d.Importpath) {
+			continue
 		}
+		if ds, ok := dsm[filepath.Join(vendorDir(o.global), filepath.FromSlash(d.Importpath))]; ok {
+			m.Dependencies[i].Unresolved = directMissing(ds, dsm)
+			for _, u := range m.Dependencies[i].Unresolved {
+				logf(u, "deferring %s, imported by %s", u, d.Importpath)
+			}
+		}
+	}
+	return writeManifest(m)
+}
 
-		missing := findMissing(pkgs(is.Pkgs), dsm)
-		switch len(missing) {
-		case 0:
-			done = true
-		default:
-
-			// sort keys in ascending order, so the shortest missing import path
-			// with be fetched first.
-			keys := keys(missing)
-			sort.Strings(keys)
-			pkg := keys[0]
-			log.Printf("fetching recursive dependency %s", pkg)
-			if err := fetch(pkg, false, global); err != nil {
-				if err == AlreadyErr {
-					break ForLoop
-				}
-				return err
+// directMissing returns the imports of the packages of ds found in none
+// of the depsets of dsm, sorted.
+func directMissing(ds *vendor.Depset, dsm map[string]*vendor.Depset) []string {
+	have := map[string]bool{"C": true}
+	for _, s := range dsm {
+		for ip := range s.Pkgs {
+			have[ip] = true
+		}
+	}
+	var missing []string
+	for _, p := range ds.Pkgs {
+		for _, i := range p.Imports {
+			if !have[i] {
+				have[i] = true
+				missing = append(missing, i)
 			}
 		}
 	}
+	sort.Strings(missing)
+	return missing
+}
 
-	return nil
+// parentsOf returns the import paths of the dependencies of m importing pkg.
+func parentsOf(m *vendor.Manifest, dsm map[string]*vendor.Depset, pkg string) []string {
+	deps := make(map[string]string)
+	for _, d := range m.Dependencies {
+		deps[d.Source()] = d.Importpath
+	}
+	var p []string
+	for _, prefix := range vendor.Importers(dsm, pkg) {
+		if ip, ok := deps[prefix]; ok {
+			p = append(p, ip)
+		}
+	}
+	return p
+}
+
+// collapseNested removes the manifest entries made redundant by another
+// entry vendoring the same tree, and merges those of sibling directories
+// of a same repository and revision.
+func collapseNested(global bool) error {
+	m, err := vendor.ReadManifest(manifestFile())
+	if err != nil {
+		return err
+	}
+	removed, err := m.CollapseNested(vendorDir(global))
+	if err != nil {
+		return err
+	}
+	if len(removed) == 0 {
+		return nil
+	}
+	for _, d := range removed {
+		logf(d.Importpath, "collapsing %s into its parent", d.Importpath)
+	}
+	return writeManifest(m)
+}
+
+// goModDir returns the directory of the go.mod closest to src, the
+// directory fetched, within root, the working copy, or "" if there is none.
+func goModDir(src, root string) string {
+	for dir := src; ; dir = filepath.Dir(dir) {
+		if _, err := os.Stat(filepath.Join(dir, "go.mod")); err == nil {
+			return dir
+		}
+		if rel, err := filepath.Rel(root, dir); err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+			return ""
+		}
+	}
+}
+
+// goModRequires returns the module versions required by the go.mod closest
+// to src within root.
+func goModRequires(src, root string) (map[string]string, error) {
+	dir := goModDir(src, root)
+	if dir == "" {
+		return nil, nil
+	}
+	return vendor.GoModRequires(dir)
+}
+
+// goModVersion returns the Go version required by the go.mod closest to
+// src within root, that of the module src is part of.
+func goModVersion(src, root string) (string, error) {
+	dir := goModDir(src, root)
+	if dir == "" {
+		return "", nil
+	}
+	return vendor.GoVersion(dir)
+}
+
+// checkGoVersion fails, or only warns with -allow-newer-go, if the go.mod
+// of path, checked out at src within root, requires a newer Go than the
+// go command.
+func (o *fetchOptions) checkGoVersion(path, src, root string) error {
+	dir := goModDir(src, root)
+	if dir == "" {
+		return nil
+	}
+	err := vendor.CheckGoVersion(dir, vendor.ToolchainGoVersion())
+	switch {
+	case err == nil:
+		return nil
+	case o.allowNewerGo:
+		logf(path, "warning: %s %v", path, err)
+		return nil
+	}
+	return fmt.Errorf("%s %v, upgrade Go or use -allow-newer-go", path, err)
 }
 
 func keys(m map[string]bool) []string {
@@ -216,7 +2675,7 @@ func pkgs(m map[string]*vendor.Pkg) []*vendor.Pkg {
 	return p
 }
 
-func findMissing(pkgs []*vendor.Pkg, dsm map[string]*vendor.Depset) map[string]bool {
+func findMissing(pkgs []*vendor.Pkg, dsm map[string]*vendor.Depset) (map[string]bool, error) {
 	missing := make(map[string]bool)
 	imports := make(map[string]*vendor.Pkg)
 	for _, s := range dsm {
@@ -232,19 +2691,13 @@ func findMissing(pkgs []*vendor.Pkg, dsm map[string]*vendor.Depset) map[string]b
 			Name: "C",
 		},
 	}
-	stk := make(map[string]bool)
-	push := func(v string) {
-		if stk[v] {
-			panic(fmt.Sprintln("import loop:", v, stk))
-		}
-		stk[v] = true
-	}
-	pop := func(v string) {
-		if !stk[v] {
-			panic(fmt.Sprintln("impossible pop:", v, stk))
-		}
-		delete(stk, v)
-	}
+
+	// stk is the chain of imports being walked, to detect import loops.
+	var (
+		stk   []string
+		onStk = make(map[string]bool)
+		loop  *importLoopError
+	)
 
 	// checked records import paths who's dependencies are all present
 	checked := make(map[string]bool)
@@ -262,8 +2715,22 @@ func findMissing(pkgs []*vendor.Pkg, dsm map[string]*vendor.Depset) map[string]b
 			return
 		}
 
+		if onStk[importpath] {
+			// skip the arm closing the loop, reporting the first one.
+			if loop == nil {
+				for i := range stk {
+					if stk[i] == importpath {
+						loop = &importLoopError{append(append([]string(nil), stk[i:]...), importpath)}
+						break
+					}
+				}
+			}
+			return
+		}
+
 		sz := len(missing)
-		push(importpath)
+		stk = append(stk, importpath)
+		onStk[importpath] = true
 		for _, i := range p.Imports {
 			if i == importpath {
 				continue
@@ -276,19 +2743,161 @@ func findMissing(pkgs []*vendor.Pkg, dsm map[string]*vendor.Depset) map[string]b
 		if len(missing) == sz {
 			checked[importpath] = true
 		}
-		pop(importpath)
+		stk = stk[:len(stk)-1]
+		delete(onStk, importpath)
 	}
 	for _, pkg := range pkgs {
 		fn(pkg.ImportPath)
 	}
-	return missing
+	if loop != nil {
+		return missing, loop
+	}
+	return missing, nil
+}
+
+// importLoopError is returned by findMissing for packages importing each
+// other.
+type importLoopError struct {
+	cycle []string // the packages of the loop, the first one repeated last
+}
+
+func (e *importLoopError) Error() string {
+	return "import loop: " + strings.Join(e.cycle, " -> ")
+}
+
+// loadBranchRules reads the branch tracking file, if one was supplied.
+func (o *fetchOptions) loadBranchRules() error {
+	if o.branchTrackingFile == "" {
+		return nil
+	}
+	rs, err := vendor.ReadBranchRules(o.branchTrackingFile)
+	if err != nil {
+		return fmt.Errorf("could not load branch tracking file: %v", err)
+	}
+	o.branchRules = rs
+	return nil
+}
+
+// trackedBranch returns the branch to check out for path. An explicit
+// branch, tag or revision always wins over the branch tracking file.
+func (o *fetchOptions) trackedBranch(path, branch, tag, revision string) string {
+	if branch != "" || tag != "" || revision != "" {
+		return branch
+	}
+	if b, ok := o.branchRules.Lookup(path); ok {
+		return b
+	}
+	return branch
+}
+
+// licenseAllowed reports whether path is exempt from the license check.
+func (o *fetchOptions) licenseAllowed(path string) bool {
+	for _, prefix := range strings.Split(o.licenseAllowlist, ",") {
+		prefix = strings.TrimSuffix(strings.TrimSpace(prefix), "/")
+		if prefix != "" && (path == prefix || strings.HasPrefix(path, prefix+"/")) {
+			return true
+		}
+	}
+	return false
+}
+
+// hasLicense reports whether any of dirs contains a license file.
+func hasLicense(dirs ...string) (bool, error) {
+	for _, dir := range dirs {
+		names, err := vendor.FindLicenses(dir)
+		if err != nil {
+			return false, err
+		}
+		if len(names) > 0 {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// defaultRepoURLTemplateFile returns the persistent repository URL
+// templates file in the user config directory.
+func defaultRepoURLTemplateFile() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "gvt", "repo-url-templates")
+}
+
+// loadRepoURLTemplates adds the templates of -repo-url-template-file to
+// those given on the command line. A missing default file is ignored.
+func (o *fetchOptions) loadRepoURLTemplates() error {
+	if o.repoURLTemplateFile == "" {
+		return nil
+	}
+	ts, err := vendor.ReadURLTemplates(o.repoURLTemplateFile)
+	if os.IsNotExist(err) && o.repoURLTemplateFile == defaultRepoURLTemplateFile() {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("could not load repository URL templates: %v", err)
+	}
+	vendor.RepoURLTemplates = append(vendor.RepoURLTemplates, ts...)
+	return nil
+}
+
+// defaultProbeCache returns the default location of the probe cache, or
+// "" if the user has no cache directory.
+func defaultProbeCache() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "gvt", "probe-cache")
+}
+
+// vendoredPath returns the import path path is vendored as.
+func (o *fetchOptions) vendoredPath(path string) (string, error) {
+	path, err := stripscheme(path)
+	if err != nil {
+		return "", err
+	}
+	if o.prefixStripHost {
+		return vendor.StripHost(path)
+	}
+	return path, nil
+}
+
+// checkDepAlias fails if -dep-alias may not name path.
+func (o *fetchOptions) checkDepAlias(path string) error {
+	importpath, err := o.vendoredPath(path)
+	if err != nil {
+		return err
+	}
+	m, err := vendor.ReadManifest(manifestFile())
+	if err != nil {
+		return fmt.Errorf("could not load manifest: %v", err)
+	}
+	return m.CheckAlias(importpath, o.depAlias)
+}
+
+// setDepAlias records -dep-alias as the alias of the fetched path.
+func (o *fetchOptions) setDepAlias(path string) error {
+	importpath, err := o.vendoredPath(path)
+	if err != nil {
+		return err
+	}
+	m, err := vendor.ReadManifest(manifestFile())
+	if err != nil {
+		return fmt.Errorf("could not load manifest: %v", err)
+	}
+	if err := m.SetAlias(importpath, o.depAlias); err != nil {
+		return err
+	}
+	return writeManifest(m)
 }
 
 // stripscheme removes any scheme components from url like paths.
-func stripscheme(path string) string {
+func stripscheme(path string) (string, error) {
 	u, err := url.Parse(path)
 	if err != nil {
-		panic(err)
+		return "", fmt.Errorf("invalid import path: %v", err)
 	}
-	return u.Host + u.Path
+	return u.Host + u.Path, nil
 }
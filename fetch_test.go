@@ -0,0 +1,1308 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"go/build"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/themoonbear/gvt/gbvendor"
+)
+
+// enterProject changes to dir, the project directory of the test, and
+// resets the state fetch keeps between runs. Both, and the fetcher, are
+// restored when the test ends.
+func enterProject(t *testing.T, dir string) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	fetcher := vendor.DefaultFetcher
+	resetFetchState()
+	t.Cleanup(func() {
+		os.Chdir(wd)
+		vendor.DefaultFetcher = fetcher
+		resetFetchState()
+	})
+}
+
+// resetFetchState clears the flags and the run state left by the commands
+// run before.
+func resetFetchState() {
+	fetchOpts = newFetchOptions()
+	parents, created, fetched, timelines = nil, nil, nil, nil
+	requests = vendor.RevisionRequests{}
+	failures = make(vendor.Failures)
+	pins = vendor.Pins{}
+	buildContext = &build.Default
+	global, insecure = false, false
+	vendorDirFlag = ""
+}
+
+// fixturesDir is the fixture directory of the test, set by fetchProject.
+var fixturesDir string
+
+// fetchProject writes files to a fixture directory standing for the
+// network and enters an empty project, as enterProject. It returns both
+// directories.
+func fetchProject(t *testing.T, files map[string]string) (fixtures, project string) {
+	fixtures, project = t.TempDir(), t.TempDir()
+	writeFixtures(t, fixtures, files)
+	enterProject(t, project)
+	fixturesDir = fixtures
+	t.Cleanup(func() { fixturesDir = "" })
+	return fixtures, project
+}
+
+// runCommand parses args with the flags of cmd and runs it.
+func runCommand(t *testing.T, cmd *Command, args ...string) error {
+	flags := flag.NewFlagSet(cmd.Name, flag.ContinueOnError)
+	cmd.AddFlags(flags)
+	if err := flags.Parse(args); err != nil {
+		t.Fatal(err)
+	}
+	return cmd.Run(flags.Args())
+}
+
+// runFetch runs fetch with args, isolated from the network by the fixtures
+// of fetchProject.
+func runFetch(t *testing.T, args ...string) error {
+	return runCommand(t, cmdFetch, append([]string{"-isolate-network", fixturesDir}, args...)...)
+}
+
+// writeFixtures writes files, by slash separated path relative to root.
+func writeFixtures(t *testing.T, root string, files map[string]string) {
+	for path, body := range files {
+		path = filepath.Join(root, filepath.FromSlash(path))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(body), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestFetchIsolateNetwork(t *testing.T) {
+	fetchProject(t, map[string]string{
+		"example.com/a/.fixture-revision": "1111\n",
+		"example.com/a/a.go":              "package a\n\nimport _ \"example.com/b/sub\"\n",
+		"example.com/b/.fixture-revision": "2222\n",
+		"example.com/b/b.go":              "package b\n",
+		"example.com/b/sub/sub.go":        "package sub\n\nimport _ \"example.com/c\"\n",
+		"example.com/c/.fixture-revision": "3333\n",
+		"example.com/c/c.go":              "package c\n",
+		"example.com/c/c_test.go":         "package c\n\nimport _ \"example.com/unused\"\n",
+	})
+
+	if err := runFetch(t, "example.com/a"); err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := vendor.ReadManifest(manifestFile())
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[string]string)
+	for _, d := range m.Dependencies {
+		got[d.Importpath] = d.Repository + "@" + d.Revision
+	}
+	want := map[string]string{
+		"example.com/a":     "https://example.com/a@1111",
+		"example.com/b/sub": "https://example.com/b@2222",
+		"example.com/c":     "https://example.com/c@3333",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("fetch -isolate-network: want %v, got %v", want, got)
+	}
+	for _, path := range []string{"example.com/a/a.go", "example.com/b/sub/sub.go", "example.com/c/c.go"} {
+		if _, err := os.Stat(filepath.Join(vendorDir(false), filepath.FromSlash(path))); err != nil {
+			t.Error(err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(vendorDir(false), "example.com", "a", vendor.FixtureRevisionFile)); !os.IsNotExist(err) {
+		t.Errorf("fetch -isolate-network: %s vendored", vendor.FixtureRevisionFile)
+	}
+
+	// fixtures stand for the network: what they lack cannot be fetched.
+	if err := runFetch(t, "example.com/missing"); err == nil {
+		t.Fatal("fetch -isolate-network of a path without fixture: want an error")
+	}
+}
+
+func TestFetchAllowEmptyRepo(t *testing.T) {
+	fetchProject(t, map[string]string{
+		"example.com/empty/.fixture-revision": "",
+	})
+
+	if err := runFetch(t, "example.com/empty"); err == nil {
+		t.Fatal("fetch of an empty repository: want an error")
+	}
+	if err := runFetch(t, "-allow-empty-repo", "example.com/empty"); err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := vendor.ReadManifest(manifestFile())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []vendor.Dependency{{Importpath: "example.com/empty", Repository: "https://example.com/empty"}}
+	if !reflect.DeepEqual(m.Dependencies, want) {
+		t.Fatalf("fetch -allow-empty-repo: want %v, got %v", want, m.Dependencies)
+	}
+	if _, err := os.Stat(filepath.Join(vendorDir(false), "example.com", "empty")); !os.IsNotExist(err) {
+		t.Errorf("fetch -allow-empty-repo: files vendored for an empty repository")
+	}
+}
+
+func TestFetchReport(t *testing.T) {
+	_, project := fetchProject(t, map[string]string{
+		"example.com/a/.fixture-revision": "1111\n",
+		"example.com/a/a.go":              "package a\n\nimport (\n\t_ \"example.com/b\"\n\t_ \"example.com/c\"\n)\n",
+		"example.com/b/.fixture-revision": "2222\n",
+		"example.com/b/b.go":              "package b\n\nimport _ \"example.com/missing\"\n",
+		"example.com/c/.fixture-revision": "3333\n",
+		"example.com/c/c.go":              "package c\n",
+	})
+
+	report := filepath.Join(project, "report.json")
+	if err := runFetch(t, "example.com/c"); err != nil {
+		t.Fatal(err)
+	}
+	readReport := func() []fetchReportEntry {
+		buf, err := ioutil.ReadFile(report)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var entries []fetchReportEntry
+		if err := json.Unmarshal(buf, &entries); err != nil {
+			t.Fatal(err)
+		}
+		return entries
+	}
+
+	// example.com/missing, needed by example.com/b, fails the fetch,
+	// which is rolled back.
+	if err := runFetch(t, "-report", report, "example.com/a"); err == nil {
+		t.Fatal("fetch of a dependency importing a missing path: want an error")
+	}
+	if got := readReport(); len(got) != 0 {
+		t.Fatalf("fetch -report rolled back: want no dependency, got %v", got)
+	}
+
+	if err := runFetch(t, "-report", report, "-rollback-on-partial-manifest=false", "example.com/a"); err == nil {
+		t.Fatal("fetch of a dependency importing a missing path: want an error")
+	}
+	want := []fetchReportEntry{
+		{"example.com/a", "https://example.com/a", "1111", "master"},
+		{"example.com/b", "https://example.com/b", "2222", "master"},
+	}
+	if got := readReport(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("fetch -report: want %v, got %v", want, got)
+	}
+}
+
+func TestFetchRecordFileCount(t *testing.T) {
+	fetchProject(t, map[string]string{
+		"example.com/a/.fixture-revision": "1111\n",
+		"example.com/a/a.go":              "package a\n",
+		"example.com/a/sub/sub.go":        "package sub\n",
+		"example.com/a/LICENSE":           "MIT\n",
+	})
+
+	if err := runFetch(t, "-record-file-count", "example.com/a"); err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := vendor.ReadManifest(manifestFile())
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := m.Dependencies[0]
+	const size = int64(len("package a\n") + len("package sub\n") + len("MIT\n"))
+	if d.FileCount != 3 || d.Bytes != size {
+		t.Fatalf("fetch -record-file-count: want 3 files and %d bytes, got %d and %d", size, d.FileCount, d.Bytes)
+	}
+}
+
+func TestFetchRetryAlternateURL(t *testing.T) {
+	fetchProject(t, map[string]string{
+		// example.com/down has no fixture: its host is down.
+		"mirror.example.com/down/.fixture-revision": "1111\n",
+		"mirror.example.com/down/sub/sub.go":        "package sub\n",
+		"example.com/old/.fixture-revision":         "1111\n",
+		"example.com/old/old.go":                    "package old\n",
+		"backup.example.com/old/.fixture-revision":  "2222\n",
+		"backup.example.com/old/old.go":             "package old\n",
+	})
+
+	// the primary repository cannot be found.
+	if err := runFetch(t, "-retry-alternate-url", "example.com/down=https://mirror.example.com/down", "example.com/down/sub"); err != nil {
+		t.Fatal(err)
+	}
+	// the primary repository lacks the revision.
+	// an alternate without prefix mirrors the only import path given.
+	if err := runFetch(t, "-retry-alternate-url", "https://backup.example.com/old", "example.com/old", "example.com/down/sub"); err == nil {
+		t.Fatal("fetch -retry-alternate-url without prefix of two import paths: want an error")
+	}
+	if err := runFetch(t, "-revision", "2222", "-retry-alternate-url", "https://nowhere.example.com/old", "-retry-alternate-url", "https://backup.example.com/old", "example.com/old"); err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := vendor.ReadManifest(manifestFile())
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[string]string)
+	for _, d := range m.Dependencies {
+		got[d.Importpath] = d.Repository + d.Path + "@" + d.Revision
+	}
+	want := map[string]string{
+		"example.com/down/sub": "https://mirror.example.com/down/sub@1111",
+		"example.com/old":      "https://backup.example.com/old@2222",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("fetch -retry-alternate-url: want %v, got %v", want, got)
+	}
+	if _, err := os.Stat(filepath.Join(vendorDir(false), "example.com", "down", "sub", "sub.go")); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestFetchMultipleImportPaths(t *testing.T) {
+	fetchProject(t, map[string]string{
+		"example.com/a/.fixture-revision": "1111\n",
+		"example.com/a/a.go":              "package a\n",
+		"example.com/b/.fixture-revision": "2222\n",
+		"example.com/b/b.go":              "package b\n",
+	})
+
+	for _, args := range [][]string{
+		{"-branch", "master"},
+		{"-tag", "v1.0.0"},
+		{"-revision", "1111"},
+		{"-tag-prefix", "v"},
+		{"-date", "2023-01-15"},
+		{"-trim-to-packages", "a"},
+		{"-package-whitelist", "a"},
+		{"-dep-alias", "a"},
+		{"-refuse-downgrade"},
+		{"-print-plan-json"},
+		{"-network-test"},
+		{"-list-vcs-tags"},
+		{"-retry-alternate-url", "https://mirror.example.com/a"},
+	} {
+		err := runFetch(t, append(args, "example.com/a", "example.com/b")...)
+		if err == nil || !strings.Contains(err.Error(), args[0]+" ") || !strings.Contains(err.Error(), "cannot be used with more than one import path") {
+			t.Errorf("fetch %v of two import paths: want %s refused, got %v", args, args[0], err)
+		}
+	}
+	if _, err := os.Stat(vendorDir(false)); !os.IsNotExist(err) {
+		t.Fatalf("fetch of two import paths refused: want nothing vendored, got %v", err)
+	}
+
+	if err := runFetch(t, "example.com/a", "example.com/b"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFetchDate(t *testing.T) {
+	fetchProject(t, map[string]string{
+		"example.com/a/.fixture-revision": "1111\n",
+		"example.com/a/a.go":              "package a\n",
+	})
+
+	for _, args := range [][]string{
+		{"-date", "2023-01-15", "-tag", "v1.0.0"},
+		{"-date", "2023-01-15", "-revision", "1111"},
+		{"-date", "15/01/2023"},
+	} {
+		if err := runFetch(t, append(args, "example.com/a")...); err == nil {
+			t.Errorf("fetch %v: want an error", args)
+		}
+	}
+	// the fixtures, unlike git, cannot resolve revisions by date.
+	err := runFetch(t, "-date", "2023-01-15", "example.com/a")
+	if err == nil || !strings.Contains(err.Error(), "only supported for git") {
+		t.Fatalf("fetch -date from a fixture: want the VCS limitation, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(vendorDir(false), "example.com", "a")); !os.IsNotExist(err) {
+		t.Errorf("fetch -date: files vendored despite the error")
+	}
+}
+
+func TestFetchPackageWhitelist(t *testing.T) {
+	fetchProject(t, map[string]string{
+		"example.com/big/.fixture-revision":  "1111\n",
+		"example.com/big/LICENSE":            "MIT\n",
+		"example.com/big/a/a.go":             "package a\n\nimport _ \"example.com/dep1\"\n",
+		"example.com/big/b/b.go":             "package b\n\nimport _ \"example.com/dep2\"\n",
+		"example.com/big/c/c.go":             "package c\n\nimport _ \"example.com/big/a\"\n",
+		"example.com/dep1/.fixture-revision": "1111\n",
+		"example.com/dep1/dep1.go":           "package dep1\n",
+		"example.com/dep2/.fixture-revision": "1111\n",
+		"example.com/dep2/dep2.go":           "package dep2\n",
+	})
+
+	// c imports a, which is vendored with it.
+	if err := runFetch(t, "-package-whitelist", "c", "example.com/big"); err != nil {
+		t.Fatal(err)
+	}
+
+	vendored := filepath.Join(vendorDir(false), "example.com")
+	for _, path := range []string{"big/LICENSE", "big/a/a.go", "big/c/c.go", "dep1/dep1.go"} {
+		if _, err := os.Stat(filepath.Join(vendored, filepath.FromSlash(path))); err != nil {
+			t.Error(err)
+		}
+	}
+	for _, path := range []string{"big/b", "dep2"} {
+		if _, err := os.Stat(filepath.Join(vendored, filepath.FromSlash(path))); !os.IsNotExist(err) {
+			t.Errorf("fetch -package-whitelist c: %s vendored", path)
+		}
+	}
+	m, err := vendor.ReadManifest(manifestFile())
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := m.GetDependencyForImportpath("example.com/big")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"a", "c"}; !reflect.DeepEqual(d.Packages, want) {
+		t.Errorf("fetch -package-whitelist c: want packages %v recorded, got %v", want, d.Packages)
+	}
+}
+
+func TestFetchRecordLicense(t *testing.T) {
+	fixtures, _ := fetchProject(t, map[string]string{
+		"example.com/mono/.fixture-revision":  "1111\n",
+		"example.com/mono/LICENSE":            "Permission is hereby granted, free of charge, to any person\n",
+		"example.com/mono/sub/sub.go":         "package sub\n",
+		"example.com/mono/sub/sub_test.go":    "package sub\n",
+		"example.com/plain/.fixture-revision": "1111\n",
+		"example.com/plain/plain.go":          "package plain\n",
+	})
+
+	for _, path := range []string{"example.com/mono/sub", "example.com/plain"} {
+		if err := runFetch(t, "-record-license", "-no-tests", path); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	m, err := vendor.ReadManifest(manifestFile())
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[string]*vendor.License)
+	for _, d := range m.Dependencies {
+		got[d.Importpath] = d.License
+	}
+	want := map[string]*vendor.License{
+		"example.com/mono/sub": {File: "LICENSE", SPDX: "MIT"},
+		"example.com/plain":    nil,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("fetch -record-license: want %v, got %v", want, got)
+	}
+	// the license of the repository is vendored with the directory.
+	license := filepath.Join(vendorDir(false), "example.com", "mono", "sub", "LICENSE")
+	if _, err := os.Stat(license); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.RemoveAll(vendorDir(false)); err != nil {
+		t.Fatal(err)
+	}
+	vendor.DefaultFetcher = &vendor.FixtureFetcher{Root: fixtures}
+	if err := runCommand(t, cmdRestore); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(license); err != nil {
+		t.Errorf("restore: %v", err)
+	}
+}
+
+func TestFetchEmitMetrics(t *testing.T) {
+	_, project := fetchProject(t, map[string]string{
+		"example.com/a/.fixture-revision": "1111\n",
+		"example.com/a/a.go":              "package a\n\nimport _ \"example.com/b\"\n",
+		"example.com/b/.fixture-revision": "2222\n",
+		"example.com/b/b.go":              "package b\n",
+	})
+
+	metrics := filepath.Join(project, "metrics.json")
+	if err := runFetch(t, "-emit-metrics", metrics, "example.com/a"); err != nil {
+		t.Fatal(err)
+	}
+
+	buf, err := ioutil.ReadFile(metrics)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var timelines []fetchTimeline
+	if err := json.Unmarshal(buf, &timelines); err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[string]string)
+	for _, tl := range timelines {
+		var names []string
+		for _, p := range tl.Phases {
+			if p.Start.IsZero() || p.Seconds < 0 {
+				t.Errorf("%s: phase %s not timed: %+v", tl.Importpath, p.Name, p)
+			}
+			names = append(names, p.Name)
+		}
+		got[tl.Importpath] = strings.Join(names, ",")
+	}
+	// the fixtures time their checkout as a whole.
+	want := map[string]string{
+		"example.com/a": "deduce,checkout,copy,checksum",
+		"example.com/b": "deduce,checkout,copy,checksum",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("fetch -emit-metrics: want phases %v, got %v", want, got)
+	}
+}
+
+func TestFetchVerbosity(t *testing.T) {
+	fetchProject(t, map[string]string{
+		"example.com/a/.fixture-revision": "1111\n",
+		"example.com/a/a.go":              "package a\n\nimport _ \"example.com/b\"\n",
+		"example.com/b/.fixture-revision": "2222\n",
+		"example.com/b/b.go":              "package b\n",
+	})
+
+	defer log.SetOutput(os.Stderr)
+	defer func() { defaultLogLevel = levelInfo }()
+
+	fetch := func(args ...string) (string, error) {
+		if err := os.Chdir(t.TempDir()); err != nil {
+			t.Fatal(err)
+		}
+		var buf bytes.Buffer
+		log.SetOutput(&buf)
+		err := runFetch(t, args...)
+		log.SetOutput(os.Stderr)
+		return buf.String(), err
+	}
+
+	out, err := fetch("-q", "example.com/a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out != "" {
+		t.Errorf("fetch -q: want no output, got %q", out)
+	}
+	out, err = fetch("example.com/a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out, "example.com/b") || strings.Contains(out, "copied") {
+		t.Errorf("fetch: want the progress without the details, got %q", out)
+	}
+	out, err = fetch("-v", "example.com/a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out, "example.com/b: copied 1 files") {
+		t.Errorf("fetch -v: want the bytes copied, got %q", out)
+	}
+	if _, err := fetch("-v", "-q", "example.com/a"); err == nil {
+		t.Error("fetch -v -q: want an error")
+	}
+}
+
+func TestFetchPerDepLogLevel(t *testing.T) {
+	fetchProject(t, map[string]string{
+		"example.com/a/.fixture-revision": "1111\n",
+		"example.com/a/a.go":              "package a\n\nimport (\n\t_ \"example.com/b\"\n\t_ \"example.org/c\"\n)\n",
+		"example.com/b/.fixture-revision": "2222\n",
+		"example.com/b/b.go":              "package b\n",
+		"example.org/c/.fixture-revision": "3333\n",
+		"example.org/c/c.go":              "package c\n",
+	})
+
+	defer log.SetOutput(os.Stderr)
+	defer func() { defaultLogLevel, perDepLogLevels = levelInfo, nil }()
+
+	fetch := func(args ...string) string {
+		if err := os.Chdir(t.TempDir()); err != nil {
+			t.Fatal(err)
+		}
+		perDepLogLevels = nil
+		var buf bytes.Buffer
+		log.SetOutput(&buf)
+		err := runFetch(t, args...)
+		log.SetOutput(os.Stderr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return buf.String()
+	}
+
+	out := fetch("-per-dep-log-level", "example.com/b=debug", "example.com/a")
+	if !strings.Contains(out, "example.com/b: deduced repository") {
+		t.Errorf("fetch -per-dep-log-level example.com/b=debug: want the details of b, got %q", out)
+	}
+	for _, other := range []string{"example.com/a: ", "example.org/c: "} {
+		if strings.Contains(out, other) {
+			t.Errorf("fetch -per-dep-log-level example.com/b=debug: want no details of %s got %q", other, out)
+		}
+	}
+	if !strings.Contains(out, "fetching recursive dependency example.org/c") {
+		t.Errorf("fetch -per-dep-log-level example.com/b=debug: want the progress of the others, got %q", out)
+	}
+
+	// the others quiet, only b is logged.
+	out = fetch("-q", "-per-dep-log-level", "example.com/b=debug", "example.com/a")
+	if out == "" {
+		t.Fatal("fetch -q -per-dep-log-level example.com/b=debug: want the details of b, got no output")
+	}
+	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		if !strings.Contains(line, "example.com/b") {
+			t.Errorf("fetch -q -per-dep-log-level example.com/b=debug: want only b logged, got %q", line)
+		}
+	}
+}
+
+func TestFetchRecordDefaultBranch(t *testing.T) {
+	fixtures, _ := fetchProject(t, map[string]string{
+		"example.com/a/.fixture-revision": "1111\n",
+		"example.com/a/a.go":              "package a\n",
+		"example.com/b/.fixture-revision": "1111\n",
+		"example.com/b/b.go":              "package b\n",
+	})
+
+	fetch := func(args ...string) {
+		if err := runFetch(t, args...); err != nil {
+			t.Fatal(err)
+		}
+	}
+	fetch("-record-default-branch", "-revision", "1111", "example.com/a")
+	fetch("example.com/b")
+	defaults := func() map[string]string {
+		m, err := vendor.ReadManifest(manifestFile())
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := make(map[string]string)
+		for _, d := range m.Dependencies {
+			got[d.Importpath] = d.Branch + "@" + d.Revision + " default " + d.DefaultBranch
+		}
+		return got
+	}
+	want := map[string]string{"example.com/a": "master@1111 default master", "example.com/b": "master@1111 default "}
+	if got := defaults(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("fetch -record-default-branch: want %v, got %v", want, got)
+	}
+
+	writeFixtures(t, fixtures, map[string]string{
+		"example.com/a/.fixture-revision": "2222\n",
+		"example.com/b/.fixture-revision": "2222\n",
+	})
+	vendor.DefaultFetcher = &vendor.FixtureFetcher{Root: fixtures}
+	if err := runCommand(t, cmdUpdate, "-all", "-to-default-branch"); err != nil {
+		t.Fatal(err)
+	}
+	want = map[string]string{"example.com/a": "master@2222 default master", "example.com/b": "master@2222 default "}
+	if got := defaults(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("update -to-default-branch: want %v, got %v", want, got)
+	}
+}
+
+func TestFetchIsolateGOPATH(t *testing.T) {
+	fetchProject(t, map[string]string{
+		"example.com/dep/.fixture-revision":   "1111\n",
+		"example.com/dep/dep.go":              "package dep\n\nimport _ \"example.com/stale\"\n",
+		"example.com/stale/.fixture-revision": "2222\n",
+		"example.com/stale/stale.go":          "package stale // upstream\n",
+	})
+	// installed in the real GOPATH, but not vendored.
+	gopath := t.TempDir()
+	writeFixtures(t, gopath, map[string]string{"src/example.com/stale/stale.go": "package stale // installed\n"})
+	t.Setenv("GOPATH", gopath)
+
+	if err := runFetch(t, "-isolate-gopath", "-g", "example.com/dep"); err != nil {
+		t.Fatal(err)
+	}
+	if buildContext != &build.Default {
+		t.Fatal("fetch -isolate-gopath: build context not reset")
+	}
+
+	m, err := vendor.ReadManifest(manifestFile())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !m.HasImportpath("example.com/stale") {
+		t.Fatalf("fetch -isolate-gopath: want example.com/stale fetched rather than satisfied by the GOPATH, got %v", m.Dependencies)
+	}
+	buf, err := ioutil.ReadFile(filepath.Join(gopath, "src", "example.com", "stale", "stale.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(buf) != "package stale // upstream\n" {
+		t.Fatalf("fetch -isolate-gopath: want the upstream example.com/stale installed, got %q", buf)
+	}
+}
+
+func TestFetchRefuseDowngradeRestoresOnFailure(t *testing.T) {
+	fixtures, _ := fetchProject(t, map[string]string{
+		"example.com/a/.fixture-revision": "1111\n",
+		"example.com/a/LICENSE":           "Permission is hereby granted, free of charge, to any person\n",
+		"example.com/a/a.go":              "package a\n",
+	})
+
+	if err := runFetch(t, "example.com/a"); err != nil {
+		t.Fatal(err)
+	}
+	before, err := ioutil.ReadFile(manifestFile())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the new revision has no license, so it is refused once copied.
+	if err := os.Remove(filepath.Join(fixtures, "example.com", "a", "LICENSE")); err != nil {
+		t.Fatal(err)
+	}
+	writeFixtures(t, fixtures, map[string]string{
+		"example.com/a/.fixture-revision": "2222\n",
+		"example.com/a/a.go":              "package a // updated\n",
+	})
+	if err := runFetch(t, "-refuse-downgrade", "-abort-on-missing-license", "example.com/a"); err == nil {
+		t.Fatal("fetch -refuse-downgrade of a revision refused: want an error")
+	}
+
+	after, err := ioutil.ReadFile(manifestFile())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(after) != string(before) {
+		t.Fatalf("failed re-fetch: want the manifest unchanged\n%s\ngot\n%s", before, after)
+	}
+	a := filepath.Join(vendorDir(false), "example.com", "a")
+	buf, err := ioutil.ReadFile(filepath.Join(a, "a.go"))
+	if err != nil || string(buf) != "package a\n" {
+		t.Fatalf("failed re-fetch: want the old tree back, got %q, %v", buf, err)
+	}
+	if _, err := os.Stat(filepath.Join(a, "LICENSE")); err != nil {
+		t.Fatalf("failed re-fetch: %v", err)
+	}
+	entries, err := ioutil.ReadDir(vendorDir(false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range entries {
+		if strings.HasPrefix(e.Name(), ".gvt-") {
+			t.Errorf("failed re-fetch: staging directory %s left behind", e.Name())
+		}
+	}
+}
+
+func TestFetchMergeManifestFailure(t *testing.T) {
+	_, project := fetchProject(t, map[string]string{
+		"example.com/a/.fixture-revision": "2222\n",
+		"example.com/a/a.go":              "package a // theirs\n",
+		"example.com/b/.fixture-revision": "1111\n",
+		"example.com/b/b.go":              "package b\n",
+	})
+	writeFixtures(t, project, map[string]string{"vendor/example.com/a/a.go": "package a // mine\n"})
+	mine := &vendor.Manifest{Dependencies: []vendor.Dependency{{Importpath: "example.com/a", Repository: "https://example.com/a", Revision: "1111"}}}
+	if err := vendor.WriteManifest(filepath.Join(project, "manifest"), mine); err != nil {
+		t.Fatal(err)
+	}
+	// example.com/b is not upstream at 9999 anymore.
+	theirs := &vendor.Manifest{Dependencies: []vendor.Dependency{
+		{Importpath: "example.com/a", Repository: "https://example.com/a", Revision: "2222"},
+		{Importpath: "example.com/b", Repository: "https://example.com/b", Revision: "9999"},
+	}}
+	other := filepath.Join(t.TempDir(), "manifest")
+	if err := vendor.WriteManifest(other, theirs); err != nil {
+		t.Fatal(err)
+	}
+	before, err := ioutil.ReadFile(filepath.Join(project, "manifest"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := runFetch(t, "-precaire", "-merge-manifest", other, "-on-conflict", "theirs"); err == nil {
+		t.Fatal("fetch -merge-manifest with a dependency that cannot be downloaded: want an error")
+	}
+
+	after, err := ioutil.ReadFile(manifestFile())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(after) != string(before) {
+		t.Fatalf("failed merge: want the manifest unchanged\n%s\ngot\n%s", before, after)
+	}
+	buf, err := ioutil.ReadFile(filepath.Join(vendorDir(false), "example.com", "a", "a.go"))
+	if err != nil || string(buf) != "package a // mine\n" {
+		t.Fatalf("failed merge: want the vendored example.com/a untouched, got %q, %v", buf, err)
+	}
+	entries, err := ioutil.ReadDir(vendorDir(false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range entries {
+		if e.Name() != "example.com" {
+			t.Errorf("failed merge: %s left in the vendor directory", e.Name())
+		}
+	}
+
+	theirs.Dependencies[1].Revision = "1111"
+	if err := vendor.WriteManifest(other, theirs); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { defaultLogLevel = levelInfo }()
+	var out bytes.Buffer
+	log.SetOutput(&out)
+	err = runFetch(t, "-q", "-merge-manifest", other, "-on-conflict", "theirs")
+	log.SetOutput(os.Stderr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.Len() > 0 {
+		t.Errorf("merge -q: want no output, got %q", out.String())
+	}
+	buf, err = ioutil.ReadFile(filepath.Join(vendorDir(false), "example.com", "a", "a.go"))
+	if err != nil || string(buf) != "package a // theirs\n" {
+		t.Fatalf("merge: want example.com/a replaced, got %q, %v", buf, err)
+	}
+	if _, err := os.Stat(filepath.Join(vendorDir(false), "example.com", "b", "b.go")); err != nil {
+		t.Fatalf("merge: %v", err)
+	}
+}
+
+func TestFetchNoFailFast(t *testing.T) {
+	fetchProject(t, map[string]string{
+		"example.com/a/.fixture-revision": "1111\n",
+		"example.com/a/a.go":              "package a\n\nimport (\n\t_ \"example.com/b\"\n\t_ \"example.com/c\"\n\t_ \"example.com/d\"\n)\n",
+		"example.com/b/.fixture-revision": "2222\n",
+		"example.com/b/b.go":              "package b\n",
+		"example.com/d/.fixture-revision": "4444\n",
+		"example.com/d/d.go":              "package d\n\nimport _ \"example.com/e\"\n",
+	})
+
+	// c, imported by a, and e, imported by d, are not in the fixtures.
+	err := runFetch(t, "-fail-fast=false", "example.com/a")
+	f, ok := err.(vendor.Failures)
+	if !ok {
+		t.Fatalf("fetch -fail-fast=false: want the failures, got %v", err)
+	}
+	var failed []string
+	for path := range f {
+		failed = append(failed, path)
+	}
+	sort.Strings(failed)
+	if want := []string{"example.com/c", "example.com/e"}; !reflect.DeepEqual(failed, want) {
+		t.Fatalf("fetch -fail-fast=false: want %v failed, got %v", want, failed)
+	}
+
+	m, err := vendor.ReadManifest(manifestFile())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, d := range m.Dependencies {
+		got = append(got, d.Importpath)
+	}
+	if want := []string{"example.com/a", "example.com/b", "example.com/d"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("fetch -fail-fast=false: want %v vendored, got %v", want, got)
+	}
+}
+
+func TestFetchRollbackGlobal(t *testing.T) {
+	fetchProject(t, map[string]string{
+		"example.com/a/.fixture-revision": "1111\n",
+		"example.com/a/a.go":              "package a\n\nimport (\n\t_ \"example.com/b\"\n\t_ \"example.com/c\"\n)\n",
+		"example.com/b/.fixture-revision": "2222\n",
+		"example.com/b/b.go":              "package b\n",
+	})
+
+	gopath := t.TempDir()
+	if err := os.Mkdir(filepath.Join(gopath, "src"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("GOPATH", gopath)
+
+	keep := vendor.Dependency{Importpath: "example.org/keep", Repository: "https://example.org/keep", Revision: "9999"}
+	if err := vendor.WriteManifest(manifestFile(), &vendor.Manifest{Dependencies: []vendor.Dependency{keep}}); err != nil {
+		t.Fatal(err)
+	}
+	before, err := ioutil.ReadFile(manifestFile())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a and b are vendored before c, not in the fixtures, fails.
+	if err := runFetch(t, "-g", "example.com/a"); err == nil {
+		t.Fatal("fetch: want an error")
+	}
+
+	after, err := ioutil.ReadFile(manifestFile())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(before, after) {
+		t.Errorf("fetch: want the manifest restored, got %s", after)
+	}
+	entries, err := ioutil.ReadDir(filepath.Join(gopath, "src"))
+	if err != nil {
+		t.Fatalf("fetch: want $GOPATH/src kept: %v", err)
+	}
+	for _, e := range entries {
+		t.Errorf("fetch: %s left in $GOPATH/src", e.Name())
+	}
+}
+
+func TestFetchTwoPhase(t *testing.T) {
+	fetchProject(t, map[string]string{
+		"example.com/a/.fixture-revision":        "1111\n",
+		"example.com/a/a.go":                     "package a\n\nimport _ \"example.com/b\"\n",
+		"example.com/a/a_test.go":                "package a\n",
+		"mirror.example.com/b/.fixture-revision": "2222\n",
+		"mirror.example.com/b/b.go":              "package b\n",
+		"example.com/c/.fixture-revision":        "3333\n",
+		"example.com/c/c.go":                     "package c\n\nimport _ \"example.com/missing\"\n",
+	})
+
+	fetch := func(args ...string) error {
+		return runFetch(t, append([]string{"-two-phase"}, args...)...)
+	}
+	untouched := func(what string) {
+		if _, err := os.Stat(manifestFile()); !os.IsNotExist(err) {
+			t.Errorf("%s: manifest written", what)
+		}
+		entries, _ := ioutil.ReadDir(vendorDir(false))
+		for _, e := range entries {
+			if e.Mode().IsDir() {
+				t.Errorf("%s: %s left in the vendor directory", what, e.Name())
+			}
+		}
+	}
+
+	// the download of a recursive dependency fails.
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	if err := fetch("example.com/c"); err == nil || !strings.HasPrefix(err.Error(), "download failed") {
+		t.Fatalf("fetch -two-phase: want a download error, got %v", err)
+	}
+	untouched("download failure")
+
+	// the install fails, example.com cannot be created.
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	writeFixtures(t, ".", map[string]string{"vendor/example.com": ""})
+	if err := fetch("-retry-alternate-url", "example.com/b=https://mirror.example.com/b", "example.com/a"); err == nil || !strings.HasPrefix(err.Error(), "install failed") {
+		t.Fatalf("fetch -two-phase: want an install error, got %v", err)
+	}
+	untouched("install failure")
+
+	// the manifest is locked by another gvt once the trees are installed.
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	writeFixtures(t, ".", map[string]string{filepath.Base(manifestFile()) + ".lock": ""})
+	if err := fetch("-retry-alternate-url", "example.com/b=https://mirror.example.com/b", "example.com/a"); err == nil || !strings.Contains(err.Error(), "is locked") {
+		t.Fatalf("fetch -two-phase: want the manifest locked, got %v", err)
+	}
+	untouched("manifest locked")
+
+	// the alternates and the copy policies apply to every dependency.
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	if err := fetch("-no-tests", "-retry-alternate-url", "example.com/b=https://mirror.example.com/b", "example.com/a"); err != nil {
+		t.Fatal(err)
+	}
+	m, err := vendor.ReadManifest(manifestFile())
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[string]string)
+	for _, d := range m.Dependencies {
+		got[d.Importpath] = fmt.Sprintf("%s@%s notests=%v", d.Repository, d.Revision, d.NoTests)
+	}
+	want := map[string]string{
+		"example.com/a": "https://example.com/a@1111 notests=true",
+		"example.com/b": "https://mirror.example.com/b@2222 notests=true",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("fetch -two-phase: want %v, got %v", want, got)
+	}
+	if _, err := os.Stat(filepath.Join(vendorDir(false), "example.com", "a", "a_test.go")); !os.IsNotExist(err) {
+		t.Error("fetch -two-phase -no-tests: a_test.go vendored")
+	}
+
+	// so do the flags checking and recording each dependency.
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	if err := fetch("-prefix-strip-host", "-record-parent", "-retry-alternate-url", "example.com/b=https://mirror.example.com/b", "example.com/a"); err != nil {
+		t.Fatal(err)
+	}
+	if m, err = vendor.ReadManifest(manifestFile()); err != nil {
+		t.Fatal(err)
+	}
+	got = make(map[string]string)
+	for _, d := range m.Dependencies {
+		got[d.Importpath] = fmt.Sprintf("origin=%s parents=%v", d.Origin, d.Parents)
+	}
+	want = map[string]string{
+		"a": "origin=example.com/a parents=[]",
+		"b": "origin=example.com/b parents=[a]",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("fetch -two-phase -prefix-strip-host -record-parent: want %v, got %v", want, got)
+	}
+	if _, err := os.Stat(filepath.Join(vendorDir(false), "b", "b.go")); err != nil {
+		t.Error(err)
+	}
+
+	for _, arg := range []string{"-lazy-recursion", "-refuse-downgrade"} {
+		if err := fetch(arg, "example.com/c"); err == nil || !strings.Contains(err.Error(), "cannot be used with -two-phase") {
+			t.Errorf("fetch -two-phase %s: want it refused, got %v", arg, err)
+		}
+	}
+}
+
+func TestFetchGuardsTwoPhase(t *testing.T) {
+	fetchProject(t, map[string]string{
+		"example.com/nolicense/.fixture-revision": "1111\n",
+		"example.com/nolicense/a.go":              "package nolicense\n",
+		"example.com/newgo/.fixture-revision":     "2222\n",
+		"example.com/newgo/go.mod":                "module example.com/newgo\n\ngo 99.0\n",
+		"example.com/newgo/a.go":                  "package newgo\n",
+		"example.com/moved/.fixture-revision":     "3333\n",
+		"example.com/moved/a.go":                  "package moved // import \"example.org/moved\"\n",
+	})
+
+	guards := []struct {
+		path  string
+		flags []string
+		want  string
+	}{
+		{"example.com/nolicense", []string{"-abort-on-missing-license"}, "has no license file"},
+		{"example.com/newgo", []string{"-min-go-version-guard"}, "requires go 99.0"},
+		{"example.com/moved", []string{"-verify-import-path-match", "-strict"}, "does not match its import path"},
+	}
+	for _, mode := range []string{"", "-two-phase", "-atomic-manifest-and-tree"} {
+		for _, g := range guards {
+			if err := os.Chdir(t.TempDir()); err != nil {
+				t.Fatal(err)
+			}
+			var args []string
+			if mode != "" {
+				args = append(args, mode)
+			}
+			what := strings.TrimSpace(fmt.Sprintf("fetch %s %s", mode, strings.Join(g.flags, " ")))
+			if err := runFetch(t, append(append(args, g.flags...), g.path)...); err == nil || !strings.Contains(err.Error(), g.want) {
+				t.Errorf("%s: want an error containing %q, got %v", what, g.want, err)
+			}
+			if _, err := os.Stat(filepath.Join(vendorDir(false), filepath.FromSlash(g.path))); !os.IsNotExist(err) {
+				t.Errorf("%s: %s vendored", what, g.path)
+			}
+			m, err := vendor.ReadManifest(manifestFile())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if m.HasImportpath(g.path) {
+				t.Errorf("%s: %s recorded", what, g.path)
+			}
+		}
+	}
+}
+
+func TestFetchMinGoVersionGuard(t *testing.T) {
+	fetchProject(t, map[string]string{
+		"example.com/a/.fixture-revision": "1111\n",
+		"example.com/a/go.mod":            "module example.com/a\n\ngo 99.0\n",
+		"example.com/a/a.go":              "package a\n",
+	})
+
+	fetch := func(args ...string) error {
+		if err := os.Chdir(t.TempDir()); err != nil {
+			t.Fatal(err)
+		}
+		return runFetch(t, append([]string{"-min-go-version-guard"}, args...)...)
+	}
+
+	err := fetch("example.com/a")
+	if err == nil || !strings.Contains(err.Error(), "requires go 99.0, newer than the "+vendor.ToolchainGoVersion()) {
+		t.Fatalf("fetch -min-go-version-guard: want the versions in the error, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(vendorDir(false), "example.com", "a")); !os.IsNotExist(err) {
+		t.Error("fetch -min-go-version-guard: example.com/a vendored")
+	}
+	if err := fetch("-allow-newer-go", "example.com/a"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(vendorDir(false), "example.com", "a", "a.go")); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestFetchDryRun(t *testing.T) {
+	fixtures, _ := fetchProject(t, map[string]string{
+		"mirror.example.com/a/.fixture-revision": "1111\n",
+		"mirror.example.com/a/a.go":              "package a\n\nimport _ \"example.com/b\"\n",
+		"example.com/b/.fixture-revision":        "2222\n",
+		"example.com/b/b.go":                     "package b\n",
+	})
+
+	defer log.SetOutput(os.Stderr)
+
+	dryRun := func(args ...string) string {
+		var buf bytes.Buffer
+		log.SetOutput(&buf)
+		err := runCommand(t, cmdFetch, append([]string{"-dry-run", "-retry-alternate-url", "example.com/a=https://mirror.example.com/a"}, args...)...)
+		log.SetOutput(os.Stderr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return buf.String()
+	}
+
+	// the dependencies are listed from scratch clones.
+	vendor.DefaultFetcher = &vendor.FixtureFetcher{Root: fixtures}
+	out := dryRun("example.com/a")
+	for _, want := range []string{
+		"would fetch example.com/a from https://mirror.example.com/a at 1111",
+		"would fetch recursive example.com/b from https://example.com/b at 2222",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("fetch -dry-run: want %q logged, got\n%s", want, out)
+		}
+	}
+
+	// a is only found on its mirror, and resolved there without a clone;
+	// b is only known once a is cloned.
+	vendor.DefaultFetcher = resolveOnlyFetcher{&vendor.FixtureFetcher{Root: fixtures}}
+	out = dryRun("-no-recurse", "example.com/a")
+	if want := "would fetch example.com/a from https://mirror.example.com/a at 1111"; !strings.Contains(out, want) {
+		t.Errorf("fetch -dry-run -no-recurse: want %q logged, got\n%s", want, out)
+	}
+	if strings.Contains(out, "example.com/b") {
+		t.Errorf("fetch -dry-run -no-recurse: want example.com/b left out, got\n%s", out)
+	}
+
+	if _, err := os.Stat(manifestFile()); !os.IsNotExist(err) {
+		t.Error("fetch -dry-run: manifest written")
+	}
+	if _, err := os.Stat(vendorDir(false)); !os.IsNotExist(err) {
+		t.Error("fetch -dry-run: vendor directory created")
+	}
+}
+
+// resolveOnlyFetcher is a Fetcher whose repositories resolve revisions
+// but fail to be checked out.
+type resolveOnlyFetcher struct {
+	vendor.Fetcher
+}
+
+func (f resolveOnlyFetcher) DeduceRemoteRepo(path string, insecure bool, repository ...string) (vendor.RemoteRepo, string, error) {
+	repo, extra, err := f.Fetcher.DeduceRemoteRepo(path, insecure, repository...)
+	if err != nil {
+		return nil, "", err
+	}
+	return resolveOnlyRepo{repo}, extra, nil
+}
+
+func (f resolveOnlyFetcher) RepoAt(repourl string, insecure bool) (vendor.RemoteRepo, error) {
+	repo, err := f.Fetcher.RepoAt(repourl, insecure)
+	if err != nil {
+		return nil, err
+	}
+	return resolveOnlyRepo{repo}, nil
+}
+
+type resolveOnlyRepo struct {
+	vendor.RemoteRepo
+}
+
+func (r resolveOnlyRepo) Checkout(branch, tag, revision string) (vendor.WorkingCopy, error) {
+	return nil, fmt.Errorf("%s checked out", r.URL())
+}
+
+func (r resolveOnlyRepo) ResolveRevision(branch, tag string) (string, error) {
+	return r.RemoteRepo.(vendor.RevisionResolver).ResolveRevision(branch, tag)
+}
+
+// slowFetcher is a Fetcher answering after delay, or failing after
+// vendor.ProbeTimeout if shorter, and only to insecure deductions if
+// secure is false.
+type slowFetcher struct {
+	vendor.Fetcher
+	delay  time.Duration
+	secure bool
+}
+
+func (f *slowFetcher) DeduceRemoteRepo(path string, insecure bool, repository ...string) (vendor.RemoteRepo, string, error) {
+	if vendor.ProbeTimeout > 0 && vendor.ProbeTimeout < f.delay {
+		time.Sleep(vendor.ProbeTimeout)
+		return nil, "", fmt.Errorf("%s: no answer within %v", path, vendor.ProbeTimeout)
+	}
+	time.Sleep(f.delay)
+	if !insecure && !f.secure {
+		return nil, "", fmt.Errorf("%s: no https", path)
+	}
+	return f.Fetcher.DeduceRemoteRepo(path, insecure, repository...)
+}
+
+func TestFetchNetworkTestDeduction(t *testing.T) {
+	// .invalid hosts fail to resolve at once, no request leaves.
+	fixtures := t.TempDir()
+	writeFixtures(t, fixtures, map[string]string{
+		"example.invalid/a/.fixture-revision": "1111\n",
+		"example.invalid/a/a.go":              "package a\n",
+	})
+	defer func(f vendor.Fetcher) { vendor.DefaultFetcher = f }(vendor.DefaultFetcher)
+	fixture := &vendor.FixtureFetcher{Root: fixtures}
+
+	vendor.DefaultFetcher = &slowFetcher{Fetcher: fixture, delay: time.Second, secure: true}
+	start := time.Now()
+	if _, err := deduceWithin("example.invalid/a", false, 10*time.Millisecond); err == nil || !strings.Contains(err.Error(), "no answer") {
+		t.Fatalf("deduceWithin: want a timeout, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Fatalf("deduceWithin: gave up after %v, want 10ms", elapsed)
+	}
+
+	// the insecure deduction is only tried with -precaire.
+	vendor.DefaultFetcher = &slowFetcher{Fetcher: fixture}
+	o := newFetchOptions()
+	o.networkTestTimeout = time.Second
+	for _, precaire := range []bool{false, true} {
+		o.insecure = precaire
+		out, _ := captureStdout(t, func() error { return o.networkTest("example.invalid/a") })
+		if got := !strings.Contains(out, "unreachable repository of"); got != precaire {
+			t.Errorf("network test with insecure %v: want the repository resolved %v, got %q", precaire, precaire, out)
+		}
+	}
+}
+
+func TestFetchPlanApply(t *testing.T) {
+	fetchProject(t, map[string]string{
+		"example.com/a/.fixture-revision": "1111\n",
+		"example.com/a/a.go":              "package a\n\nimport _ \"example.com/b/sub\"\n",
+		"example.com/a/a_test.go":         "package a\n",
+		"example.com/b/.fixture-revision": "2222\n",
+		"example.com/b/b.go":              "package b\n",
+		"example.com/b/sub/sub.go":        "package sub\n",
+	})
+
+	fetch := func(args ...string) (string, error) {
+		return captureStdout(t, func() error { return runFetch(t, args...) })
+	}
+	out, err := fetch("-print-plan-json", "-no-tests", "example.com/a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var plan fetchPlan
+	if err := json.Unmarshal([]byte(out), &plan); err != nil {
+		t.Fatalf("fetch -print-plan-json: %v: %q", err, out)
+	}
+	if len(plan.Steps) != 2 {
+		t.Fatalf("fetch -print-plan-json: want a and b planned, got %+v", plan.Steps)
+	}
+	if _, err := os.Stat("vendor"); !os.IsNotExist(err) {
+		t.Fatalf("fetch -print-plan-json: want nothing vendored, got %v", err)
+	}
+
+	// the plan is stable.
+	again, err := fetch("-print-plan-json", "-no-tests", "example.com/a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again != out {
+		t.Fatalf("fetch -print-plan-json: want the same plan twice, got %s and %s", out, again)
+	}
+
+	if err := ioutil.WriteFile("plan.json", []byte(out), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fetch("-apply", "plan.json"); err != nil {
+		t.Fatal(err)
+	}
+	m, err := vendor.ReadManifest(manifestFile())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m.Dependencies) != len(plan.Steps) {
+		t.Fatalf("fetch -apply: want %d dependencies, got %v", len(plan.Steps), m.Dependencies)
+	}
+	for _, s := range plan.Steps {
+		d, err := m.GetDependencyForImportpath(s.Importpath)
+		if err != nil {
+			t.Fatalf("fetch -apply: %v", err)
+		}
+		d.Checksum = ""
+		if want := s.dependency(); !reflect.DeepEqual(d, want) {
+			t.Errorf("fetch -apply: want %+v installed, got %+v", want, d)
+		}
+		if _, err := os.Stat(filepath.FromSlash(s.Destination)); err != nil {
+			t.Errorf("fetch -apply: %v", err)
+		}
+		if !d.NoTests {
+			t.Errorf("fetch -apply: want %s recorded with the -no-tests of the plan", s.Importpath)
+		}
+	}
+	// the copy policy of the plan applies, not the flags of -apply.
+	if _, err := os.Stat(filepath.Join("vendor", "example.com", "a", "a_test.go")); !os.IsNotExist(err) {
+		t.Errorf("fetch -apply: want a_test.go left out, got %v", err)
+	}
+}
+
+func TestFetchPlanApplyReplace(t *testing.T) {
+	fetchProject(t, map[string]string{
+		"example.com/a/.fixture-revision":      "1111\n",
+		"example.com/a/a.go":                   "package a\n\nimport _ \"example.com/b\"\n",
+		"example.com/fork/b/.fixture-revision": "2222\n",
+		"example.com/fork/b/b.go":              "package b // fork\n",
+	})
+
+	// example.com/b is only reachable through its fork.
+	out, err := captureStdout(t, func() error {
+		return runFetch(t, "-print-plan-json", "-replace", "example.com/b=example.com/fork/b", "example.com/a")
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile("plan.json", []byte(out), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := captureStdout(t, func() error { return runFetch(t, "-apply", "plan.json") }); err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := vendor.ReadManifest(manifestFile())
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := m.GetDependencyForImportpath("example.com/b")
+	if err != nil {
+		t.Fatalf("fetch -apply: %v", err)
+	}
+	if d.Replacement != "example.com/fork/b" || d.Revision != "2222" {
+		t.Fatalf("fetch -apply: want example.com/b replaced by example.com/fork/b at 2222, got %+v", d)
+	}
+	buf, err := ioutil.ReadFile(filepath.Join("vendor", "example.com", "b", "b.go"))
+	if err != nil || string(buf) != "package b // fork\n" {
+		t.Fatalf("fetch -apply: want the fork vendored, got %q, %v", buf, err)
+	}
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/themoonbear/gvt/gbvendor"
+)
+
+var cmdFmt = &Command{
+	Name:      "fmt",
+	UsageLine: "fmt",
+	Short:     "rewrite the manifest in canonical form",
+	Long: `fmt reads the manifest and writes it back in the canonical form every gvt
+command writes it in: dependencies sorted by import path, their lists
+sorted, indented with tabs, at the latest manifest version. It normalizes
+a manifest edited by hand or written by an older gvt in a single commit.
+Nothing is fetched and the vendor directory is left untouched.
+
+`,
+	Run: func(args []string) error {
+		if len(args) != 0 {
+			return fmt.Errorf("fmt takes no arguments")
+		}
+		m, err := vendor.ReadManifest(manifestFile())
+		if err != nil {
+			return fmt.Errorf("could not load manifest: %v", err)
+		}
+		return writeManifest(m)
+	},
+}
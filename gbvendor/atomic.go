@@ -0,0 +1,82 @@
+package vendor
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/constabulary/gb/fileutils"
+)
+
+// Transaction moves a set of staged files and directories into place,
+// all of them or none. Destinations that already exist are moved aside
+// first and put back if the transaction fails.
+type Transaction struct {
+	moves []move
+
+	rename func(oldpath, newpath string) error // os.Rename, replaced by tests
+}
+
+type move struct {
+	staged, dst string
+}
+
+// Add schedules staged to be renamed to dst on Commit. staged and dst
+// must be on the same filesystem.
+func (t *Transaction) Add(staged, dst string) {
+	t.moves = append(t.moves, move{staged, dst})
+}
+
+// Commit renames every staged path into place, in the order they were
+// added. If a rename fails, the paths already renamed are moved back to
+// their staged location and the previous destinations are restored.
+func (t *Transaction) Commit() (err error) {
+	rename := t.rename
+	if rename == nil {
+		rename = os.Rename
+	}
+	var done []move    // moves applied
+	var backups []move // previous destinations, moved aside
+	defer func() {
+		if err != nil {
+			for i := len(done) - 1; i >= 0; i-- {
+				os.Rename(done[i].dst, done[i].staged)
+			}
+			for i := len(backups) - 1; i >= 0; i-- {
+				os.Rename(backups[i].staged, backups[i].dst)
+			}
+			return
+		}
+		for _, b := range backups {
+			fileutils.RemoveAll(b.staged)
+		}
+	}()
+	for _, m := range t.moves {
+		if _, err := os.Lstat(m.dst); err == nil {
+			backup := m.dst + ".gvt-old"
+			if err := rename(m.dst, backup); err != nil {
+				return err
+			}
+			backups = append(backups, move{backup, m.dst})
+		}
+		if err := rename(m.staged, m.dst); err != nil {
+			return err
+		}
+		done = append(done, m)
+	}
+	return nil
+}
+
+// Lock takes an exclusive lock on path by creating path.lock, failing if
+// another process holds it. The returned function releases the lock.
+func Lock(path string) (func() error, error) {
+	lock := path + ".lock"
+	f, err := os.OpenFile(lock, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		if os.IsExist(err) {
+			return nil, fmt.Errorf("%s is locked, remove %s if no other gvt is running", path, lock)
+		}
+		return nil, err
+	}
+	f.Close()
+	return func() error { return os.Remove(lock) }, nil
+}
@@ -0,0 +1,99 @@
+package vendor
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/constabulary/gb/fileutils"
+)
+
+func TestTransactionCommit(t *testing.T) {
+	root := mktemp(t)
+	defer fileutils.RemoveAll(root)
+
+	writeFiles(t, root, map[string]string{
+		"stage/github.com/foo/bar/bar.go": "package bar\n",
+		"stage/manifest":                  "new",
+		"vendor/manifest":                 "old",
+	})
+	if err := os.MkdirAll(filepath.Join(root, "vendor/github.com/foo"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	var tx Transaction
+	tx.Add(filepath.Join(root, "stage/github.com/foo/bar"), filepath.Join(root, "vendor/github.com/foo/bar"))
+	tx.Add(filepath.Join(root, "stage/manifest"), filepath.Join(root, "vendor/manifest"))
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	assertExists(t, filepath.Join(root, "vendor/github.com/foo/bar/bar.go"))
+	assertNotExists(t, filepath.Join(root, "vendor/manifest.gvt-old"))
+	assertContent(t, filepath.Join(root, "vendor/manifest"), "new")
+}
+
+func TestTransactionRollback(t *testing.T) {
+	root := mktemp(t)
+	defer fileutils.RemoveAll(root)
+
+	writeFiles(t, root, map[string]string{
+		"stage/github.com/foo/bar/bar.go": "package bar\n",
+		"stage/manifest":                  "new",
+		"vendor/manifest":                 "old",
+	})
+	if err := os.MkdirAll(filepath.Join(root, "vendor/github.com/foo"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	// crash once the tree is in place, before the manifest is.
+	var tx Transaction
+	tx.rename = func(oldpath, newpath string) error {
+		if filepath.Base(oldpath) == "manifest" && filepath.Base(filepath.Dir(oldpath)) == "stage" {
+			return errors.New("simulated crash")
+		}
+		return os.Rename(oldpath, newpath)
+	}
+	tx.Add(filepath.Join(root, "stage/github.com/foo/bar"), filepath.Join(root, "vendor/github.com/foo/bar"))
+	tx.Add(filepath.Join(root, "stage/manifest"), filepath.Join(root, "vendor/manifest"))
+	if err := tx.Commit(); err == nil {
+		t.Fatal("Commit: want error, got nil")
+	}
+	assertNotExists(t, filepath.Join(root, "vendor/github.com/foo/bar"))
+	assertNotExists(t, filepath.Join(root, "vendor/manifest.gvt-old"))
+	assertContent(t, filepath.Join(root, "vendor/manifest"), "old")
+	assertExists(t, filepath.Join(root, "stage/github.com/foo/bar/bar.go"))
+}
+
+func TestLock(t *testing.T) {
+	root := mktemp(t)
+	defer fileutils.RemoveAll(root)
+
+	path := filepath.Join(root, "manifest")
+	unlock, err := Lock(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Lock(path); err == nil {
+		t.Fatal("Lock: want error while locked, got nil")
+	}
+	if err := unlock(); err != nil {
+		t.Fatal(err)
+	}
+	unlock, err = Lock(path)
+	if err != nil {
+		t.Fatalf("Lock after unlock: %v", err)
+	}
+	unlock()
+}
+
+func assertContent(t *testing.T, path, want string) {
+	buf, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(buf); got != want {
+		t.Fatalf("%s: want %q, got %q", path, want, got)
+	}
+}
@@ -0,0 +1,79 @@
+package vendor
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// BranchRule pins the import paths matching Prefix to Branch.
+// A Prefix ending in "/*" matches every import path below it, otherwise
+// it matches the import path itself and its subpackages.
+type BranchRule struct {
+	Prefix string
+	Branch string
+}
+
+// BranchRules is a set of BranchRule read from a branch tracking file.
+type BranchRules []BranchRule
+
+// Lookup returns the branch of the most specific rule matching importpath.
+func (rs BranchRules) Lookup(importpath string) (string, bool) {
+	var best BranchRule
+	match := false
+	for _, r := range rs {
+		if !r.matches(importpath) {
+			continue
+		}
+		if !match || len(r.Prefix) > len(best.Prefix) {
+			best = r
+			match = true
+		}
+	}
+	return best.Branch, match
+}
+
+func (r BranchRule) matches(importpath string) bool {
+	if strings.HasSuffix(r.Prefix, "/*") {
+		return strings.HasPrefix(importpath, strings.TrimSuffix(r.Prefix, "*"))
+	}
+	return importpath == r.Prefix || strings.HasPrefix(importpath, r.Prefix+"/")
+}
+
+// ReadBranchRules reads a branch tracking file from path. Each non blank
+// line has the form
+//
+//	golang.org/x/* -> release-branch.go1.21
+//
+// and lines starting with # are ignored.
+func ReadBranchRules(path string) (BranchRules, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return readBranchRules(f)
+}
+
+func readBranchRules(r io.Reader) (BranchRules, error) {
+	var rs BranchRules
+	s := bufio.NewScanner(r)
+	for n := 1; s.Scan(); n++ {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		parts := strings.SplitN(line, "->", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("line %d: expected \"prefix -> branch\", got %q", n, line)
+		}
+		prefix, branch := strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1])
+		if prefix == "" || branch == "" {
+			return nil, fmt.Errorf("line %d: prefix and branch must not be empty", n)
+		}
+		rs = append(rs, BranchRule{Prefix: prefix, Branch: branch})
+	}
+	return rs, s.Err()
+}
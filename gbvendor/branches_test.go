@@ -0,0 +1,54 @@
+package vendor
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestReadBranchRules(t *testing.T) {
+	const file = `
+# release branches for the x repos
+golang.org/x/* -> release-branch.go1.21
+golang.org/x/net -> master
+github.com/foo/bar->develop
+`
+	rs, err := readBranchRules(strings.NewReader(file))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rs) != 3 {
+		t.Fatalf("readBranchRules: want 3 rules, got %d", len(rs))
+	}
+
+	if _, err := readBranchRules(strings.NewReader("github.com/foo/bar develop\n")); err == nil {
+		t.Fatalf("readBranchRules: expected error for a line without ->")
+	}
+}
+
+func TestBranchRulesLookup(t *testing.T) {
+	rs := BranchRules{
+		{Prefix: "golang.org/x/*", Branch: "release-branch.go1.21"},
+		{Prefix: "golang.org/x/net", Branch: "master"},
+		{Prefix: "github.com/foo/bar", Branch: "develop"},
+	}
+	tests := []struct {
+		importpath string
+		branch     string
+		ok         bool
+	}{
+		{"golang.org/x/tools/go/vcs", "release-branch.go1.21", true},
+		{"golang.org/x/net", "master", true},
+		{"golang.org/x/net/context", "master", true},
+		{"golang.org/x/network", "release-branch.go1.21", true},
+		{"golang.org/x", "", false},
+		{"github.com/foo/bar/baz", "develop", true},
+		{"github.com/foo/barn", "", false},
+		{"github.com/quux/flobble", "", false},
+	}
+	for _, tt := range tests {
+		branch, ok := rs.Lookup(tt.importpath)
+		if branch != tt.branch || ok != tt.ok {
+			t.Errorf("Lookup(%q): want %q, %v, got %q, %v", tt.importpath, tt.branch, tt.ok, branch, ok)
+		}
+	}
+}
@@ -0,0 +1,218 @@
+package vendor
+
+import (
+	"bufio"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/constabulary/gb/fileutils"
+)
+
+// includeRe matches the local, quoted, #include directives of C sources.
+var includeRe = regexp.MustCompile(`^\s*#\s*include\s+"([^"]+)"`)
+
+// CgoFiles returns the set of files below root referenced, directly or
+// through other headers, by the quoted #include directives of the cgo
+// preambles and of the C sources of the cgo packages below root, as
+// slash separated paths relative to root. Includes are looked up in the
+// directory of the including file, then in the -I directories of the
+// #cgo directives. Files outside root are ignored, and so are the .go
+// files which do not parse, with a warning.
+func CgoFiles(root string) (map[string]bool, error) {
+	files := make(map[string]bool)
+	fset := token.NewFileSet()
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		name := info.Name()
+		if info.IsDir() {
+			if path != root && (strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_") || name == "testdata") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if filepath.Ext(name) != ".go" {
+			return nil
+		}
+		f, err := parser.ParseFile(fset, path, nil, parser.ImportsOnly|parser.ParseComments)
+		if err != nil {
+			// such as templates or deliberately broken test inputs, which
+			// are no cgo packages either.
+			Logf("warning: skipping %s for cgo files: %v", path, err)
+			return nil
+		}
+		preamble, ok := cgoPreamble(f)
+		if !ok {
+			return nil
+		}
+		dir := filepath.Dir(path)
+		incdirs := cgoIncludeDirs(dir, preamble)
+		for _, inc := range includes(preamble) {
+			if err := addInclude(root, dir, inc, incdirs, files); err != nil {
+				return err
+			}
+		}
+		// the C sources of the package may include headers from elsewhere.
+		sources, err := filepath.Glob(filepath.Join(dir, "*"))
+		if err != nil {
+			return err
+		}
+		for _, src := range sources {
+			if ext := filepath.Ext(src); ext != ".go" && buildExts[ext] {
+				if err := scanIncludes(root, src, incdirs, files); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	return files, err
+}
+
+// RestoreCgoFiles copies back from src to dst the files returned by
+// CgoFiles for src which are missing from dst, because they were stripped,
+// trimmed or hidden. It returns the slash separated paths of the files
+// copied.
+func RestoreCgoFiles(dst, src string) ([]string, error) {
+	cgo, err := CgoFiles(src)
+	if err != nil {
+		return nil, err
+	}
+	var restored []string
+	for rel := range cgo {
+		path := filepath.Join(dst, filepath.FromSlash(rel))
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			continue
+		}
+		if err := fileutils.Copyfile(path, filepath.Join(src, filepath.FromSlash(rel))); err != nil {
+			return nil, err
+		}
+		restored = append(restored, rel)
+	}
+	return restored, nil
+}
+
+// cgoPreamble returns the comment preceding the import "C" of f.
+func cgoPreamble(f *ast.File) (string, bool) {
+	for _, decl := range f.Decls {
+		gd, ok := decl.(*ast.GenDecl)
+		if !ok || gd.Tok != token.IMPORT {
+			continue
+		}
+		for _, spec := range gd.Specs {
+			is := spec.(*ast.ImportSpec)
+			if p, _ := strconv.Unquote(is.Path.Value); p != "C" {
+				continue
+			}
+			doc := is.Doc
+			if doc == nil && len(gd.Specs) == 1 {
+				doc = gd.Doc
+			}
+			if doc == nil {
+				return "", true
+			}
+			return doc.Text(), true
+		}
+	}
+	return "", false
+}
+
+// cgoIncludeDirs returns the -I directories of the #cgo directives of
+// preamble, ${SRCDIR} and relative directories being resolved against dir.
+func cgoIncludeDirs(dir, preamble string) []string {
+	var dirs []string
+	for _, line := range strings.Split(preamble, "\n") {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, "#cgo ") {
+			continue
+		}
+		i := strings.Index(line, ":")
+		if i < 0 {
+			continue
+		}
+		args := strings.Fields(line[i+1:])
+		for j, arg := range args {
+			var d string
+			switch {
+			case arg == "-I" && j+1 < len(args):
+				d = args[j+1]
+			case strings.HasPrefix(arg, "-I") && arg != "-I":
+				d = arg[2:]
+			default:
+				continue
+			}
+			d = filepath.FromSlash(strings.Replace(d, "${SRCDIR}", dir, -1))
+			if !filepath.IsAbs(d) {
+				d = filepath.Join(dir, d)
+			}
+			dirs = append(dirs, filepath.Clean(d))
+		}
+	}
+	return dirs
+}
+
+// includes returns the quoted #include directives of text.
+func includes(text string) []string {
+	var incs []string
+	for _, line := range strings.Split(text, "\n") {
+		if m := includeRe.FindStringSubmatch(line); m != nil {
+			incs = append(incs, m[1])
+		}
+	}
+	return incs
+}
+
+// addInclude adds to files the file below root included as inc from dir,
+// and the files it includes in turn.
+func addInclude(root, dir, inc string, incdirs []string, files map[string]bool) error {
+	for _, d := range append([]string{dir}, incdirs...) {
+		path := filepath.Join(d, filepath.FromSlash(inc))
+		fi, err := os.Stat(path)
+		if err != nil || !fi.Mode().IsRegular() {
+			continue
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return nil
+		}
+		rel = filepath.ToSlash(rel)
+		if files[rel] {
+			return nil
+		}
+		files[rel] = true
+		return scanIncludes(root, path, incdirs, files)
+	}
+	return nil
+}
+
+// scanIncludes adds to files the files below root included by path.
+func scanIncludes(root, path string, incdirs []string, files map[string]bool) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	var incs []string
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		if m := includeRe.FindStringSubmatch(s.Text()); m != nil {
+			incs = append(incs, m[1])
+		}
+	}
+	if err := s.Err(); err != nil {
+		return err
+	}
+	for _, inc := range incs {
+		if err := addInclude(root, filepath.Dir(path), inc, incdirs, files); err != nil {
+			return err
+		}
+	}
+	return nil
+}
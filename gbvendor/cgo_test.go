@@ -0,0 +1,95 @@
+package vendor
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/constabulary/gb/fileutils"
+)
+
+func TestCgoFiles(t *testing.T) {
+	root := mktemp(t)
+	defer fileutils.RemoveAll(root)
+
+	writeFiles(t, root, map[string]string{
+		"go.mod":             "module example.com/dep\n\ngo 1.16\n",
+		"dep.go":             "package dep\n\nimport \"example.com/dep/binding\"\n\nvar X = binding.Add(1, 2)\n",
+		"binding/binding.go": "package binding\n\n// #cgo CFLAGS: -I${SRCDIR}/../include\n// #include \"local.h\"\n// #include \"add.h\"\n// #include <stdlib.h>\nimport \"C\"\n\nfunc Add(a, b int) int { return int(C.add(C.int(a), C.int(b))) }\n",
+		"binding/local.h":    "#define LOCAL 1\n",
+		"binding/add.c":      "#include \"add.h\"\n\nint add(int a, int b) { return a + b + ADD_BIAS; }\n",
+		"include/add.h":      "#include \"bias.h\"\n\nint add(int, int);\n",
+		"include/bias.h":     "#define ADD_BIAS 0\n",
+		"include/unused.h":   "",
+		"pure/pure.go":       "package pure\n\n// #include \"ignored.h\"\nimport \"fmt\"\n\nvar _ = fmt.Sprint\n",
+		"pure/ignored.h":     "",
+	})
+
+	got, err := CgoFiles(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for name := range got {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	if want := []string{"binding/local.h", "include/add.h", "include/bias.h"}; !reflect.DeepEqual(names, want) {
+		t.Fatalf("CgoFiles: want %v, got %v", want, names)
+	}
+
+	// only binding is kept, trimming the headers the build needs.
+	dst := mktemp(t)
+	defer fileutils.RemoveAll(dst)
+	if err := Copytree(dst, root, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := TrimPackages(dst, []string{".", "binding"}); err != nil {
+		t.Fatal(err)
+	}
+	assertNotExists(t, filepath.Join(dst, "include"))
+	restored, err := RestoreCgoFiles(dst, root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(restored)
+	if want := []string{"include/add.h", "include/bias.h"}; !reflect.DeepEqual(restored, want) {
+		t.Fatalf("RestoreCgoFiles: want %v, got %v", want, restored)
+	}
+	assertNotExists(t, filepath.Join(dst, "include", "unused.h"))
+
+	if _, err := exec.LookPath("cc"); err != nil {
+		t.Skip("no C compiler")
+	}
+	writeFiles(t, dst, map[string]string{"go.mod": "module example.com/dep\n\ngo 1.16\n"})
+	cmd := exec.Command("go", "build", "./...")
+	cmd.Dir = dst
+	cmd.Env = append(os.Environ(), "GO111MODULE=on", "GOFLAGS=-mod=mod", "GOPROXY=off", "CGO_ENABLED=1")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("go build after RestoreCgoFiles failed: %v\n%s", err, out)
+	}
+}
+
+func TestCgoFilesUnparsable(t *testing.T) {
+	root := mktemp(t)
+	defer fileutils.RemoveAll(root)
+
+	writeFiles(t, root, map[string]string{
+		"binding/binding.go": "package binding\n\n// #include \"local.h\"\nimport \"C\"\n",
+		"binding/local.h":    "",
+		"tmpl/tmpl.go":       "package {{.Name}}\n\n// #include \"tmpl.h\"\nimport \"C\"\n",
+		"tmpl/tmpl.h":        "",
+	})
+
+	// the template is skipped, the cgo files of the others still found.
+	got, err := CgoFiles(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]bool{"binding/local.h": true}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("CgoFiles: want %v, got %v", want, got)
+	}
+}
@@ -0,0 +1,266 @@
+package vendor
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// TreeChecksum returns the SHA-256 of the files below root that Copytree
+// copies, in the form sha256:hex. It covers the content and the slash
+// separated relative path of every file. The directories of nested, slash
+// separated paths relative to root, are left out: they are the trees of
+// other dependencies vendored inside this one. So are the trees listed in
+// the vendor/manifest of root, which restore fetches separately.
+func TreeChecksum(root string, nested []string) (string, error) {
+	sums, err := treeSums(root, nested)
+	if err != nil {
+		return "", err
+	}
+	return summarize(sums), nil
+}
+
+// TreeStats returns the number and total size in bytes of the files
+// covered by TreeChecksum.
+func TreeStats(root string, nested []string) (int, int64, error) {
+	nested, err := treeNested(root, nested)
+	if err != nil {
+		return 0, 0, err
+	}
+	skip := make(map[string]bool)
+	for _, n := range nested {
+		skip[n] = true
+	}
+	var files int
+	var size int64
+	err = filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if path == root {
+			return nil
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		if strings.HasPrefix(info.Name(), ".") || (info.IsDir() && skip[filepath.ToSlash(rel)]) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if info.Mode().IsRegular() {
+			files++
+			size += info.Size()
+		}
+		return nil
+	})
+	return files, size, err
+}
+
+// File changes reported by DiffTrees.
+const (
+	FileModified = "modified"
+	FileAdded    = "added"
+	FileRemoved  = "removed"
+)
+
+// FileChange is a file differing between two trees.
+type FileChange struct {
+	Path   string `json:"path"` // slash separated, relative to the tree
+	Change string `json:"change"`
+}
+
+// DiffTrees returns the files covered by TreeChecksum which differ between
+// the trees at want and got, sorted by path: those modified in got, added
+// to got and removed from got.
+func DiffTrees(want, got string, nested []string) ([]FileChange, error) {
+	wsums, err := treeSums(want, nested)
+	if err != nil {
+		return nil, err
+	}
+	gsums, err := treeSums(got, nested)
+	if err != nil {
+		return nil, err
+	}
+	return diffSums(wsums, gsums), nil
+}
+
+// diffSums returns the files of two fileSums differing, sorted by path.
+func diffSums(wsums, gsums map[string]string) []FileChange {
+	var changes []FileChange
+	for path, sum := range gsums {
+		switch w, ok := wsums[path]; {
+		case !ok:
+			changes = append(changes, FileChange{path, FileAdded})
+		case w != sum:
+			changes = append(changes, FileChange{path, FileModified})
+		}
+	}
+	for path := range wsums {
+		if _, ok := gsums[path]; !ok {
+			changes = append(changes, FileChange{path, FileRemoved})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Path < changes[j].Path })
+	return changes
+}
+
+// treeSums returns the fileSums of root, leaving out the trees of nested
+// and of the vendor/manifest of root.
+func treeSums(root string, nested []string) (map[string]string, error) {
+	nested, err := treeNested(root, nested)
+	if err != nil {
+		return nil, err
+	}
+	return fileSums(root, nested)
+}
+
+// treeNested returns nested along with the trees listed in the
+// vendor/manifest of root.
+func treeNested(root string, nested []string) ([]string, error) {
+	if _, err := os.Stat(filepath.Join(root, "vendor", "manifest")); err == nil {
+		m, err := ReadVendoredManifest(filepath.Join(root, "vendor", "manifest"))
+		if err != nil {
+			return nil, err
+		}
+		for _, d := range m.Dependencies {
+			nested = append(nested, "vendor/"+d.Importpath)
+		}
+	}
+	return nested, nil
+}
+
+// fileSums returns the hex SHA-256 of every file below root, by slash
+// separated relative path.
+func fileSums(root string, nested []string) (map[string]string, error) {
+	skip := make(map[string]bool)
+	for _, n := range nested {
+		skip[n] = true
+	}
+	sums := make(map[string]string)
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if path == root {
+			return nil
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if strings.HasPrefix(info.Name(), ".") || (info.IsDir() && skip[rel]) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		h := sha256.New()
+		if _, err := io.Copy(h, f); err != nil {
+			return err
+		}
+		sums[rel] = fmt.Sprintf("%x", h.Sum(nil))
+		return nil
+	})
+	return sums, err
+}
+
+func summarize(sums map[string]string) string {
+	paths := make([]string, 0, len(sums))
+	for path := range sums {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	h := sha256.New()
+	for _, path := range paths {
+		fmt.Fprintf(h, "%s  %s\n", sums[path], path)
+	}
+	return fmt.Sprintf("sha256:%x", h.Sum(nil))
+}
+
+// Nested returns the import paths of the dependencies of m vendored
+// inside the tree of dep, relative to dep.
+func (m *Manifest) Nested(dep Dependency) []string {
+	var nested []string
+	for _, d := range m.Dependencies {
+		if strings.HasPrefix(d.Importpath, dep.Importpath+"/") {
+			nested = append(nested, d.Importpath[len(dep.Importpath)+1:])
+		}
+	}
+	return nested
+}
+
+// VerifyChecksums recomputes the checksum of the trees vendored in
+// vendorDir of deps, dependencies of m, and returns the import paths
+// whose tree differs from the recorded Checksum, and those without a
+// recorded Checksum, which cannot be verified.
+func VerifyChecksums(vendorDir string, m *Manifest, deps []Dependency) (mismatched, unverifiable []string, err error) {
+	return VerifyChecksumsParallel(vendorDir, m, deps, 1)
+}
+
+// VerifyChecksumsParallel is like VerifyChecksums but hashes up to j trees
+// at once. Both lists are sorted, whatever the order the trees are hashed
+// in. The error returned, if any, is that of the first dependency failing
+// in the order of deps.
+func VerifyChecksumsParallel(vendorDir string, m *Manifest, deps []Dependency, j int) (mismatched, unverifiable []string, err error) {
+	if j < 1 {
+		j = 1
+	}
+	type result struct {
+		sum string
+		err error
+	}
+	results := make([]result, len(deps))
+	work := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < j; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range work {
+				d := deps[i]
+				results[i].sum, results[i].err = TreeChecksum(filepath.Join(vendorDir, filepath.FromSlash(d.Importpath)), m.Nested(d))
+			}
+		}()
+	}
+	for i, d := range deps {
+		if d.Checksum == "" {
+			unverifiable = append(unverifiable, d.Importpath)
+			continue
+		}
+		work <- i
+	}
+	close(work)
+	wg.Wait()
+
+	for i, d := range deps {
+		if d.Checksum == "" {
+			continue
+		}
+		if results[i].err != nil {
+			return nil, nil, results[i].err
+		}
+		if results[i].sum != d.Checksum {
+			mismatched = append(mismatched, d.Importpath)
+		}
+	}
+	sort.Strings(mismatched)
+	sort.Strings(unverifiable)
+	return mismatched, unverifiable, nil
+}
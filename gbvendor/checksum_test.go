@@ -0,0 +1,217 @@
+package vendor
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/constabulary/gb/fileutils"
+)
+
+func TestTreeChecksum(t *testing.T) {
+	root := mktemp(t)
+	defer fileutils.RemoveAll(root)
+
+	writeFiles(t, root, map[string]string{
+		"a/a.go":            "package a\n",
+		"a/b/b.go":          "package b\n",
+		"a/.git/HEAD":       "ref: refs/heads/master\n",
+		"a/sub/s.go":        "package sub\n",
+		"a/vendor/manifest": `{"version": 99, "dependencies": [{"importpath": "example.com/n"}]}`,
+	})
+	// the nested manifest is that of the dependency, whatever its version.
+	dir := filepath.Join(root, "a")
+	sum, err := TreeChecksum(dir, []string{"sub"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// hidden files and nested dependencies, restored from vendor/manifest
+	// or not, are not covered.
+	writeFiles(t, root, map[string]string{
+		"a/.git/HEAD":                 "ref: refs/heads/other\n",
+		"a/sub/s.go":                  "package sub // changed\n",
+		"a/vendor/example.com/n/n.go": "package n\n",
+	})
+	if got, err := TreeChecksum(dir, []string{"sub"}); err != nil || got != sum {
+		t.Fatalf("TreeChecksum: want %s, got %s, %v", sum, got, err)
+	}
+
+	for name, files := range map[string]map[string]string{
+		"content": {"a/b/b.go": "package b // changed\n"},
+		"added":   {"a/c.go": "package a\n"},
+	} {
+		writeFiles(t, root, files)
+		got, err := TreeChecksum(dir, []string{"sub"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got == sum {
+			t.Errorf("TreeChecksum: %s file not detected", name)
+		}
+		sum = got
+	}
+}
+
+func TestVerifyChecksums(t *testing.T) {
+	root := mktemp(t)
+	defer fileutils.RemoveAll(root)
+
+	writeFiles(t, root, map[string]string{
+		"example.com/a/a.go": "package a\n",
+		"example.com/b/b.go": "package b\n",
+		"example.com/c/c.go": "package c\n",
+	})
+	m := &Manifest{}
+	for _, path := range []string{"example.com/a", "example.com/b"} {
+		sum, err := TreeChecksum(filepath.Join(root, path), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		m.Dependencies = append(m.Dependencies, Dependency{Importpath: path, Checksum: sum})
+	}
+	m.Dependencies = append(m.Dependencies, Dependency{Importpath: "example.com/c"})
+
+	// tamper with the tree of b after it was vendored.
+	if err := ioutil.WriteFile(filepath.Join(root, "example.com/b/b.go"), []byte("package b // hacked\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	mismatched, unverifiable, err := VerifyChecksums(root, m, m.Dependencies)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"example.com/b"}; !reflect.DeepEqual(mismatched, want) {
+		t.Errorf("VerifyChecksums: want mismatched %v, got %v", want, mismatched)
+	}
+	if want := []string{"example.com/c"}; !reflect.DeepEqual(unverifiable, want) {
+		t.Errorf("VerifyChecksums: want unverifiable %v, got %v", want, unverifiable)
+	}
+}
+
+func TestVerifyChecksumsParallel(t *testing.T) {
+	root := mktemp(t)
+	defer fileutils.RemoveAll(root)
+
+	m := &Manifest{}
+	var wantMismatched, wantUnverifiable []string
+	for i := 0; i < 40; i++ {
+		path := fmt.Sprintf("example.com/dep%02d", i)
+		writeFiles(t, root, map[string]string{path + "/dep.go": "package dep\n", path + "/sub/sub.go": "package sub\n"})
+		d := Dependency{Importpath: path}
+		switch i % 4 {
+		case 0:
+			d.Checksum = "sha256:0000"
+			wantMismatched = append(wantMismatched, path)
+		case 1:
+			wantUnverifiable = append(wantUnverifiable, path)
+		default:
+			sum, err := TreeChecksum(filepath.Join(root, path), nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			d.Checksum = sum
+		}
+		m.Dependencies = append(m.Dependencies, d)
+	}
+	// the report must not depend on the order of deps nor on scheduling.
+	deps := make([]Dependency, len(m.Dependencies))
+	for i, d := range m.Dependencies {
+		deps[len(deps)-1-i] = d
+	}
+
+	for _, j := range []int{1, 3, 16} {
+		for run := 0; run < 5; run++ {
+			mismatched, unverifiable, err := VerifyChecksumsParallel(root, m, deps, j)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(mismatched, wantMismatched) {
+				t.Fatalf("VerifyChecksumsParallel(-j %d): want mismatched %v, got %v", j, wantMismatched, mismatched)
+			}
+			if !reflect.DeepEqual(unverifiable, wantUnverifiable) {
+				t.Fatalf("VerifyChecksumsParallel(-j %d): want unverifiable %v, got %v", j, wantUnverifiable, unverifiable)
+			}
+		}
+	}
+
+	deps = append(deps, Dependency{Importpath: "example.com/gone", Checksum: "sha256:0000"})
+	if _, _, err := VerifyChecksumsParallel(root, m, deps, 4); err == nil {
+		t.Fatal("VerifyChecksumsParallel of a missing tree: want an error")
+	}
+}
+
+func TestDiffTrees(t *testing.T) {
+	want := mktemp(t)
+	defer fileutils.RemoveAll(want)
+	got := mktemp(t)
+	defer fileutils.RemoveAll(got)
+
+	writeFiles(t, want, map[string]string{
+		"a.go":            "package a\n",
+		"b.go":            "package a\n",
+		"sub/c.go":        "package sub\n",
+		"nested/dep/d.go": "package dep\n",
+	})
+	writeFiles(t, got, map[string]string{
+		"a.go":            "package a\n",
+		"b.go":            "package a // hand edited\n",
+		"sub/new.go":      "package sub\n",
+		"nested/dep/d.go": "package dep // another dependency\n",
+		".hidden":         "",
+	})
+
+	changes, err := DiffTrees(want, got, []string{"nested/dep"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantChanges := []FileChange{
+		{"b.go", FileModified},
+		{"sub/c.go", FileRemoved},
+		{"sub/new.go", FileAdded},
+	}
+	if !reflect.DeepEqual(changes, wantChanges) {
+		t.Fatalf("DiffTrees: want %v, got %v", wantChanges, changes)
+	}
+}
+
+func TestTreeStats(t *testing.T) {
+	root := mktemp(t)
+	defer fileutils.RemoveAll(root)
+	writeFiles(t, root, map[string]string{
+		"a.go":            "package a\n",
+		"sub/b.go":        "package sub\n",
+		"nested/dep/d.go": "package dep\n",
+		".hidden":         "hidden",
+	})
+
+	files, size, err := TreeStats(root, []string{"nested/dep"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := int64(len("package a\n") + len("package sub\n")); files != 2 || size != want {
+		t.Fatalf("TreeStats: want 2 files and %d bytes, got %d and %d", want, files, size)
+	}
+}
+
+func TestChecksumRoundTrip(t *testing.T) {
+	root := mktemp(t)
+	defer fileutils.RemoveAll(root)
+
+	file := filepath.Join(root, "manifest")
+	m := &Manifest{Dependencies: []Dependency{
+		{Importpath: "example.com/a", Repository: "https://example.com/a", Revision: "1", Checksum: "sha256:abcd"},
+		{Importpath: "example.com/old", Repository: "https://example.com/old", Revision: "2"},
+	}}
+	if err := WriteManifest(file, m); err != nil {
+		t.Fatal(err)
+	}
+	got, err := ReadManifest(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Dependencies[0].Checksum != "sha256:abcd" || got.Dependencies[1].Checksum != "" {
+		t.Fatalf("round trip: want the checksums kept, got %+v", got.Dependencies)
+	}
+}
@@ -0,0 +1,41 @@
+package vendor
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// CheckCleanWorkTree fails if the git working tree holding dir has
+// changes not committed, untracked files included.
+func CheckCleanWorkTree(dir string) error {
+	out, err := run("git", "-C", dir, "status", "--porcelain")
+	if err != nil {
+		return fmt.Errorf("%s is not in a git working tree: %v", dir, err)
+	}
+	if len(bytes.TrimSpace(out)) > 0 {
+		return fmt.Errorf("the git working tree of %s has uncommitted changes", dir)
+	}
+	return nil
+}
+
+// CommitPaths stages every change below paths, removed files included, in
+// the git working tree holding dir and commits them, and only them, with
+// msg. It reports false without committing if nothing changed.
+func CommitPaths(dir, msg string, paths ...string) (bool, error) {
+	args := append([]string{"-C", dir, "add", "-A", "--"}, paths...)
+	if _, err := run("git", args...); err != nil {
+		return false, err
+	}
+	out, err := run("git", append([]string{"-C", dir, "status", "--porcelain", "--"}, paths...)...)
+	if err != nil {
+		return false, err
+	}
+	if len(bytes.TrimSpace(out)) == 0 {
+		return false, nil
+	}
+	args = append([]string{"-C", dir, "commit", "-q", "-m", msg, "--"}, paths...)
+	if _, err := run("git", args...); err != nil {
+		return false, err
+	}
+	return true, nil
+}
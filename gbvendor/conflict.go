@@ -0,0 +1,49 @@
+package vendor
+
+import "sort"
+
+// RevisionRequest is a revision of an import path asked for by a
+// dependency, or by the user.
+type RevisionRequest struct {
+	By       string // who asked for it
+	Revision string
+}
+
+// RevisionRequests collects, by import path, the revisions the
+// dependencies of a fetch were asked for.
+type RevisionRequests map[string][]RevisionRequest
+
+// Add records that by asked for importpath at revision.
+func (r RevisionRequests) Add(importpath, by, revision string) {
+	r[importpath] = append(r[importpath], RevisionRequest{By: by, Revision: revision})
+}
+
+// AddManifest records the revisions pinned by the manifest of the
+// dependency by, such as the vendor/manifest of its own tree.
+func (r RevisionRequests) AddManifest(by string, m *Manifest) {
+	for _, d := range m.Dependencies {
+		r.Add(d.Importpath, by, d.Revision)
+	}
+}
+
+// RevisionConflict is an import path asked for at different revisions.
+type RevisionConflict struct {
+	Importpath string
+	Requests   []RevisionRequest // in the order they were made
+}
+
+// Conflicts returns the import paths asked for at more than one revision,
+// sorted by import path.
+func (r RevisionRequests) Conflicts() []RevisionConflict {
+	var conflicts []RevisionConflict
+	for path, reqs := range r {
+		for _, req := range reqs[1:] {
+			if req.Revision != reqs[0].Revision {
+				conflicts = append(conflicts, RevisionConflict{Importpath: path, Requests: reqs})
+				break
+			}
+		}
+	}
+	sort.Slice(conflicts, func(i, j int) bool { return conflicts[i].Importpath < conflicts[j].Importpath })
+	return conflicts
+}
@@ -0,0 +1,35 @@
+package vendor
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRevisionConflicts(t *testing.T) {
+	// a and b both depend on x and y, pinning x at different revisions.
+	r := RevisionRequests{}
+	r.Add("github.com/a/a", "the command line", "aaaa")
+	r.AddManifest("github.com/a/a", &Manifest{Dependencies: []Dependency{
+		{Importpath: "github.com/x/x", Revision: "1111"},
+		{Importpath: "github.com/y/y", Revision: "yyyy"},
+	}})
+	r.Add("github.com/b/b", "the command line", "bbbb")
+	r.AddManifest("github.com/b/b", &Manifest{Dependencies: []Dependency{
+		{Importpath: "github.com/x/x", Revision: "2222"},
+		{Importpath: "github.com/y/y", Revision: "yyyy"},
+	}})
+	r.Add("github.com/x/x", "github.com/a/a", "1111")
+	r.Add("github.com/y/y", "github.com/a/a", "yyyy")
+
+	want := []RevisionConflict{{
+		Importpath: "github.com/x/x",
+		Requests: []RevisionRequest{
+			{By: "github.com/a/a", Revision: "1111"},
+			{By: "github.com/b/b", Revision: "2222"},
+			{By: "github.com/a/a", Revision: "1111"},
+		},
+	}}
+	if got := r.Conflicts(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("Conflicts: want %+v, got %+v", want, got)
+	}
+}
@@ -0,0 +1,113 @@
+package vendor
+
+import (
+	"go/build/constraint"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// knownOS and knownArch mirror the lists go/build uses to interpret
+// file name suffixes like _linux_amd64.go.
+var (
+	knownOS = newSet("aix", "android", "darwin", "dragonfly", "freebsd", "hurd", "illumos", "ios", "js",
+		"linux", "nacl", "netbsd", "openbsd", "plan9", "solaris", "wasip1", "windows", "zos")
+	knownArch = newSet("386", "amd64", "amd64p32", "arm", "armbe", "arm64", "arm64be", "loong64", "mips",
+		"mipsle", "mips64", "mips64le", "mips64p32", "mips64p32le", "ppc", "ppc64", "ppc64le",
+		"riscv", "riscv64", "s390", "s390x", "sparc", "sparc64", "wasm")
+)
+
+func newSet(args ...string) map[string]bool {
+	r := make(map[string]bool)
+	for _, a := range args {
+		r[a] = true
+	}
+	return r
+}
+
+// BuildConstraints returns the sorted set of build tags, GOOS and GOARCH
+// values that constrain the non test Go files below root, either through
+// their file name or through //go:build and // +build lines.
+func BuildConstraints(root string) ([]string, error) {
+	tags := make(map[string]bool)
+	fset := token.NewFileSet()
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		name := info.Name()
+		if info.IsDir() {
+			if path != root && (strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_") || name == "testdata") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if filepath.Ext(name) != ".go" || strings.HasSuffix(name, "_test.go") {
+			return nil
+		}
+		for _, tag := range fileNameTags(name) {
+			tags[tag] = true
+		}
+
+		f, err := parser.ParseFile(fset, path, nil, parser.PackageClauseOnly|parser.ParseComments)
+		if err != nil {
+			return err
+		}
+		for _, cg := range f.Comments {
+			if cg.Pos() > f.Package {
+				break
+			}
+			for _, c := range cg.List {
+				if !constraint.IsGoBuild(c.Text) && !constraint.IsPlusBuild(c.Text) {
+					continue
+				}
+				expr, err := constraint.Parse(c.Text)
+				if err != nil {
+					continue
+				}
+				exprTags(expr, tags)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	var r []string
+	for tag := range tags {
+		r = append(r, tag)
+	}
+	sort.Strings(r)
+	return r, nil
+}
+
+// fileNameTags returns the GOOS and GOARCH implied by a file name.
+func fileNameTags(name string) []string {
+	l := strings.Split(strings.TrimSuffix(name, ".go"), "_")
+	n := len(l)
+	if n >= 3 && knownOS[l[n-2]] && knownArch[l[n-1]] {
+		return []string{l[n-2], l[n-1]}
+	}
+	if n >= 2 && (knownOS[l[n-1]] || knownArch[l[n-1]]) {
+		return []string{l[n-1]}
+	}
+	return nil
+}
+
+func exprTags(x constraint.Expr, tags map[string]bool) {
+	switch x := x.(type) {
+	case *constraint.TagExpr:
+		tags[x.Tag] = true
+	case *constraint.NotExpr:
+		exprTags(x.X, tags)
+	case *constraint.AndExpr:
+		exprTags(x.X, tags)
+		exprTags(x.Y, tags)
+	case *constraint.OrExpr:
+		exprTags(x.X, tags)
+		exprTags(x.Y, tags)
+	}
+}
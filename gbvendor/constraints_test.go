@@ -0,0 +1,34 @@
+package vendor
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/constabulary/gb/fileutils"
+)
+
+func TestBuildConstraints(t *testing.T) {
+	root := mktemp(t)
+	defer fileutils.RemoveAll(root)
+
+	writeFiles(t, root, map[string]string{
+		"foo.go":               "package foo\n",
+		"foo_linux.go":         "package foo\n",
+		"foo_windows_amd64.go": "package foo\n",
+		"foo_arm64.go":         "package foo\n",
+		"tagged.go":            "// Copyright 2016\n\n//go:build appengine && !cgo\n// +build appengine,!cgo\n\npackage foo\n",
+		"legacy.go":            "// +build ignore\n\npackage main\n",
+		"notheader.go":         "package foo\n\n//go:build never\n",
+		"foo_test.go":          "//go:build integration\n\npackage foo\n",
+		"testdata/x_plan9.go":  "package x\n",
+	})
+
+	got, err := BuildConstraints(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"amd64", "appengine", "arm64", "cgo", "ignore", "linux", "windows"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("BuildConstraints: want %v, got %v", want, got)
+	}
+}
@@ -0,0 +1,220 @@
+package vendor
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/constabulary/gb/fileutils"
+)
+
+// Copytree copies the contents of src to dst like fileutils.Copypath,
+// additionally leaving out every file for which skip returns true.
+// skip is passed the slash separated path of the file relative to src.
+// If skip is nil every file is copied. Files already in dst are kept,
+// unless they are overwritten by a file of src.
+func Copytree(dst, src string, skip func(rel string, info os.FileInfo) bool) error {
+	_, statErr := os.Stat(dst)
+	err := filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if strings.HasPrefix(filepath.Base(path), ".") {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if info.IsDir() || info.Mode()&os.ModeSymlink != 0 {
+			return nil
+		}
+
+		rel := path[len(src):]
+		if skip != nil && skip(filepath.ToSlash(strings.TrimPrefix(rel, string(filepath.Separator))), info) {
+			return nil
+		}
+		return fileutils.Copyfile(filepath.Join(dst, rel), path)
+	})
+	if err != nil && os.IsNotExist(statErr) {
+		// if there was an error during copying, remove the partial copy,
+		// unless it was mixed with existing files.
+		fileutils.RemoveAll(dst)
+	}
+	return err
+}
+
+// SkipFiles returns a Copytree skip function leaving out the listed files.
+func SkipFiles(files []string) func(string, os.FileInfo) bool {
+	skip := make(map[string]bool)
+	for _, f := range files {
+		skip[f] = true
+	}
+	return func(rel string, _ os.FileInfo) bool {
+		return skip[rel]
+	}
+}
+
+// SkipTests returns a Copytree skip function leaving out the _test.go
+// files and the files of testdata directories, along with those skip, if
+// not nil, leaves out. Packages build the same without them.
+func SkipTests(skip func(string, os.FileInfo) bool) func(string, os.FileInfo) bool {
+	return func(rel string, info os.FileInfo) bool {
+		if strings.HasSuffix(rel, "_test.go") {
+			return true
+		}
+		for _, e := range strings.Split(path.Dir(rel), "/") {
+			if e == "testdata" {
+				return true
+			}
+		}
+		return skip != nil && skip(rel, info)
+	}
+}
+
+// sniffLen is how much of a file is inspected to tell text from binary.
+const sniffLen = 8000
+
+// BinaryFiles returns the slash separated paths, relative to root, of the
+// binary files larger than minSize that are not needed to build or embedded
+// by a Go package. A file is binary if its first bytes contain a NUL byte.
+func BinaryFiles(root string, minSize int64) ([]string, error) {
+	embedded, err := EmbeddedFiles(root)
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	err = filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if strings.HasPrefix(info.Name(), ".") && path != root {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !info.Mode().IsRegular() || info.Size() <= minSize {
+			return nil
+		}
+		if IsBuildFile(info.Name()) || IsLicenseFile(info.Name()) {
+			return nil
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if embedded[rel] {
+			return nil
+		}
+		binary, err := isBinary(path)
+		if err != nil {
+			return err
+		}
+		if binary {
+			files = append(files, rel)
+		}
+		return nil
+	})
+	sort.Strings(files)
+	return files, err
+}
+
+func isBinary(path string) (bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+	buf := make([]byte, sniffLen)
+	n, err := io.ReadFull(f, buf)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return false, err
+	}
+	return bytes.IndexByte(buf[:n], 0) >= 0, nil
+}
+
+// SourceDateEpoch returns the time set by the SOURCE_DATE_EPOCH environment
+// variable of reproducible builds, in seconds since the Unix epoch, and
+// whether it is set.
+func SourceDateEpoch() (int64, bool, error) {
+	s := os.Getenv("SOURCE_DATE_EPOCH")
+	if s == "" {
+		return 0, false, nil
+	}
+	epoch, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || epoch < 0 {
+		return 0, false, fmt.Errorf("invalid SOURCE_DATE_EPOCH %q", s)
+	}
+	return epoch, true, nil
+}
+
+// SetMtimes sets the modification time of every file and directory below
+// root, root included, to t, so that archives of the tree are identical
+// whenever and wherever it was copied.
+func SetMtimes(root string, t time.Time) error {
+	var dirs []string
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			dirs = append(dirs, path)
+			return nil
+		}
+		if info.Mode()&os.ModeSymlink != 0 {
+			return nil
+		}
+		return os.Chtimes(path, t, t)
+	})
+	if err != nil {
+		return err
+	}
+	// directories last, deepest first, as setting the time of their
+	// entries does not touch them but creating files would.
+	for i := len(dirs) - 1; i >= 0; i-- {
+		if err := os.Chtimes(dirs[i], t, t); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// NormalizeModes gives the files below dst mode 0755 if their counterpart
+// in src, the working copy dst was copied from, is executable, as recorded
+// by the VCS, and 0644 otherwise. Directories, dst included, get 0755.
+func NormalizeModes(dst, src string) error {
+	return filepath.Walk(dst, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return os.Chmod(path, 0755)
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		exec := info.Mode()&0111 != 0
+		rel, err := filepath.Rel(dst, path)
+		if err != nil {
+			return err
+		}
+		if fi, err := os.Stat(filepath.Join(src, rel)); err == nil {
+			exec = fi.Mode()&0111 != 0
+		}
+		mode := os.FileMode(0644)
+		if exec {
+			mode = 0755
+		}
+		return os.Chmod(path, mode)
+	})
+}
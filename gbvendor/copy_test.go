@@ -0,0 +1,245 @@
+package vendor
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/constabulary/gb/fileutils"
+)
+
+func TestStripBinaries(t *testing.T) {
+	root := mktemp(t)
+	defer fileutils.RemoveAll(root)
+	dst := mktemp(t)
+	defer fileutils.RemoveAll(dst)
+
+	binary := "\x7fELF\x00\x00" + strings.Repeat("\x01", 2048)
+	writeFiles(t, root, map[string]string{
+		"foo.go":             "package foo\n\nimport _ \"embed\"\n\n//go:embed assets/logo.png \"static/*\"\nvar logo []byte\n",
+		"assets/logo.png":    binary,
+		"static/font.bin":    binary,
+		"bin/tool":           binary,
+		"small.bin":          "\x00\x01",
+		"foo_amd64.syso":     binary,
+		"README.md":          strings.Repeat("text ", 1024),
+		".git/objects/blob":  binary,
+		"sub/sub.go":         "package sub\n",
+		"sub/testdata/x.bin": binary,
+	})
+
+	got, err := BinaryFiles(root, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"bin/tool", "sub/testdata/x.bin"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("BinaryFiles: want %v, got %v", want, got)
+	}
+
+	if err := Copytree(dst, root, SkipFiles(got)); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"foo.go", "assets/logo.png", "static/font.bin", "small.bin", "foo_amd64.syso", "README.md"} {
+		assertExists(t, filepath.Join(dst, filepath.FromSlash(name)))
+	}
+	for _, name := range []string{"bin", "sub/testdata/x.bin", ".git"} {
+		assertNotExists(t, filepath.Join(dst, filepath.FromSlash(name)))
+	}
+}
+
+func TestParseEmbedPatterns(t *testing.T) {
+	got, err := parseEmbedPatterns("a.txt  \"b c.txt\" `d*.txt`\tall:e")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"a.txt", "b c.txt", "d*.txt", "all:e"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("parseEmbedPatterns: want %v, got %v", want, got)
+	}
+	if _, err := parseEmbedPatterns("\"unterminated"); err == nil {
+		t.Fatalf("parseEmbedPatterns: expected error for unterminated string")
+	}
+}
+
+func TestProtectEmbedded(t *testing.T) {
+	root := mktemp(t)
+	defer fileutils.RemoveAll(root)
+	dst := mktemp(t)
+	defer fileutils.RemoveAll(dst)
+
+	writeFiles(t, root, map[string]string{
+		"foo.go":              "package foo\n\nimport _ \"embed\"\n\n//go:embed testdata/golden.txt\nvar golden string\n",
+		"testdata/golden.txt": "golden",
+		"testdata/other.txt":  "other",
+	})
+
+	// a filter dropping every testdata file must not drop the embedded one.
+	skip, err := ProtectEmbedded(root, func(rel string, _ os.FileInfo) bool {
+		return strings.HasPrefix(rel, "testdata/")
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Copytree(dst, root, skip); err != nil {
+		t.Fatal(err)
+	}
+	assertExists(t, filepath.Join(dst, "testdata", "golden.txt"))
+	assertNotExists(t, filepath.Join(dst, "testdata", "other.txt"))
+}
+
+func TestSkipTests(t *testing.T) {
+	root := mktemp(t)
+	defer fileutils.RemoveAll(root)
+	dst := mktemp(t)
+	defer fileutils.RemoveAll(dst)
+
+	writeFiles(t, root, map[string]string{
+		"foo.go":                  "package foo\n\nimport _ \"embed\"\n\n//go:embed testdata/golden.txt\nvar golden string\n",
+		"foo_test.go":             "package foo\n",
+		"testdata/golden.txt":     "golden",
+		"testdata/input.txt":      "input",
+		"sub/sub.go":              "package sub\n",
+		"sub/testdata/deep/x.txt": "x",
+		"sub/big.bin":             "\x00",
+		"testing.go":              "package foo\n",
+		".git/HEAD":               "ref: refs/heads/master\n",
+		".hg/store/data":          "data",
+	})
+
+	skip, err := ProtectEmbedded(root, SkipTests(SkipFiles([]string{"sub/big.bin"})))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Copytree(dst, root, skip); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"foo.go", "testing.go", "sub/sub.go", "testdata/golden.txt"} {
+		assertExists(t, filepath.Join(dst, filepath.FromSlash(name)))
+	}
+	for _, name := range []string{"foo_test.go", "testdata/input.txt", "sub/testdata", "sub/big.bin", ".git", ".hg"} {
+		assertNotExists(t, filepath.Join(dst, filepath.FromSlash(name)))
+	}
+}
+
+func TestCopytreeKeepsExisting(t *testing.T) {
+	src := mktemp(t)
+	defer fileutils.RemoveAll(src)
+	dst := mktemp(t)
+	defer fileutils.RemoveAll(dst)
+
+	writeFiles(t, src, map[string]string{
+		"foo.go":     "package foo // new\n",
+		"sub/bar.go": "package sub\n",
+	})
+	writeFiles(t, dst, map[string]string{
+		"foo.go":          "package foo // old\n",
+		"patches/fix.txt": "local patch",
+		"sub/local.go":    "package sub\n",
+	})
+
+	if err := Copytree(dst, src, nil); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"sub/bar.go", "patches/fix.txt", "sub/local.go"} {
+		assertExists(t, filepath.Join(dst, filepath.FromSlash(name)))
+	}
+	buf, err := ioutil.ReadFile(filepath.Join(dst, "foo.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(buf) != "package foo // new\n" {
+		t.Fatalf("foo.go: want the incoming file, got %q", buf)
+	}
+}
+
+func TestSetMtimesSourceDateEpoch(t *testing.T) {
+	root := mktemp(t)
+	defer fileutils.RemoveAll(root)
+	writeFiles(t, root, map[string]string{
+		"a.go":         "package a\n",
+		"sub/b.go":     "package sub\n",
+		"sub/deep/c.h": "",
+	})
+
+	t.Setenv("SOURCE_DATE_EPOCH", "1600000000")
+	epoch, ok, err := SourceDateEpoch()
+	if err != nil || !ok || epoch != 1600000000 {
+		t.Fatalf("SourceDateEpoch: want 1600000000, got %d, %v, %v", epoch, ok, err)
+	}
+	if err := SetMtimes(root, time.Unix(epoch, 0)); err != nil {
+		t.Fatal(err)
+	}
+	err = filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if got := info.ModTime().Unix(); got != epoch {
+			t.Errorf("%s: want mtime %d, got %d", path, epoch, got)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("SOURCE_DATE_EPOCH", "yesterday")
+	if _, _, err := SourceDateEpoch(); err == nil {
+		t.Fatal("SourceDateEpoch: want an error for an invalid value")
+	}
+	t.Setenv("SOURCE_DATE_EPOCH", "")
+	if _, ok, err := SourceDateEpoch(); ok || err != nil {
+		t.Fatalf("SourceDateEpoch unset: want not ok, got %v, %v", ok, err)
+	}
+}
+
+func TestNormalizeModes(t *testing.T) {
+	src := mktemp(t)
+	defer fileutils.RemoveAll(src)
+	dst := mktemp(t)
+	defer fileutils.RemoveAll(dst)
+
+	writeFiles(t, src, map[string]string{
+		"a.go":           "package a\n",
+		"script.sh":      "#!/bin/sh\n",
+		"sub/b.go":       "package sub\n",
+		"sub/private.go": "package sub\n",
+	})
+	if err := os.Chmod(filepath.Join(src, "script.sh"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := Copytree(dst, src, nil); err != nil {
+		t.Fatal(err)
+	}
+	// modes a contributor's umask or editor may leave behind.
+	for name, mode := range map[string]os.FileMode{"a.go": 0600, "sub/private.go": 0666, "sub": 0700} {
+		if err := os.Chmod(filepath.Join(dst, filepath.FromSlash(name)), mode); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := NormalizeModes(dst, src); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]os.FileMode{
+		".":              0755,
+		"a.go":           0644,
+		"script.sh":      0755,
+		"sub":            0755,
+		"sub/b.go":       0644,
+		"sub/private.go": 0644,
+	}
+	for name, mode := range want {
+		fi, err := os.Stat(filepath.Join(dst, filepath.FromSlash(name)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := fi.Mode().Perm(); got != mode {
+			t.Errorf("%s: want mode %o, got %o", name, mode, got)
+		}
+	}
+}
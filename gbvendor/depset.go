@@ -3,8 +3,11 @@ package vendor
 import (
 	"fmt"
 	"go/build"
+	"go/parser"
+	"go/token"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 )
 
@@ -23,9 +26,15 @@ type Depset struct {
 
 // LoadPaths returns a map of paths to Depsets.
 func LoadPaths(paths ...struct{ Root, Prefix string }) (map[string]*Depset, error) {
+	return LoadPathsContext(&build.Default, paths...)
+}
+
+// LoadPathsContext is LoadPaths discovering the packages with ctx, such as
+// the one of IsolateGOPATH.
+func LoadPathsContext(ctx *build.Context, paths ...struct{ Root, Prefix string }) (map[string]*Depset, error) {
 	m := make(map[string]*Depset)
 	for _, p := range paths {
-		set, err := LoadTree(p.Root, p.Prefix)
+		set, err := LoadTreeContext(ctx, p.Root, p.Prefix)
 		if err != nil {
 			return nil, err
 		}
@@ -36,6 +45,11 @@ func LoadPaths(paths ...struct{ Root, Prefix string }) (map[string]*Depset, erro
 
 // LoadTree parses a tree of source files into a map of *pkgs.
 func LoadTree(root string, prefix string) (*Depset, error) {
+	return LoadTreeContext(&build.Default, root, prefix)
+}
+
+// LoadTreeContext is LoadTree discovering the packages with ctx.
+func LoadTreeContext(ctx *build.Context, root string, prefix string) (*Depset, error) {
 	d := Depset{
 		Root:   root,
 		Prefix: prefix,
@@ -49,7 +63,7 @@ func LoadTree(root string, prefix string) (*Depset, error) {
 			return nil
 		}
 
-		p, err := loadPackage(&d, dir)
+		p, err := loadPackage(ctx, &d, dir)
 		if err != nil {
 			if _, ok := err.(*build.NoGoError); ok {
 				return nil
@@ -77,14 +91,14 @@ func LoadTree(root string, prefix string) (*Depset, error) {
 	return &d, err
 }
 
-func loadPackage(d *Depset, dir string) (*Pkg, error) {
+func loadPackage(ctx *build.Context, d *Depset, dir string) (*Pkg, error) {
 	p := Pkg{
 		Depset: d,
 	}
 	var err error
 
 	// expolit local import logic
-	p.Package, err = build.ImportDir(dir, build.ImportComment)
+	p.Package, err = ctx.ImportDir(dir, build.ImportComment)
 	return &p, err
 }
 
@@ -112,3 +126,91 @@ func eachDir(dir string, fn func(string, os.FileInfo) error) error {
 	}
 	return nil
 }
+
+// AllImports returns the imports of p across all its non test files,
+// including the files excluded by build constraints for the current
+// platform.
+func (p *Pkg) AllImports() ([]string, error) {
+	seen := make(map[string]bool)
+	var imports []string
+	add := func(i string) {
+		if !seen[i] {
+			seen[i] = true
+			imports = append(imports, i)
+		}
+	}
+	for _, i := range p.Imports {
+		add(i)
+	}
+	fset := token.NewFileSet()
+	for _, name := range p.IgnoredGoFiles {
+		if strings.HasSuffix(name, "_test.go") {
+			continue
+		}
+		f, err := parser.ParseFile(fset, filepath.Join(p.Dir, name), nil, parser.ImportsOnly)
+		if err != nil {
+			return nil, err
+		}
+		for _, s := range f.Imports {
+			add(strings.Trim(s.Path.Value, "\""))
+		}
+	}
+	return imports, nil
+}
+
+// Importers returns the prefixes of the depsets in dsm holding a package
+// that imports importpath, sorted.
+func Importers(dsm map[string]*Depset, importpath string) []string {
+	var prefixes []string
+	for _, d := range dsm {
+		if d.Prefix == "" {
+			continue
+		}
+	pkgs:
+		for _, p := range d.Pkgs {
+			for _, i := range p.Imports {
+				if i == importpath {
+					prefixes = append(prefixes, filepath.ToSlash(d.Prefix))
+					break pkgs
+				}
+			}
+		}
+	}
+	sort.Strings(prefixes)
+	return prefixes
+}
+
+// DirectImports returns the packages of the other depsets of dsm imported
+// by a package of the depset with the slash separated prefix, sorted. The
+// imports of tests are left out.
+func DirectImports(dsm map[string]*Depset, prefix string) []string {
+	var own *Depset
+	others := make(map[string]bool)
+	for _, d := range dsm {
+		if filepath.ToSlash(d.Prefix) == prefix {
+			own = d
+			continue
+		}
+		if d.Prefix == "" {
+			continue
+		}
+		for ip := range d.Pkgs {
+			others[ip] = true
+		}
+	}
+	if own == nil {
+		return nil
+	}
+	seen := make(map[string]bool)
+	var imports []string
+	for _, p := range own.Pkgs {
+		for _, i := range p.Imports {
+			if _, ok := own.Pkgs[i]; !ok && others[i] && !seen[i] {
+				seen[i] = true
+				imports = append(imports, i)
+			}
+		}
+	}
+	sort.Strings(imports)
+	return imports
+}
@@ -0,0 +1,82 @@
+package vendor
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/constabulary/gb/fileutils"
+)
+
+func TestImporters(t *testing.T) {
+	root := mktemp(t)
+	defer fileutils.RemoveAll(root)
+
+	writeFiles(t, root, map[string]string{
+		"example.com/a/a.go":     "package a\n\nimport _ \"example.com/c\"\n",
+		"example.com/b/b.go":     "package b\n",
+		"example.com/b/sub/s.go": "package sub\n\nimport _ \"example.com/c/d\"\n",
+		"example.com/e/e.go":     "package e\n\nimport _ \"example.com/c\"\n",
+	})
+
+	var paths []struct{ Root, Prefix string }
+	for _, p := range []string{"example.com/a", "example.com/b", "example.com/e"} {
+		paths = append(paths, struct{ Root, Prefix string }{filepath.Join(root, filepath.FromSlash(p)), filepath.FromSlash(p)})
+	}
+	dsm, err := LoadPaths(paths...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		importpath string
+		want       []string
+	}{
+		{"example.com/c", []string{"example.com/a", "example.com/e"}},
+		{"example.com/c/d", []string{"example.com/b"}},
+		{"example.com/f", nil},
+	}
+	for _, tt := range tests {
+		if got := Importers(dsm, tt.importpath); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Importers(%q): want %v, got %v", tt.importpath, tt.want, got)
+		}
+	}
+}
+
+func TestDirectImports(t *testing.T) {
+	root := mktemp(t)
+	defer fileutils.RemoveAll(root)
+
+	writeFiles(t, root, map[string]string{
+		"example.com/a/a.go":          "package a\n\nimport (\n\t_ \"fmt\"\n\t_ \"example.com/a/internal\"\n\t_ \"example.com/b\"\n\t_ \"example.com/missing\"\n)\n",
+		"example.com/a/a_test.go":     "package a\n\nimport _ \"example.com/c\"\n",
+		"example.com/a/internal/i.go": "package internal\n\nimport (\n\t_ \"example.com/b\"\n\t_ \"example.com/b/sub\"\n)\n",
+		"example.com/b/b.go":          "package b\n\nimport _ \"example.com/c\"\n",
+		"example.com/b/sub/s.go":      "package sub\n",
+		"example.com/c/c.go":          "package c\n",
+	})
+
+	var paths []struct{ Root, Prefix string }
+	for _, p := range []string{"example.com/a", "example.com/b", "example.com/c"} {
+		paths = append(paths, struct{ Root, Prefix string }{filepath.Join(root, filepath.FromSlash(p)), filepath.FromSlash(p)})
+	}
+	dsm, err := LoadPaths(paths...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		prefix string
+		want   []string
+	}{
+		{"example.com/a", []string{"example.com/b", "example.com/b/sub"}},
+		{"example.com/b", []string{"example.com/c"}},
+		{"example.com/c", nil},
+		{"example.com/d", nil},
+	}
+	for _, tt := range tests {
+		if got := DirectImports(dsm, tt.prefix); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("DirectImports(%q): want %v, got %v", tt.prefix, tt.want, got)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package vendor
+
+import (
+	"fmt"
+	"time"
+)
+
+// DowngradeError is returned by CheckDowngrade when the checked out
+// revision is older than the recorded one.
+type DowngradeError struct {
+	Importpath       string
+	Old, New         string
+	OldDate, NewDate time.Time
+}
+
+func (e *DowngradeError) Error() string {
+	return fmt.Sprintf("%s: revision %s (%s) is older than the recorded %s (%s)",
+		e.Importpath, e.New, e.NewDate.Format(time.RFC3339), e.Old, e.OldDate.Format(time.RFC3339))
+}
+
+// CheckDowngrade returns a DowngradeError if the revision checked out in
+// wc is an ancestor of old, the revision recorded for importpath. Working
+// copies unable to tell are never considered downgraded.
+func CheckDowngrade(importpath string, wc WorkingCopy, old string) error {
+	ac, ok := wc.(AncestryChecker)
+	if !ok || old == "" {
+		return nil
+	}
+	older, err := ac.IsAncestorOf(old)
+	if err != nil || !older {
+		return err
+	}
+	rev, err := wc.Revision()
+	if err != nil {
+		return err
+	}
+	e := &DowngradeError{Importpath: importpath, Old: old, New: rev}
+	if e.OldDate, err = ac.RevisionDate(old); err != nil {
+		return err
+	}
+	if e.NewDate, err = ac.RevisionDate(rev); err != nil {
+		return err
+	}
+	return e
+}
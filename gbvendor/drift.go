@@ -0,0 +1,43 @@
+package vendor
+
+import "sort"
+
+// Drift kinds reported by Compare.
+const (
+	DriftRevision = "revision" // vendored at another revision
+	DriftMissing  = "missing"  // only in the reference manifest
+	DriftExtra    = "extra"    // only in the local manifest
+)
+
+// Drift is a difference between a dependency of two manifests.
+type Drift struct {
+	Importpath string `json:"importpath"`
+	Kind       string `json:"kind"`
+	Local      string `json:"local,omitempty"`     // local revision
+	Reference  string `json:"reference,omitempty"` // reference revision
+}
+
+// Compare returns how the dependencies of local differ from those of
+// reference, sorted by import path.
+func Compare(local, reference *Manifest) []Drift {
+	ref := make(map[string]Dependency)
+	for _, d := range reference.Dependencies {
+		ref[d.Importpath] = d
+	}
+	var drift []Drift
+	for _, d := range local.Dependencies {
+		r, ok := ref[d.Importpath]
+		delete(ref, d.Importpath)
+		switch {
+		case !ok:
+			drift = append(drift, Drift{Importpath: d.Importpath, Kind: DriftExtra, Local: d.Revision})
+		case r.Revision != d.Revision:
+			drift = append(drift, Drift{Importpath: d.Importpath, Kind: DriftRevision, Local: d.Revision, Reference: r.Revision})
+		}
+	}
+	for _, r := range ref {
+		drift = append(drift, Drift{Importpath: r.Importpath, Kind: DriftMissing, Reference: r.Revision})
+	}
+	sort.Slice(drift, func(i, j int) bool { return drift[i].Importpath < drift[j].Importpath })
+	return drift
+}
@@ -0,0 +1,31 @@
+package vendor
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCompare(t *testing.T) {
+	local := &Manifest{Dependencies: []Dependency{
+		{Importpath: "github.com/a/same", Revision: "1"},
+		{Importpath: "github.com/b/moved", Revision: "2"},
+		{Importpath: "github.com/c/extra", Revision: "3"},
+	}}
+	reference := &Manifest{Dependencies: []Dependency{
+		{Importpath: "github.com/d/missing", Revision: "4"},
+		{Importpath: "github.com/b/moved", Revision: "5"},
+		{Importpath: "github.com/a/same", Revision: "1"},
+	}}
+
+	want := []Drift{
+		{Importpath: "github.com/b/moved", Kind: DriftRevision, Local: "2", Reference: "5"},
+		{Importpath: "github.com/c/extra", Kind: DriftExtra, Local: "3"},
+		{Importpath: "github.com/d/missing", Kind: DriftMissing, Reference: "4"},
+	}
+	if got := Compare(local, reference); !reflect.DeepEqual(got, want) {
+		t.Fatalf("Compare: want %+v, got %+v", want, got)
+	}
+	if got := Compare(local, local); len(got) != 0 {
+		t.Fatalf("Compare with itself: want no drift, got %+v", got)
+	}
+}
@@ -0,0 +1,39 @@
+package vendor
+
+import "sort"
+
+// Duplicate is a tree vendored more than once, from different repositories,
+// such as a fork and its original.
+type Duplicate struct {
+	Checksum     string
+	Dependencies []Dependency // sorted by import path
+}
+
+// Duplicates returns the dependencies of deps whose Checksum is that of a
+// dependency from another Repository, grouped by Checksum. Dependencies
+// without a Checksum are ignored. The groups are sorted by the import path
+// of their first dependency.
+func Duplicates(deps []Dependency) []Duplicate {
+	bySum := make(map[string][]Dependency)
+	for _, d := range deps {
+		if d.Checksum != "" {
+			bySum[d.Checksum] = append(bySum[d.Checksum], d)
+		}
+	}
+	var dups []Duplicate
+	for sum, ds := range bySum {
+		repos := make(map[string]bool)
+		for _, d := range ds {
+			repos[d.Repository] = true
+		}
+		if len(repos) < 2 {
+			continue
+		}
+		sort.Slice(ds, func(i, j int) bool { return ds[i].Importpath < ds[j].Importpath })
+		dups = append(dups, Duplicate{Checksum: sum, Dependencies: ds})
+	}
+	sort.Slice(dups, func(i, j int) bool {
+		return dups[i].Dependencies[0].Importpath < dups[j].Dependencies[0].Importpath
+	})
+	return dups
+}
@@ -0,0 +1,60 @@
+package vendor
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/constabulary/gb/fileutils"
+)
+
+func TestDuplicates(t *testing.T) {
+	root := mktemp(t)
+	defer fileutils.RemoveAll(root)
+
+	writeFiles(t, root, map[string]string{
+		"github.com/fork/lib/lib.go":   "package lib\n",
+		"github.com/orig/lib/lib.go":   "package lib\n",
+		"github.com/other/pkg/pkg.go":  "package pkg\n",
+		"example.com/mono/a/lib.go":    "package lib\n",
+		"example.com/mono/b/lib.go":    "package lib\n",
+		"example.com/nosum/lib/lib.go": "package lib\n",
+	})
+	deps := []Dependency{
+		{Importpath: "github.com/orig/lib", Repository: "https://github.com/orig/lib"},
+		{Importpath: "github.com/fork/lib", Repository: "https://github.com/fork/lib"},
+		{Importpath: "github.com/other/pkg", Repository: "https://github.com/other/pkg"},
+		// identical trees of the same repository are not duplicates.
+		{Importpath: "example.com/mono/a", Repository: "https://example.com/mono", Path: "/a"},
+		{Importpath: "example.com/mono/b", Repository: "https://example.com/mono", Path: "/b"},
+	}
+	for i, d := range deps {
+		sum, err := TreeChecksum(filepath.Join(root, filepath.FromSlash(d.Importpath)), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		deps[i].Checksum = sum
+	}
+	deps = append(deps, Dependency{Importpath: "example.com/nosum/lib", Repository: "https://example.com/nosum"})
+
+	got := Duplicates(deps)
+	if len(got) != 1 {
+		t.Fatalf("Duplicates: want 1 duplicate, got %+v", got)
+	}
+	var paths []string
+	for _, d := range got[0].Dependencies {
+		paths = append(paths, d.Importpath)
+	}
+	// the mono trees share the checksum too, and are reported with the
+	// trees of the other repositories.
+	if want := []string{"example.com/mono/a", "example.com/mono/b", "github.com/fork/lib", "github.com/orig/lib"}; !reflect.DeepEqual(paths, want) {
+		t.Fatalf("Duplicates: want %v, got %v", want, paths)
+	}
+	if got[0].Checksum != deps[0].Checksum {
+		t.Fatalf("Duplicates: want checksum %s, got %s", deps[0].Checksum, got[0].Checksum)
+	}
+
+	if got := Duplicates(deps[2:]); len(got) != 0 {
+		t.Fatalf("Duplicates of a single repository: want none, got %+v", got)
+	}
+}
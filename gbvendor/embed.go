@@ -0,0 +1,152 @@
+package vendor
+
+import (
+	"fmt"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// EmbeddedFiles returns the set of files below root referenced by the
+// //go:embed directives of the Go files below root, as slash separated
+// paths relative to root.
+func EmbeddedFiles(root string) (map[string]bool, error) {
+	files := make(map[string]bool)
+	fset := token.NewFileSet()
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		name := info.Name()
+		if info.IsDir() {
+			if path != root && (strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_") || name == "testdata") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if filepath.Ext(name) != ".go" {
+			return nil
+		}
+		f, err := parser.ParseFile(fset, path, nil, parser.ParseComments)
+		if err != nil {
+			return err
+		}
+		dir := filepath.Dir(path)
+		for _, cg := range f.Comments {
+			for _, c := range cg.List {
+				if !strings.HasPrefix(c.Text, "//go:embed ") {
+					continue
+				}
+				patterns, err := parseEmbedPatterns(strings.TrimPrefix(c.Text, "//go:embed "))
+				if err != nil {
+					return fmt.Errorf("%s: %v", path, err)
+				}
+				for _, p := range patterns {
+					if err := matchEmbed(root, dir, p, files); err != nil {
+						return err
+					}
+				}
+			}
+		}
+		return nil
+	})
+	return files, err
+}
+
+// ProtectEmbedded wraps skip, a Copytree skip function for the tree at
+// root, so that it never skips a file referenced by a //go:embed directive.
+// Every feature leaving files out of a vendored tree should go through it,
+// as dropping an embedded file breaks the build.
+func ProtectEmbedded(root string, skip func(string, os.FileInfo) bool) (func(string, os.FileInfo) bool, error) {
+	if skip == nil {
+		return nil, nil
+	}
+	embedded, err := EmbeddedFiles(root)
+	if err != nil {
+		return nil, err
+	}
+	return func(rel string, info os.FileInfo) bool {
+		return !embedded[rel] && skip(rel, info)
+	}, nil
+}
+
+// matchEmbed adds to files the files below dir matched by pattern.
+func matchEmbed(root, dir, pattern string, files map[string]bool) error {
+	all := strings.HasPrefix(pattern, "all:")
+	pattern = strings.TrimPrefix(pattern, "all:")
+	matches, err := filepath.Glob(filepath.Join(dir, filepath.FromSlash(pattern)))
+	if err != nil {
+		return err
+	}
+	for _, match := range matches {
+		err := filepath.Walk(match, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			name := info.Name()
+			if path != match && !all && (strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_")) {
+				if info.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if info.IsDir() {
+				return nil
+			}
+			rel, err := filepath.Rel(root, path)
+			if err != nil {
+				return err
+			}
+			files[filepath.ToSlash(rel)] = true
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parseEmbedPatterns splits the arguments of a //go:embed directive,
+// which may be quoted with double quotes or back quotes.
+func parseEmbedPatterns(s string) ([]string, error) {
+	var patterns []string
+	for {
+		s = strings.TrimLeft(s, " \t")
+		if s == "" {
+			return patterns, nil
+		}
+		switch s[0] {
+		case '"', '`':
+			i := 1
+			for ; i < len(s); i++ {
+				if s[i] == '\\' && s[0] == '"' {
+					i++
+					continue
+				}
+				if s[i] == s[0] {
+					break
+				}
+			}
+			if i >= len(s) {
+				return nil, fmt.Errorf("invalid quoted string in //go:embed: %s", s)
+			}
+			p, err := strconv.Unquote(s[:i+1])
+			if err != nil {
+				return nil, fmt.Errorf("invalid quoted string in //go:embed: %s", s[:i+1])
+			}
+			patterns = append(patterns, p)
+			s = s[i+1:]
+		default:
+			i := strings.IndexAny(s, " \t")
+			if i < 0 {
+				i = len(s)
+			}
+			patterns = append(patterns, s[:i])
+			s = s[i:]
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package vendor
+
+import (
+	"bufio"
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// NormalizeLineEndings converts the CRLF line endings of the text files
+// below dst to LF, and returns the slash separated paths, relative to dst,
+// of the converted files. Binary files are left alone, and so are the
+// files the .gitattributes at the root of src marks as eol=crlf, -text
+// or binary.
+func NormalizeLineEndings(dst, src string) ([]string, error) {
+	keep, err := readGitattributes(filepath.Join(src, ".gitattributes"))
+	if err != nil {
+		return nil, err
+	}
+	var converted []string
+	err = filepath.Walk(dst, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(dst, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if keep(rel) {
+			return nil
+		}
+		binary, err := isBinary(p)
+		if err != nil || binary {
+			return err
+		}
+		buf, err := ioutil.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		if !bytes.Contains(buf, []byte("\r\n")) {
+			return nil
+		}
+		converted = append(converted, rel)
+		return ioutil.WriteFile(p, bytes.Replace(buf, []byte("\r\n"), []byte("\n"), -1), info.Mode())
+	})
+	return converted, err
+}
+
+// readGitattributes returns a function reporting whether the line endings
+// of a file must be kept according to the .gitattributes file.
+func readGitattributes(file string) (func(rel string) bool, error) {
+	var patterns []string
+	f, err := os.Open(file)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if err == nil {
+		defer f.Close()
+		s := bufio.NewScanner(f)
+		for s.Scan() {
+			fields := strings.Fields(s.Text())
+			if len(fields) < 2 || strings.HasPrefix(fields[0], "#") {
+				continue
+			}
+			for _, attr := range fields[1:] {
+				if attr == "eol=crlf" || attr == "-text" || attr == "binary" {
+					patterns = append(patterns, fields[0])
+					break
+				}
+			}
+		}
+		if err := s.Err(); err != nil {
+			return nil, err
+		}
+	}
+	return func(rel string) bool {
+		for _, p := range patterns {
+			name := rel
+			if !strings.Contains(strings.TrimPrefix(p, "/"), "/") {
+				// patterns without a slash match the file name at any depth.
+				name = path.Base(rel)
+			}
+			if ok, _ := path.Match(strings.TrimPrefix(p, "/"), name); ok {
+				return true
+			}
+		}
+		return false
+	}, nil
+}
@@ -0,0 +1,58 @@
+package vendor
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/constabulary/gb/fileutils"
+)
+
+func TestNormalizeLineEndings(t *testing.T) {
+	src := mktemp(t)
+	defer fileutils.RemoveAll(src)
+	dst := mktemp(t)
+	defer fileutils.RemoveAll(dst)
+
+	writeFiles(t, src, map[string]string{
+		".gitattributes": "*.bat eol=crlf\n# comment\n/testdata/*.txt -text\n",
+	})
+	files := map[string]string{
+		"main.go":           "package main\r\n\r\nfunc main() {}\r\n",
+		"lf.go":             "package main\n",
+		"sub/README":        "line\r\nline\r\n",
+		"run.bat":           "echo\r\n",
+		"testdata/raw.txt":  "raw\r\n",
+		"image.png":         "\x89PNG\r\n\x1a\n\x00\x00\r\n",
+		"sub/nested/x.json": "{}\r\n",
+	}
+	writeFiles(t, dst, files)
+
+	got, err := NormalizeLineEndings(dst, src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"main.go", "sub/README", "sub/nested/x.json"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("NormalizeLineEndings: want %v converted, got %v", want, got)
+	}
+
+	want := map[string]string{
+		"main.go":           "package main\n\nfunc main() {}\n",
+		"lf.go":             files["lf.go"],
+		"sub/README":        "line\nline\n",
+		"run.bat":           files["run.bat"],
+		"testdata/raw.txt":  files["testdata/raw.txt"],
+		"image.png":         files["image.png"],
+		"sub/nested/x.json": "{}\n",
+	}
+	for name, body := range want {
+		buf, err := ioutil.ReadFile(filepath.Join(dst, filepath.FromSlash(name)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(buf) != body {
+			t.Errorf("%s: want %q, got %q", name, body, buf)
+		}
+	}
+}
@@ -0,0 +1,36 @@
+package vendor
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Failures collects the errors of the import paths a fetch continuing
+// past failures could not vendor.
+type Failures map[string]error
+
+// Error lists the failed import paths in order, one per line.
+func (f Failures) Error() string {
+	paths := make([]string, 0, len(f))
+	for path := range f {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	lines := []string{fmt.Sprintf("failed to fetch %d dependencies:", len(f))}
+	for _, path := range paths {
+		lines = append(lines, fmt.Sprintf("\t%s: %v", path, f[path]))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Skip returns the paths of missing that have not failed, in order.
+func (f Failures) Skip(missing []string) []string {
+	var left []string
+	for _, path := range missing {
+		if _, failed := f[path]; !failed {
+			left = append(left, path)
+		}
+	}
+	return left
+}
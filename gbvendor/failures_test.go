@@ -0,0 +1,28 @@
+package vendor
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestFailures(t *testing.T) {
+	f := Failures{
+		"github.com/foo/zed": errors.New("no such repository"),
+		"github.com/foo/bar": errors.New("connection refused"),
+	}
+	want := "failed to fetch 2 dependencies:\n" +
+		"\tgithub.com/foo/bar: connection refused\n" +
+		"\tgithub.com/foo/zed: no such repository"
+	if got := f.Error(); got != want {
+		t.Fatalf("Error: want %q, got %q", want, got)
+	}
+
+	missing := []string{"github.com/foo/bar", "github.com/foo/baz", "github.com/foo/zed"}
+	if got, want := f.Skip(missing), []string{"github.com/foo/baz"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("Skip: want %v, got %v", want, got)
+	}
+	if got := f.Skip(missing[2:]); len(got) != 0 {
+		t.Fatalf("Skip: want nothing left, got %v", got)
+	}
+}
@@ -0,0 +1,159 @@
+package vendor
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/constabulary/gb/fileutils"
+)
+
+// Fetcher deduces the RemoteRepo of import paths, as DeduceRemoteRepo
+// documents. It is the seam between gvt and the network: the RemoteRepos
+// it returns do every clone.
+type Fetcher interface {
+	DeduceRemoteRepo(path string, insecure bool, repository ...string) (RemoteRepo, string, error)
+
+	// RepoAt returns the RemoteRepo at repourl, a URL or a host and
+	// path such as git.corp/repo.git.
+	RepoAt(repourl string, insecure bool) (RemoteRepo, error)
+}
+
+// DefaultFetcher is the Fetcher of DeduceRemoteRepo. Unless replaced, by
+// a FixtureFetcher for instance, it probes the hosts of the import paths
+// and clones with the VCS tools.
+var DefaultFetcher Fetcher = networkFetcher{}
+
+type networkFetcher struct{}
+
+func (networkFetcher) DeduceRemoteRepo(path string, insecure bool, repository ...string) (RemoteRepo, string, error) {
+	return deduceRemoteRepo(path, insecure, repository...)
+}
+
+func (networkFetcher) RepoAt(repourl string, insecure bool) (RemoteRepo, error) {
+	return templateRepo(repourl, insecure)
+}
+
+// RepoAt returns the RemoteRepo at repourl, as DefaultFetcher finds it.
+func RepoAt(repourl string, insecure bool) (RemoteRepo, error) {
+	return DefaultFetcher.RepoAt(repourl, insecure)
+}
+
+// FixtureRevisionFile is the file marking the root of a repository in the
+// fixtures of a FixtureFetcher, holding the revision it is at.
+const FixtureRevisionFile = ".fixture-revision"
+
+// FixtureFetcher is a Fetcher serving recorded fixtures, without network
+// access or VCS tools. Each repository is a directory below Root named
+// after the import path of its root, such as Root/example.com/repo, with
+// the files of a single revision, on the default branch master, which
+// its FixtureRevisionFile holds, or no commit at all if it is empty.
+// Their URL is that of the import path over https. Repositories have no
+// tags.
+type FixtureFetcher struct {
+	Root string
+}
+
+func (f *FixtureFetcher) DeduceRemoteRepo(path string, insecure bool, repository ...string) (RemoteRepo, string, error) {
+	if u, err := url.Parse(path); err == nil && u.Scheme != "" {
+		path = u.Host + u.Path
+	}
+	for root := path; root != "." && root != "/"; root = filepath.ToSlash(filepath.Dir(root)) {
+		repo, err := f.repo(root)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, "", err
+		}
+		return repo, strings.TrimPrefix(path, root), nil
+	}
+	return nil, "", fmt.Errorf("%s: no repository in the fixtures of %s", path, f.Root)
+}
+
+// RepoAt returns the repository of the fixtures named after the host and
+// path of repourl.
+func (f *FixtureFetcher) RepoAt(repourl string, insecure bool) (RemoteRepo, error) {
+	root := repourl
+	if u, err := url.Parse(repourl); err == nil && u.Scheme != "" {
+		root = u.Host + u.Path
+	}
+	repo, err := f.repo(strings.TrimSuffix(root, ".git"))
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("%s: no repository in the fixtures of %s", repourl, f.Root)
+	}
+	return repo, err
+}
+
+// repo returns the repository of the fixtures at the import path root.
+func (f *FixtureFetcher) repo(root string) (*fixtureRepo, error) {
+	dir := filepath.Join(f.Root, filepath.FromSlash(root))
+	buf, err := ioutil.ReadFile(filepath.Join(dir, FixtureRevisionFile))
+	if err != nil {
+		return nil, err
+	}
+	return &fixtureRepo{dir: dir, url: "https://" + root, revision: strings.TrimSpace(string(buf))}, nil
+}
+
+// fixtureRepo is a repository of a FixtureFetcher.
+type fixtureRepo struct {
+	dir, url, revision string
+}
+
+func (r *fixtureRepo) URL() string { return r.url }
+
+func (r *fixtureRepo) DefaultBranch() (string, error) { return "master", nil }
+
+func (r *fixtureRepo) ResolveRevision(branch, tag string) (string, error) {
+	if err := r.check(branch, tag, ""); err != nil {
+		return "", err
+	}
+	return r.revision, nil
+}
+
+// check returns the error of Checkout for a branch, tag or revision the
+// fixture is not at.
+func (r *fixtureRepo) check(branch, tag, revision string) error {
+	switch {
+	case tag != "":
+		return fmt.Errorf("tag %s not found in %s", tag, r.url)
+	case revision != "" && revision != r.revision:
+		return &RevisionNotFoundError{revision, fmt.Errorf("revision %s not found in %s", revision, r.url)}
+	case branch != "" && branch != "master" && branch != "HEAD":
+		return fmt.Errorf("branch %s not found in %s", branch, r.url)
+	}
+	return nil
+}
+
+func (r *fixtureRepo) Checkout(branch, tag, revision string) (WorkingCopy, error) {
+	if err := r.check(branch, tag, revision); err != nil {
+		return nil, err
+	}
+	dir, err := mktmp()
+	if err != nil {
+		return nil, err
+	}
+	// Copypath leaves out FixtureRevisionFile, like every hidden file.
+	if err := fileutils.Copypath(dir, r.dir); err != nil {
+		fileutils.RemoveAll(dir)
+		return nil, err
+	}
+	return &fixtureCopy{workingcopy{dir}, r.revision}, nil
+}
+
+type fixtureCopy struct {
+	workingcopy
+	revision string
+}
+
+func (c *fixtureCopy) Revision() (string, error) {
+	if c.revision == "" {
+		return "", ErrEmptyRepo
+	}
+	return c.revision, nil
+}
+
+func (c *fixtureCopy) Branch() (string, error) { return "master", nil }
@@ -0,0 +1,43 @@
+package vendor
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/constabulary/gb/fileutils"
+)
+
+func TestFixtureFetcher(t *testing.T) {
+	root := mktemp(t)
+	defer fileutils.RemoveAll(root)
+	writeFiles(t, root, map[string]string{
+		"example.com/repo/.fixture-revision": "abc123\n",
+		"example.com/repo/sub/sub.go":        "package sub\n",
+	})
+	f := &FixtureFetcher{Root: root}
+
+	repo, extra, err := f.DeduceRemoteRepo("https://example.com/repo/sub", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if repo.URL() != "https://example.com/repo" || extra != "/sub" {
+		t.Fatalf("DeduceRemoteRepo: want https://example.com/repo, /sub, got %s, %s", repo.URL(), extra)
+	}
+	wc, err := repo.Checkout("", "", "abc123")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer wc.Destroy()
+	if rev, _ := wc.Revision(); rev != "abc123" {
+		t.Fatalf("Revision: want abc123, got %s", rev)
+	}
+	assertExists(t, filepath.Join(wc.Dir(), "sub", "sub.go"))
+	assertNotExists(t, filepath.Join(wc.Dir(), FixtureRevisionFile))
+
+	if _, err := repo.Checkout("", "", "def456"); err == nil {
+		t.Fatal("Checkout of another revision: want an error")
+	}
+	if _, _, err := f.DeduceRemoteRepo("example.com/other", false); err == nil {
+		t.Fatal("DeduceRemoteRepo without fixture: want an error")
+	}
+}
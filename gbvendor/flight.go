@@ -0,0 +1,45 @@
+package vendor
+
+import "sync"
+
+// FetchGroup runs at most one fetch of an import path at a time. Callers
+// asking for a path already being fetched wait for that fetch and share
+// its result instead of starting their own.
+type FetchGroup struct {
+	mu    sync.Mutex
+	calls map[string]*fetchCall
+}
+
+type fetchCall struct {
+	wg   sync.WaitGroup
+	err  error
+	dups int // callers waiting for this call
+}
+
+// Do runs fn for importpath, unless a call for importpath is in flight, in
+// which case it waits for it. shared reports whether the result came from
+// another caller.
+func (g *FetchGroup) Do(importpath string, fn func() error) (err error, shared bool) {
+	g.mu.Lock()
+	if g.calls == nil {
+		g.calls = make(map[string]*fetchCall)
+	}
+	if c, ok := g.calls[importpath]; ok {
+		c.dups++
+		g.mu.Unlock()
+		c.wg.Wait()
+		return c.err, true
+	}
+	c := new(fetchCall)
+	c.wg.Add(1)
+	g.calls[importpath] = c
+	g.mu.Unlock()
+
+	c.err = fn()
+	c.wg.Done()
+
+	g.mu.Lock()
+	delete(g.calls, importpath)
+	g.mu.Unlock()
+	return c.err, false
+}
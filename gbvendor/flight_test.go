@@ -0,0 +1,65 @@
+package vendor
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestFetchGroup(t *testing.T) {
+	const path = "github.com/foo/bar"
+	var (
+		g       FetchGroup
+		m       Manifest
+		fetches int32
+	)
+	release := make(chan struct{})
+	fetch := func() error {
+		atomic.AddInt32(&fetches, 1)
+		<-release
+		return m.AddDependency(Dependency{Importpath: path})
+	}
+
+	// every worker discovers the same missing dependency at once.
+	const workers = 8
+	var wg sync.WaitGroup
+	errs := make([]error, workers)
+	shared := make([]bool, workers)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i], shared[i] = g.Do(path, fetch)
+		}(i)
+	}
+	for {
+		g.mu.Lock()
+		c := g.calls[path]
+		waiting := c != nil && c.dups == workers-1
+		g.mu.Unlock()
+		if waiting {
+			break
+		}
+	}
+	close(release)
+	wg.Wait()
+
+	var n int
+	for i, err := range errs {
+		if err != nil {
+			t.Errorf("worker %d: %v", i, err)
+		}
+		if shared[i] {
+			n++
+		}
+	}
+	if fetches != 1 {
+		t.Fatalf("want a single fetch, got %d", fetches)
+	}
+	if n != workers-1 {
+		t.Fatalf("want %d shared results, got %d", workers-1, n)
+	}
+	if len(m.Dependencies) != 1 {
+		t.Fatalf("want a single manifest entry, got %d", len(m.Dependencies))
+	}
+}
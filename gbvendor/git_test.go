@@ -0,0 +1,421 @@
+package vendor
+
+import (
+	"crypto/rand"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/constabulary/gb/fileutils"
+)
+
+// git runs git in dir, failing the test on error.
+func git(t *testing.T, dir string, args ...string) string {
+	cmd := exec.Command("git", append([]string{"-c", "user.name=gvt", "-c", "user.email=gvt@example.com"}, args...)...)
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %v: %v\n%s", args, err, out)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// commit writes files in the git repository at dir and commits them.
+func commit(t *testing.T, dir, msg string, files map[string]string) string {
+	writeFiles(t, dir, files)
+	git(t, dir, "add", "-A")
+	git(t, dir, "commit", "-q", "-m", msg)
+	return git(t, dir, "rev-parse", "HEAD")
+}
+
+// mkgitrepo creates a git repository with an initial commit on master.
+func mkgitrepo(t *testing.T) string {
+	dir := mktemp(t)
+	git(t, dir, "init", "-q", "-b", "master")
+	commit(t, dir, "initial", map[string]string{"foo.go": "package foo\n"})
+	return dir
+}
+
+func TestGitChangelog(t *testing.T) {
+	dir := mkgitrepo(t)
+	defer fileutils.RemoveAll(dir)
+
+	old := git(t, dir, "rev-parse", "HEAD")
+	commit(t, dir, "add bar", map[string]string{"bar.go": "package foo\n"})
+	commit(t, dir, "add baz", map[string]string{"baz.go": "package foo\n"})
+	git(t, dir, "checkout", "-q", "-b", "rewritten", old)
+	unrelated := commit(t, dir, "force pushed", map[string]string{"quux.go": "package foo\n"})
+	git(t, dir, "checkout", "-q", "master")
+
+	repo := &gitrepo{url: "file://" + dir}
+	wc, err := repo.Checkout("master", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer wc.Destroy()
+
+	got, err := wc.(Changelogger).Changelog(old)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(got), "\n")
+	if len(lines) != 2 || !strings.HasSuffix(lines[0], "add baz") || !strings.HasSuffix(lines[1], "add bar") {
+		t.Fatalf("Changelog(%q): got %q", old, got)
+	}
+
+	if _, err := wc.(Changelogger).Changelog(unrelated); err != ErrNotAncestor {
+		t.Fatalf("Changelog(%q): want %v, got %v", unrelated, ErrNotAncestor, err)
+	}
+}
+
+func TestGitShallowRevisionFallback(t *testing.T) {
+	dir := mkgitrepo(t)
+	defer fileutils.RemoveAll(dir)
+
+	deep := git(t, dir, "rev-parse", "HEAD")
+	for i := 0; i < 20; i++ {
+		commit(t, dir, fmt.Sprintf("commit %d", i), map[string]string{"foo.go": fmt.Sprintf("package foo\n\nconst N = %d\n", i)})
+	}
+	git(t, dir, "checkout", "-q", "-b", "other", deep)
+	other := commit(t, dir, "only on other", map[string]string{"other.go": "package foo\n"})
+	git(t, dir, "checkout", "-q", "master")
+
+	defer func(depth, steps int) { shallowDepth, deepenSteps = depth, steps }(shallowDepth, deepenSteps)
+	shallowDepth, deepenSteps = 2, 2
+
+	repo := &gitrepo{url: "file://" + dir}
+	for _, rev := range []string{deep, other} {
+		wc, err := repo.Checkout("", "", rev)
+		if err != nil {
+			t.Fatalf("Checkout(%q): %v", rev, err)
+		}
+		got, err := wc.Revision()
+		wc.Destroy()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != rev {
+			t.Fatalf("Checkout(%q): got revision %q", rev, got)
+		}
+	}
+}
+
+func TestGitEmptyRepo(t *testing.T) {
+	dir := mktemp(t)
+	defer fileutils.RemoveAll(dir)
+	git(t, dir, "init", "-q", "-b", "master")
+
+	wc, err := (&gitrepo{url: "file://" + dir}).Checkout("", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer wc.Destroy()
+	if _, err := wc.Revision(); err != ErrEmptyRepo {
+		t.Fatalf("Revision of an empty repository: want %v, got %v", ErrEmptyRepo, err)
+	}
+
+	commit(t, dir, "initial", map[string]string{"foo.go": "package foo\n"})
+	wc2, err := (&gitrepo{url: "file://" + dir}).Checkout("", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer wc2.Destroy()
+	if _, err := wc2.Revision(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGitCloneDepth(t *testing.T) {
+	dir := mkgitrepo(t)
+	defer fileutils.RemoveAll(dir)
+
+	for i := 0; i < 4; i++ {
+		commit(t, dir, fmt.Sprintf("commit %d", i), map[string]string{"foo.go": fmt.Sprintf("package foo\n\nconst N = %d\n", i)})
+	}
+	git(t, dir, "tag", "v1.0.0")
+
+	defer func(depth int) { CloneDepth = depth }(CloneDepth)
+	repo := &gitrepo{url: "file://" + dir}
+	for _, tt := range []struct {
+		depth int
+		tag   string
+		want  string
+	}{
+		{1, "", "1"},
+		{3, "", "3"},
+		{0, "", "5"},
+		{2, "v1.0.0", "2"},
+	} {
+		CloneDepth = tt.depth
+		wc, err := repo.Checkout("", tt.tag, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := git(t, wc.Dir(), "rev-list", "--count", "HEAD")
+		wc.Destroy()
+		if got != tt.want {
+			t.Errorf("Checkout with CloneDepth %d and tag %q: want %s commits, got %s", tt.depth, tt.tag, tt.want, got)
+		}
+	}
+}
+
+func TestGitSparseCheckout(t *testing.T) {
+	dir := mkgitrepo(t)
+	defer fileutils.RemoveAll(dir)
+
+	first := commit(t, dir, "add packages", map[string]string{
+		"LICENSE":        "MIT",
+		"pkg/a/a.go":     "package a\n",
+		"pkg/a/sub/s.go": "package sub\n",
+		"pkg/b/b.go":     "package b\n",
+		"cmd/c/main.go":  "package main\n",
+	})
+	commit(t, dir, "more", map[string]string{"pkg/b/more.go": "package b\n"})
+
+	repo := &gitrepo{url: "file://" + dir}
+	for _, revision := range []string{"", first} {
+		wc, err := repo.SparseCheckout("", "", revision, "pkg/a")
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, name := range []string{"LICENSE", "foo.go", "pkg/a/a.go", "pkg/a/sub/s.go"} {
+			assertExists(t, filepath.Join(wc.Dir(), filepath.FromSlash(name)))
+		}
+		for _, name := range []string{"pkg/b", "cmd"} {
+			assertNotExists(t, filepath.Join(wc.Dir(), filepath.FromSlash(name)))
+		}
+		if rev, err := wc.(*GitClone).Revision(); err != nil || (revision != "" && rev != revision) {
+			t.Fatalf("SparseCheckout(%q): got revision %s, %v", revision, rev, err)
+		}
+		wc.Destroy()
+	}
+}
+
+func TestGitCommitDate(t *testing.T) {
+	dir := mkgitrepo(t)
+	defer fileutils.RemoveAll(dir)
+
+	cmd := exec.Command("git", "-c", "user.name=gvt", "-c", "user.email=gvt@example.com", "commit", "-q", "--allow-empty", "-m", "dated")
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GIT_COMMITTER_DATE=2021-03-04T05:06:07+01:00")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("git commit: %v\n%s", err, out)
+	}
+
+	repo := &gitrepo{url: "file://" + dir}
+	wc, err := repo.Checkout("master", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer wc.Destroy()
+
+	got, err := wc.(CommitDater).CommitDate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := time.Date(2021, 3, 4, 4, 6, 7, 0, time.UTC)
+	if !got.Equal(want) {
+		t.Fatalf("CommitDate: want %v, got %v", want, got)
+	}
+}
+
+func TestGitMaxHistorySize(t *testing.T) {
+	dir := mkgitrepo(t)
+	defer fileutils.RemoveAll(dir)
+
+	old := git(t, dir, "rev-parse", "HEAD")
+	// random data does not compress, the repository stays oversized.
+	big := make([]byte, 256*1024)
+	if _, err := rand.Read(big); err != nil {
+		t.Fatal(err)
+	}
+	commit(t, dir, "add blob", map[string]string{"blob.bin": string(big)})
+	// the latest revision is small again, its history is not.
+	git(t, dir, "rm", "-q", "blob.bin")
+	git(t, dir, "commit", "-q", "-m", "remove blob")
+
+	defer func(max int64, fallback bool) { MaxHistorySize, ShallowRevisionFallback = max, fallback }(MaxHistorySize, ShallowRevisionFallback)
+	MaxHistorySize, ShallowRevisionFallback = 64*1024, false
+
+	repo := &gitrepo{url: "file://" + dir}
+	_, err := repo.Checkout("", "", old)
+	if err == nil {
+		t.Fatal("Checkout of an oversized repository: want error, got nil")
+	}
+	if !strings.Contains(err.Error(), "refusing a full clone") || !strings.Contains(err.Error(), "-split-large-repos") {
+		t.Fatalf("Checkout: want an error suggesting a shallow or sparse fetch, got %v", err)
+	}
+
+	// the latest revision only needs a shallow clone, which is not guarded.
+	wc, err := repo.Checkout("", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	wc.Destroy()
+
+	MaxHistorySize = 1024 * 1024
+	wc, err = repo.Checkout("", "", old)
+	if err != nil {
+		t.Fatalf("Checkout within the limit: %v", err)
+	}
+	wc.Destroy()
+}
+
+func TestGitCheckDowngrade(t *testing.T) {
+	dir := mkgitrepo(t)
+	defer fileutils.RemoveAll(dir)
+
+	old := git(t, dir, "rev-parse", "HEAD")
+	commit(t, dir, "newer", map[string]string{"newer.go": "package foo\n"})
+	recorded := git(t, dir, "rev-parse", "HEAD")
+
+	repo := &gitrepo{url: "file://" + dir}
+	wc, err := repo.Checkout("", "", old)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer wc.Destroy()
+
+	err = CheckDowngrade("example.com/foo", wc, recorded)
+	e, ok := err.(*DowngradeError)
+	if !ok {
+		t.Fatalf("CheckDowngrade to an older revision: want a DowngradeError, got %v", err)
+	}
+	if e.Old != recorded || e.New != old || e.OldDate.IsZero() || e.NewDate.IsZero() {
+		t.Fatalf("CheckDowngrade: unexpected %+v", e)
+	}
+
+	// moving forward, or staying put, is no downgrade.
+	if err := CheckDowngrade("example.com/foo", wc, old); err != nil {
+		t.Fatalf("CheckDowngrade to the same revision: %v", err)
+	}
+	wc2, err := repo.Checkout("master", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer wc2.Destroy()
+	if err := CheckDowngrade("example.com/foo", wc2, old); err != nil {
+		t.Fatalf("CheckDowngrade to a newer revision: %v", err)
+	}
+}
+
+func TestGitIsAncestorOfUnreachable(t *testing.T) {
+	dir := mkgitrepo(t)
+	defer fileutils.RemoveAll(dir)
+
+	repo := &gitrepo{url: "file://" + dir}
+	wc, err := repo.Checkout("master", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer wc.Destroy()
+	ac := wc.(AncestryChecker)
+
+	// a revision the repository does not have, as after a force push.
+	unknown := "0123456789abcdef0123456789abcdef01234567"
+	if older, err := ac.IsAncestorOf(unknown); err != nil || older {
+		t.Fatalf("IsAncestorOf an unknown revision: want false, nil, got %v, %v", older, err)
+	}
+
+	// a repository gone cannot tell.
+	if err := fileutils.RemoveAll(dir); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ac.IsAncestorOf(unknown); err == nil {
+		t.Fatal("IsAncestorOf with the repository unreachable: want an error")
+	}
+}
+
+func TestGitCheckoutDate(t *testing.T) {
+	dir := mktemp(t)
+	defer fileutils.RemoveAll(dir)
+	git(t, dir, "init", "-q", "-b", "master")
+
+	var revs []string
+	for i, date := range []string{"2023-01-10T12:00:00Z", "2023-01-14T23:00:00Z", "2023-01-20T12:00:00Z"} {
+		writeFiles(t, dir, map[string]string{"foo.go": fmt.Sprintf("package foo\n\nconst N = %d\n", i)})
+		git(t, dir, "add", "-A")
+		cmd := exec.Command("git", "-c", "user.name=gvt", "-c", "user.email=gvt@example.com", "commit", "-q", "-m", date)
+		cmd.Dir = dir
+		cmd.Env = append(os.Environ(), "GIT_AUTHOR_DATE="+date, "GIT_COMMITTER_DATE="+date)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git commit: %v\n%s", err, out)
+		}
+		revs = append(revs, git(t, dir, "rev-parse", "HEAD"))
+	}
+
+	repo := &gitrepo{url: "file://" + dir}
+	for _, tt := range []struct {
+		date string
+		want string
+	}{
+		{"2023-01-15", revs[1]},
+		{"2023-01-14", revs[0]},
+		{"2024-01-01", revs[2]},
+		{"2023-01-01", ""},
+	} {
+		wc, err := repo.Checkout("master", "", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		day, _ := time.Parse("2006-01-02", tt.date)
+		err = CheckoutDate(wc, day)
+		if tt.want == "" {
+			wc.Destroy()
+			if err == nil {
+				t.Errorf("CheckoutDate(%s): want an error, got none", tt.date)
+			}
+			continue
+		}
+		if err != nil {
+			wc.Destroy()
+			t.Fatalf("CheckoutDate(%s): %v", tt.date, err)
+		}
+		got, err := wc.Revision()
+		wc.Destroy()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("CheckoutDate(%s): want %s, got %s", tt.date, tt.want, got)
+		}
+	}
+	if err := CheckoutDate(new(BzrClone), time.Now()); err == nil {
+		t.Error("CheckoutDate of a bzr working copy: want an error, got none")
+	}
+}
+
+func TestGitPhases(t *testing.T) {
+	dir := mkgitrepo(t)
+	defer fileutils.RemoveAll(dir)
+	rev := git(t, dir, "rev-parse", "HEAD")
+
+	repo := &gitrepo{url: "file://" + dir}
+	for _, tt := range []struct {
+		revision string
+		want     []string
+	}{
+		{"", []string{"clone"}},
+		{rev, []string{"clone", "checkout"}},
+	} {
+		wc, err := repo.Checkout("", "", tt.revision)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, p := range wc.(PhaseTimer).Phases() {
+			if p.Start.IsZero() || p.Duration <= 0 {
+				t.Errorf("Checkout(%q): phase %s not timed: %+v", tt.revision, p.Name, p)
+			}
+			got = append(got, p.Name)
+		}
+		wc.Destroy()
+		if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+			t.Errorf("Checkout(%q): want phases %v, got %v", tt.revision, tt.want, got)
+		}
+	}
+}
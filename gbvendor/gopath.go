@@ -0,0 +1,23 @@
+package vendor
+
+import (
+	"go/build"
+	"io/ioutil"
+
+	"github.com/constabulary/gb/fileutils"
+)
+
+// IsolateGOPATH returns a copy of build.Default whose GOPATH is an empty
+// temporary directory, for LoadPathsContext to discover packages seeing
+// only GOROOT and the trees loaded explicitly, not whatever happens to be
+// installed in the real GOPATH. build.Default is left untouched. The
+// returned function removes the temporary directory.
+func IsolateGOPATH() (*build.Context, func(), error) {
+	dir, err := ioutil.TempDir("", "gvt-gopath-")
+	if err != nil {
+		return nil, nil, err
+	}
+	ctx := build.Default
+	ctx.GOPATH = dir
+	return &ctx, func() { fileutils.RemoveAll(dir) }, nil
+}
@@ -0,0 +1,60 @@
+package vendor
+
+import (
+	"go/build"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/constabulary/gb/fileutils"
+)
+
+func TestIsolateGOPATH(t *testing.T) {
+	root := mktemp(t)
+	defer fileutils.RemoveAll(root)
+
+	writeFiles(t, root, map[string]string{
+		"gopath/src/example.com/stale/stale.go": "package stale\n",
+		"vendor/example.com/dep/dep.go":         "package dep\n\nimport _ \"example.com/stale\"\n",
+	})
+	gopath := filepath.Join(root, "gopath")
+	defer func(gopath string) { build.Default.GOPATH = gopath }(build.Default.GOPATH)
+	build.Default.GOPATH = gopath
+	t.Setenv("GO111MODULE", "off")
+	if _, err := build.Import("example.com/stale", root, 0); err != nil {
+		t.Fatalf("stale package not found in the real GOPATH: %v", err)
+	}
+
+	ctx, cleanup, err := IsolateGOPATH()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if build.Default.GOPATH != gopath {
+		t.Fatalf("IsolateGOPATH changed build.Default.GOPATH to %s", build.Default.GOPATH)
+	}
+	if _, err := ctx.Import("example.com/stale", root, 0); err == nil {
+		t.Fatal("stale package of the real GOPATH found by the isolated context")
+	}
+
+	// the vendored import of the stale package is still missing.
+	dsm, err := LoadPathsContext(ctx, struct{ Root, Prefix string }{filepath.Join(root, "vendor", "example.com", "dep"), "example.com/dep"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := dsm[filepath.Join(root, "vendor", "example.com", "dep")]
+	p, ok := d.Pkgs["example.com/dep"]
+	if !ok {
+		t.Fatal("example.com/dep not loaded")
+	}
+	if len(p.Imports) != 1 || p.Imports[0] != "example.com/stale" {
+		t.Fatalf("want example.com/dep to import example.com/stale, got %v", p.Imports)
+	}
+	if _, ok := d.Pkgs["example.com/stale"]; ok {
+		t.Fatal("example.com/stale loaded from the real GOPATH")
+	}
+
+	cleanup()
+	if _, err := os.Stat(ctx.GOPATH); !os.IsNotExist(err) {
+		t.Fatalf("isolated GOPATH %s not removed", ctx.GOPATH)
+	}
+}
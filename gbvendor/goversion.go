@@ -0,0 +1,239 @@
+package vendor
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// GoVersion returns the Go version required by the go directive of the
+// go.mod file in dir, or "" if there is no go.mod or no go directive.
+func GoVersion(dir string) (string, error) {
+	return goModDirective(dir, "go")
+}
+
+// ModulePath returns the module path declared by the go.mod file in dir,
+// or "" if there is no go.mod.
+func ModulePath(dir string) (string, error) {
+	path, err := goModDirective(dir, "module")
+	if err != nil {
+		return "", err
+	}
+	if p, err := strconv.Unquote(path); err == nil {
+		path = p
+	}
+	return path, nil
+}
+
+// goModDirective returns the argument of the first name directive of the
+// go.mod file in dir, or "" if there is none.
+func goModDirective(dir, name string) (string, error) {
+	f, err := os.Open(filepath.Join(dir, "go.mod"))
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		line := s.Text()
+		if i := strings.Index(line, "//"); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 2 && fields[0] == name {
+			return fields[1], nil
+		}
+	}
+	return "", s.Err()
+}
+
+// GoModRequires returns the versions the require directives of the go.mod
+// file in dir pin, by module path, or nil if there is no go.mod.
+func GoModRequires(dir string) (map[string]string, error) {
+	f, err := os.Open(filepath.Join(dir, "go.mod"))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	reqs := make(map[string]string)
+	block := false
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		line := s.Text()
+		if i := strings.Index(line, "//"); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		switch {
+		case block && len(fields) == 1 && fields[0] == ")":
+			block = false
+			continue
+		case len(fields) == 2 && fields[0] == "require" && fields[1] == "(":
+			block = true
+			continue
+		case !block && len(fields) == 3 && fields[0] == "require":
+			fields = fields[1:]
+		case !block:
+			continue
+		}
+		if len(fields) != 2 {
+			continue
+		}
+		path := fields[0]
+		if p, err := strconv.Unquote(path); err == nil {
+			path = p
+		}
+		reqs[path] = fields[1]
+	}
+	return reqs, s.Err()
+}
+
+// PseudoVersionRevision returns the revision a pseudo-version such as
+// v0.0.0-20200101120000-abcdef123456 stands for, and false if version is
+// not a pseudo-version.
+func PseudoVersionRevision(version string) (string, bool) {
+	version = strings.TrimSuffix(version, "+incompatible")
+	i := strings.LastIndex(version, "-")
+	if i < 0 || len(version)-i-1 != 12 {
+		return "", false
+	}
+	rev := version[i+1:]
+	for _, r := range rev {
+		if !strings.ContainsRune("0123456789abcdef", r) {
+			return "", false
+		}
+	}
+	// the revision follows a yyyymmddhhmmss timestamp.
+	ts := version[:i]
+	if j := strings.LastIndexAny(ts, "-."); j >= 0 {
+		ts = ts[j+1:]
+	}
+	if len(ts) != 14 {
+		return "", false
+	}
+	if _, err := strconv.ParseUint(ts, 10, 64); err != nil {
+		return "", false
+	}
+	return rev, true
+}
+
+// ModuleTag returns the tag of the repository whose root is the import
+// path root that carries version of the module modpath: version itself
+// for a module at the root of the repository, prefixed with the directory
+// of the module otherwise. A major version suffix of modpath is not part
+// of the directory.
+func ModuleTag(modpath, root, version string) string {
+	version = strings.TrimSuffix(version, "+incompatible")
+	dir := trimMajorVersion(strings.TrimPrefix(strings.TrimPrefix(modpath, root), "/"))
+	if dir == "" {
+		return version
+	}
+	return dir + "/" + version
+}
+
+// trimMajorVersion returns the slash separated dir without its last
+// element if it is a major version suffix, such as v2.
+func trimMajorVersion(dir string) string {
+	i := strings.LastIndex(dir, "/")
+	if last := dir[i+1:]; strings.HasPrefix(last, "v") {
+		if n, err := strconv.Atoi(last[1:]); err == nil && n >= 2 {
+			return strings.TrimSuffix(dir[:i+1], "/")
+		}
+	}
+	return dir
+}
+
+// NewerGoError is returned by CheckGoVersion for a module requiring a
+// newer Go than the current one.
+type NewerGoError struct {
+	Required, Current string
+}
+
+func (e *NewerGoError) Error() string {
+	return fmt.Sprintf("requires go %s, newer than the %s in use", e.Required, e.Current)
+}
+
+// CheckGoVersion returns a NewerGoError if the go directive of the go.mod
+// file in dir requires a newer Go than current, see GoVersionNewer.
+func CheckGoVersion(dir, current string) error {
+	required, err := GoVersion(dir)
+	if err != nil {
+		return err
+	}
+	if GoVersionNewer(required, current) {
+		return &NewerGoError{Required: required, Current: current}
+	}
+	return nil
+}
+
+var (
+	toolchainOnce    sync.Once
+	toolchainVersion string
+)
+
+// ToolchainGoVersion returns the version of the go command that builds the
+// vendored code, as go env GOVERSION prints it, or runtime.Version, that
+// of the Go gvt was built with, if there is no go command or it predates
+// GOVERSION.
+func ToolchainGoVersion() string {
+	toolchainOnce.Do(func() {
+		out, err := exec.Command("go", "env", "GOVERSION").Output()
+		if toolchainVersion = strings.TrimSpace(string(out)); err != nil || toolchainVersion == "" {
+			toolchainVersion = runtime.Version()
+		}
+	})
+	return toolchainVersion
+}
+
+// GoVersionNewer reports whether the Go version required is newer than
+// current. Both may carry the go prefix of runtime.Version, and pre-release
+// suffixes such as rc1 are ignored. A version that cannot be parsed is
+// never newer.
+func GoVersionNewer(required, current string) bool {
+	r, ok := parseGoVersion(required)
+	if !ok {
+		return false
+	}
+	c, ok := parseGoVersion(current)
+	if !ok {
+		return false
+	}
+	for i := range r {
+		if r[i] != c[i] {
+			return r[i] > c[i]
+		}
+	}
+	return false
+}
+
+func parseGoVersion(v string) ([3]int, bool) {
+	var n [3]int
+	v = strings.TrimPrefix(v, "go")
+	if i := strings.IndexAny(v, "abcdefghijklmnopqrstuvwxyz -+"); i >= 0 {
+		v = v[:i]
+	}
+	parts := strings.Split(v, ".")
+	if len(parts) > 3 {
+		return n, false
+	}
+	for i, p := range parts {
+		x, err := strconv.Atoi(p)
+		if err != nil {
+			return n, false
+		}
+		n[i] = x
+	}
+	return n, true
+}
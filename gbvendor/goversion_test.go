@@ -0,0 +1,102 @@
+package vendor
+
+import (
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/constabulary/gb/fileutils"
+)
+
+func TestGoVersion(t *testing.T) {
+	root := mktemp(t)
+	defer fileutils.RemoveAll(root)
+
+	writeFiles(t, root, map[string]string{
+		"mod/go.mod":   "module example.com/mod\n\ngo 1.21 // for min and max\n\nrequire example.com/dep v1.0.0\n",
+		"nogo/go.mod":  "module example.com/nogo\n",
+		"nomod/foo.go": "package foo\n",
+	})
+	for dir, want := range map[string]string{"mod": "1.21", "nogo": "", "nomod": ""} {
+		got, err := GoVersion(filepath.Join(root, dir))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("GoVersion(%s): want %q, got %q", dir, want, got)
+		}
+	}
+}
+
+func TestGoVersionNewer(t *testing.T) {
+	tests := []struct {
+		required, current string
+		want              bool
+	}{
+		{"1.21", "go1.20.14", true},
+		{"1.21", "go1.21.0", false},
+		{"1.21.5", "go1.21.3", true},
+		{"1.21", "go1.22rc1", false},
+		{"1.9", "go1.10", false},
+		{"2.0", "go1.99", true},
+		{"", "go1.21", false},
+		{"1.21", "devel +abcdef", false},
+	}
+	for _, tt := range tests {
+		if got := GoVersionNewer(tt.required, tt.current); got != tt.want {
+			t.Errorf("GoVersionNewer(%q, %q): want %v, got %v", tt.required, tt.current, tt.want, got)
+		}
+	}
+}
+
+func TestCheckGoVersion(t *testing.T) {
+	root := mktemp(t)
+	defer fileutils.RemoveAll(root)
+
+	writeFiles(t, root, map[string]string{
+		"future/go.mod": "module example.com/future\n\ngo 1.99\n",
+		"old/go.mod":    "module example.com/old\n\ngo 1.13\n",
+		"nomod/foo.go":  "package foo\n",
+	})
+	err := CheckGoVersion(filepath.Join(root, "future"), "go1.21.5")
+	if e, ok := err.(*NewerGoError); !ok || e.Required != "1.99" || e.Current != "go1.21.5" {
+		t.Fatalf("CheckGoVersion of a module requiring a newer Go: want a NewerGoError, got %v", err)
+	}
+	for _, dir := range []string{"old", "nomod"} {
+		if err := CheckGoVersion(filepath.Join(root, dir), "go1.21.5"); err != nil {
+			t.Errorf("CheckGoVersion(%s): %v", dir, err)
+		}
+	}
+}
+
+func TestToolchainGoVersion(t *testing.T) {
+	want := runtime.Version()
+	if out, err := exec.Command("go", "env", "GOVERSION").Output(); err == nil {
+		want = strings.TrimSpace(string(out))
+	}
+	if got := ToolchainGoVersion(); got != want {
+		t.Fatalf("ToolchainGoVersion: want %s, got %s", want, got)
+	}
+}
+
+func TestPseudoVersionRevision(t *testing.T) {
+	tests := []struct {
+		version, revision string
+	}{
+		{"v0.0.0-20200102150405-abcdef123456", "abcdef123456"},
+		{"v1.2.4-0.20200102150405-abcdef123456", "abcdef123456"},
+		{"v1.3.0-pre.0.20200102150405-abcdef123456", "abcdef123456"},
+		{"v2.0.0-20200102150405-abcdef123456+incompatible", "abcdef123456"},
+		{"v1.2.3", ""},
+		{"v1.2.3-rc.1", ""},
+		{"v1.0.0-beta-abcdef123456", ""},
+	}
+	for _, tt := range tests {
+		rev, ok := PseudoVersionRevision(tt.version)
+		if rev != tt.revision || ok != (tt.revision != "") {
+			t.Errorf("PseudoVersionRevision(%q): want %q, got %q, %v", tt.version, tt.revision, rev, ok)
+		}
+	}
+}
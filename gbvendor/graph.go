@@ -0,0 +1,147 @@
+package vendor
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+)
+
+// graphColors are the fill colors of the nodes of WriteDOT, one per depset.
+var graphColors = []string{
+	"lightblue", "palegreen", "khaki", "plum", "lightsalmon",
+	"lightcyan", "wheat", "thistle", "lightpink", "honeydew",
+}
+
+// WriteDOT writes to w the import graph of the packages of the depsets of
+// dsm, without the standard library, in Graphviz DOT format. Each package
+// is a node, filled with the color of its depset, and each import of
+// another package of dsm an edge. The edges of import cycles are red.
+func WriteDOT(w io.Writer, dsm map[string]*Depset) error {
+	var sets []*Depset
+	pkgs := make(map[string]*Pkg)
+	for _, d := range dsm {
+		if d.Prefix == "" {
+			continue
+		}
+		sets = append(sets, d)
+		for ip, p := range d.Pkgs {
+			pkgs[ip] = p
+		}
+	}
+	sort.Slice(sets, func(i, j int) bool { return sets[i].Prefix < sets[j].Prefix })
+
+	edges := make(map[string][]string)
+	for ip, p := range pkgs {
+		for _, i := range p.Imports {
+			if _, ok := pkgs[i]; ok {
+				edges[ip] = append(edges[ip], i)
+			}
+		}
+		sort.Strings(edges[ip])
+	}
+	cyclic := cycles(edges)
+
+	if _, err := fmt.Fprintln(w, "digraph deps {\n\tnode [shape=box, style=filled];"); err != nil {
+		return err
+	}
+	for n, d := range sets {
+		fmt.Fprintf(w, "\tsubgraph %q {\n\t\tlabel=%q;\n", "cluster_"+filepath.ToSlash(d.Prefix), filepath.ToSlash(d.Prefix))
+		var names []string
+		for ip := range d.Pkgs {
+			names = append(names, ip)
+		}
+		sort.Strings(names)
+		for _, ip := range names {
+			fmt.Fprintf(w, "\t\t%q [fillcolor=%s];\n", ip, graphColors[n%len(graphColors)])
+		}
+		fmt.Fprintln(w, "\t}")
+	}
+	var from []string
+	for ip := range edges {
+		from = append(from, ip)
+	}
+	sort.Strings(from)
+	for _, ip := range from {
+		for _, i := range edges[ip] {
+			if cyclic[ip] != 0 && cyclic[ip] == cyclic[i] {
+				fmt.Fprintf(w, "\t%q -> %q [color=red, penwidth=2];\n", ip, i)
+			} else {
+				fmt.Fprintf(w, "\t%q -> %q;\n", ip, i)
+			}
+		}
+	}
+	_, err := fmt.Fprintln(w, "}")
+	return err
+}
+
+// cycles returns, for every node of graph part of a cycle, a non zero
+// number identifying its strongly connected component.
+func cycles(graph map[string][]string) map[string]int {
+	var (
+		index   = make(map[string]int)
+		low     = make(map[string]int)
+		onStack = make(map[string]bool)
+		stack   []string
+		next    = 1
+		comp    = make(map[string]int)
+		ncomp   int
+	)
+	var visit func(v string)
+	visit = func(v string) {
+		index[v], low[v] = next, next
+		next++
+		stack = append(stack, v)
+		onStack[v] = true
+		for _, u := range graph[v] {
+			if index[u] == 0 {
+				visit(u)
+				if low[u] < low[v] {
+					low[v] = low[u]
+				}
+			} else if onStack[u] && index[u] < low[v] {
+				low[v] = index[u]
+			}
+		}
+		if low[v] != index[v] {
+			return
+		}
+		var scc []string
+		for {
+			u := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			onStack[u] = false
+			scc = append(scc, u)
+			if u == v {
+				break
+			}
+		}
+		if len(scc) == 1 && !importsItself(graph, v) {
+			return
+		}
+		ncomp++
+		for _, u := range scc {
+			comp[u] = ncomp
+		}
+	}
+	var nodes []string
+	for v := range graph {
+		nodes = append(nodes, v)
+	}
+	sort.Strings(nodes)
+	for _, v := range nodes {
+		if index[v] == 0 {
+			visit(v)
+		}
+	}
+	return comp
+}
+
+func importsItself(graph map[string][]string, v string) bool {
+	for _, u := range graph[v] {
+		if u == v {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,56 @@
+package vendor
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/constabulary/gb/fileutils"
+)
+
+func TestWriteDOT(t *testing.T) {
+	root := mktemp(t)
+	defer fileutils.RemoveAll(root)
+
+	writeFiles(t, root, map[string]string{
+		"example.com/a/a.go":     "package a\n\nimport (\n\t_ \"fmt\"\n\t_ \"example.com/b\"\n\t_ \"example.com/a/sub\"\n)\n",
+		"example.com/a/sub/s.go": "package sub\n",
+		"example.com/b/b.go":     "package b\n\nimport _ \"example.com/c\"\n",
+		"example.com/c/c.go":     "package c\n\nimport _ \"example.com/b\"\n",
+	})
+	var paths []struct{ Root, Prefix string }
+	for _, p := range []string{"example.com/a", "example.com/b", "example.com/c"} {
+		paths = append(paths, struct{ Root, Prefix string }{filepath.Join(root, filepath.FromSlash(p)), filepath.FromSlash(p)})
+	}
+	dsm, err := LoadPaths(paths...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := WriteDOT(&buf, dsm); err != nil {
+		t.Fatal(err)
+	}
+	got := buf.String()
+	if !strings.HasPrefix(got, "digraph deps {") || !strings.HasSuffix(got, "}\n") {
+		t.Fatalf("WriteDOT: not a digraph:\n%s", got)
+	}
+	for _, want := range []string{
+		`"example.com/a" [fillcolor=lightblue];`,
+		`"example.com/a/sub" [fillcolor=lightblue];`,
+		`"example.com/b" [fillcolor=palegreen];`,
+		`"example.com/c" [fillcolor=khaki];`,
+		`"example.com/a" -> "example.com/a/sub";`,
+		`"example.com/a" -> "example.com/b";`,
+		`"example.com/b" -> "example.com/c" [color=red, penwidth=2];`,
+		`"example.com/c" -> "example.com/b" [color=red, penwidth=2];`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("WriteDOT: missing %s in:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, `"fmt"`) {
+		t.Errorf("WriteDOT: the standard library must be left out:\n%s", got)
+	}
+}
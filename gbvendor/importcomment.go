@@ -0,0 +1,51 @@
+package vendor
+
+import (
+	"go/build"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// ImportMismatch is a package whose canonical import comment, such as
+// package foo // import "example.com/foo", disagrees with the import
+// path it is vendored as.
+type ImportMismatch struct {
+	Importpath string // import path the package is vendored as
+	Canonical  string // import path declared by its import comment
+}
+
+// ImportMismatches returns the packages below root, vendored as importpath,
+// whose import comment declares another import path.
+func ImportMismatches(root, importpath string) ([]ImportMismatch, error) {
+	var mismatches []ImportMismatch
+	err := filepath.Walk(root, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			return nil
+		}
+		name := info.Name()
+		if p != root && (strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_") || name == "testdata" || name == "vendor") {
+			return filepath.SkipDir
+		}
+		pkg, err := build.ImportDir(p, build.ImportComment)
+		if err != nil {
+			// directories without Go files, or with conflicting
+			// package clauses, are left to the go tool.
+			return nil
+		}
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+		want := path.Join(importpath, filepath.ToSlash(rel))
+		if pkg.ImportComment != "" && pkg.ImportComment != want {
+			mismatches = append(mismatches, ImportMismatch{want, pkg.ImportComment})
+		}
+		return nil
+	})
+	return mismatches, err
+}
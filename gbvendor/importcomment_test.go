@@ -0,0 +1,33 @@
+package vendor
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/constabulary/gb/fileutils"
+)
+
+func TestImportMismatches(t *testing.T) {
+	root := mktemp(t)
+	defer fileutils.RemoveAll(root)
+
+	writeFiles(t, root, map[string]string{
+		"foo.go":         "package foo // import \"gopkg.in/foo.v1\"\n",
+		"doc.go":         "// Package foo is a fixture.\npackage foo\n",
+		"good/good.go":   "package good // import \"github.com/someone/foo/good\"\n",
+		"plain/plain.go": "package plain\n",
+		"bad/bad.go":     "package bad /* import \"gopkg.in/foo.v1/bad\" */\n",
+	})
+
+	got, err := ImportMismatches(root, "github.com/someone/foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []ImportMismatch{
+		{Importpath: "github.com/someone/foo", Canonical: "gopkg.in/foo.v1"},
+		{Importpath: "github.com/someone/foo/bad", Canonical: "gopkg.in/foo.v1/bad"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("ImportMismatches: want %+v, got %+v", want, got)
+	}
+}
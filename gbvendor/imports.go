@@ -1,14 +1,17 @@
 package vendor
 
 import (
+	"errors"
 	"fmt"
 	"go/parser"
 	"go/token"
 	"io"
+	"net"
 	"net/http"
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 )
 
 // ParseImports parses Go packages from a specific root returning a set of import paths.
@@ -45,29 +48,67 @@ func ParseImports(root string) (map[string]bool, error) {
 }
 
 // FetchMetadata fetchs the remote metadata for path.
-func FetchMetadata(path string, insecure bool) (rc io.ReadCloser, err error) {
+func FetchMetadata(path string, insecure bool) (io.ReadCloser, error) {
+	rc, _, err := fetchAnyMetadata(path, insecure)
+	return rc, err
+}
+
+// fetchAnyMetadata is FetchMetadata also returning the scheme the
+// metadata was fetched with.
+func fetchAnyMetadata(path string, insecure bool) (rc io.ReadCloser, scheme string, err error) {
 	defer func() {
 		if err != nil {
 			err = fmt.Errorf("unable to determine remote metadata protocol: %s", err)
 		}
 	}()
 	// try https first
-	rc, err = fetchMetadata("https", path)
+	scheme = "https"
+	rc, err = fetchMetadata(scheme, path)
 	if err == nil {
 		return
 	}
 	// try http if supported
 	if insecure {
-		rc, err = fetchMetadata("http", path)
+		scheme = "http"
+		rc, err = fetchMetadata(scheme, path)
 	}
 	return
 }
 
+// MaxRedirects is the number of HTTP redirects followed when fetching
+// remote metadata.
+var MaxRedirects = 10
+
+var errTooManyRedirects = errors.New("too many redirects")
+
+// ProbeTimeout bounds each request and VCS command probing the repository
+// of an import path, 0 for no limit.
+var ProbeTimeout time.Duration
+
+var metadataClient = &http.Client{
+	CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		if len(via) > MaxRedirects {
+			return errTooManyRedirects
+		}
+		Logf("following redirect %d: %s -> %s", len(via), via[len(via)-1].URL, req.URL)
+		return nil
+	},
+}
+
 func fetchMetadata(scheme, path string) (io.ReadCloser, error) {
 	url := fmt.Sprintf("%s://%s?go-get=1", scheme, path)
 	switch scheme {
 	case "https", "http":
-		resp, err := http.Get(url)
+		client := *metadataClient
+		client.Timeout = ProbeTimeout
+		resp, err := client.Get(url)
+		if errors.Is(err, errTooManyRedirects) {
+			return nil, fmt.Errorf("failed to access url %q: stopped after %d redirects", url, MaxRedirects)
+		}
+		var nerr net.Error
+		if errors.As(err, &nerr) && nerr.Timeout() && ProbeTimeout > 0 {
+			return nil, fmt.Errorf("failed to access url %q: no answer within %v", url, ProbeTimeout)
+		}
 		if err != nil {
 			return nil, fmt.Errorf("failed to access url %q", url)
 		}
@@ -77,9 +118,13 @@ func fetchMetadata(scheme, path string) (io.ReadCloser, error) {
 	}
 }
 
-// ParseMetadata fetchs and decodes remote metadata for path.
+// ParseMetadata fetchs and decodes remote metadata for path. The result
+// is remembered in ProbeCacheFile, if set.
 func ParseMetadata(path string, insecure bool) (string, string, string, error) {
-	rc, err := FetchMetadata(path, insecure)
+	if prefix, vcs, reporoot, ok := lookupProbeCache(path, insecure); ok {
+		return prefix, vcs, reporoot, nil
+	}
+	rc, scheme, err := fetchAnyMetadata(path, insecure)
 	if err != nil {
 		return "", "", "", err
 	}
@@ -102,5 +147,6 @@ func ParseMetadata(path string, insecure bool) (string, string, string, error) {
 	if match == -1 {
 		return "", "", "", fmt.Errorf("go-import metadata not found")
 	}
+	storeProbeCache(imports[match].Prefix, imports[match].VCS, imports[match].RepoRoot, scheme == "http")
 	return imports[match].Prefix, imports[match].VCS, imports[match].RepoRoot, nil
 }
@@ -4,10 +4,14 @@ import (
 	"bytes"
 	"fmt"
 	"io"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
 	"reflect"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestParseImports(t *testing.T) {
@@ -152,6 +156,53 @@ func TestParseMetadata(t *testing.T) {
 	}
 }
 
+func TestFetchMetadataMaxRedirects(t *testing.T) {
+	var hits int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		http.Redirect(w, r, fmt.Sprintf("/hop%d?go-get=1", hits), http.StatusFound)
+	}))
+	defer srv.Close()
+
+	defer func(n int) { MaxRedirects = n }(MaxRedirects)
+	MaxRedirects = 3
+
+	path := strings.TrimPrefix(srv.URL, "http://") + "/loop"
+	_, err := fetchMetadata("http", path)
+	if err == nil {
+		t.Fatalf("fetchMetadata(%q): expected error", path)
+	}
+	want := fmt.Sprintf(`failed to access url "http://%s?go-get=1": stopped after 3 redirects`, path)
+	if err.Error() != want {
+		t.Fatalf("fetchMetadata(%q): want %q, got %q", path, want, err)
+	}
+	if hits != 4 {
+		t.Fatalf("fetchMetadata(%q): want 4 requests, got %d", path, hits)
+	}
+}
+
+func TestFetchMetadataProbeTimeout(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	defer func(d time.Duration) { ProbeTimeout = d }(ProbeTimeout)
+	ProbeTimeout = 50 * time.Millisecond
+
+	path := strings.TrimPrefix(srv.URL, "http://") + "/slow"
+	start := time.Now()
+	_, err := fetchMetadata("http", path)
+	if err == nil || !strings.Contains(err.Error(), "no answer within 50ms") {
+		t.Fatalf("fetchMetadata(%q): want a timeout, got %v", path, err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("fetchMetadata(%q): gave up after %v, want 50ms", path, elapsed)
+	}
+}
+
 func getwd(t *testing.T) string {
 	cwd, err := os.Getwd()
 	if err != nil {
@@ -0,0 +1,176 @@
+package vendor
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/constabulary/gb/fileutils"
+)
+
+// LicenseGroup is a license text found, byte for byte, in the license
+// files of one or more dependencies.
+type LicenseGroup struct {
+	Checksum     string   `json:"checksum"`     // hex SHA-256 of the text
+	Files        []string `json:"files"`        // slash separated, relative to the vendor directory
+	Dependencies []string `json:"dependencies"` // import paths of the dependencies holding them
+}
+
+// GroupLicenses groups the license files at the root of the vendored tree
+// of each dependency of m by their content. The groups shared by the most
+// dependencies come first, ties are sorted by checksum; files and
+// dependencies are sorted by path. Dependencies not vendored are skipped.
+func GroupLicenses(vendorDir string, m *Manifest) ([]LicenseGroup, error) {
+	groups := make(map[string]*LicenseGroup)
+	for _, d := range m.Dependencies {
+		dir := filepath.Join(vendorDir, filepath.FromSlash(d.Importpath))
+		names, err := FindLicenses(dir)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range names {
+			buf, err := ioutil.ReadFile(filepath.Join(dir, name))
+			if err != nil {
+				return nil, err
+			}
+			sum := fmt.Sprintf("%x", sha256.Sum256(buf))
+			g, ok := groups[sum]
+			if !ok {
+				g = &LicenseGroup{Checksum: sum}
+				groups[sum] = g
+			}
+			g.Files = append(g.Files, path.Join(d.Importpath, name))
+			if n := len(g.Dependencies); n == 0 || g.Dependencies[n-1] != d.Importpath {
+				g.Dependencies = append(g.Dependencies, d.Importpath)
+			}
+		}
+	}
+	var list []LicenseGroup
+	for _, g := range groups {
+		sort.Strings(g.Files)
+		sort.Strings(g.Dependencies)
+		list = append(list, *g)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if len(list[i].Dependencies) != len(list[j].Dependencies) {
+			return len(list[i].Dependencies) > len(list[j].Dependencies)
+		}
+		return list[i].Checksum < list[j].Checksum
+	})
+	return list, nil
+}
+
+// License is the license detected for a dependency.
+type License struct {
+	File string `json:"file"`           // license file at the root of the vendored tree
+	SPDX string `json:"spdx,omitempty"` // SPDX identifier guessed from its text, if any
+}
+
+// licensePrefixes are the prefixes of the names of the files DetectLicense
+// considers, preferred in this order.
+var licensePrefixes = []string{"LICENSE", "LICENCE", "COPYING"}
+
+// licenseFile returns the path of the preferred license file of dir, or of
+// the nearest directory above it up to root, "" if there is none.
+func licenseFile(root, dir string) (string, error) {
+	root, dir = filepath.Clean(root), filepath.Clean(dir)
+	for {
+		names, err := FindLicenses(dir)
+		if err != nil {
+			return "", err
+		}
+		for _, prefix := range licensePrefixes {
+			for _, name := range names {
+				if strings.HasPrefix(strings.ToUpper(name), prefix) {
+					return filepath.Join(dir, name), nil
+				}
+			}
+		}
+		if dir == root || !strings.HasPrefix(dir, root+string(filepath.Separator)) {
+			return "", nil
+		}
+		dir = filepath.Dir(dir)
+	}
+}
+
+// DetectLicense returns the license of the tree at dir, a directory of the
+// working copy at root: its LICENSE, LICENCE or COPYING file, preferred in
+// that order, or else that of the nearest directory above it, with the
+// SPDX identifier guessed from its text. It returns nil if there is none.
+func DetectLicense(root, dir string) (*License, error) {
+	file, err := licenseFile(root, dir)
+	if err != nil || file == "" {
+		return nil, err
+	}
+	buf, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	return &License{File: filepath.Base(file), SPDX: GuessSPDX(buf)}, nil
+}
+
+// CopyLicense copies the license file of l to the root of the vendored
+// tree dst, copied from dir, a directory of the working copy at root, when
+// it was found above dir.
+func CopyLicense(dst, root, dir string, l *License) error {
+	if _, err := os.Stat(filepath.Join(dst, l.File)); err == nil {
+		return nil
+	}
+	file, err := licenseFile(root, dir)
+	if err != nil {
+		return err
+	}
+	if file == "" || filepath.Base(file) != l.File {
+		return fmt.Errorf("license file %s not found", l.File)
+	}
+	return fileutils.Copyfile(filepath.Join(dst, l.File), file)
+}
+
+// spdxHeuristics map phrases of the usual license texts, lower cased with
+// their spaces collapsed, to their SPDX identifier. The first whose phrases
+// are all found wins, so the more specific come first.
+var spdxHeuristics = []struct {
+	id      string
+	phrases []string
+}{
+	{"Apache-2.0", []string{"apache license", "version 2.0"}},
+	{"MPL-2.0", []string{"mozilla public license", "2.0"}},
+	{"EPL-2.0", []string{"eclipse public license", "2.0"}},
+	{"AGPL-3.0", []string{"gnu affero general public license", "version 3"}},
+	{"LGPL-3.0", []string{"gnu lesser general public license", "version 3"}},
+	{"LGPL-2.1", []string{"gnu lesser general public license", "version 2.1"}},
+	{"GPL-3.0", []string{"gnu general public license", "version 3"}},
+	{"GPL-2.0", []string{"gnu general public license", "version 2"}},
+	{"MIT", []string{"permission is hereby granted, free of charge"}},
+	{"ISC", []string{"permission to use, copy, modify, and/or distribute this software for any purpose"}},
+	{"BSD-3-Clause", []string{"redistribution and use in source and binary forms", "neither the name"}},
+	{"BSD-2-Clause", []string{"redistribution and use in source and binary forms"}},
+	{"Unlicense", []string{"free and unencumbered software released into the public domain"}},
+}
+
+// GuessSPDX returns the SPDX identifier of the license text, as told by
+// a few phrases of the usual licenses, "" if it is not recognised.
+func GuessSPDX(text []byte) string {
+	t := strings.Join(strings.Fields(strings.ToLower(string(text))), " ")
+	for _, h := range spdxHeuristics {
+		found := true
+		for _, p := range h.phrases {
+			if !strings.Contains(t, p) {
+				found = false
+				break
+			}
+		}
+		if found {
+			return h.id
+		}
+	}
+	return ""
+}
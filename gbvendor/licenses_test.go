@@ -0,0 +1,114 @@
+package vendor
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/constabulary/gb/fileutils"
+)
+
+func TestGroupLicenses(t *testing.T) {
+	vendorDir := filepath.Join(mktemp(t), "vendor")
+	defer fileutils.RemoveAll(filepath.Dir(vendorDir))
+
+	const apache = "Apache License\nVersion 2.0, January 2004\n"
+	writeFiles(t, vendorDir, map[string]string{
+		"example.com/a/LICENSE":     apache,
+		"example.com/b/LICENSE.txt": apache,
+		"example.com/b/NOTICE":      "Copyright b\n",
+		"example.com/c/COPYING":     apache,
+		"example.com/d/LICENSE":     apache + "\n", // not byte-identical
+		"example.com/e/e.go":        "package e\n",
+	})
+	m := &Manifest{}
+	for _, ip := range []string{"example.com/a", "example.com/b", "example.com/c", "example.com/d", "example.com/e", "example.com/gone"} {
+		m.Dependencies = append(m.Dependencies, Dependency{Importpath: ip})
+	}
+
+	groups, err := GroupLicenses(vendorDir, m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(groups) != 3 {
+		t.Fatalf("GroupLicenses: want 3 groups, got %v", groups)
+	}
+	want := LicenseGroup{
+		Checksum:     groups[0].Checksum,
+		Files:        []string{"example.com/a/LICENSE", "example.com/b/LICENSE.txt", "example.com/c/COPYING"},
+		Dependencies: []string{"example.com/a", "example.com/b", "example.com/c"},
+	}
+	if !reflect.DeepEqual(groups[0], want) {
+		t.Fatalf("GroupLicenses: want the shared text first, %v, got %v", want, groups[0])
+	}
+	for _, g := range groups[1:] {
+		if len(g.Dependencies) != 1 {
+			t.Errorf("GroupLicenses: want a single dependency, got %v", g)
+		}
+	}
+}
+
+func TestGuessSPDX(t *testing.T) {
+	for _, tt := range []struct {
+		text string
+		want string
+	}{
+		{"Apache License\n  Version 2.0, January 2004\n", "Apache-2.0"},
+		{"MIT License\n\nPermission is hereby granted, free of charge, to any person", "MIT"},
+		{"Redistribution and use in source and binary forms, with or without\nmodification... Neither the name of Google Inc.", "BSD-3-Clause"},
+		{"Redistribution and use in source and binary forms, with or without\nmodification", "BSD-2-Clause"},
+		{"GNU LESSER GENERAL PUBLIC LICENSE\nVersion 3, 29 June 2007\n... GNU General Public License version 3", "LGPL-3.0"},
+		{"GNU GENERAL PUBLIC LICENSE\nVersion 2, June 1991", "GPL-2.0"},
+		{"Mozilla Public License Version 2.0", "MPL-2.0"},
+		{"All rights reserved.", ""},
+	} {
+		if got := GuessSPDX([]byte(tt.text)); got != tt.want {
+			t.Errorf("GuessSPDX(%q): want %q, got %q", tt.text, tt.want, got)
+		}
+	}
+}
+
+func TestDetectLicense(t *testing.T) {
+	root := mktemp(t)
+	defer fileutils.RemoveAll(root)
+	writeFiles(t, root, map[string]string{
+		"COPYING":           "GNU GENERAL PUBLIC LICENSE\nVersion 3, 29 June 2007\n",
+		"LICENSE":           "Permission is hereby granted, free of charge",
+		"NOTICE":            "notice",
+		"sub/pkg/pkg.go":    "package pkg\n",
+		"other/LICENCE.txt": "All rights reserved.",
+	})
+
+	for _, tt := range []struct {
+		dir  string
+		want *License
+	}{
+		{".", &License{File: "LICENSE", SPDX: "MIT"}},
+		{"sub/pkg", &License{File: "LICENSE", SPDX: "MIT"}},
+		{"other", &License{File: "LICENCE.txt"}},
+	} {
+		got, err := DetectLicense(root, filepath.Join(root, filepath.FromSlash(tt.dir)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("DetectLicense(%s): want %+v, got %+v", tt.dir, tt.want, got)
+		}
+	}
+
+	dst := mktemp(t)
+	defer fileutils.RemoveAll(dst)
+	if err := CopyLicense(dst, root, filepath.Join(root, "sub", "pkg"), &License{File: "LICENSE"}); err != nil {
+		t.Fatal(err)
+	}
+	assertExists(t, filepath.Join(dst, "LICENSE"))
+	if err := CopyLicense(dst, root, filepath.Join(root, "sub", "pkg"), &License{File: "COPYING"}); err == nil {
+		t.Error("CopyLicense of a license no longer found: want an error")
+	}
+
+	empty := mktemp(t)
+	defer fileutils.RemoveAll(empty)
+	if got, err := DetectLicense(empty, empty); err != nil || got != nil {
+		t.Errorf("DetectLicense without license: want nil, got %+v, %v", got, err)
+	}
+}
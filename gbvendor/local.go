@@ -0,0 +1,52 @@
+package vendor
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// localRepo is a gitrepo checked out from a local clone or bundle, whose
+// URL is that of its canonical remote.
+type localRepo struct {
+	*gitrepo
+	canonical string
+}
+
+func (l *localRepo) URL() string { return l.canonical }
+
+// LocalRepo returns a RemoteRepo checking out the git repository at path,
+// a local clone or a git bundle, given as a path or a file:// URL, for
+// machines without network access. Its URL is canonical, the remote to
+// record in the manifest for later restores. For a local clone, an empty
+// canonical defaults to its origin remote.
+func LocalRepo(path, canonical string) (RemoteRepo, error) {
+	if err := checkVCS("git"); err != nil {
+		return nil, err
+	}
+	path, err := filepath.Abs(strings.TrimPrefix(path, "file://"))
+	if err != nil {
+		return nil, err
+	}
+	fi, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	url := path // git clones bundles from their path.
+	if fi.IsDir() {
+		// a file:// URL, unlike a path, allows shallow clones.
+		url = "file://" + filepath.ToSlash(path)
+		if canonical == "" {
+			out, err := run("git", "-C", path, "remote", "get-url", "origin")
+			if err != nil {
+				return nil, fmt.Errorf("%s has no origin remote, the repository to record must be given", path)
+			}
+			canonical = strings.TrimSpace(string(out))
+		}
+	}
+	if canonical == "" {
+		return nil, fmt.Errorf("%s is a bundle, the repository to record must be given", path)
+	}
+	return &localRepo{gitrepo: &gitrepo{url: url}, canonical: canonical}, nil
+}
@@ -0,0 +1,57 @@
+package vendor
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/constabulary/gb/fileutils"
+)
+
+func TestLocalRepo(t *testing.T) {
+	dir := mkgitrepo(t)
+	defer fileutils.RemoveAll(dir)
+	rev := commit(t, dir, "add bar", map[string]string{"bar.go": "package foo\n"})
+
+	tmp := mktemp(t)
+	defer fileutils.RemoveAll(tmp)
+	bundle := filepath.Join(tmp, "repo.bundle")
+	git(t, dir, "bundle", "create", bundle, "--all")
+	clone := filepath.Join(tmp, "clone")
+	git(t, tmp, "clone", "-q", dir, clone)
+
+	tests := []struct {
+		path, canonical, want string
+	}{
+		{dir, "https://example.com/repo", "https://example.com/repo"},
+		{"file://" + dir, "https://example.com/repo", "https://example.com/repo"},
+		{bundle, "https://example.com/repo", "https://example.com/repo"},
+		{clone, "", dir}, // its origin
+	}
+	for _, tt := range tests {
+		repo, err := LocalRepo(tt.path, tt.canonical)
+		if err != nil {
+			t.Fatalf("LocalRepo(%q, %q): %v", tt.path, tt.canonical, err)
+		}
+		if got := repo.URL(); got != tt.want {
+			t.Errorf("LocalRepo(%q, %q): want URL %q, got %q", tt.path, tt.canonical, tt.want, got)
+		}
+		wc, err := repo.Checkout("", "", "")
+		if err != nil {
+			t.Fatalf("LocalRepo(%q).Checkout: %v", tt.path, err)
+		}
+		got, err := wc.Revision()
+		wc.Destroy()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != rev {
+			t.Errorf("LocalRepo(%q).Checkout: want revision %s, got %s", tt.path, rev, got)
+		}
+	}
+
+	for _, path := range []string{bundle, dir, filepath.Join(tmp, "missing")} {
+		if _, err := LocalRepo(path, ""); err == nil {
+			t.Errorf("LocalRepo(%q) without a repository to record: want an error", path)
+		}
+	}
+}
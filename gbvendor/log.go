@@ -0,0 +1,11 @@
+package vendor
+
+import "log"
+
+// Logf logs the progress of the operations of the package, such as the
+// fallbacks of a checkout. Commands may replace it to control verbosity.
+var Logf = log.Printf
+
+// Debugf logs their details, such as the URLs cloned. It discards them
+// unless replaced.
+var Debugf = func(format string, args ...interface{}) {}
@@ -2,19 +2,56 @@ package vendor
 
 import (
 	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
+	"path"
+	"path/filepath"
 	"reflect"
 	"sort"
+	"strings"
 )
 
 // gb-vendor manifest support
 
+// ManifestVersion is the version of the manifests WriteManifest writes.
+// ReadManifest reads older manifests as they are, and rejects newer ones.
+// Version 0 manifests, written before the version was checked, have the
+// same shape. The optional fields added since, read as unset from older
+// manifests, need no new version: ReadManifest rejects the fields it does
+// not know, rather than dropping them once the manifest is written back.
+// A change to the meaning of a manifest does, along with its migration in
+// readManifest.
+const ManifestVersion = 1
+
+// ManifestVersionError is returned by ReadManifest for a manifest written
+// by a newer gvt, which this one cannot read without losing fields.
+type ManifestVersionError struct {
+	Version int
+}
+
+func (e *ManifestVersionError) Error() string {
+	return fmt.Sprintf("manifest version %d is newer than version %d, the latest this gvt supports, please upgrade gvt", e.Version, ManifestVersion)
+}
+
+// ManifestFieldError is returned by ReadManifest for a manifest with a
+// field this gvt does not know, added by a newer gvt, which would be lost
+// once the manifest is written back.
+type ManifestFieldError struct {
+	Field string
+}
+
+func (e *ManifestFieldError) Error() string {
+	return fmt.Sprintf("manifest field %s is unknown to this gvt, it was written by a newer one, please upgrade gvt", e.Field)
+}
+
 // Manifest describes the layout of $PROJECT/vendor/manifest.
 type Manifest struct {
-	// Manifest version. Current manifest version is 0.
+	// Manifest version, see ManifestVersion.
 	Version int `json:"version"`
 
 	// Depenencies is a list of vendored dependencies.
@@ -60,6 +97,293 @@ func (m *Manifest) GetDependencyForImportpath(path string) (Dependency, error) {
 	return Dependency{}, fmt.Errorf("dependency for %s does not exist", path)
 }
 
+// CollapseNested removes the dependencies whose tree is already vendored
+// as part of another dependency of the same Repository at the same
+// Revision. It then merges the sibling dependencies of a same Repository
+// and Revision, such as golang.org/x/net/context and golang.org/x/net/http2,
+// into one dependency of their parent directory trimmed to their packages,
+// those of their trees in vendorDir. It returns the dependencies removed.
+func (m *Manifest) CollapseNested(vendorDir string) ([]Dependency, error) {
+	var kept, removed []Dependency
+	for _, d := range m.Dependencies {
+		if _, ok := m.containing(d); ok {
+			removed = append(removed, d)
+			continue
+		}
+		kept = append(kept, d)
+	}
+	m.Dependencies = kept
+
+	groups := make(map[siblings][]Dependency)
+	var order []siblings
+	for _, d := range m.Dependencies {
+		k, ok := siblingsOf(d)
+		if !ok {
+			continue
+		}
+		if groups[k] == nil {
+			order = append(order, k)
+		}
+		groups[k] = append(groups[k], d)
+	}
+	for _, k := range order {
+		sibs := groups[k]
+		if len(sibs) < 2 || m.HasImportpath(k.importpath) || m.hasNested(sibs) {
+			continue
+		}
+		parent, err := m.mergeSiblings(k, sibs, vendorDir)
+		if err != nil {
+			return nil, err
+		}
+		for _, d := range sibs {
+			if err := m.RemoveDependency(d); err != nil {
+				return nil, err
+			}
+		}
+		if sibs[0].Checksum != "" {
+			// the siblings are no longer nested in it.
+			if parent.Checksum, err = TreeChecksum(filepath.Join(vendorDir, filepath.FromSlash(parent.Importpath)), m.Nested(parent)); err != nil {
+				return nil, err
+			}
+		}
+		m.Dependencies = append(m.Dependencies, parent)
+		removed = append(removed, sibs...)
+	}
+	return removed, nil
+}
+
+// siblings is what the dependencies CollapseNested merges share: their
+// repository and revision, the way they were copied, and their parent
+// directory, as an import path and in the repository.
+type siblings struct {
+	repository, revision, branch, tag string
+	policy                            string
+	importpath, path                  string
+}
+
+// siblingsOf returns the siblings d is one of. Dependencies trimmed,
+// stripped, with submodules, a license or cgo files, fetched under another
+// import path, or whose import path and path in the repository do not end
+// with the same element, are never merged.
+func siblingsOf(d Dependency) (siblings, bool) {
+	i := strings.LastIndex(d.Importpath, "/")
+	if i < 0 || len(d.Packages) > 0 || d.StripBinaries || len(d.Stripped) > 0 || d.Submodules != nil || d.License != nil || d.ExtractCgo || d.Origin != "" || d.Replacement != "" {
+		return siblings{}, false
+	}
+	elem := d.Importpath[i:]
+	if !strings.HasSuffix(d.Path, elem) {
+		return siblings{}, false
+	}
+	policy, _ := json.Marshal([]interface{}{d.NoTests, d.NormalizeEOL, d.NormalizeModes,
+		d.PreserveUnlisted, d.Symlinks, d.SymlinkDepth, d.SourceDateEpoch})
+	return siblings{
+		repository: d.Repository,
+		revision:   d.Revision,
+		branch:     d.Branch,
+		tag:        d.Tag,
+		policy:     string(policy),
+		importpath: d.Importpath[:i],
+		path:       strings.TrimSuffix(d.Path, elem),
+	}, true
+}
+
+// hasNested reports whether other dependencies are vendored inside those
+// of deps.
+func (m *Manifest) hasNested(deps []Dependency) bool {
+	for _, d := range deps {
+		if len(m.Nested(d)) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// mergeSiblings returns the dependency of the parent directory of sibs,
+// trimmed to the package directories of their trees in vendorDir. The
+// trees are trimmed like restore trims that of the parent, so they stay
+// what it vendors.
+func (m *Manifest) mergeSiblings(k siblings, sibs []Dependency, vendorDir string) (Dependency, error) {
+	parent := sibs[0]
+	parent.Importpath, parent.Path = k.importpath, k.path
+	parent.Checksum, parent.FileCount, parent.Bytes = "", 0, 0
+	parent.Packages, parent.Parents, parent.Imports, parent.Unresolved = nil, nil, nil, nil
+	parent.Description, parent.Alias = "", ""
+	for _, d := range sibs {
+		rel := d.Importpath[len(k.importpath)+1:]
+		dir := filepath.Join(vendorDir, filepath.FromSlash(d.Importpath))
+		dirs, err := packageDirs(dir)
+		if err != nil {
+			return Dependency{}, err
+		}
+		if err := TrimPackages(dir, dirs); err != nil {
+			return Dependency{}, err
+		}
+		for _, dir := range dirs {
+			parent.Packages = append(parent.Packages, path.Join(rel, dir))
+		}
+		parent.Parents = append(parent.Parents, d.Parents...)
+		parent.Imports = append(parent.Imports, d.Imports...)
+		parent.Unresolved = append(parent.Unresolved, d.Unresolved...)
+	}
+	return parent, nil
+}
+
+// packageDirs returns the slash separated directories below root, "." for
+// root itself, holding files needed to build a package.
+func packageDirs(root string) ([]string, error) {
+	seen := make(map[string]bool)
+	var dirs []string
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		if dir := filepath.ToSlash(filepath.Dir(rel)); IsBuildFile(info.Name()) && !seen[dir] {
+			seen[dir] = true
+			dirs = append(dirs, dir)
+		}
+		return nil
+	})
+	sort.Strings(dirs)
+	return dirs, err
+}
+
+// containing returns the dependency whose tree includes d, if any.
+func (m *Manifest) containing(d Dependency) (Dependency, bool) {
+	for _, o := range m.Dependencies {
+		if o.Repository != d.Repository || o.Revision != d.Revision || len(o.Packages) > 0 {
+			continue
+		}
+		if !strings.HasPrefix(d.Importpath, o.Importpath+"/") {
+			continue
+		}
+		if o.Path+d.Importpath[len(o.Importpath):] == d.Path {
+			return o, true
+		}
+	}
+	return Dependency{}, false
+}
+
+// Resolve returns the import path of the dependency aliased name, or name
+// itself if it is not an alias.
+func (m *Manifest) Resolve(name string) string {
+	if m.HasImportpath(name) {
+		return name
+	}
+	for _, d := range m.Dependencies {
+		if d.Alias == name {
+			return d.Importpath
+		}
+	}
+	return name
+}
+
+// CheckAlias returns an error if alias may not name the dependency at
+// importpath, because it names another dependency or is an import path.
+func (m *Manifest) CheckAlias(importpath, alias string) error {
+	if alias == "" || strings.Contains(alias, "/") {
+		return fmt.Errorf("invalid alias %q", alias)
+	}
+	for _, d := range m.Dependencies {
+		if d.Importpath != importpath && (d.Alias == alias || d.Importpath == alias) {
+			return fmt.Errorf("alias %s is already used by %s", alias, d.Importpath)
+		}
+	}
+	return nil
+}
+
+// SetAlias sets the alias of the dependency at importpath, removing it if
+// alias is empty.
+func (m *Manifest) SetAlias(importpath, alias string) error {
+	if alias != "" {
+		if err := m.CheckAlias(importpath, alias); err != nil {
+			return err
+		}
+	}
+	for i, d := range m.Dependencies {
+		if d.Importpath == importpath {
+			m.Dependencies[i].Alias = alias
+			return nil
+		}
+	}
+	return fmt.Errorf("dependency for %s does not exist", importpath)
+}
+
+// Select returns the dependencies at or below prefix, along with those
+// recorded as having a selected dependency among their Parents, and the
+// dependencies left out.
+func (m *Manifest) Select(prefix string) (selected, skipped []Dependency) {
+	prefix = strings.TrimSuffix(prefix, "/")
+	in := make(map[string]bool)
+	for _, d := range m.Dependencies {
+		if d.Importpath == prefix || strings.HasPrefix(d.Importpath, prefix+"/") {
+			in[d.Importpath] = true
+		}
+	}
+	// follow the parents until no more dependency is pulled in.
+	for changed := true; changed; {
+		changed = false
+		for _, d := range m.Dependencies {
+			if in[d.Importpath] {
+				continue
+			}
+			for _, p := range d.Parents {
+				if in[p] {
+					in[d.Importpath] = true
+					changed = true
+					break
+				}
+			}
+		}
+	}
+	for _, d := range m.Dependencies {
+		if in[d.Importpath] {
+			selected = append(selected, d)
+		} else {
+			skipped = append(skipped, d)
+		}
+	}
+	return selected, skipped
+}
+
+// CacheKey returns a hash of the vendored state described by the manifest,
+// independent of the order of the dependencies. It changes exactly when a
+// dependency is added, removed, or vendored differently: from another
+// revision, repository or path, or with other copy policies.
+func (m *Manifest) CacheKey() string {
+	deps := make([]Dependency, len(m.Dependencies))
+	copy(deps, m.Dependencies)
+	sort.Sort(byImportpath(deps))
+
+	h := sha256.New()
+	fmt.Fprintf(h, "version %d\n", m.Version)
+	for _, d := range deps {
+		fmt.Fprintf(h, "%q %q %q %q %q %q\n", d.Importpath, d.Repository, d.Revision, d.Path,
+			strings.Join(d.Packages, ","), strings.Join(d.Stripped, ","))
+		// the copy policies and what else decides the files vendored.
+		tree := struct {
+			Checksum                          string
+			NoTests, NormalizeEOL, ExtractCgo bool
+			NormalizeModes, PreserveUnlisted  bool
+			Symlinks                          string
+			SymlinkDepth                      int
+			SourceDateEpoch                   *int64
+			Submodules                        []Submodule
+			License                           *License
+		}{d.Checksum, d.NoTests, d.NormalizeEOL, d.ExtractCgo, d.NormalizeModes, d.PreserveUnlisted,
+			d.Symlinks, d.SymlinkDepth, d.SourceDateEpoch, d.Submodules, d.License}
+		buf, _ := json.Marshal(tree)
+		fmt.Fprintf(h, "%s\n", buf)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
 // Dependency describes one vendored import path of code
 // A Dependency is an Importpath sources from a Respository
 // at Revision from Path.
@@ -79,9 +403,147 @@ type Dependency struct {
 	// Can be blank if not needed.
 	Branch string `json:"branch"`
 
+	// DefaultBranch is the branch the HEAD of the Repository pointed to
+	// when the dependency was fetched, whatever Branch is. Only recorded
+	// on request.
+	DefaultBranch string `json:"defaultbranch,omitempty"`
+
+	// Tag is the tag the Revision was fetched at, in full, such as
+	// foo/v1.2.3 when selected by fetch -tag-prefix.
+	Tag string `json:"tag,omitempty"`
+
 	// Path is the path inside the Repository where the
 	// dependency was fetched from.
 	Path string `json:"path,omitempty"`
+
+	// Packages lists the package directories, relative to Path, that
+	// were kept when the dependency was trimmed. Empty means the whole
+	// tree was vendored.
+	Packages []string `json:"packages,omitempty"`
+
+	// BuildConstraints is the set of build tags, GOOS and GOARCH values
+	// seen across the dependency's files. Only recorded on request.
+	BuildConstraints []string `json:"buildconstraints,omitempty"`
+
+	// Stripped lists the binary files, relative to Path, that were left
+	// out of the vendored tree.
+	Stripped []string `json:"stripped,omitempty"`
+
+	// StripBinaries records that the binary files larger than
+	// StripBinariesSize bytes are left out of the vendored tree, even
+	// when none was found to strip.
+	StripBinaries     bool  `json:"stripbinaries,omitempty"`
+	StripBinariesSize int64 `json:"stripbinariessize,omitempty"`
+
+	// Origin is the import path the dependency was fetched as, when it
+	// is vendored under a different Importpath.
+	Origin string `json:"origin,omitempty"`
+
+	// Parents lists the dependencies whose packages import this one,
+	// when it was fetched recursively. Only recorded on request.
+	Parents []string `json:"parents,omitempty"`
+
+	// Imports lists the packages of the other dependencies that the
+	// packages of this one import directly, tests excluded. Only recorded
+	// on request.
+	Imports []string `json:"imports,omitempty"`
+
+	// Unresolved lists the imports of the dependency that fetch
+	// -lazy-recursion left missing, so its recursion is incomplete.
+	Unresolved []string `json:"unresolved,omitempty"`
+
+	// NormalizeEOL records that the CRLF line endings of the text files
+	// were converted to LF when copying.
+	NormalizeEOL bool `json:"normalizeeol,omitempty"`
+
+	// NoTests records that the _test.go files and the testdata
+	// directories were left out when copying.
+	NoTests bool `json:"notests,omitempty"`
+
+	// ExtractCgo records that the local files included by the cgo
+	// packages of the dependency are always copied, see CgoFiles.
+	ExtractCgo bool `json:"extractcgo,omitempty"`
+
+	// SourceDateEpoch, if set, is the modification time, in seconds since
+	// the Unix epoch, given to every file of the vendored tree.
+	SourceDateEpoch *int64 `json:"sourcedateepoch,omitempty"`
+
+	// NormalizeModes records that the vendored files are given canonical
+	// permissions, see NormalizeModes.
+	NormalizeModes bool `json:"chmodnormalize,omitempty"`
+
+	// Replacement is the import path fetched in place of this one, the
+	// end of its chain of replace rules.
+	Replacement string `json:"replacement,omitempty"`
+
+	// PreserveUnlisted records that files found in the vendored tree but
+	// not in the upstream one are kept when the dependency is copied again.
+	PreserveUnlisted bool `json:"preserveunlisted,omitempty"`
+
+	// Symlinks is the symlink policy the tree was copied with, SymlinksDeref
+	// or, if empty, SymlinksSkip. SymlinkDepth is the length of the chains
+	// of symlinks dereferenced, zero meaning DefaultSymlinkDepth.
+	Symlinks     string `json:"symlinks,omitempty"`
+	SymlinkDepth int    `json:"symlinkdepth,omitempty"`
+
+	// Submodules lists the git submodules checked out and copied with the
+	// tree, at the commits its Revision records for them.
+	Submodules []Submodule `json:"submodules,omitempty"`
+
+	// Description is an excerpt of the README of the dependency.
+	Description string `json:"description,omitempty"`
+
+	// Checksum is the TreeChecksum of the vendored tree, recorded when
+	// it was copied.
+	Checksum string `json:"checksum,omitempty"`
+
+	// FileCount and Bytes are the number and total size of the files
+	// of the vendored tree, recorded when it was copied. Only recorded
+	// on request, zero means unknown.
+	FileCount int   `json:"filecount,omitempty"`
+	Bytes     int64 `json:"bytes,omitempty"`
+
+	// CommitDate is the committer date of Revision, in RFC 3339 format.
+	// Only recorded on request.
+	CommitDate string `json:"commitdate,omitempty"`
+
+	// GoVersion is the go directive of the go.mod of the dependency, the
+	// oldest Go release able to build it. Only recorded on request.
+	GoVersion string `json:"goversion,omitempty"`
+
+	// License is the license detected in the tree of the dependency, see
+	// DetectLicense. Only recorded on request.
+	License *License `json:"license,omitempty"`
+
+	// Alias is a short name the commands accept in place of Importpath.
+	Alias string `json:"alias,omitempty"`
+}
+
+// Source returns the import path the dependency is fetched from upstream.
+func (d Dependency) Source() string {
+	if d.Origin != "" {
+		return d.Origin
+	}
+	return d.Importpath
+}
+
+// Remote returns the import path the repository of the dependency is
+// deduced from, its Replacement if any.
+func (d Dependency) Remote() string {
+	if d.Replacement != "" {
+		return d.Replacement
+	}
+	return d.Source()
+}
+
+// StripHost returns importpath without its leading host element, so
+// github.com/foo/bar becomes foo/bar.
+func StripHost(importpath string) (string, error) {
+	i := strings.Index(importpath, "/")
+	if i < 0 || i == len(importpath)-1 {
+		return "", fmt.Errorf("%q has no path below its host", importpath)
+	}
+	return importpath[i+1:], nil
 }
 
 // WriteManifest writes a Manifest to the path. If the manifest does
@@ -100,6 +562,7 @@ func WriteManifest(path string, m *Manifest) error {
 		return nil
 	}
 
+	m.Version = ManifestVersion
 	f, err := os.Create(path)
 	if err != nil {
 		return err
@@ -111,8 +574,17 @@ func WriteManifest(path string, m *Manifest) error {
 	return f.Close()
 }
 
+// writeManifest writes m in canonical form, which only depends on its
+// content: the dependencies sorted by import path, and the lists of each,
+// which are sets, sorted, so the order they were fetched or recorded in
+// does not show in diffs.
 func writeManifest(w io.Writer, m *Manifest) error {
 	sort.Sort(byImportpath(m.Dependencies))
+	for _, d := range m.Dependencies {
+		for _, set := range [][]string{d.Packages, d.BuildConstraints, d.Stripped, d.Parents, d.Imports, d.Unresolved} {
+			sort.Strings(set)
+		}
+	}
 	buf, err := json.MarshalIndent(m, "", "\t")
 	if err != nil {
 		return err
@@ -122,8 +594,23 @@ func writeManifest(w io.Writer, m *Manifest) error {
 }
 
 // ReadManifest reads a Manifest from path. If the Manifest is not
-// found, a blank Manifest will be returned.
+// found, a blank Manifest will be returned. Manifests of an older version
+// are read as ManifestVersion, those of a newer one rejected with a
+// ManifestVersionError, and those with an unknown field with a
+// ManifestFieldError.
 func ReadManifest(path string) (*Manifest, error) {
+	return readManifestFile(path, true)
+}
+
+// ReadVendoredManifest reads, like ReadManifest, the manifest of a
+// dependency, vendored with its tree or at its root upstream. It was
+// written by whatever gvt the dependency uses and is only read: a newer
+// version is not rejected, its unknown fields are ignored.
+func ReadVendoredManifest(path string) (*Manifest, error) {
+	return readManifestFile(path, false)
+}
+
+func readManifestFile(path string, strict bool) (*Manifest, error) {
 	f, err := os.Open(path)
 	if err != nil {
 		if os.IsNotExist(err) {
@@ -132,14 +619,39 @@ func ReadManifest(path string) (*Manifest, error) {
 		return nil, err
 	}
 	defer f.Close()
-	return readManifest(f)
+	return readManifest(f, strict)
 }
 
-func readManifest(r io.Reader) (*Manifest, error) {
+// readManifest decodes a manifest from r, rejecting a newer version and
+// unknown fields if strict is set.
+func readManifest(r io.Reader, strict bool) (*Manifest, error) {
+	buf, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
 	var m Manifest
-	d := json.NewDecoder(r)
-	err := d.Decode(&m)
-	return &m, err
+	if err := json.Unmarshal(buf, &m); err != nil {
+		return &m, err
+	}
+	switch {
+	case m.Version > ManifestVersion && strict:
+		return nil, &ManifestVersionError{m.Version}
+	case m.Version < 0:
+		return nil, fmt.Errorf("invalid manifest version %d", m.Version)
+	case m.Version < ManifestVersion:
+		m.Version = ManifestVersion
+	}
+	if strict {
+		d := json.NewDecoder(bytes.NewReader(buf))
+		d.DisallowUnknownFields()
+		if err := d.Decode(new(Manifest)); err != nil {
+			if field := strings.TrimPrefix(err.Error(), "json: unknown field "); field != err.Error() {
+				return nil, &ManifestFieldError{strings.Trim(field, `"`)}
+			}
+			return nil, err
+		}
+	}
+	return &m, nil
 }
 
 type byImportpath []Dependency
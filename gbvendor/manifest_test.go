@@ -2,8 +2,12 @@ package vendor
 
 import (
 	"bytes"
+	"fmt"
+	"io/ioutil"
 	"os"
 	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/constabulary/gb/fileutils"
@@ -104,3 +108,335 @@ func TestEmptyPathIsNotWritten(t *testing.T) {
 		t.Fatalf("want: %s, got %s", want, got)
 	}
 }
+
+func TestManifestVersion(t *testing.T) {
+	// manifests written before versioning are read as they are.
+	m, err := readManifest(strings.NewReader(`{"version": 0, "dependencies": [{"importpath": "github.com/foo/bar", "revision": "abcdef"}]}`), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Version != ManifestVersion || len(m.Dependencies) != 1 {
+		t.Fatalf("readManifest of version 0: want version %d and 1 dependency, got %v", ManifestVersion, m)
+	}
+
+	newer := fmt.Sprintf(`{"version": %d, "dependencies": [{"importpath": "github.com/foo/bar", "revision": "abcdef", "unknown": true}]}`, ManifestVersion+1)
+	_, err = readManifest(strings.NewReader(newer), true)
+	if _, ok := err.(*ManifestVersionError); !ok {
+		t.Fatalf("readManifest of a newer version: want a ManifestVersionError, got %v", err)
+	}
+	// unless it is a vendored one, only read.
+	vendored, err := readManifest(strings.NewReader(newer), false)
+	if err != nil {
+		t.Fatalf("readManifest of a newer vendored version: %v", err)
+	}
+	if len(vendored.Dependencies) != 1 {
+		t.Fatalf("readManifest of a newer vendored version: want 1 dependency, got %v", vendored)
+	}
+
+	root := mktemp(t)
+	defer fileutils.RemoveAll(root)
+	mf := filepath.Join(root, "manifest")
+	m.Version = 0
+	if err := WriteManifest(mf, m); err != nil {
+		t.Fatal(err)
+	}
+	buf, err := ioutil.ReadFile(mf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := fmt.Sprintf(`"version": %d,`, ManifestVersion); !strings.Contains(string(buf), want) {
+		t.Fatalf("WriteManifest: want %s, got %s", want, buf)
+	}
+}
+
+func TestManifestUnknownField(t *testing.T) {
+	root := mktemp(t)
+	defer fileutils.RemoveAll(root)
+	mf := filepath.Join(root, "manifest")
+	// written by a newer gvt, of the same version.
+	newer := fmt.Sprintf(`{"version": %d, "dependencies": [{"importpath": "github.com/foo/bar", "revision": "abcdef", "unknown": true}]}`, ManifestVersion)
+	if err := ioutil.WriteFile(mf, []byte(newer), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// read to be written back, it would lose the field.
+	_, err := ReadManifest(mf)
+	if e, ok := err.(*ManifestFieldError); !ok || e.Field != "unknown" {
+		t.Fatalf("ReadManifest of an unknown field: want a ManifestFieldError, got %v", err)
+	}
+	buf, err := ioutil.ReadFile(mf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(buf) != newer {
+		t.Fatalf("ReadManifest of an unknown field: want the manifest untouched, got %s", buf)
+	}
+
+	// only read, the field is ignored.
+	m, err := ReadVendoredManifest(mf)
+	if err != nil {
+		t.Fatalf("ReadVendoredManifest of an unknown field: %v", err)
+	}
+	if err := WriteManifest(mf, m); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ReadManifest(mf); err != nil {
+		t.Fatalf("ReadManifest of a manifest written back: %v", err)
+	}
+}
+
+func TestWriteManifestCanonical(t *testing.T) {
+	write := func(deps ...Dependency) string {
+		var buf bytes.Buffer
+		if err := writeManifest(&buf, &Manifest{Version: ManifestVersion, Dependencies: deps}); err != nil {
+			t.Fatal(err)
+		}
+		return buf.String()
+	}
+	a := func(parents ...string) Dependency {
+		return Dependency{Importpath: "github.com/foo/a", Revision: "abcdef", Parents: parents, Packages: []string{"sub", "."}}
+	}
+	b := Dependency{Importpath: "github.com/foo/b", Revision: "123456"}
+
+	want := write(a("github.com/x", "github.com/y"), b)
+	if got := write(b, a("github.com/y", "github.com/x")); got != want {
+		t.Fatalf("writeManifest depends on the order of the dependencies and their lists:\n%s\n%s", want, got)
+	}
+	if !strings.Contains(want, `"packages": [
+				".",
+				"sub"
+			]`) {
+		t.Fatalf("writeManifest: want the packages sorted, got %s", want)
+	}
+}
+
+func TestCollapseNested(t *testing.T) {
+	dep := func(importpath, repository, revision, path string) Dependency {
+		return Dependency{
+			Importpath: importpath,
+			Repository: repository,
+			Revision:   revision,
+			Branch:     "master",
+			Path:       path,
+		}
+	}
+	m := Manifest{
+		Dependencies: []Dependency{
+			dep("github.com/foo/bar", "https://github.com/foo/bar", "cafebad", ""),
+			dep("github.com/foo/bar/baz", "https://github.com/foo/bar", "cafebad", "/baz"),
+			dep("github.com/foo/bar/baz/quux", "https://github.com/foo/bar", "cafebad", "/baz/quux"),
+			dep("github.com/foo/bar/old", "https://github.com/foo/bar", "deadbeef", "/old"),
+			dep("github.com/foo/barn", "https://github.com/foo/barn", "cafebad", ""),
+			dep("golang.org/x/net/context", "https://github.com/golang/net", "abcdef", "/context"),
+			dep("golang.org/x/net/http2", "https://github.com/golang/net", "abcdef", "/http2"),
+		},
+	}
+	root := mktemp(t)
+	defer fileutils.RemoveAll(root)
+	writeFiles(t, root, map[string]string{
+		"golang.org/x/net/context/context.go":    "package context\n",
+		"golang.org/x/net/http2/http2.go":        "package http2\n",
+		"golang.org/x/net/http2/README":          "http2\n",
+		"golang.org/x/net/http2/hpack/hpack.go":  "package hpack\n",
+		"golang.org/x/net/http2/testdata/x.json": "{}\n",
+	})
+	removed, err := m.CollapseNested(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, d := range removed {
+		got = append(got, d.Importpath)
+	}
+	want := []string{"github.com/foo/bar/baz", "github.com/foo/bar/baz/quux", "golang.org/x/net/context", "golang.org/x/net/http2"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("CollapseNested: want removed %v, got %v", want, got)
+	}
+	if len(m.Dependencies) != 4 {
+		t.Fatalf("CollapseNested: want 4 dependencies left, got %d", len(m.Dependencies))
+	}
+	// the siblings are merged into their parent, trimmed to their packages.
+	net, err := m.GetDependencyForImportpath("golang.org/x/net")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if net.Repository != "https://github.com/golang/net" || net.Revision != "abcdef" || net.Path != "" {
+		t.Errorf("CollapseNested: want golang.org/x/net from https://github.com/golang/net at abcdef, got %v", net)
+	}
+	if want := []string{"context", "http2", "http2/hpack"}; !reflect.DeepEqual(net.Packages, want) {
+		t.Errorf("CollapseNested: want golang.org/x/net trimmed to %v, got %v", want, net.Packages)
+	}
+	// as restore then trims the tree of golang.org/x/net.
+	assertExists(t, filepath.Join(root, "golang.org", "x", "net", "http2", "hpack", "hpack.go"))
+	assertNotExists(t, filepath.Join(root, "golang.org", "x", "net", "http2", "README"))
+}
+
+func TestCacheKey(t *testing.T) {
+	a := Dependency{Importpath: "github.com/foo/bar", Repository: "https://github.com/foo/bar", Revision: "cafebad", Branch: "master"}
+	b := Dependency{Importpath: "github.com/quux/flobble", Repository: "https://github.com/quux/flobble", Revision: "abcdef", Branch: "master"}
+
+	m1 := Manifest{Dependencies: []Dependency{a, b}}
+	m2 := Manifest{Dependencies: []Dependency{b, a}}
+	key := m1.CacheKey()
+	if key != m1.CacheKey() {
+		t.Fatalf("CacheKey is not stable across calls")
+	}
+	if key != m2.CacheKey() {
+		t.Fatalf("CacheKey depends on the order of the dependencies")
+	}
+	if m2.Dependencies[0].Importpath != b.Importpath {
+		t.Fatalf("CacheKey reordered the manifest")
+	}
+
+	a.Revision = "deadbeef"
+	m3 := Manifest{Dependencies: []Dependency{a, b}}
+	if key == m3.CacheKey() {
+		t.Fatalf("CacheKey did not change with a revision")
+	}
+
+	// the copy policies change the vendored tree too.
+	a.Revision = "cafebad"
+	for name, change := range map[string]func(*Dependency){
+		"Checksum":     func(d *Dependency) { d.Checksum = "1234" },
+		"NoTests":      func(d *Dependency) { d.NoTests = true },
+		"NormalizeEOL": func(d *Dependency) { d.NormalizeEOL = true },
+		"Symlinks":     func(d *Dependency) { d.Symlinks = SymlinksDeref },
+		"SymlinkDepth": func(d *Dependency) { d.SymlinkDepth = 3 },
+		"Submodules":   func(d *Dependency) { d.Submodules = []Submodule{{Path: "sub", Revision: "abcd"}} },
+	} {
+		d := a
+		change(&d)
+		m := Manifest{Dependencies: []Dependency{d, b}}
+		if key == m.CacheKey() {
+			t.Errorf("CacheKey did not change with %s", name)
+		}
+	}
+}
+
+func TestStripHost(t *testing.T) {
+	tests := []struct {
+		path, want string
+		err        bool
+	}{
+		{path: "github.com/foo/bar", want: "foo/bar"},
+		{path: "gopkg.in/yaml.v2", want: "yaml.v2"},
+		{path: "github.com/foo/bar/baz", want: "foo/bar/baz"},
+		{path: "github.com", err: true},
+		{path: "github.com/", err: true},
+	}
+	for _, tt := range tests {
+		got, err := StripHost(tt.path)
+		if tt.err {
+			if err == nil {
+				t.Errorf("StripHost(%q): want error, got %q", tt.path, got)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("StripHost(%q): want %q, got %q, %v", tt.path, tt.want, got, err)
+		}
+	}
+}
+
+func TestManifestOrigin(t *testing.T) {
+	m := Manifest{
+		Dependencies: []Dependency{{
+			Importpath: "foo/bar",
+			Repository: "https://github.com/foo/bar",
+			Revision:   "cafebabe",
+			Branch:     "master",
+			Origin:     "github.com/foo/bar",
+		}},
+	}
+	var buf bytes.Buffer
+	if err := writeManifest(&buf, &m); err != nil {
+		t.Fatal(err)
+	}
+	got, err := readManifest(&buf, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := got.Dependencies[0]
+	if d.Importpath != "foo/bar" || d.Repository != "https://github.com/foo/bar" {
+		t.Fatalf("want foo/bar from https://github.com/foo/bar, got %s from %s", d.Importpath, d.Repository)
+	}
+	if src := d.Source(); src != "github.com/foo/bar" {
+		t.Fatalf("Source: want github.com/foo/bar, got %s", src)
+	}
+	if src := (Dependency{Importpath: "github.com/a/b"}).Source(); src != "github.com/a/b" {
+		t.Fatalf("Source without origin: want github.com/a/b, got %s", src)
+	}
+}
+
+func TestSelect(t *testing.T) {
+	m := Manifest{
+		Dependencies: []Dependency{
+			{Importpath: "github.com/foo/bar"},
+			{Importpath: "github.com/foo/baz", Parents: []string{"github.com/other/x"}},
+			{Importpath: "github.com/foobar/x"},
+			{Importpath: "github.com/other/x"},
+			{Importpath: "golang.org/x/net", Parents: []string{"github.com/foo/bar"}},
+			{Importpath: "golang.org/x/text", Parents: []string{"golang.org/x/net"}},
+		},
+	}
+	selected, skipped := m.Select("github.com/foo/")
+
+	paths := func(deps []Dependency) []string {
+		var s []string
+		for _, d := range deps {
+			s = append(s, d.Importpath)
+		}
+		return s
+	}
+	want := []string{"github.com/foo/bar", "github.com/foo/baz", "golang.org/x/net", "golang.org/x/text"}
+	if got := paths(selected); !reflect.DeepEqual(got, want) {
+		t.Fatalf("Select: want selected %v, got %v", want, got)
+	}
+	want = []string{"github.com/foobar/x", "github.com/other/x"}
+	if got := paths(skipped); !reflect.DeepEqual(got, want) {
+		t.Fatalf("Select: want skipped %v, got %v", want, got)
+	}
+}
+
+func TestAlias(t *testing.T) {
+	m := Manifest{
+		Dependencies: []Dependency{
+			{Importpath: "gopkg.in/yaml.v2"},
+			{Importpath: "github.com/pkg/errors"},
+		},
+	}
+	if err := m.SetAlias("gopkg.in/yaml.v2", "yaml"); err != nil {
+		t.Fatal(err)
+	}
+	for name, want := range map[string]string{
+		"yaml":                  "gopkg.in/yaml.v2",
+		"gopkg.in/yaml.v2":      "gopkg.in/yaml.v2",
+		"github.com/pkg/errors": "github.com/pkg/errors",
+		"unknown":               "unknown",
+	} {
+		if got := m.Resolve(name); got != want {
+			t.Errorf("Resolve(%s): want %s, got %s", name, want, got)
+		}
+	}
+
+	if err := m.SetAlias("gopkg.in/yaml.v2", "yaml"); err != nil {
+		t.Errorf("SetAlias to the same alias: %v", err)
+	}
+	if err := m.SetAlias("github.com/pkg/errors", "yaml"); err == nil {
+		t.Error("SetAlias to a used alias: want error, got nil")
+	}
+	if err := m.SetAlias("github.com/pkg/errors", "foo/bar"); err == nil {
+		t.Error("SetAlias to an import path: want error, got nil")
+	}
+	if err := m.SetAlias("github.com/other/x", "x"); err == nil {
+		t.Error("SetAlias of a missing dependency: want error, got nil")
+	}
+
+	if err := m.SetAlias("gopkg.in/yaml.v2", ""); err != nil {
+		t.Fatal(err)
+	}
+	if got := m.Resolve("yaml"); got != "yaml" {
+		t.Errorf("Resolve(yaml) after removal: want yaml, got %s", got)
+	}
+}
@@ -0,0 +1,66 @@
+package vendor
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Policies resolving the conflicts of Merge.
+const (
+	KeepMine       = "mine"   // keep the dependency of the manifest merged into
+	TakeTheirs     = "theirs" // take the dependency of the merged manifest
+	FailOnConflict = "error"  // fail, listing the conflicts
+)
+
+// Conflict is an import path merged at a revision differing from the
+// recorded one.
+type Conflict struct {
+	Mine, Theirs Dependency
+}
+
+// Merge adds to m the dependencies of other, resolving the import paths
+// recorded in both at different revisions according to policy. It
+// returns the dependencies it added or replaced, and the conflicts.
+// With FailOnConflict m is left unchanged if there is any conflict.
+func (m *Manifest) Merge(other *Manifest, policy string) (changed []Dependency, conflicts []Conflict, err error) {
+	switch policy {
+	case KeepMine, TakeTheirs, FailOnConflict:
+	default:
+		return nil, nil, fmt.Errorf("unknown conflict policy %q, expected mine, theirs or error", policy)
+	}
+	for _, theirs := range other.Dependencies {
+		mine, err := m.GetDependencyForImportpath(theirs.Importpath)
+		if err == nil && mine.Revision != theirs.Revision {
+			conflicts = append(conflicts, Conflict{mine, theirs})
+		}
+	}
+	if policy == FailOnConflict && len(conflicts) > 0 {
+		var s []string
+		for _, c := range conflicts {
+			s = append(s, fmt.Sprintf("%s at %s and %s", c.Mine.Importpath, c.Mine.Revision, c.Theirs.Revision))
+		}
+		return nil, conflicts, fmt.Errorf("conflicting revisions: %s", strings.Join(s, ", "))
+	}
+
+	for _, theirs := range other.Dependencies {
+		mine, err := m.GetDependencyForImportpath(theirs.Importpath)
+		if err != nil {
+			if err := m.AddDependency(theirs); err != nil {
+				return nil, nil, err
+			}
+			changed = append(changed, theirs)
+			continue
+		}
+		if mine.Revision == theirs.Revision || policy == KeepMine {
+			continue
+		}
+		if err := m.RemoveDependency(mine); err != nil {
+			return nil, nil, err
+		}
+		if err := m.AddDependency(theirs); err != nil {
+			return nil, nil, err
+		}
+		changed = append(changed, theirs)
+	}
+	return changed, conflicts, nil
+}
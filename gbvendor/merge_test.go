@@ -0,0 +1,102 @@
+package vendor
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func mergeFixture() (mine, theirs *Manifest) {
+	dep := func(importpath, revision string) Dependency {
+		return Dependency{
+			Importpath: importpath,
+			Repository: "https://" + importpath,
+			Revision:   revision,
+			Branch:     "master",
+		}
+	}
+	mine = &Manifest{Dependencies: []Dependency{
+		dep("github.com/a/a", "aaaa"),
+		dep("github.com/b/b", "bbbb"),
+		dep("github.com/c/c", "cccc"),
+	}}
+	theirs = &Manifest{Dependencies: []Dependency{
+		dep("github.com/b/b", "bbbb"),
+		dep("github.com/c/c", "c2c2"),
+		dep("github.com/d/d", "dddd"),
+	}}
+	return mine, theirs
+}
+
+func revisions(m *Manifest) map[string]string {
+	revs := make(map[string]string)
+	for _, d := range m.Dependencies {
+		revs[d.Importpath] = d.Revision
+	}
+	return revs
+}
+
+func importpaths(deps []Dependency) []string {
+	var s []string
+	for _, d := range deps {
+		s = append(s, d.Importpath)
+	}
+	sort.Strings(s)
+	return s
+}
+
+func TestMergeWithoutConflict(t *testing.T) {
+	mine, theirs := mergeFixture()
+	theirs.Dependencies = theirs.Dependencies[2:]
+	changed, conflicts, err := mine.Merge(theirs, FailOnConflict)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(conflicts) != 0 {
+		t.Fatalf("Merge: want no conflict, got %v", conflicts)
+	}
+	if got, want := importpaths(changed), []string{"github.com/d/d"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("Merge: want changed %v, got %v", want, got)
+	}
+	if got := len(mine.Dependencies); got != 4 {
+		t.Fatalf("Merge: want 4 dependencies, got %d", got)
+	}
+}
+
+func TestMergePolicies(t *testing.T) {
+	tests := []struct {
+		policy  string
+		changed []string
+		c       string // revision of github.com/c/c after the merge
+		err     bool
+	}{
+		{KeepMine, []string{"github.com/d/d"}, "cccc", false},
+		{TakeTheirs, []string{"github.com/c/c", "github.com/d/d"}, "c2c2", false},
+		{FailOnConflict, nil, "cccc", true},
+	}
+	for _, tt := range tests {
+		mine, theirs := mergeFixture()
+		changed, conflicts, err := mine.Merge(theirs, tt.policy)
+		if (err != nil) != tt.err {
+			t.Errorf("Merge(%s): want error %v, got %v", tt.policy, tt.err, err)
+			continue
+		}
+		if len(conflicts) != 1 || conflicts[0].Mine.Revision != "cccc" || conflicts[0].Theirs.Revision != "c2c2" {
+			t.Errorf("Merge(%s): want the github.com/c/c conflict, got %v", tt.policy, conflicts)
+		}
+		if got := importpaths(changed); !reflect.DeepEqual(got, tt.changed) {
+			t.Errorf("Merge(%s): want changed %v, got %v", tt.policy, tt.changed, got)
+		}
+		revs := revisions(mine)
+		if revs["github.com/c/c"] != tt.c {
+			t.Errorf("Merge(%s): want github.com/c/c at %s, got %s", tt.policy, tt.c, revs["github.com/c/c"])
+		}
+		if _, ok := revs["github.com/d/d"]; ok == tt.err {
+			t.Errorf("Merge(%s): github.com/d/d merged %v", tt.policy, ok)
+		}
+	}
+
+	if _, _, err := new(Manifest).Merge(new(Manifest), "ours"); err == nil {
+		t.Error("Merge(ours): want error, got nil")
+	}
+}
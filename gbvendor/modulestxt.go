@@ -0,0 +1,243 @@
+package vendor
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// VendoredModule is a module listed by the vendor/modules.txt that go mod
+// vendor writes.
+type VendoredModule struct {
+	Path           string   // module path, the directory of vendor it is in
+	Version        string   // version required, "" if replaced at every version
+	Replace        string   // module path or directory replacing it, if any
+	ReplaceVersion string   // version of Replace, "" for a directory
+	Packages       []string // import paths of the packages vendored
+}
+
+// Local reports whether m is replaced by a directory, which no repository
+// can be fetched for.
+func (m VendoredModule) Local() bool {
+	r := m.Replace
+	return strings.HasPrefix(r, "./") || strings.HasPrefix(r, "../") || filepath.IsAbs(r)
+}
+
+// Source returns the module path and version the files of m come from:
+// those of its replacement if it is replaced.
+func (m VendoredModule) Source() (string, string) {
+	if m.Replace != "" {
+		return m.Replace, m.ReplaceVersion
+	}
+	return m.Path, m.Version
+}
+
+// Pin returns what the version of m stands for in the repository whose
+// root is the import path root: the revision of a pseudo-version, or else
+// the tag of the version, see ModuleTag.
+func (m VendoredModule) Pin(root string) (revision, tag string) {
+	path, version := m.Source()
+	if rev, ok := PseudoVersionRevision(version); ok {
+		return rev, ""
+	}
+	return "", ModuleTag(path, root, version)
+}
+
+// RelativePackages returns the packages of m relative to its module path,
+// "." being the module itself, as Dependency.Packages lists them.
+func (m VendoredModule) RelativePackages() []string {
+	var pkgs []string
+	for _, p := range m.Packages {
+		if p == m.Path {
+			pkgs = append(pkgs, ".")
+		} else {
+			pkgs = append(pkgs, strings.TrimPrefix(p, m.Path+"/"))
+		}
+	}
+	return pkgs
+}
+
+// ReadModulesTxt reads the modules listed by the modules.txt file, in the
+// order they are. Modules without any package vendored, which go mod
+// vendor lists for their replacements or go versions, are left out.
+func ReadModulesTxt(file string) ([]VendoredModule, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var mods []VendoredModule
+	var cur *VendoredModule
+	s := bufio.NewScanner(f)
+	for n := 1; s.Scan(); n++ {
+		line := strings.TrimSpace(s.Text())
+		switch {
+		case line == "", strings.HasPrefix(line, "##"):
+			// annotations such as ## explicit
+		case strings.HasPrefix(line, "#"):
+			m, err := parseModuleLine(strings.TrimSpace(line[1:]))
+			if err != nil {
+				return nil, fmt.Errorf("%s:%d: %v", file, n, err)
+			}
+			mods = append(mods, m)
+			cur = &mods[len(mods)-1]
+		case cur == nil:
+			return nil, fmt.Errorf("%s:%d: package %s outside of any module", file, n, line)
+		default:
+			cur.Packages = append(cur.Packages, line)
+		}
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	vendored := mods[:0]
+	for _, m := range mods {
+		if len(m.Packages) > 0 {
+			vendored = append(vendored, m)
+		}
+	}
+	return vendored, nil
+}
+
+// parseModuleLine parses "path [version] [=> replace [version]]".
+func parseModuleLine(line string) (VendoredModule, error) {
+	var m VendoredModule
+	left, right := line, ""
+	if i := strings.Index(line, "=>"); i >= 0 {
+		left, right = line[:i], line[i+2:]
+	}
+	lf, rf := strings.Fields(left), strings.Fields(right)
+	if len(lf) < 1 || len(lf) > 2 || len(rf) > 2 || (right != "" && len(rf) == 0) {
+		return m, fmt.Errorf("malformed module line %q", line)
+	}
+	m.Path = lf[0]
+	if len(lf) == 2 {
+		m.Version = lf[1]
+	}
+	if len(rf) > 0 {
+		m.Replace = rf[0]
+	}
+	if len(rf) == 2 {
+		m.ReplaceVersion = rf[1]
+	}
+	if m.Version == "" && m.Replace == "" {
+		return m, fmt.Errorf("module %s without version", m.Path)
+	}
+	return m, nil
+}
+
+// VendoredChanges compares the files of the vendored tree at got, which
+// may hold only some of the files of the tree at want, with those of want:
+// it returns, sorted by path, the files of got modified or not in want at
+// all. Files of want left out of got are not changes. Like DiffTrees, the
+// trees of nested are left out.
+func VendoredChanges(want, got string, nested []string) ([]FileChange, error) {
+	wsums, err := fileSums(want, nested)
+	if err != nil {
+		return nil, err
+	}
+	gsums, err := fileSums(got, nested)
+	if err != nil {
+		return nil, err
+	}
+	var changes []FileChange
+	for _, c := range diffSums(wsums, gsums) {
+		if c.Change != FileRemoved {
+			changes = append(changes, c)
+		}
+	}
+	return changes, nil
+}
+
+// UnmappedModule is a module ModuleDependencies could not map to a
+// Dependency, and why.
+type UnmappedModule struct {
+	Module VendoredModule
+	Err    error
+}
+
+// ModuleDependencies maps each module of mods, those of the modules.txt
+// of vendorDir, to the Dependency vendoring its packages at the revision
+// its version stands for, which it checks out to resolve. Modules replaced
+// by a directory, and those whose repository or version cannot be found,
+// are returned as unmapped. With verify, so are the modules whose vendored
+// files differ from those of the revision.
+func ModuleDependencies(mods []VendoredModule, vendorDir string, verify, insecure bool) ([]Dependency, []UnmappedModule) {
+	var deps []Dependency
+	var unmapped []UnmappedModule
+	for _, m := range mods {
+		dep, err := moduleDependency(m, mods, vendorDir, verify, insecure)
+		if err != nil {
+			unmapped = append(unmapped, UnmappedModule{m, err})
+			continue
+		}
+		deps = append(deps, dep)
+	}
+	return deps, unmapped
+}
+
+func moduleDependency(m VendoredModule, mods []VendoredModule, vendorDir string, verify, insecure bool) (Dependency, error) {
+	if m.Local() {
+		return Dependency{}, fmt.Errorf("replaced by the directory %s", m.Replace)
+	}
+	src, _ := m.Source()
+	repo, extra, err := DeduceRemoteRepo(src, insecure)
+	if err != nil {
+		return Dependency{}, err
+	}
+	revision, tag := m.Pin(strings.TrimSuffix(src, extra))
+	wc, err := repo.Checkout("", tag, revision)
+	if err != nil {
+		return Dependency{}, err
+	}
+	defer wc.Destroy()
+	rev, err := wc.Revision()
+	if err != nil {
+		return Dependency{}, err
+	}
+	if _, err := os.Stat(filepath.Join(wc.Dir(), extra)); os.IsNotExist(err) {
+		// a module at the root of its repository, whose major version
+		// suffix is not a directory.
+		extra = trimMajorVersion(extra)
+	}
+
+	// the modules vendored below m are not part of its tree.
+	var nested []string
+	for _, o := range mods {
+		if strings.HasPrefix(o.Path, m.Path+"/") {
+			nested = append(nested, strings.TrimPrefix(o.Path, m.Path+"/"))
+		}
+	}
+	dst := filepath.Join(vendorDir, filepath.FromSlash(m.Path))
+	if verify {
+		changes, err := VendoredChanges(filepath.Join(wc.Dir(), extra), dst, nested)
+		if err != nil {
+			return Dependency{}, err
+		}
+		if len(changes) > 0 {
+			diff := changes[0].Path + " " + changes[0].Change
+			if len(changes) > 1 {
+				diff += fmt.Sprintf(" and %d more", len(changes)-1)
+			}
+			return Dependency{}, fmt.Errorf("vendored files differ from revision %s: %s", rev, diff)
+		}
+	}
+	dep := Dependency{
+		Importpath: m.Path,
+		Repository: repo.URL(),
+		Revision:   rev,
+		Tag:        tag,
+		Path:       extra,
+		Packages:   m.RelativePackages(),
+	}
+	if m.Replace != "" {
+		dep.Replacement = m.Replace
+	}
+	if dep.Checksum, err = TreeChecksum(dst, nested); err != nil {
+		return Dependency{}, err
+	}
+	return dep, nil
+}
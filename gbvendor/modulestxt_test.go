@@ -0,0 +1,123 @@
+package vendor
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/constabulary/gb/fileutils"
+)
+
+const modulesTxt = `# example.com/a v0.0.0-20200101120000-abcdef123456
+## explicit
+example.com/a
+example.com/a/sub
+# example.com/b v1.2.0
+## explicit; go 1.16
+example.com/b/pkg
+# example.com/old v1.0.0 => example.com/new v0.0.0-20210101120000-0123456789ab
+example.com/old
+# example.com/local v1.0.0 => ./local
+example.com/local
+# example.com/unused v1.0.0
+## explicit
+`
+
+func TestReadModulesTxt(t *testing.T) {
+	root := mktemp(t)
+	defer fileutils.RemoveAll(root)
+	writeFiles(t, root, map[string]string{"modules.txt": modulesTxt})
+
+	mods, err := ReadModulesTxt(filepath.Join(root, "modules.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []VendoredModule{
+		{Path: "example.com/a", Version: "v0.0.0-20200101120000-abcdef123456", Packages: []string{"example.com/a", "example.com/a/sub"}},
+		{Path: "example.com/b", Version: "v1.2.0", Packages: []string{"example.com/b/pkg"}},
+		{Path: "example.com/old", Version: "v1.0.0", Replace: "example.com/new", ReplaceVersion: "v0.0.0-20210101120000-0123456789ab", Packages: []string{"example.com/old"}},
+		{Path: "example.com/local", Version: "v1.0.0", Replace: "./local", Packages: []string{"example.com/local"}},
+	}
+	if !reflect.DeepEqual(mods, want) {
+		t.Fatalf("ReadModulesTxt: want %v, got %v", want, mods)
+	}
+	if !mods[3].Local() || mods[2].Local() {
+		t.Errorf("Local: only example.com/local is replaced by a directory")
+	}
+	if rev, tag := mods[1].Pin("example.com/b"); rev != "" || tag != "v1.2.0" {
+		t.Errorf("Pin of example.com/b: want tag v1.2.0, got %q, %q", rev, tag)
+	}
+	if got := mods[0].RelativePackages(); !reflect.DeepEqual(got, []string{".", "sub"}) {
+		t.Errorf("RelativePackages of example.com/a: want [. sub], got %v", got)
+	}
+
+	writeFiles(t, root, map[string]string{"bad.txt": "example.com/a\n"})
+	if _, err := ReadModulesTxt(filepath.Join(root, "bad.txt")); err == nil {
+		t.Error("ReadModulesTxt of a package outside of any module: want an error")
+	}
+}
+
+func TestModuleDependencies(t *testing.T) {
+	root := mktemp(t)
+	defer fileutils.RemoveAll(root)
+	fixtures, vendorDir := filepath.Join(root, "fixtures"), filepath.Join(root, "vendor")
+	writeFiles(t, fixtures, map[string]string{
+		"example.com/a/.fixture-revision":   "abcdef123456\n",
+		"example.com/a/a.go":                "package a\n",
+		"example.com/a/sub/sub.go":          "package sub\n",
+		"example.com/b/.fixture-revision":   "2222\n", // untagged
+		"example.com/b/pkg/pkg.go":          "package pkg\n",
+		"example.com/new/.fixture-revision": "0123456789ab\n",
+		"example.com/new/new.go":            "package old\n",
+	})
+	writeFiles(t, vendorDir, map[string]string{
+		"modules.txt":              modulesTxt,
+		"example.com/a/a.go":       "package a\n",
+		"example.com/a/sub/sub.go": "package sub\n",
+		"example.com/old/new.go":   "package old\n\n// patched\n",
+		"example.com/local/l.go":   "package local\n",
+	})
+	defer func(f Fetcher) { DefaultFetcher = f }(DefaultFetcher)
+	DefaultFetcher = &FixtureFetcher{Root: fixtures}
+
+	mods, err := ReadModulesTxt(filepath.Join(vendorDir, "modules.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// a root-level v2 module, in the major version branch layout.
+	writeFiles(t, fixtures, map[string]string{
+		"example.com/c/.fixture-revision": "333300000000\n",
+		"example.com/c/c.go":              "package c\n",
+	})
+	writeFiles(t, vendorDir, map[string]string{"example.com/c/v2/c.go": "package c\n"})
+	mods = append(mods, VendoredModule{Path: "example.com/c/v2", Version: "v2.0.0-20200101120000-333300000000", Packages: []string{"example.com/c/v2"}})
+
+	deps, unmapped := ModuleDependencies(mods, vendorDir, false, false)
+	for i := range deps {
+		if deps[i].Checksum == "" {
+			t.Errorf("ModuleDependencies: no checksum recorded for %s", deps[i].Importpath)
+		}
+		deps[i].Checksum = ""
+	}
+	want := []Dependency{
+		{Importpath: "example.com/a", Repository: "https://example.com/a", Revision: "abcdef123456", Packages: []string{".", "sub"}},
+		{Importpath: "example.com/old", Repository: "https://example.com/new", Revision: "0123456789ab", Packages: []string{"."}, Replacement: "example.com/new"},
+		{Importpath: "example.com/c/v2", Repository: "https://example.com/c", Revision: "333300000000", Packages: []string{"."}},
+	}
+	if !reflect.DeepEqual(deps, want) {
+		t.Fatalf("ModuleDependencies: want %v, got %v", want, deps)
+	}
+	var got []string
+	for _, u := range unmapped {
+		got = append(got, u.Module.Path)
+	}
+	if want := []string{"example.com/b", "example.com/local"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("ModuleDependencies: want %v unmapped, got %v", want, got)
+	}
+
+	// with verify, the patched example.com/old is not mapped.
+	deps, unmapped = ModuleDependencies(mods, vendorDir, true, false)
+	if len(deps) != 2 || deps[0].Importpath != "example.com/a" || deps[1].Importpath != "example.com/c/v2" || len(unmapped) != 3 {
+		t.Fatalf("ModuleDependencies with verify: want only example.com/a and example.com/c/v2, got %v, %v", deps, unmapped)
+	}
+}
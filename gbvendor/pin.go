@@ -0,0 +1,29 @@
+package vendor
+
+import (
+	"fmt"
+	"strings"
+)
+
+// RevisionMismatchError is returned by CheckRevision when a working copy
+// is not at the requested revision.
+type RevisionMismatchError struct {
+	Requested, Got string
+}
+
+func (e *RevisionMismatchError) Error() string {
+	return fmt.Sprintf("requested revision %s but checked out %s", e.Requested, e.Got)
+}
+
+// CheckRevision returns a RevisionMismatchError unless wc is at revision
+// requested. An abbreviated revision matches the full one it starts.
+func CheckRevision(wc WorkingCopy, requested string) error {
+	rev, err := wc.Revision()
+	if err != nil {
+		return err
+	}
+	if requested == "" || !strings.HasPrefix(strings.ToLower(rev), strings.ToLower(requested)) {
+		return &RevisionMismatchError{requested, rev}
+	}
+	return nil
+}
@@ -0,0 +1,26 @@
+package vendor
+
+import "testing"
+
+// fixedWorkingCopy is a WorkingCopy always at the same revision.
+type fixedWorkingCopy string
+
+func (w fixedWorkingCopy) Dir() string               { return "" }
+func (w fixedWorkingCopy) Revision() (string, error) { return string(w), nil }
+func (w fixedWorkingCopy) Branch() (string, error)   { return "master", nil }
+func (w fixedWorkingCopy) Destroy() error            { return nil }
+
+func TestCheckRevision(t *testing.T) {
+	wc := fixedWorkingCopy("0123456789abcdef0123456789abcdef01234567")
+	for _, rev := range []string{"0123456789abcdef0123456789abcdef01234567", "0123456", "0123456789ABCDEF"} {
+		if err := CheckRevision(wc, rev); err != nil {
+			t.Errorf("CheckRevision(%q): %v", rev, err)
+		}
+	}
+	for _, rev := range []string{"", "fedcba9", "0123456789abcdef0123456789abcdef01234568"} {
+		err := CheckRevision(wc, rev)
+		if e, ok := err.(*RevisionMismatchError); !ok || e.Got != string(wc) || e.Requested != rev {
+			t.Errorf("CheckRevision(%q): want a RevisionMismatchError, got %v", rev, err)
+		}
+	}
+}
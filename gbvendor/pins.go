@@ -0,0 +1,81 @@
+package vendor
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// UpstreamManifest returns the manifest of the gvt project checked out at
+// dir, vendor/manifest or, failing that, a valid manifest at its root, or
+// nil if dir is not a gvt project.
+func UpstreamManifest(dir string) (*Manifest, error) {
+	if _, err := os.Stat(filepath.Join(dir, "vendor", "manifest")); err == nil {
+		return ReadVendoredManifest(filepath.Join(dir, "vendor", "manifest"))
+	}
+	if _, err := os.Stat(filepath.Join(dir, "manifest")); err == nil {
+		// a file named manifest may be anything, only a valid one counts.
+		if m, err := ReadVendoredManifest(filepath.Join(dir, "manifest")); err == nil && len(m.Dependencies) > 0 {
+			return m, nil
+		}
+	}
+	return nil, nil
+}
+
+// Pins are the dependencies recorded by the manifests of upstream gvt
+// projects, or required by the go.mod of upstream modules, whose revisions
+// the recursive dependencies they list adopt.
+// It is safe for concurrent use.
+type Pins struct {
+	mu   sync.Mutex
+	deps map[string]Dependency
+}
+
+// Add adds the dependencies of m. A dependency already pinned keeps its
+// first pin.
+func (p *Pins) Add(m *Manifest) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.deps == nil {
+		p.deps = make(map[string]Dependency)
+	}
+	for _, d := range m.Dependencies {
+		if _, ok := p.deps[d.Importpath]; !ok {
+			p.deps[d.Importpath] = d
+		}
+	}
+}
+
+// AddRequires adds the module versions required by a go.mod, see
+// GoModRequires. Those of pseudo-versions pin a revision, the others a
+// Tag holding the version, see ModuleTag. A module already pinned keeps
+// its first pin.
+func (p *Pins) AddRequires(reqs map[string]string) {
+	m := new(Manifest)
+	for path, version := range reqs {
+		d := Dependency{Importpath: path}
+		if rev, ok := PseudoVersionRevision(version); ok {
+			d.Revision = rev
+		} else {
+			d.Tag = version
+		}
+		m.Dependencies = append(m.Dependencies, d)
+	}
+	p.Add(m)
+}
+
+// Lookup returns the pinned dependency path is part of, that with the
+// longest import path if several are.
+func (p *Pins) Lookup(path string) (Dependency, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var pin Dependency
+	found := false
+	for ip, d := range p.deps {
+		if (path == ip || strings.HasPrefix(path, ip+"/")) && len(ip) > len(pin.Importpath) {
+			pin, found = d, true
+		}
+	}
+	return pin, found
+}
@@ -0,0 +1,134 @@
+package vendor
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/constabulary/gb/fileutils"
+)
+
+func TestPins(t *testing.T) {
+	root := mktemp(t)
+	defer fileutils.RemoveAll(root)
+
+	writeFiles(t, root, map[string]string{
+		"gvt/vendor/manifest": `{"version": 0, "dependencies": [
+			{"importpath": "example.com/lib", "repository": "https://example.com/lib", "revision": "1111111"},
+			{"importpath": "example.com/lib/v2", "repository": "https://example.com/lib", "revision": "2222222"},
+			{"importpath": "example.com/shared", "repository": "https://example.com/shared", "revision": "aaaaaaa"}
+		]}`,
+		"other/manifest": `{"version": 0, "dependencies": [
+			{"importpath": "example.com/shared", "repository": "https://example.com/shared", "revision": "bbbbbbb"},
+			{"importpath": "example.com/new", "repository": "https://example.com/new", "revision": "ccccccc"}
+		]}`,
+		"k8s/manifest": "apiVersion: v1\nkind: Pod\n",
+		"plain/foo.go": "package foo\n",
+	})
+
+	var pins Pins
+	for _, dir := range []string{"gvt", "other", "k8s", "plain"} {
+		m, err := UpstreamManifest(filepath.Join(root, dir))
+		if err != nil {
+			t.Fatalf("UpstreamManifest(%s): %v", dir, err)
+		}
+		if (m != nil) != (dir == "gvt" || dir == "other") {
+			t.Fatalf("UpstreamManifest(%s): unexpected %v", dir, m)
+		}
+		if m != nil {
+			pins.Add(m)
+		}
+	}
+
+	tests := []struct {
+		path, revision string
+	}{
+		{"example.com/lib", "1111111"},
+		{"example.com/lib/sub", "1111111"},
+		{"example.com/lib/v2/sub", "2222222"},
+		{"example.com/shared", "aaaaaaa"}, // the first pin wins
+		{"example.com/new", "ccccccc"},
+		{"example.com/library", ""},
+		{"example.com/unpinned", ""},
+	}
+	for _, tt := range tests {
+		pin, ok := pins.Lookup(tt.path)
+		if ok != (tt.revision != "") || pin.Revision != tt.revision {
+			t.Errorf("Lookup(%q): want %q, got %q, %v", tt.path, tt.revision, pin.Revision, ok)
+		}
+	}
+}
+
+func TestPinsRequires(t *testing.T) {
+	root := mktemp(t)
+	defer fileutils.RemoveAll(root)
+
+	writeFiles(t, root, map[string]string{
+		"dep/go.mod": `module example.com/dep
+
+go 1.16
+
+require example.com/single v1.0.0
+
+require (
+	example.com/lib v1.2.3
+	example.com/lib/v2 v2.0.1 // indirect
+	example.com/repo/sub v0.3.0
+	example.com/old v2.1.0+incompatible
+	example.com/untagged v0.0.0-20200102150405-abcdef123456
+	"example.com/quoted" v1.1.0
+)
+
+replace example.com/lib => ../lib
+`,
+		"nomod/foo.go": "package foo\n",
+	})
+
+	reqs, err := GoModRequires(filepath.Join(root, "nomod"))
+	if err != nil || reqs != nil {
+		t.Fatalf("GoModRequires without go.mod: want nil, got %v, %v", reqs, err)
+	}
+	reqs, err = GoModRequires(filepath.Join(root, "dep"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reqs) != 7 {
+		t.Fatalf("GoModRequires: want 7 modules, got %v", reqs)
+	}
+
+	var pins Pins
+	pins.Add(&Manifest{Dependencies: []Dependency{
+		{Importpath: "example.com/single", Repository: "https://example.com/single", Revision: "1111111"},
+	}})
+	pins.AddRequires(reqs)
+
+	// root is the import path of the root of the repository of the
+	// transitive dependency fetched.
+	tests := []struct {
+		path, root, revision, tag string
+	}{
+		{"example.com/single", "example.com/single", "1111111", ""}, // the first pin wins
+		{"example.com/lib/pkg", "example.com/lib", "", "v1.2.3"},
+		{"example.com/lib/v2/pkg", "example.com/lib", "", "v2.0.1"},
+		{"example.com/repo/sub/pkg", "example.com/repo", "", "sub/v0.3.0"},
+		{"example.com/old", "example.com/old", "", "v2.1.0"},
+		{"example.com/untagged/pkg", "example.com/untagged", "abcdef123456", ""},
+		{"example.com/quoted", "example.com/quoted", "", "v1.1.0"},
+	}
+	for _, tt := range tests {
+		pin, ok := pins.Lookup(tt.path)
+		if !ok {
+			t.Errorf("Lookup(%q): not pinned", tt.path)
+			continue
+		}
+		var tag string
+		if pin.Tag != "" {
+			tag = ModuleTag(pin.Importpath, tt.root, pin.Tag)
+		}
+		if pin.Revision != tt.revision || tag != tt.tag {
+			t.Errorf("Lookup(%q): want revision %q, tag %q, got %q, %q", tt.path, tt.revision, tt.tag, pin.Revision, tag)
+		}
+	}
+	if _, ok := pins.Lookup("example.com/unrequired"); ok {
+		t.Error("Lookup of a module not required: want no pin")
+	}
+}
@@ -0,0 +1,107 @@
+package vendor
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ProbeCacheFile is the file remembering the go-import metadata found by
+// ParseMetadata. If empty, metadata is probed every time.
+var ProbeCacheFile string
+
+// ProbeCacheTTL is how long an entry of ProbeCacheFile is trusted.
+var ProbeCacheTTL = 24 * time.Hour
+
+// RefreshProbeCache ignores the entries of ProbeCacheFile, probing again
+// and replacing them.
+var RefreshProbeCache bool
+
+// probeEntry is the go-import metadata of an import path prefix.
+type probeEntry struct {
+	VCS      string    `json:"vcs"`
+	RepoRoot string    `json:"reporoot"`
+	Time     time.Time `json:"time"`
+
+	// Insecure is set when the metadata was fetched over http, only
+	// trusted by insecure lookups.
+	Insecure bool `json:"insecure,omitempty"`
+}
+
+// lookupProbeCache returns the cached metadata of the longest prefix of
+// path, leaving out that fetched over http unless insecure is set.
+func lookupProbeCache(path string, insecure bool) (prefix, vcs, reporoot string, ok bool) {
+	if ProbeCacheFile == "" || RefreshProbeCache {
+		return "", "", "", false
+	}
+	entries, err := readProbeCache()
+	if err != nil {
+		return "", "", "", false
+	}
+	for p, e := range entries {
+		if path != p && !strings.HasPrefix(path, p+"/") {
+			continue
+		}
+		if (e.Insecure && !insecure) || time.Since(e.Time) > ProbeCacheTTL || len(p) <= len(prefix) {
+			continue
+		}
+		prefix, vcs, reporoot, ok = p, e.VCS, e.RepoRoot, true
+	}
+	return prefix, vcs, reporoot, ok
+}
+
+// probeCacheMu serialises the updates of ProbeCacheFile by concurrent fetches.
+var probeCacheMu sync.Mutex
+
+// storeProbeCache records the metadata of prefix, fetched over http if
+// insecure is set. Failures are ignored, the cache is only an optimisation.
+func storeProbeCache(prefix, vcs, reporoot string, insecure bool) {
+	if ProbeCacheFile == "" {
+		return
+	}
+	probeCacheMu.Lock()
+	defer probeCacheMu.Unlock()
+	entries, err := readProbeCache()
+	if err != nil {
+		entries = make(map[string]probeEntry)
+	}
+	entries[prefix] = probeEntry{VCS: vcs, RepoRoot: reporoot, Time: time.Now(), Insecure: insecure}
+	buf, err := json.MarshalIndent(entries, "", "\t")
+	if err != nil {
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(ProbeCacheFile), 0755); err != nil {
+		return
+	}
+	// a temporary file of its own, concurrent gvt processes may store too.
+	tmp, err := ioutil.TempFile(filepath.Dir(ProbeCacheFile), filepath.Base(ProbeCacheFile)+".tmp-")
+	if err != nil {
+		return
+	}
+	defer os.Remove(tmp.Name())
+	_, err = tmp.Write(buf)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return
+	}
+	os.Rename(tmp.Name(), ProbeCacheFile)
+}
+
+func readProbeCache() (map[string]probeEntry, error) {
+	buf, err := ioutil.ReadFile(ProbeCacheFile)
+	if err != nil {
+		return nil, err
+	}
+	entries := make(map[string]probeEntry)
+	err = json.Unmarshal(buf, &entries)
+	return entries, err
+}
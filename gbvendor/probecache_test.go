@@ -0,0 +1,71 @@
+package vendor
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/constabulary/gb/fileutils"
+)
+
+func TestProbeCache(t *testing.T) {
+	var hits int
+	var prefix string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		fmt.Fprintf(w, `<html><head><meta name="go-import" content="%s git https://example.com/foo"></head></html>`, prefix)
+	}))
+	defer srv.Close()
+	prefix = strings.TrimPrefix(srv.URL, "http://") + "/foo"
+
+	dir := mktemp(t)
+	defer fileutils.RemoveAll(dir)
+	defer func(f string, ttl time.Duration) { ProbeCacheFile, ProbeCacheTTL, RefreshProbeCache = f, ttl, false }(ProbeCacheFile, ProbeCacheTTL)
+	ProbeCacheFile = filepath.Join(dir, "probe-cache")
+
+	resolve := func(path string, wantHits int) {
+		t.Helper()
+		importpath, vcs, reporoot, err := ParseMetadata(path, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if importpath != prefix || vcs != "git" || reporoot != "https://example.com/foo" {
+			t.Fatalf("ParseMetadata(%q): got %q %q %q", path, importpath, vcs, reporoot)
+		}
+		if hits != wantHits {
+			t.Fatalf("ParseMetadata(%q): want %d requests, got %d", path, wantHits, hits)
+		}
+	}
+
+	resolve(prefix+"/bar", 1)
+	// served from the cache, subpackages included.
+	resolve(prefix+"/bar", 1)
+	resolve(prefix+"/baz", 1)
+
+	RefreshProbeCache = true
+	resolve(prefix, 2)
+	RefreshProbeCache = false
+
+	ProbeCacheTTL = 0
+	resolve(prefix, 3)
+	ProbeCacheTTL = time.Hour
+
+	// the metadata was fetched over http, a secure lookup probes again.
+	if _, _, _, err := ParseMetadata(prefix+"/bar", false); err == nil {
+		t.Fatalf("ParseMetadata(%q) without insecure: want the http metadata left out", prefix+"/bar")
+	}
+
+	// the cache is written through temporary files of its own.
+	names, err := filepath.Glob(filepath.Join(dir, "*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{ProbeCacheFile}; !reflect.DeepEqual(names, want) {
+		t.Fatalf("probe cache directory: want %v, got %v", want, names)
+	}
+}
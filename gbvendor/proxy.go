@@ -0,0 +1,207 @@
+package vendor
+
+import (
+	"archive/zip"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode"
+)
+
+var (
+	// ErrNotModule is returned by CompareWithProxy for a tree without a
+	// go.mod, which a proxy cannot be asked about.
+	ErrNotModule = errors.New("not a module")
+
+	// ErrNotOnProxy is returned by CompareWithProxy when the proxy does
+	// not serve the module at the revision.
+	ErrNotOnProxy = errors.New("not on the proxy")
+)
+
+// proxyClient downloads from module proxies.
+var proxyClient = &http.Client{Timeout: 5 * time.Minute}
+
+// ProxyURL returns the first module proxy listed by GOPROXY, the default
+// proxy.golang.org if it is not set, and false if it only lists direct or
+// off.
+func ProxyURL() (string, bool) {
+	v, ok := os.LookupEnv("GOPROXY")
+	if !ok || v == "" {
+		return "https://proxy.golang.org", true
+	}
+	for _, p := range strings.FieldsFunc(v, func(r rune) bool { return r == ',' || r == '|' }) {
+		if p != "direct" && p != "off" {
+			return strings.TrimSuffix(p, "/"), true
+		}
+	}
+	return "", false
+}
+
+// CompareWithProxy compares the tree at root, the root of a module checked
+// out at rev, with the zip the module proxy at proxy serves for the same
+// module and revision. It returns the version the proxy resolved rev to
+// and the files of the module zip below sub, a slash separated directory
+// relative to root, "" for the whole module, that differ from the tree,
+// see DiffTrees: modified, added to the tree or removed from it. Like in
+// a vendored tree, hidden files are left out.
+func CompareWithProxy(proxy, root, sub, rev string) (string, []FileChange, error) {
+	modpath, err := ModulePath(root)
+	if err != nil {
+		return "", nil, err
+	}
+	if modpath == "" {
+		return "", nil, ErrNotModule
+	}
+	base := proxy + "/" + escapeModulePath(modpath) + "/@v/"
+
+	var info struct{ Version string }
+	if err := proxyGet(base+rev+".info", func(r io.Reader) error {
+		return json.NewDecoder(r).Decode(&info)
+	}); err != nil {
+		return "", nil, err
+	}
+
+	zsums := make(map[string]string)
+	if err := proxyGet(base+info.Version+".zip", func(r io.Reader) error {
+		return zipSums(r, modpath+"@"+info.Version+"/", zsums)
+	}); err != nil {
+		return info.Version, nil, err
+	}
+	tsums, err := moduleSums(root)
+	if err != nil {
+		return info.Version, nil, err
+	}
+	if sub != "" && sub != "." {
+		below := func(sums map[string]string) {
+			for path := range sums {
+				if !strings.HasPrefix(path, sub+"/") {
+					delete(sums, path)
+				}
+			}
+		}
+		below(zsums)
+		below(tsums)
+	}
+	return info.Version, diffSums(zsums, tsums), nil
+}
+
+// proxyGet gets url from a module proxy and passes the body to read.
+func proxyGet(url string, read func(io.Reader) error) error {
+	resp, err := proxyClient.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return read(resp.Body)
+	case http.StatusNotFound, http.StatusGone:
+		return ErrNotOnProxy
+	default:
+		return fmt.Errorf("%s: %s", url, resp.Status)
+	}
+}
+
+// zipSums adds to sums the hex SHA-256 of the files of the module zip read
+// from r, whose paths all start with prefix, by path relative to prefix.
+func zipSums(r io.Reader, prefix string, sums map[string]string) error {
+	f, err := ioutil.TempFile("", "gvt-proxy-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+	size, err := io.Copy(f, r)
+	if err != nil {
+		return err
+	}
+	z, err := zip.NewReader(f, size)
+	if err != nil {
+		return err
+	}
+	for _, zf := range z.File {
+		if !strings.HasPrefix(zf.Name, prefix) {
+			return fmt.Errorf("unexpected file %s in module zip", zf.Name)
+		}
+		rel := strings.TrimPrefix(zf.Name, prefix)
+		if hidden(rel) || strings.HasSuffix(rel, "/") {
+			continue
+		}
+		rc, err := zf.Open()
+		if err != nil {
+			return err
+		}
+		h := sha256.New()
+		_, err = io.Copy(h, rc)
+		rc.Close()
+		if err != nil {
+			return err
+		}
+		sums[rel] = fmt.Sprintf("%x", h.Sum(nil))
+	}
+	return nil
+}
+
+// moduleSums is fileSums for the files of root a module zip holds: those
+// of nested modules and of vendor directories are left out.
+func moduleSums(root string) (map[string]string, error) {
+	var nested []string
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() || path == root {
+			return nil
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		if info.Name() == "vendor" {
+			nested = append(nested, filepath.ToSlash(rel))
+			return filepath.SkipDir
+		}
+		if _, err := os.Stat(filepath.Join(path, "go.mod")); err == nil {
+			nested = append(nested, filepath.ToSlash(rel))
+			return filepath.SkipDir
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return fileSums(root, nested)
+}
+
+// hidden reports whether any element of the slash separated path starts
+// with a dot.
+func hidden(path string) bool {
+	for _, e := range strings.Split(path, "/") {
+		if strings.HasPrefix(e, ".") {
+			return true
+		}
+	}
+	return false
+}
+
+// escapeModulePath escapes the upper case letters of path, as module
+// proxies expect: github.com/Foo becomes github.com/!foo.
+func escapeModulePath(path string) string {
+	var b strings.Builder
+	for _, r := range path {
+		if unicode.IsUpper(r) {
+			b.WriteByte('!')
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
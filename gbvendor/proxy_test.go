@@ -0,0 +1,135 @@
+package vendor
+
+import (
+	"archive/zip"
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/constabulary/gb/fileutils"
+)
+
+// fakeProxy serves example.com/Mod at revision abc123, version v1.2.0,
+// with files as its content.
+func fakeProxy(t *testing.T, files map[string]string) *httptest.Server {
+	var buf bytes.Buffer
+	z := zip.NewWriter(&buf)
+	for name, body := range files {
+		w, err := z.Create("example.com/Mod@v1.2.0/" + name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(body))
+	}
+	if err := z.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/example.com/!mod/@v/abc123.info":
+			w.Write([]byte(`{"Version":"v1.2.0","Time":"2020-01-01T00:00:00Z"}`))
+		case "/example.com/!mod/@v/v1.2.0.zip":
+			w.Write(buf.Bytes())
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+}
+
+func TestCompareWithProxy(t *testing.T) {
+	module := map[string]string{
+		"go.mod":         "module example.com/Mod\n",
+		"mod.go":         "package mod\n",
+		"sub/sub.go":     "package sub\n",
+		"other/other.go": "package other\n",
+		".github/ci.yml": "on: push\n",
+	}
+	srv := fakeProxy(t, module)
+	defer srv.Close()
+
+	root := mktemp(t)
+	defer fileutils.RemoveAll(root)
+	writeFiles(t, root, module)
+	writeFiles(t, root, map[string]string{
+		".git/HEAD":          "ref: refs/heads/master\n", // not in module zips
+		"vendor/x/x.go":      "package x\n",
+		"nested/go.mod":      "module example.com/Mod/nested\n",
+		"nested/nested.go":   "package nested\n",
+		"other/.hidden/file": "hidden",
+	})
+
+	version, changes, err := CompareWithProxy(srv.URL, root, "", "abc123")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version != "v1.2.0" || len(changes) != 0 {
+		t.Fatalf("CompareWithProxy of an identical tree: want v1.2.0 and no change, got %s, %v", version, changes)
+	}
+
+	// tamper with the tree.
+	writeFiles(t, root, map[string]string{
+		"mod.go":         "package mod\n\nvar Backdoor = true\n",
+		"sub/extra.go":   "package sub\n",
+		"other/other.go": "package other\n\n// changed\n",
+	})
+	os.Remove(filepath.Join(root, "sub", "sub.go"))
+
+	_, changes, err = CompareWithProxy(srv.URL, root, "", "abc123")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []FileChange{
+		{"mod.go", FileModified},
+		{"other/other.go", FileModified},
+		{"sub/extra.go", FileAdded},
+		{"sub/sub.go", FileRemoved},
+	}
+	if !reflect.DeepEqual(changes, want) {
+		t.Fatalf("CompareWithProxy: want %v, got %v", want, changes)
+	}
+
+	// only the vendored directory is compared.
+	_, changes, err = CompareWithProxy(srv.URL, root, "sub", "abc123")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := want[2:]; !reflect.DeepEqual(changes, want) {
+		t.Fatalf("CompareWithProxy of sub: want %v, got %v", want, changes)
+	}
+
+	if _, _, err := CompareWithProxy(srv.URL, root, "", "def456"); err != ErrNotOnProxy {
+		t.Fatalf("CompareWithProxy of an unknown revision: want %v, got %v", ErrNotOnProxy, err)
+	}
+
+	os.Remove(filepath.Join(root, "go.mod"))
+	if _, _, err := CompareWithProxy(srv.URL, root, "", "abc123"); err != ErrNotModule {
+		t.Fatalf("CompareWithProxy without go.mod: want %v, got %v", ErrNotModule, err)
+	}
+}
+
+func TestProxyURL(t *testing.T) {
+	defer os.Setenv("GOPROXY", os.Getenv("GOPROXY"))
+
+	tests := []struct {
+		goproxy string
+		want    string
+		ok      bool
+	}{
+		{"", "https://proxy.golang.org", true},
+		{"https://goproxy.example.com/,direct", "https://goproxy.example.com", true},
+		{"direct", "", false},
+		{"off", "", false},
+		{"direct|https://b.example.com", "https://b.example.com", true},
+	}
+	for _, tt := range tests {
+		os.Setenv("GOPROXY", tt.goproxy)
+		got, ok := ProxyURL()
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("ProxyURL with GOPROXY=%q: want %q, %v, got %q, %v", tt.goproxy, tt.want, tt.ok, got, ok)
+		}
+	}
+}
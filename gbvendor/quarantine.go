@@ -0,0 +1,105 @@
+package vendor
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/constabulary/gb/fileutils"
+)
+
+// QuarantineDir returns the directory of vendorDir where fetch -quarantine
+// stages dependencies until they are promoted or rejected. Its name starts
+// with a dot, so the go tool ignores it.
+func QuarantineDir(vendorDir string) string {
+	return filepath.Join(vendorDir, ".quarantine")
+}
+
+func pendingFile(vendorDir string) string {
+	return filepath.Join(QuarantineDir(vendorDir), "manifest")
+}
+
+// Pending returns the manifest of the dependencies quarantined in
+// vendorDir, blank if there is none.
+func Pending(vendorDir string) (*Manifest, error) {
+	return ReadManifest(pendingFile(vendorDir))
+}
+
+// AddPending records dep, whose tree was copied to the QuarantineDir of
+// vendorDir, as quarantined.
+func AddPending(vendorDir string, dep Dependency) error {
+	p, err := Pending(vendorDir)
+	if err != nil {
+		return err
+	}
+	if err := p.AddDependency(dep); err != nil {
+		return err
+	}
+	return WriteManifest(pendingFile(vendorDir), p)
+}
+
+// Promote moves the quarantined tree of importpath into vendorDir and its
+// entry from the pending manifest to m, which the caller writes.
+func Promote(vendorDir string, m *Manifest, importpath string) error {
+	p, dep, err := pending(vendorDir, importpath)
+	if err != nil {
+		return err
+	}
+	if m.HasImportpath(importpath) {
+		return fmt.Errorf("%s is already vendored", importpath)
+	}
+	src := filepath.Join(QuarantineDir(vendorDir), filepath.FromSlash(importpath))
+	dst := filepath.Join(vendorDir, filepath.FromSlash(importpath))
+	if _, err := os.Stat(dst); err == nil {
+		return fmt.Errorf("%s already exists", dst)
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	if err := os.Rename(src, dst); err != nil {
+		return err
+	}
+	if err := m.AddDependency(dep); err != nil {
+		return err
+	}
+	return removePending(vendorDir, p, dep)
+}
+
+// Reject removes the quarantined tree of importpath and its entry from the
+// pending manifest.
+func Reject(vendorDir, importpath string) error {
+	p, dep, err := pending(vendorDir, importpath)
+	if err != nil {
+		return err
+	}
+	if err := fileutils.RemoveAll(filepath.Join(QuarantineDir(vendorDir), filepath.FromSlash(importpath))); err != nil {
+		return err
+	}
+	return removePending(vendorDir, p, dep)
+}
+
+// pending returns the pending manifest of vendorDir and its entry for
+// importpath.
+func pending(vendorDir, importpath string) (*Manifest, Dependency, error) {
+	p, err := Pending(vendorDir)
+	if err != nil {
+		return nil, Dependency{}, err
+	}
+	dep, err := p.GetDependencyForImportpath(importpath)
+	if err != nil {
+		return nil, Dependency{}, fmt.Errorf("%s is not quarantined", importpath)
+	}
+	return p, dep, nil
+}
+
+// removePending removes dep from p, the pending manifest of vendorDir,
+// and the directories its tree leaves empty, the QuarantineDir included.
+func removePending(vendorDir string, p *Manifest, dep Dependency) error {
+	if err := p.RemoveDependency(dep); err != nil {
+		return err
+	}
+	if err := WriteManifest(pendingFile(vendorDir), p); err != nil {
+		return err
+	}
+	return CleanPath(filepath.Dir(filepath.Join(QuarantineDir(vendorDir), filepath.FromSlash(dep.Importpath))), vendorDir)
+}
@@ -0,0 +1,98 @@
+package vendor
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/constabulary/gb/fileutils"
+)
+
+// quarantine stages the tree of importpath in the QuarantineDir of
+// vendorDir like fetch -quarantine.
+func quarantine(t *testing.T, vendorDir, importpath string) Dependency {
+	writeFiles(t, QuarantineDir(vendorDir), map[string]string{
+		importpath + "/dep.go": "package dep\n",
+	})
+	dep := Dependency{Importpath: importpath, Repository: "https://" + importpath, Revision: "1"}
+	if err := AddPending(vendorDir, dep); err != nil {
+		t.Fatal(err)
+	}
+	return dep
+}
+
+func TestQuarantinePromote(t *testing.T) {
+	vendorDir := filepath.Join(mktemp(t), "vendor")
+	defer fileutils.RemoveAll(filepath.Dir(vendorDir))
+
+	quarantine(t, vendorDir, "example.com/a")
+	quarantine(t, vendorDir, "example.com/b")
+
+	m := new(Manifest)
+	if err := Promote(vendorDir, m, "example.com/a"); err != nil {
+		t.Fatal(err)
+	}
+	if !m.HasImportpath("example.com/a") {
+		t.Fatal("Promote: example.com/a missing from the manifest")
+	}
+	assertExists(t, filepath.Join(vendorDir, "example.com", "a", "dep.go"))
+	assertNotExists(t, filepath.Join(QuarantineDir(vendorDir), "example.com", "a"))
+	p, err := Pending(vendorDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.HasImportpath("example.com/a") || !p.HasImportpath("example.com/b") {
+		t.Fatalf("Promote: want only example.com/b pending, got %v", p.Dependencies)
+	}
+
+	if err := Promote(vendorDir, m, "example.com/a"); err == nil {
+		t.Fatal("Promote of a dependency not quarantined: want an error")
+	}
+	quarantine(t, vendorDir, "example.com/a")
+	if err := Promote(vendorDir, m, "example.com/a"); err == nil {
+		t.Fatal("Promote of a dependency already vendored: want an error")
+	}
+	if err := Reject(vendorDir, "example.com/a"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Promote(vendorDir, m, "example.com/b"); err != nil {
+		t.Fatal(err)
+	}
+	if len(m.Dependencies) != 2 {
+		t.Fatalf("Promote: want 2 dependencies, got %v", m.Dependencies)
+	}
+	// nothing is left in quarantine.
+	assertNotExists(t, QuarantineDir(vendorDir))
+}
+
+func TestQuarantineReject(t *testing.T) {
+	vendorDir := filepath.Join(mktemp(t), "vendor")
+	defer fileutils.RemoveAll(filepath.Dir(vendorDir))
+
+	writeFiles(t, vendorDir, map[string]string{"example.com/c/c.go": "package c\n"})
+	quarantine(t, vendorDir, "example.com/a/sub")
+	quarantine(t, vendorDir, "example.com/b")
+
+	if err := Reject(vendorDir, "example.com/a/sub"); err != nil {
+		t.Fatal(err)
+	}
+	assertNotExists(t, filepath.Join(QuarantineDir(vendorDir), "example.com", "a"))
+	assertNotExists(t, filepath.Join(vendorDir, "example.com", "a"))
+	assertExists(t, filepath.Join(QuarantineDir(vendorDir), "example.com", "b", "dep.go"))
+
+	if err := Reject(vendorDir, "example.com/a/sub"); err == nil {
+		t.Fatal("Reject of a dependency not quarantined: want an error")
+	}
+	if err := Reject(vendorDir, "example.com/b"); err != nil {
+		t.Fatal(err)
+	}
+	p, err := Pending(vendorDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(p.Dependencies) != 0 {
+		t.Fatalf("Reject: want nothing pending, got %v", p.Dependencies)
+	}
+	assertNotExists(t, QuarantineDir(vendorDir))
+	assertExists(t, filepath.Join(vendorDir, "example.com", "c", "c.go"))
+}
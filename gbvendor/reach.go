@@ -0,0 +1,56 @@
+package vendor
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// defaultPorts are the ports dialed by Reachable for the schemes without
+// a cheap request to send.
+var defaultPorts = map[string]string{
+	"ssh": "22",
+	"git": "9418",
+}
+
+// Reachable checks, within timeout, that the host of rawurl accepts
+// connections, sending a HEAD request for http and https URLs and
+// opening a TCP connection otherwise. Any HTTP response, even an error
+// status, counts as reachable. The returned string describes the answer.
+func Reachable(rawurl string, timeout time.Duration) (string, error) {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return "", err
+	}
+	switch u.Scheme {
+	case "http", "https":
+		client := &http.Client{
+			Transport: httpTransport,
+			Timeout:   timeout,
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		}
+		resp, err := client.Head(u.String())
+		if err != nil {
+			return "", err
+		}
+		resp.Body.Close()
+		return resp.Status, nil
+	case "ssh", "git":
+		port := u.Port()
+		if port == "" {
+			port = defaultPorts[u.Scheme]
+		}
+		conn, err := net.DialTimeout("tcp", net.JoinHostPort(u.Hostname(), port), timeout)
+		if err != nil {
+			return "", err
+		}
+		conn.Close()
+		return "connected to port " + port, nil
+	default:
+		return "", fmt.Errorf("unsupported scheme: %v", u.Scheme)
+	}
+}
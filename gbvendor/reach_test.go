@@ -0,0 +1,37 @@
+package vendor
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestReachable(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.NotFound(w, r)
+	}))
+	defer srv.Close()
+
+	if _, err := Reachable(srv.URL+"/foo/bar", time.Second); err != nil {
+		t.Fatalf("Reachable(%s): %v", srv.URL, err)
+	}
+
+	// grab a free port, then close it so connections are refused.
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+	for _, u := range []string{"http://" + addr + "/foo/bar", "ssh://" + addr + "/foo/bar"} {
+		if _, err := Reachable(u, time.Second); err == nil {
+			t.Errorf("Reachable(%s): want error, got nil", u)
+		}
+	}
+
+	if _, err := Reachable("ftp://example.com/foo", time.Second); err == nil {
+		t.Error("Reachable(ftp://...): want error, got nil")
+	}
+}
@@ -0,0 +1,84 @@
+package vendor
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+var (
+	mdImage     = regexp.MustCompile(`!\[[^\]]*\]\([^)]*\)`)
+	mdLink      = regexp.MustCompile(`\[([^\]]*)\]\([^)]*\)`)
+	mdEmptyLink = regexp.MustCompile(`\[\s*\]\([^)]*\)`)
+	htmlTag     = regexp.MustCompile(`<[^>]*>`)
+	mdMarkup    = strings.NewReplacer("**", "", "__", "", "`", "")
+)
+
+// ReadmeExcerpt returns the first paragraph of the README of dir as plain
+// text, cut to at most max runes. It returns "" if dir has no README or
+// the README has no paragraph of text.
+func ReadmeExcerpt(dir string, max int) (string, error) {
+	fis, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return "", err
+	}
+	var names []string
+	for _, fi := range fis {
+		if fi.Mode().IsRegular() && strings.HasPrefix(strings.ToUpper(fi.Name()), "README") {
+			names = append(names, fi.Name())
+		}
+	}
+	if len(names) == 0 {
+		return "", nil
+	}
+	sort.Strings(names)
+	buf, err := ioutil.ReadFile(filepath.Join(dir, names[0]))
+	if err != nil && !os.IsNotExist(err) {
+		return "", err
+	}
+	return excerpt(string(buf), max), nil
+}
+
+// excerpt extracts the first paragraph of text from a README.
+func excerpt(readme string, max int) string {
+	var para []string
+	fence := false
+	for _, raw := range strings.Split(readme, "\n") {
+		line := strings.TrimSpace(raw)
+		if strings.HasPrefix(line, "```") || strings.HasPrefix(line, "~~~") {
+			fence = !fence
+			line = ""
+		}
+		line = mdImage.ReplaceAllString(line, "")
+		line = mdEmptyLink.ReplaceAllString(line, "")
+		line = strings.TrimSpace(htmlTag.ReplaceAllString(line, ""))
+		code := fence || strings.HasPrefix(raw, "    ") || strings.HasPrefix(raw, "\t")
+		markup := strings.HasPrefix(line, "#") || strings.HasPrefix(line, "|") || strings.Trim(line, "=-*_ ") == ""
+		if code || markup {
+			// a paragraph ends at the first line that is not text.
+			if len(para) > 0 {
+				break
+			}
+			continue
+		}
+		para = append(para, line)
+	}
+	s := strings.Join(para, " ")
+	s = mdLink.ReplaceAllString(s, "$1")
+	s = mdMarkup.Replace(s)
+	s = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return ' '
+		}
+		return r
+	}, s)
+	s = strings.Join(strings.Fields(s), " ")
+	if r := []rune(s); len(r) > max {
+		s = strings.TrimSpace(string(r[:max-3])) + "..."
+	}
+	return s
+}
@@ -0,0 +1,44 @@
+package vendor
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/constabulary/gb/fileutils"
+)
+
+func TestReadmeExcerpt(t *testing.T) {
+	root := mktemp(t)
+	defer fileutils.RemoveAll(root)
+
+	writeFiles(t, root, map[string]string{
+		"README.md": "# bar\n\n[![Build Status](https://travis-ci.org/foo/bar.svg)](https://travis-ci.org/foo/bar)\n\n" +
+			"Package **bar** implements a `fast` [parser](https://example.com)\nfor <b>config</b> files.\x07\n\n" +
+			"## Install\n\n    go get github.com/foo/bar\n",
+	})
+
+	got, err := ReadmeExcerpt(root, 200)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "Package bar implements a fast parser for config files."; got != want {
+		t.Fatalf("ReadmeExcerpt: want %q, got %q", want, got)
+	}
+
+	got, err = ReadmeExcerpt(root, 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "Package bar imple..."; got != want || len([]rune(got)) > 20 {
+		t.Fatalf("ReadmeExcerpt truncated: want %q, got %q", want, got)
+	}
+	if !strings.HasSuffix(got, "...") {
+		t.Fatalf("ReadmeExcerpt truncated: want an ellipsis, got %q", got)
+	}
+
+	empty := mktemp(t)
+	defer fileutils.RemoveAll(empty)
+	if got, err := ReadmeExcerpt(empty, 200); err != nil || got != "" {
+		t.Fatalf("ReadmeExcerpt without README: got %q, %v", got, err)
+	}
+}
@@ -0,0 +1,24 @@
+package vendor
+
+import (
+	"io"
+	"sort"
+	"text/template"
+)
+
+// Render executes the text/template text over m, sorted by import path,
+// writing the result to w. The template has access to the Manifest and
+// the fields of each of its Dependencies, as in
+//
+//	{{range .Dependencies}}- {{.Importpath}} {{.Revision}}
+//	{{end}}
+func Render(w io.Writer, name, text string, m *Manifest) error {
+	t, err := template.New(name).Parse(text)
+	if err != nil {
+		return err
+	}
+	sorted := *m
+	sorted.Dependencies = append([]Dependency(nil), m.Dependencies...)
+	sort.Sort(byImportpath(sorted.Dependencies))
+	return t.Execute(w, &sorted)
+}
@@ -0,0 +1,37 @@
+package vendor
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestRender(t *testing.T) {
+	const tmpl = `# Dependencies
+{{range .Dependencies}}
+- [{{.Importpath}}]({{.Repository}}) at {{.Revision}}{{if .Branch}} ({{.Branch}}){{end}}
+{{- end}}
+`
+	m := &Manifest{Dependencies: []Dependency{
+		{Importpath: "gopkg.in/yaml.v2", Repository: "https://gopkg.in/yaml.v2", Revision: "cafebabe"},
+		{Importpath: "github.com/pkg/errors", Repository: "https://github.com/pkg/errors", Revision: "deadbeef", Branch: "master"},
+	}}
+	var buf bytes.Buffer
+	if err := Render(&buf, "deps", tmpl, m); err != nil {
+		t.Fatal(err)
+	}
+	want := `# Dependencies
+
+- [github.com/pkg/errors](https://github.com/pkg/errors) at deadbeef (master)
+- [gopkg.in/yaml.v2](https://gopkg.in/yaml.v2) at cafebabe
+`
+	if got := buf.String(); got != want {
+		t.Fatalf("Render: want\n%s\ngot\n%s", want, got)
+	}
+	if m.Dependencies[0].Importpath != "gopkg.in/yaml.v2" {
+		t.Fatal("Render reordered the manifest")
+	}
+
+	if err := Render(&buf, "bad", "{{.Nope}}", m); err == nil {
+		t.Fatal("Render: want error for an unknown field, got nil")
+	}
+}
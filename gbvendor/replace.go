@@ -0,0 +1,50 @@
+package vendor
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Replacements maps import path prefixes to the import paths fetched in
+// their place, such as a fork.
+type Replacements map[string]string
+
+// Resolve returns the import path fetched in place of importpath, which
+// is importpath itself if no replacement matches it. The longest matching
+// prefix is replaced. If chains is set, the replaced path is resolved in
+// turn until no replacement matches, and a cycle is an error.
+func (rs Replacements) Resolve(importpath string, chains bool) (string, error) {
+	path := importpath
+	visited := []string{path}
+	seen := map[string]bool{}
+	for {
+		prefix, ok := rs.match(path)
+		if !ok {
+			return path, nil
+		}
+		if seen[prefix] {
+			return "", fmt.Errorf("replace cycle: %s", strings.Join(visited, " -> "))
+		}
+		seen[prefix] = true
+		path = rs[prefix] + path[len(prefix):]
+		visited = append(visited, path)
+		if !chains {
+			return path, nil
+		}
+	}
+}
+
+// match returns the longest prefix of rs matching path.
+func (rs Replacements) match(path string) (string, bool) {
+	var best string
+	ok := false
+	for prefix := range rs {
+		if path != prefix && !strings.HasPrefix(path, prefix+"/") {
+			continue
+		}
+		if !ok || len(prefix) > len(best) {
+			best, ok = prefix, true
+		}
+	}
+	return best, ok
+}
@@ -0,0 +1,43 @@
+package vendor
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestReplacementsResolve(t *testing.T) {
+	rs := Replacements{
+		"github.com/a/lib":     "github.com/b/lib",
+		"github.com/b/lib":     "github.com/c/lib",
+		"github.com/c/lib/sub": "github.com/d/sub",
+		"github.com/x/loop":    "github.com/y/loop",
+		"github.com/y/loop":    "github.com/x/loop",
+	}
+	tests := []struct {
+		path   string
+		chains bool
+		want   string
+		err    string
+	}{
+		{path: "github.com/other/pkg", chains: true, want: "github.com/other/pkg"},
+		{path: "github.com/a/lib", want: "github.com/b/lib"},
+		{path: "github.com/a/lib", chains: true, want: "github.com/c/lib"},
+		{path: "github.com/a/lib/pkg", chains: true, want: "github.com/c/lib/pkg"},
+		{path: "github.com/a/lib/sub/x", chains: true, want: "github.com/d/sub/x"},
+		{path: "github.com/a/library", chains: true, want: "github.com/a/library"},
+		{path: "github.com/x/loop", want: "github.com/y/loop"},
+		{path: "github.com/x/loop/pkg", chains: true, err: "replace cycle: github.com/x/loop/pkg -> github.com/y/loop/pkg -> github.com/x/loop/pkg"},
+	}
+	for _, tt := range tests {
+		got, err := rs.Resolve(tt.path, tt.chains)
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("Resolve(%q): want error %q, got %q, %v", tt.path, tt.err, got, err)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("Resolve(%q, %v): want %q, got %q, %v", tt.path, tt.chains, tt.want, got, err)
+		}
+	}
+}
@@ -2,16 +2,19 @@ package vendor
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
-	"log"
 	"net/url"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"regexp"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/constabulary/gb/fileutils"
 )
@@ -58,7 +61,12 @@ var (
 // Remote repositories can be bare import paths, or urls including a checkout scheme.
 // If deduction would cause traversal of an insecure host, a message will be
 // printed and the travelsal path will be ignored.
+// The deduction is that of DefaultFetcher.
 func DeduceRemoteRepo(path string, insecure bool, repository ...string) (RemoteRepo, string, error) {
+	return DefaultFetcher.DeduceRemoteRepo(path, insecure, repository...)
+}
+
+func deduceRemoteRepo(path string, insecure bool, repository ...string) (RemoteRepo, string, error) {
 	u, err := url.Parse(path)
 	if err != nil {
 		return nil, "", fmt.Errorf("%q is not a valid import path", path)
@@ -74,6 +82,11 @@ func DeduceRemoteRepo(path string, insecure bool, repository ...string) (RemoteR
 		return nil, "", fmt.Errorf("%q is not a valid import path", path)
 	}
 
+	if repourl, extra, ok := RepoURLTemplates.Expand(path); ok {
+		repo, err := templateRepo(repourl, insecure)
+		return repo, extra, err
+	}
+
 	switch {
 	case ghregex.MatchString(path):
 		v := ghregex.FindStringSubmatch(path)
@@ -89,30 +102,30 @@ func DeduceRemoteRepo(path string, insecure bool, repository ...string) (RemoteR
 			Host: "bitbucket.org",
 			Path: v[2],
 		}
-		repo, err := Gitrepo(url, insecure, schemes...)
-		if err == nil {
+		repo, gitErr := Gitrepo(url, insecure, schemes...)
+		if gitErr == nil {
 			return repo, v[0][len(v[1]):], nil
 		}
-		repo, err = Hgrepo(url, insecure)
-		if err == nil {
+		repo, hgErr := Hgrepo(url, insecure)
+		if hgErr == nil {
 			return repo, v[0][len(v[1]):], nil
 		}
-		return nil, "", fmt.Errorf("unknown repository type")
+		return nil, "", missingVCS(fmt.Errorf("unknown repository type"), gitErr, hgErr)
 	case gcregex.MatchString(path):
 		v := gcregex.FindStringSubmatch(path)
 		url := &url.URL{
 			Host: "code.google.com",
 			Path: "p/" + v[2],
 		}
-		repo, err := Hgrepo(url, insecure, schemes...)
-		if err == nil {
+		repo, hgErr := Hgrepo(url, insecure, schemes...)
+		if hgErr == nil {
 			return repo, v[0][len(v[1]):], nil
 		}
-		repo, err = Gitrepo(url, insecure, schemes...)
-		if err == nil {
+		repo, gitErr := Gitrepo(url, insecure, schemes...)
+		if gitErr == nil {
 			return repo, v[0][len(v[1]):], nil
 		}
-		return nil, "", fmt.Errorf("unknown repository type")
+		return nil, "", missingVCS(fmt.Errorf("unknown repository type"), hgErr, gitErr)
 	case lpregex.MatchString(path):
 		v := lpregex.FindStringSubmatch(path)
 		v = append(v, "", "")
@@ -160,7 +173,8 @@ func DeduceRemoteRepo(path string, insecure bool, repository ...string) (RemoteR
 	// golang.org 被墙
 	golangX := "golang.org/x"
 	if len(repository) > 0 {
-		importpath, vcs, reporoot = path, "git", repository[0]
+		// the VCS is not recorded, find it from the repository itself.
+		importpath, vcs, reporoot = path, "", repository[0]
 	} else if strings.Contains(path, golangX) {
 		importpath, vcs, reporoot = path, "git", "https://github.com/golang/"
 
@@ -180,30 +194,95 @@ func DeduceRemoteRepo(path string, insecure bool, repository ...string) (RemoteR
 		}
 	}
 
-	u, err = url.Parse(reporoot)
+	repo, err := repoForRoot(vcs, reporoot, insecure)
 	if err != nil {
 		return nil, "", err
 	}
-	extra := path[len(importpath):]
-	switch vcs {
-	case "git":
+	return repo, path[len(importpath):], nil
+}
+
+// templateRepo returns the RemoteRepo at repourl, expanded from a
+// URLTemplate. Without a scheme, the schemes are probed like for the
+// import paths of known hosts.
+func templateRepo(repourl string, insecure bool) (RemoteRepo, error) {
+	if strings.Contains(repourl, "://") {
+		return repoForRoot("", repourl, insecure)
+	}
+	u, err := url.Parse("//" + repourl)
+	if err != nil {
+		return nil, err
+	}
+	u.Path = strings.TrimPrefix(u.Path, "/")
+	repo, gitErr := Gitrepo(u, insecure)
+	if gitErr == nil {
+		return repo, nil
+	}
+	repo, hgErr := Hgrepo(u, insecure)
+	if hgErr == nil {
+		return repo, nil
+	}
+	return nil, missingVCS(fmt.Errorf("unknown repository type for %s", repourl), gitErr, hgErr)
+}
+
+// vcsRepos maps the VCS named by a go-import meta tag to the constructor
+// of the RemoteRepo at reporoot.
+var vcsRepos = map[string]func(reporoot string, insecure bool) (RemoteRepo, error){
+	"git": func(reporoot string, insecure bool) (RemoteRepo, error) {
+		u, err := url.Parse(reporoot)
+		if err != nil {
+			return nil, err
+		}
 		u.Path = u.Path[1:]
-		repo, err := Gitrepo(u, insecure, u.Scheme)
-		return repo, extra, err
-	case "hg":
+		return Gitrepo(u, insecure, u.Scheme)
+	},
+	"hg": func(reporoot string, insecure bool) (RemoteRepo, error) {
+		u, err := url.Parse(reporoot)
+		if err != nil {
+			return nil, err
+		}
 		u.Path = u.Path[1:]
-		repo, err := Hgrepo(u, insecure, u.Scheme)
-		return repo, extra, err
-	case "bzr":
-		repo, err := Bzrrepo(reporoot)
-		return repo, extra, err
-	default:
-		return nil, "", fmt.Errorf("unknown repository type: %q", vcs)
+		return Hgrepo(u, insecure, u.Scheme)
+	},
+	"bzr": func(reporoot string, insecure bool) (RemoteRepo, error) {
+		return Bzrrepo(reporoot)
+	},
+}
+
+// repoForRoot returns the RemoteRepo of the given VCS at reporoot. If vcs
+// is blank each of git, hg and bzr is tried in turn.
+func repoForRoot(vcs, reporoot string, insecure bool) (RemoteRepo, error) {
+	if vcs != "" {
+		fn, ok := vcsRepos[vcs]
+		if !ok {
+			return nil, fmt.Errorf("unknown repository type: %q", vcs)
+		}
+		return fn(reporoot, insecure)
 	}
+	var errs []string
+	var missing []error
+	for _, vcs := range []string{"git", "hg", "bzr"} {
+		repo, err := vcsRepos[vcs](reporoot, insecure)
+		if err == nil {
+			return repo, nil
+		}
+		if _, ok := err.(*MissingVCSError); ok {
+			missing = append(missing, err)
+			continue
+		}
+		errs = append(errs, fmt.Sprintf("%s: %v", vcs, err))
+	}
+	if len(errs) == 0 {
+		// none of them could be tried.
+		return nil, missingVCS(nil, missing...)
+	}
+	return nil, fmt.Errorf("unknown repository type for %s: %s", reporoot, strings.Join(errs, "; "))
 }
 
 // Gitrepo returns a RemoteRepo representing a remote git repository.
 func Gitrepo(url *url.URL, insecure bool, schemes ...string) (RemoteRepo, error) {
+	if err := checkVCS("git"); err != nil {
+		return nil, err
+	}
 	if len(schemes) == 0 {
 		schemes = []string{"https", "git", "ssh", "http"}
 	}
@@ -218,7 +297,7 @@ func Gitrepo(url *url.URL, insecure bool, schemes ...string) (RemoteRepo, error)
 
 func probeGitUrl(u *url.URL, insecure bool, schemes []string) (string, error) {
 	git := func(url *url.URL) error {
-		out, err := run("git", "ls-remote", url.String(), "HEAD")
+		out, err := runProbe("git", "ls-remote", url.String(), "HEAD")
 		if err != nil {
 			return err
 		}
@@ -233,7 +312,7 @@ func probeGitUrl(u *url.URL, insecure bool, schemes []string) (string, error) {
 
 func probeHgUrl(u *url.URL, insecure bool, schemes []string) (string, error) {
 	hg := func(url *url.URL) error {
-		_, err := run("hg", "identify", url.String())
+		_, err := runProbe("hg", "identify", url.String())
 		return err
 	}
 	return probe(hg, u, insecure, schemes...)
@@ -241,7 +320,7 @@ func probeHgUrl(u *url.URL, insecure bool, schemes []string) (string, error) {
 
 func probeBzrUrl(u string) error {
 	bzr := func(url *url.URL) error {
-		_, err := run("bzr", "info", url.String())
+		_, err := runProbe("bzr", "info", url.String())
 		return err
 	}
 	url, err := url.Parse(u)
@@ -269,7 +348,7 @@ func probe(vcs func(*url.URL) error, url *url.URL, insecure bool, schemes ...str
 			}
 		case "http", "git":
 			if !insecure {
-				log.Printf("skipping insecure protocol: %s", url.String())
+				Logf("skipping insecure protocol: %s", url.String())
 				continue
 			}
 			if err := vcs(&url); err == nil {
@@ -298,6 +377,32 @@ func (g *gitrepo) URL() string {
 // then the default remote branch will be used. If the branch is "HEAD" and
 // revision is empty, an impossible update is assumed.
 func (g *gitrepo) Checkout(branch, tag, revision string) (WorkingCopy, error) {
+	return g.checkout(branch, tag, revision, nil)
+}
+
+// SparseCheckouter is implemented by the RemoteRepos able to check out
+// only some directories of the repository.
+type SparseCheckouter interface {
+	// SparseCheckout is Checkout materialising only the files at the
+	// root of the repository and the directories in paths, given
+	// relative to the root.
+	SparseCheckout(branch, tag, revision string, paths ...string) (WorkingCopy, error)
+}
+
+// SparseCheckout uses a partial clone and git sparse-checkout, falling
+// back to a full checkout if either is not supported.
+func (g *gitrepo) SparseCheckout(branch, tag, revision string, paths ...string) (WorkingCopy, error) {
+	wc, err := g.checkout(branch, tag, revision, paths)
+	if err == errSparseUnsupported {
+		Logf("sparse checkout of %s failed, falling back to a full checkout", g.url)
+		return g.checkout(branch, tag, revision, nil)
+	}
+	return wc, err
+}
+
+var errSparseUnsupported = errors.New("sparse checkout not supported")
+
+func (g *gitrepo) checkout(branch, tag, revision string, sparse []string) (WorkingCopy, error) {
 	if branch == "HEAD" && revision == "" {
 		return nil, fmt.Errorf("cannot update %q as it has been previously fetched with -tag or -revision. Please use gvt delete then fetch again.", g.url)
 	}
@@ -328,30 +433,186 @@ func (g *gitrepo) Checkout(branch, tag, revision string) (WorkingCopy, error) {
 	if tag != "" {
 		quiet = true // git REALLY wants to tell you how awesome 'detached HEAD' is...
 		args = append(args, "--branch", tag, "--single-branch")
-		args = append(args, "--depth", "1")
 	}
-	if revision == "" {
-		args = append(args, "--depth", "1")
+	if len(sparse) > 0 {
+		quiet = true // servers without partial clone support warn about the filter
+		args = append(args, "--no-checkout", "--filter=blob:none")
+	}
+	clone := func(args []string) error {
+		if quiet {
+			return runQuiet("git", args...)
+		}
+		_, err := run("git", args...)
+		return err
 	}
 
-	if quiet {
-		err = runQuiet("git", args...)
-	} else {
-		_, err = run("git", args...)
+	Debugf("cloning %s", g.url)
+	var phases []Phase
+	timed := func(name string, start time.Time) {
+		phases = append(phases, Phase{name, start, time.Since(start)})
+	}
+
+	start := time.Now()
+	shallow := revision != "" && ShallowRevisionFallback
+	switch {
+	case revision == "" && CloneDepth > 0:
+		err = clone(append(args, "--depth", strconv.Itoa(CloneDepth)))
+	case shallow:
+		err = clone(append(args, "--depth", strconv.Itoa(shallowDepth)))
+	default:
+		if err = clone(args); err == nil {
+			err = g.checkHistorySize(dir)
+		}
 	}
 	if err != nil {
 		wc.Destroy()
 		return nil, err
 	}
 
+	// setSparse restricts the working tree of a fresh clone to sparse.
+	setSparse := func() error {
+		if len(sparse) == 0 {
+			return nil
+		}
+		set := append([]string{"-C", dir, "sparse-checkout", "set", "--cone"}, sparse...)
+		if err := runQuiet("git", set...); err != nil {
+			wc.Destroy()
+			return errSparseUnsupported
+		}
+		// populate the working tree, which --no-checkout left empty.
+		if err := runQuiet("git", "-C", dir, "read-tree", "-mu", "HEAD"); err != nil {
+			wc.Destroy()
+			return err
+		}
+		return nil
+	}
+	if err := setSparse(); err != nil {
+		return nil, err
+	}
+	timed("clone", start)
+
 	if revision != "" {
+		start := time.Now()
+		if shallow && !deepen(dir, revision) {
+			// the revision is not in the history of the cloned branch,
+			// start over with a full clone.
+			Logf("%s not found in shallow clone of %s, falling back to a full clone", revision, g.url)
+			if err := fileutils.RemoveAll(dir); err != nil {
+				return nil, err
+			}
+			if err := clone(args); err != nil {
+				wc.Destroy()
+				return nil, err
+			}
+			if err := g.checkHistorySize(dir); err != nil {
+				wc.Destroy()
+				return nil, err
+			}
+			if err := setSparse(); err != nil {
+				return nil, err
+			}
+			timed("clone", start)
+			start = time.Now()
+		}
 		if err := runOutPath(os.Stderr, dir, "git", "checkout", "-q", revision); err != nil {
 			wc.Destroy()
-			return nil, err
+			return nil, revisionNotFound(revision, err)
 		}
+		timed("checkout", start)
 	}
 
-	return &GitClone{wc}, nil
+	return &GitClone{wc, phases}, nil
+}
+
+// Phase is a timed step of a Checkout.
+type Phase struct {
+	Name     string // such as clone or checkout
+	Start    time.Time
+	Duration time.Duration
+}
+
+// PhaseTimer is implemented by the WorkingCopies telling how long the
+// steps of their Checkout took.
+type PhaseTimer interface {
+	// Phases returns the steps of the Checkout, in order.
+	Phases() []Phase
+}
+
+// CloneDepth is the number of commits of history git checkouts of the
+// latest revision of a branch or of a tag clone, zero for the whole
+// history. Checkouts of a specific revision ignore it, see
+// ShallowRevisionFallback.
+var CloneDepth = 1
+
+// ShallowRevisionFallback makes git checkouts of a specific revision start
+// from a shallow clone, deepened until the revision is found, instead of
+// cloning the whole history up front.
+var ShallowRevisionFallback = true
+
+var (
+	shallowDepth = 50 // depth of the initial shallow clone
+	deepenSteps  = 3  // deepen attempts, doubling each time, before unshallowing
+)
+
+// deepen deepens the shallow clone at dir until it contains revision,
+// reporting whether it was found.
+func deepen(dir, revision string) bool {
+	has := func() bool {
+		return runQuiet("git", "-C", dir, "cat-file", "-e", revision+"^{commit}") == nil
+	}
+	depth := shallowDepth
+	for i := 0; i < deepenSteps && !has(); i++ {
+		Logf("deepening clone by %d commits to find %s", depth, revision)
+		if runQuiet("git", "-C", dir, "fetch", "-q", "--deepen="+strconv.Itoa(depth)) != nil {
+			return false
+		}
+		depth *= 2
+	}
+	if has() {
+		return true
+	}
+	if MaxHistorySize > 0 {
+		// leave the full history to the guarded full clone.
+		return false
+	}
+	Logf("unshallowing clone to find %s", revision)
+	if runQuiet("git", "-C", dir, "fetch", "-q", "--unshallow") != nil {
+		return false
+	}
+	return has()
+}
+
+// MaxHistorySize is the size, in bytes, above which full git clones are
+// refused: the objects a full clone fetched are measured, and the clone is
+// removed if they exceed it. Zero means no limit.
+var MaxHistorySize int64
+
+// checkHistorySize returns an error if the objects of the full clone at
+// dir exceed MaxHistorySize.
+func (g *gitrepo) checkHistorySize(dir string) error {
+	if MaxHistorySize <= 0 {
+		return nil
+	}
+	out, err := run("git", "-C", dir, "count-objects", "-v")
+	if err != nil {
+		return err
+	}
+	var size int64
+	for _, line := range strings.Split(string(out), "\n") {
+		kv := strings.SplitN(line, ": ", 2)
+		if len(kv) != 2 || (kv[0] != "size" && kv[0] != "size-pack") {
+			continue
+		}
+		kib, err := strconv.ParseInt(strings.TrimSpace(kv[1]), 10, 64)
+		if err != nil {
+			return fmt.Errorf("unexpected git count-objects output %q", line)
+		}
+		size += kib * 1024
+	}
+	if size > MaxHistorySize {
+		return fmt.Errorf("%s: refusing a full clone, its history is %d bytes, above the history size limit of %d bytes; fetch the latest revision or a tag, which only need a shallow clone, or use -split-large-repos to check out only the fetched directory", g.url, size, MaxHistorySize)
+	}
+	return nil
 }
 
 type workingcopy struct {
@@ -367,20 +628,210 @@ func (w workingcopy) Destroy() error {
 // GitClone is a git WorkingCopy.
 type GitClone struct {
 	workingcopy
+	phases []Phase
 }
 
+// ErrEmptyRepo is returned by the Revision of a WorkingCopy of a
+// repository without any commit.
+var ErrEmptyRepo = errors.New("repository has no commit")
+
 func (g *GitClone) Revision() (string, error) {
+	if runQuiet("git", "-C", g.path, "rev-parse", "-q", "--verify", "HEAD") != nil {
+		if refs, err := run("git", "-C", g.path, "for-each-ref"); err == nil && len(bytes.TrimSpace(refs)) == 0 {
+			return "", ErrEmptyRepo
+		}
+	}
 	rev, err := runPath(g.path, "git", "rev-parse", "HEAD")
 	return strings.TrimSpace(string(rev)), err
 }
 
+// Phases returns the clone and, for a revision, the checkout phases of
+// the Checkout, a full clone after a shallow one failed to reach the
+// revision being timed as a second clone.
+func (g *GitClone) Phases() []Phase { return g.phases }
+
 func (g *GitClone) Branch() (string, error) {
 	rev, err := runPath(g.path, "git", "rev-parse", "--abbrev-ref", "HEAD")
 	return strings.TrimSpace(string(rev)), err
 }
 
+// RevisionNotFoundError is returned by Checkout when the repository could
+// be cloned but the revision asked for could not be checked out of it,
+// for example because upstream history was rewritten.
+type RevisionNotFoundError struct {
+	Revision string
+	Err      error
+}
+
+func (e *RevisionNotFoundError) Error() string { return e.Err.Error() }
+
+func (e *RevisionNotFoundError) Unwrap() error { return e.Err }
+
+// IsRevisionNotFound reports whether err was caused by a revision missing
+// from its repository, even once wrapped into another message.
+func IsRevisionNotFound(err error) bool {
+	var rn *RevisionNotFoundError
+	return errors.As(err, &rn)
+}
+
+// revisionNotFound wraps err, the failure to check out revision of a
+// clone, into a RevisionNotFoundError unless the network caused it.
+func revisionNotFound(revision string, err error) error {
+	if IsNetworkError(err) || IsRateLimited(err) {
+		return err
+	}
+	return &RevisionNotFoundError{revision, err}
+}
+
+// ErrNotAncestor is returned by Changelog when the old revision is not an
+// ancestor of the working copy, for example because upstream history was
+// rewritten.
+var ErrNotAncestor = fmt.Errorf("revision is not an ancestor of the working copy")
+
+// Changelogger is implemented by the WorkingCopies able to summarize the
+// commits between a previous revision and their own.
+type Changelogger interface {
+	// Changelog returns one line per commit in from..HEAD.
+	Changelog(from string) (string, error)
+}
+
+// Changelog returns the one line summary of the commits in from..HEAD,
+// deepening a shallow clone if from is not part of its history.
+func (g *GitClone) Changelog(from string) (string, error) {
+	if runQuiet("git", "-C", g.path, "cat-file", "-e", from+"^{commit}") != nil {
+		if _, err := os.Stat(filepath.Join(g.path, ".git", "shallow")); err == nil {
+			if _, err := runPath(g.path, "git", "fetch", "-q", "--unshallow"); err != nil {
+				return "", err
+			}
+		}
+		if runQuiet("git", "-C", g.path, "cat-file", "-e", from+"^{commit}") != nil {
+			return "", ErrNotAncestor
+		}
+	}
+	if runQuiet("git", "-C", g.path, "merge-base", "--is-ancestor", from, "HEAD") != nil {
+		return "", ErrNotAncestor
+	}
+	out, err := runPath(g.path, "git", "log", "--oneline", "--no-decorate", from+"..HEAD")
+	return string(out), err
+}
+
+// CommitDater is implemented by the WorkingCopies able to tell when their
+// revision was committed.
+type CommitDater interface {
+	// CommitDate returns the committer date of the checked out revision.
+	CommitDate() (time.Time, error)
+}
+
+// CommitDate returns the committer date of HEAD.
+func (g *GitClone) CommitDate() (time.Time, error) {
+	return g.RevisionDate("HEAD")
+}
+
+// AncestryChecker is implemented by the WorkingCopies able to tell whether
+// their revision is older than another one.
+type AncestryChecker interface {
+	// IsAncestorOf reports whether the checked out revision is a proper
+	// ancestor of rev.
+	IsAncestorOf(rev string) (bool, error)
+
+	// RevisionDate returns the committer date of rev.
+	RevisionDate(rev string) (time.Time, error)
+}
+
+// IsAncestorOf reports whether HEAD is a proper ancestor of rev, deepening
+// a shallow clone if rev is not part of its history. A rev unknown to the
+// repository, as after a history rewrite, is not a descendant of HEAD; a
+// repository that cannot be reached to tell is an error.
+func (g *GitClone) IsAncestorOf(rev string) (bool, error) {
+	if err := g.fetchRevision(rev); err != nil {
+		if _, lerr := runPath(g.path, "git", "ls-remote", "origin", "HEAD"); lerr != nil {
+			return false, err
+		}
+		return false, nil
+	}
+	head, err := g.Revision()
+	if err != nil || head == rev {
+		return false, err
+	}
+	err = runQuiet("git", "-C", g.path, "merge-base", "--is-ancestor", "HEAD", rev)
+	if e, ok := err.(*exec.ExitError); ok && e.ExitCode() == 1 {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// RevisionDate returns the committer date of rev.
+func (g *GitClone) RevisionDate(rev string) (time.Time, error) {
+	if err := g.fetchRevision(rev); err != nil {
+		return time.Time{}, err
+	}
+	out, err := runPath(g.path, "git", "show", "-s", "--format=%cI", rev)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Parse(time.RFC3339, strings.TrimSpace(string(out)))
+}
+
+// fetchRevision makes sure rev is part of the clone, fetching it from
+// origin if need be.
+func (g *GitClone) fetchRevision(rev string) error {
+	if runQuiet("git", "-C", g.path, "cat-file", "-e", rev+"^{commit}") == nil {
+		return nil
+	}
+	if _, err := os.Stat(filepath.Join(g.path, ".git", "shallow")); err == nil {
+		if _, err := runPath(g.path, "git", "fetch", "-q", "--unshallow"); err != nil {
+			return err
+		}
+	} else if _, err := runPath(g.path, "git", "fetch", "-q", "origin", rev); err != nil {
+		return err
+	}
+	return runQuiet("git", "-C", g.path, "cat-file", "-e", rev+"^{commit}")
+}
+
+// DateCheckouter is implemented by the WorkingCopies able to go back to
+// the revision their branch was at on a given date.
+type DateCheckouter interface {
+	// CheckoutDate checks out the last revision of the branch checked
+	// out committed before t.
+	CheckoutDate(t time.Time) error
+}
+
+// CheckoutDate checks out the last commit of the first-parent history of
+// HEAD whose committer date is before t, unshallowing the clone to reach
+// it.
+func (g *GitClone) CheckoutDate(t time.Time) error {
+	if _, err := os.Stat(filepath.Join(g.path, ".git", "shallow")); err == nil {
+		if _, err := runPath(g.path, "git", "fetch", "-q", "--unshallow"); err != nil {
+			return err
+		}
+	}
+	out, err := runPath(g.path, "git", "rev-list", "-1", "--first-parent", "--before="+t.Format(time.RFC3339), "HEAD")
+	if err != nil {
+		return err
+	}
+	rev := strings.TrimSpace(string(out))
+	if rev == "" {
+		return fmt.Errorf("no commit before %s", t.Format(time.RFC3339))
+	}
+	return runQuiet("git", "-C", g.path, "checkout", "-q", rev)
+}
+
+// CheckoutDate checks out the revision the branch of wc was at on t, see
+// DateCheckouter, or returns an error if the VCS of wc cannot resolve
+// revisions by date.
+func CheckoutDate(wc WorkingCopy, t time.Time) error {
+	dc, ok := wc.(DateCheckouter)
+	if !ok {
+		return fmt.Errorf("resolving a revision by date is only supported for git repositories")
+	}
+	return dc.CheckoutDate(t)
+}
+
 // Hgrepo returns a RemoteRepo representing a remote git repository.
 func Hgrepo(u *url.URL, insecure bool, schemes ...string) (RemoteRepo, error) {
+	if err := checkVCS("hg"); err != nil {
+		return nil, err
+	}
 	if len(schemes) == 0 {
 		schemes = []string{"https", "http"}
 	}
@@ -420,6 +871,7 @@ func (h *hgrepo) Checkout(branch, tag, revision string) (WorkingCopy, error) {
 	if branch != "" {
 		args = append(args, "--branch", branch)
 	}
+	Debugf("cloning %s", h.url)
 	if err := runOut(os.Stderr, "hg", args...); err != nil {
 		fileutils.RemoveAll(dir)
 		return nil, err
@@ -427,7 +879,7 @@ func (h *hgrepo) Checkout(branch, tag, revision string) (WorkingCopy, error) {
 	if revision != "" {
 		if err := runOut(os.Stderr, "hg", "--cwd", dir, "update", "-r", revision); err != nil {
 			fileutils.RemoveAll(dir)
-			return nil, err
+			return nil, revisionNotFound(revision, err)
 		}
 	}
 
@@ -453,8 +905,20 @@ func (h *HgClone) Branch() (string, error) {
 	return strings.TrimSpace(string(rev)), err
 }
 
+// CommitDate returns the date of the working directory parent revision.
+func (h *HgClone) CommitDate() (time.Time, error) {
+	out, err := run("hg", "--cwd", h.path, "log", "-r", ".", "--template", "{date|rfc3339date}")
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Parse(time.RFC3339, strings.TrimSpace(string(out)))
+}
+
 // Bzrrepo returns a RemoteRepo representing a remote bzr repository.
 func Bzrrepo(url string) (RemoteRepo, error) {
+	if err := checkVCS("bzr"); err != nil {
+		return nil, err
+	}
 	if err := probeBzrUrl(url); err != nil {
 		return nil, err
 	}
@@ -483,6 +947,7 @@ func (b *bzrrepo) Checkout(branch, tag, revision string) (WorkingCopy, error) {
 		return nil, err
 	}
 	wc := filepath.Join(dir, "wc")
+	Debugf("branching %s", b.url)
 	if err := runOut(os.Stderr, "bzr", "branch", b.url, wc); err != nil {
 		fileutils.RemoveAll(dir)
 		return nil, err
@@ -516,15 +981,20 @@ func (b *BzrClone) Destroy() error {
 	return os.Remove(parent)
 }
 
-func cleanPath(path string) error {
-	if files, _ := ioutil.ReadDir(path); len(files) > 0 || filepath.Base(path) == "vendor" {
-		return nil
-	}
-	parent := filepath.Dir(path)
-	if err := fileutils.RemoveAll(path); err != nil {
-		return err
+// CleanPath removes path if it is an empty directory, then its parents
+// as long as they are left empty, stopping at vendorDir, which is never
+// removed.
+func CleanPath(path, vendorDir string) error {
+	for strings.HasPrefix(path, vendorDir+string(filepath.Separator)) {
+		if files, _ := ioutil.ReadDir(path); len(files) > 0 {
+			return nil
+		}
+		if err := fileutils.RemoveAll(path); err != nil {
+			return err
+		}
+		path = filepath.Dir(path)
 	}
-	return cleanPath(parent)
+	return nil
 }
 
 func mktmp() (string, error) {
@@ -537,20 +1007,69 @@ func run(c string, args ...string) ([]byte, error) {
 	return buf.Bytes(), err
 }
 
+// runProbe is run killing c if it has not completed within ProbeTimeout.
+func runProbe(c string, args ...string) ([]byte, error) {
+	ctx := context.Background()
+	if ProbeTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, ProbeTimeout)
+		defer cancel()
+	}
+	var buf bytes.Buffer
+	cmd := commandContext(ctx, c, args...)
+	cmd.Stdin = nil
+	cmd.Stdout = &buf
+	err := runStderr(cmd, os.Stderr)
+	if ctx.Err() == context.DeadlineExceeded {
+		err = fmt.Errorf("%s: no answer within %v", c, ProbeTimeout)
+	}
+	return buf.Bytes(), err
+}
+
+// command returns the Cmd running c with args in the environment set by
+// SetTransport.
+func command(c string, args ...string) *exec.Cmd {
+	return commandContext(context.Background(), c, args...)
+}
+
+// commandContext is command killing c once ctx is done.
+func commandContext(ctx context.Context, c string, args ...string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, c, args...)
+	if commandEnv != nil {
+		cmd.Env = append(os.Environ(), commandEnv...)
+	}
+	return cmd
+}
+
 func runOut(w io.Writer, c string, args ...string) error {
-	cmd := exec.Command(c, args...)
+	cmd := command(c, args...)
 	cmd.Stdin = nil
 	cmd.Stdout = w
-	cmd.Stderr = os.Stderr
-	return cmd.Run()
+	return runStderr(cmd, os.Stderr)
 }
 
 func runQuiet(c string, args ...string) error {
-	cmd := exec.Command(c, args...)
+	cmd := command(c, args...)
 	cmd.Stdin = nil
 	cmd.Stdout = nil
-	cmd.Stderr = nil
-	return cmd.Run()
+	return runStderr(cmd, ioutil.Discard)
+}
+
+// runStderr runs cmd with its standard error copied to stderr, returning
+// a RateLimitError if it complains about rate limiting, and a NetworkError
+// if it complains about the network.
+func runStderr(cmd *exec.Cmd, stderr io.Writer) error {
+	var buf bytes.Buffer
+	cmd.Stderr = io.MultiWriter(stderr, &buf)
+	err := cmd.Run()
+	switch {
+	case err == nil:
+	case rateLimitRe.Match(buf.Bytes()):
+		return &RateLimitError{err}
+	case networkRe.Match(buf.Bytes()):
+		return &NetworkError{err}
+	}
+	return err
 }
 
 func runPath(path string, c string, args ...string) ([]byte, error) {
@@ -560,12 +1079,11 @@ func runPath(path string, c string, args ...string) ([]byte, error) {
 }
 
 func runOutPath(w io.Writer, path string, c string, args ...string) error {
-	cmd := exec.Command(c, args...)
+	cmd := command(c, args...)
 	cmd.Dir = path
 	cmd.Stdin = nil
 	cmd.Stdout = w
-	cmd.Stderr = os.Stderr
-	return cmd.Run()
+	return runStderr(cmd, os.Stderr)
 }
 
 // atMostOne returns true if no more than one string supplied is not empty.
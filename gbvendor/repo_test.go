@@ -2,8 +2,12 @@ package vendor
 
 import (
 	"fmt"
+	"os"
+	"path/filepath"
 	"reflect"
 	"testing"
+
+	"github.com/constabulary/gb/fileutils"
 )
 
 func TestDeduceRemoteRepo(t *testing.T) {
@@ -143,3 +147,82 @@ func TestDeduceRemoteRepo(t *testing.T) {
 		}
 	}
 }
+
+func TestRepoForRoot(t *testing.T) {
+	var called []string
+	defer func(repos map[string]func(string, bool) (RemoteRepo, error)) { vcsRepos = repos }(vcsRepos)
+	stub := func(vcs string, ok bool) func(string, bool) (RemoteRepo, error) {
+		return func(reporoot string, _ bool) (RemoteRepo, error) {
+			called = append(called, vcs)
+			if !ok {
+				return nil, fmt.Errorf("not a %s repo", vcs)
+			}
+			return &hgrepo{url: reporoot}, nil
+		}
+	}
+
+	tests := []struct {
+		vcs    string
+		repos  map[string]bool
+		called []string
+		err    bool
+	}{
+		{vcs: "hg", repos: map[string]bool{"git": true, "hg": true, "bzr": true}, called: []string{"hg"}},
+		{vcs: "bzr", repos: map[string]bool{"git": true, "hg": true, "bzr": true}, called: []string{"bzr"}},
+		{vcs: "svn", err: true},
+		{vcs: "", repos: map[string]bool{"hg": true}, called: []string{"git", "hg"}},
+		{vcs: "", repos: map[string]bool{}, called: []string{"git", "hg", "bzr"}, err: true},
+	}
+	for _, tt := range tests {
+		called = nil
+		vcsRepos = map[string]func(string, bool) (RemoteRepo, error){
+			"git": stub("git", tt.repos["git"]),
+			"hg":  stub("hg", tt.repos["hg"]),
+			"bzr": stub("bzr", tt.repos["bzr"]),
+		}
+		repo, err := repoForRoot(tt.vcs, "https://example.com/foo", false)
+		if tt.err {
+			if err == nil {
+				t.Errorf("repoForRoot(%q): expected error", tt.vcs)
+			}
+		} else if err != nil || repo.URL() != "https://example.com/foo" {
+			t.Errorf("repoForRoot(%q): got %v, %v", tt.vcs, repo, err)
+		}
+		if !reflect.DeepEqual(called, tt.called) {
+			t.Errorf("repoForRoot(%q): want %v tried, got %v", tt.vcs, tt.called, called)
+		}
+	}
+}
+
+func TestCleanPath(t *testing.T) {
+	root := mktemp(t)
+	defer fileutils.RemoveAll(root)
+
+	src := filepath.Join(root, "src")
+	writeFiles(t, src, map[string]string{
+		"github.com/foo/other/other.go": "package other\n",
+	})
+	for _, dir := range []string{"github.com/foo/bar/baz", "example.com/a/b"} {
+		if err := os.MkdirAll(filepath.Join(src, filepath.FromSlash(dir)), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := CleanPath(filepath.Join(src, "github.com", "foo", "bar", "baz"), src); err != nil {
+		t.Fatal(err)
+	}
+	assertNotExists(t, filepath.Join(src, "github.com", "foo", "bar"))
+	assertExists(t, filepath.Join(src, "github.com", "foo", "other"))
+
+	if err := CleanPath(filepath.Join(src, "example.com", "a", "b"), src); err != nil {
+		t.Fatal(err)
+	}
+	assertNotExists(t, filepath.Join(src, "example.com"))
+	assertExists(t, src)
+
+	// the vendor directory itself is kept even if empty.
+	if err := CleanPath(src, src); err != nil {
+		t.Fatal(err)
+	}
+	assertExists(t, src)
+}
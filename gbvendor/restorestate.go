@@ -0,0 +1,88 @@
+package vendor
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// RestoreStateFile returns the file of vendorDir recording the manifest
+// entries of the last successful restore. Its name starts with a dot, so
+// the go tool ignores it.
+func RestoreStateFile(vendorDir string) string {
+	return filepath.Join(vendorDir, ".restore-state")
+}
+
+// RestoreState maps the import path of each dependency restored to the
+// EntryKey of its manifest entry at the time.
+type RestoreState map[string]string
+
+// EntryKey returns a digest of every field of the manifest entry of d, so
+// that any change to it changes the key.
+func EntryKey(d Dependency) string {
+	buf, err := json.Marshal(d)
+	if err != nil {
+		panic(err) // a Dependency always marshals
+	}
+	return fmt.Sprintf("%x", sha256.Sum256(buf))
+}
+
+// ReadRestoreState reads the restore state of path, empty if it does not
+// exist.
+func ReadRestoreState(path string) (RestoreState, error) {
+	s := make(RestoreState)
+	buf, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(buf, &s); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return s, nil
+}
+
+// WriteRestoreState writes s to path.
+func WriteRestoreState(path string, s RestoreState) error {
+	buf, err := json.MarshalIndent(s, "", "\t")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, append(buf, '\n'), 0644)
+}
+
+// Changed splits deps into those whose manifest entry changed since s was
+// recorded, or whose tree is missing from vendorDir, and the others, in
+// the order of deps.
+func (s RestoreState) Changed(vendorDir string, deps []Dependency) (changed, unchanged []Dependency) {
+	for _, d := range deps {
+		_, err := os.Stat(filepath.Join(vendorDir, filepath.FromSlash(d.Importpath)))
+		if err != nil || s[d.Importpath] != EntryKey(d) {
+			changed = append(changed, d)
+			continue
+		}
+		unchanged = append(unchanged, d)
+	}
+	return changed, unchanged
+}
+
+// Record records the manifest entries of deps, restored successfully, and
+// forgets the dependencies m no longer lists.
+func (s RestoreState) Record(m *Manifest, deps []Dependency) {
+	for _, d := range deps {
+		s[d.Importpath] = EntryKey(d)
+	}
+	for path := range s {
+		if !m.HasImportpath(path) {
+			delete(s, path)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package vendor
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/constabulary/gb/fileutils"
+)
+
+func TestRestoreState(t *testing.T) {
+	vendorDir := filepath.Join(mktemp(t), "vendor")
+	defer fileutils.RemoveAll(filepath.Dir(vendorDir))
+
+	writeFiles(t, vendorDir, map[string]string{
+		"example.com/a/a.go": "package a\n",
+		"example.com/b/b.go": "package b\n",
+		"example.com/c/c.go": "package c\n",
+	})
+	m := &Manifest{Dependencies: []Dependency{
+		{Importpath: "example.com/a", Repository: "https://example.com/a", Revision: "1"},
+		{Importpath: "example.com/b", Repository: "https://example.com/b", Revision: "1"},
+		{Importpath: "example.com/c", Repository: "https://example.com/c", Revision: "1"},
+	}}
+	file := RestoreStateFile(vendorDir)
+
+	s, err := ReadRestoreState(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changed, _ := s.Changed(vendorDir, m.Dependencies); len(changed) != 3 {
+		t.Fatalf("Changed without a state: want every dependency, got %v", changed)
+	}
+	s.Record(m, m.Dependencies)
+	if err := WriteRestoreState(file, s); err != nil {
+		t.Fatal(err)
+	}
+
+	// b is bumped, c is deleted from the vendor directory.
+	m.Dependencies[1].Revision = "2"
+	if err := fileutils.RemoveAll(filepath.Join(vendorDir, "example.com", "c")); err != nil {
+		t.Fatal(err)
+	}
+	if s, err = ReadRestoreState(file); err != nil {
+		t.Fatal(err)
+	}
+	changed, unchanged := s.Changed(vendorDir, m.Dependencies)
+	if want := m.Dependencies[1:]; !reflect.DeepEqual(changed, want) {
+		t.Fatalf("Changed: want %v, got %v", want, changed)
+	}
+	if want := m.Dependencies[:1]; !reflect.DeepEqual(unchanged, want) {
+		t.Fatalf("Changed: want %v unchanged, got %v", want, unchanged)
+	}
+
+	// once b is restored again and c removed, only c is forgotten.
+	m.Dependencies = m.Dependencies[:2]
+	s.Record(m, m.Dependencies[1:])
+	if _, ok := s["example.com/c"]; ok || len(s) != 2 {
+		t.Fatalf("Record: want a and b, got %v", s)
+	}
+	if changed, _ := s.Changed(vendorDir, m.Dependencies); len(changed) != 0 {
+		t.Fatalf("Changed after Record: want nothing, got %v", changed)
+	}
+}
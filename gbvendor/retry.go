@@ -0,0 +1,52 @@
+package vendor
+
+import (
+	"errors"
+	"net"
+	"regexp"
+	"time"
+)
+
+// networkRe matches the messages of VCS commands failing because the
+// network or the host was unreachable, rather than because of what was
+// asked of them.
+var networkRe = regexp.MustCompile(`(?i)could not resolve host|temporary failure in name resolution|connection (reset|refused|timed out)|operation timed out|timed out after|early eof|remote end hung up unexpectedly|rpc failed|gnutls_handshake|ssl_connect|ssh: connect to host|network is unreachable|abort: error: `)
+
+// NetworkError is returned by the VCS commands failing because of the
+// network, which may succeed when run again.
+type NetworkError struct {
+	Err error
+}
+
+func (e *NetworkError) Error() string { return e.Err.Error() }
+
+func (e *NetworkError) Unwrap() error { return e.Err }
+
+// IsNetworkError reports whether err was caused by the network, even once
+// wrapped into another message. Rate limiting is not, see IsRateLimited.
+func IsNetworkError(err error) bool {
+	if err == nil {
+		return false
+	}
+	var ne *NetworkError
+	var nerr net.Error
+	return errors.As(err, &ne) || errors.As(err, &nerr)
+}
+
+// Retry calls f until it succeeds or fails with an error other than an
+// IsNetworkError one, at most retries times more than the first, waiting
+// backoff before the first retry and twice as long before each next one.
+// It returns the last error of f unchanged.
+func Retry(retries int, backoff time.Duration, f func() error, retrying func(err error, wait time.Duration)) error {
+	for i := 0; ; i++ {
+		err := f()
+		if err == nil || i >= retries || !IsNetworkError(err) {
+			return err
+		}
+		if retrying != nil {
+			retrying(err, backoff)
+		}
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
@@ -0,0 +1,69 @@
+package vendor
+
+import (
+	"errors"
+	"io/ioutil"
+	"os/exec"
+	"testing"
+	"time"
+)
+
+func TestRetry(t *testing.T) {
+	netErr := &NetworkError{errors.New("exit status 128")}
+	notFound := errors.New("revision not found")
+
+	tests := []struct {
+		name    string
+		errs    []error // returned by each call, nil past the end
+		retries int
+		calls   int
+		err     error
+	}{
+		{"success", nil, 2, 1, nil},
+		{"transient", []error{netErr, netErr}, 2, 3, nil},
+		{"exhausted", []error{netErr, netErr, netErr, netErr}, 2, 3, netErr},
+		{"not retried", []error{notFound, netErr}, 2, 1, notFound},
+		{"no retries", []error{netErr}, 0, 1, netErr},
+	}
+	for _, tt := range tests {
+		calls := 0
+		var waits []time.Duration
+		err := Retry(tt.retries, time.Millisecond, func() error {
+			calls++
+			if calls <= len(tt.errs) {
+				return tt.errs[calls-1]
+			}
+			return nil
+		}, func(err error, wait time.Duration) {
+			waits = append(waits, wait)
+		})
+		if err != tt.err || calls != tt.calls {
+			t.Errorf("%s: want %d calls and %v, got %d and %v", tt.name, tt.calls, tt.err, calls, err)
+		}
+		for i := 1; i < len(waits); i++ {
+			if waits[i] != 2*waits[i-1] {
+				t.Errorf("%s: want doubling waits, got %v", tt.name, waits)
+			}
+		}
+	}
+}
+
+func TestNetworkErrors(t *testing.T) {
+	tests := []struct {
+		stderr  string
+		network bool
+	}{
+		{"fatal: unable to access 'https://example.com/a/': Could not resolve host: example.com", true},
+		{"error: RPC failed; curl 56 GnuTLS recv error (-54): Error in the pull function.", true},
+		{"fatal: the remote end hung up unexpectedly", true},
+		{"ssh: connect to host example.com port 22: Connection refused", true},
+		{"fatal: reference is not a tree: 1234567", false},
+		{"fatal: repository 'https://example.com/a/' not found", false},
+	}
+	for _, tt := range tests {
+		err := runStderr(exec.Command("sh", "-c", "echo \"$0\" >&2; exit 1", tt.stderr), ioutil.Discard)
+		if IsNetworkError(err) != tt.network {
+			t.Errorf("IsNetworkError(%q): want %v, got %v", tt.stderr, tt.network, err)
+		}
+	}
+}
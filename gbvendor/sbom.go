@@ -0,0 +1,179 @@
+package vendor
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// SBOM formats supported by WriteSBOM.
+const (
+	SBOMCycloneDX = "cyclonedx"
+	SBOMSPDX      = "spdx"
+)
+
+// SBOMComponent is a vendored dependency listed in an SBOM.
+type SBOMComponent struct {
+	Dependency
+
+	// LicenseFiles are the license files found in the vendored tree.
+	LicenseFiles []string
+}
+
+// WriteSBOM writes to w a software bill of materials in the given format,
+// CycloneDX 1.5 or SPDX 2.3 JSON, describing the components vendored by
+// the project name.
+func WriteSBOM(w io.Writer, format, name string, components []SBOMComponent, created time.Time) error {
+	var doc interface{}
+	switch format {
+	case SBOMCycloneDX:
+		doc = cycloneDX(name, components, created)
+	case SBOMSPDX:
+		doc = spdx(name, components, created)
+	default:
+		return fmt.Errorf("unknown SBOM format %q, expected %s or %s", format, SBOMCycloneDX, SBOMSPDX)
+	}
+	buf, err := json.MarshalIndent(doc, "", "\t")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "%s\n", buf)
+	return err
+}
+
+// purl returns the package URL of c.
+func (c SBOMComponent) purl() string {
+	return "pkg:golang/" + c.Importpath + "@" + c.Revision
+}
+
+type cdxProperty struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type cdxComponent struct {
+	Type       string        `json:"type"`
+	BOMRef     string        `json:"bom-ref"`
+	Name       string        `json:"name"`
+	Version    string        `json:"version"`
+	Purl       string        `json:"purl"`
+	ExtRefs    []cdxRef      `json:"externalReferences,omitempty"`
+	Properties []cdxProperty `json:"properties,omitempty"`
+}
+
+type cdxRef struct {
+	Type string `json:"type"`
+	URL  string `json:"url"`
+}
+
+func cycloneDX(name string, components []SBOMComponent, created time.Time) interface{} {
+	comps := []cdxComponent{}
+	for _, c := range components {
+		cc := cdxComponent{
+			Type:    "library",
+			BOMRef:  c.purl(),
+			Name:    c.Importpath,
+			Version: c.Revision,
+			Purl:    c.purl(),
+			ExtRefs: []cdxRef{{Type: "vcs", URL: c.Repository}},
+		}
+		for _, f := range c.LicenseFiles {
+			cc.Properties = append(cc.Properties, cdxProperty{"gvt:license-file", f})
+		}
+		comps = append(comps, cc)
+	}
+	return struct {
+		BOMFormat   string         `json:"bomFormat"`
+		SpecVersion string         `json:"specVersion"`
+		Version     int            `json:"version"`
+		Metadata    interface{}    `json:"metadata"`
+		Components  []cdxComponent `json:"components"`
+	}{
+		BOMFormat:   "CycloneDX",
+		SpecVersion: "1.5",
+		Version:     1,
+		Metadata: struct {
+			Timestamp string      `json:"timestamp"`
+			Component interface{} `json:"component"`
+		}{
+			Timestamp: created.UTC().Format(time.RFC3339),
+			Component: struct {
+				Type string `json:"type"`
+				Name string `json:"name"`
+			}{"application", name},
+		},
+		Components: comps,
+	}
+}
+
+type spdxPackage struct {
+	Name             string       `json:"name"`
+	SPDXID           string       `json:"SPDXID"`
+	VersionInfo      string       `json:"versionInfo"`
+	DownloadLocation string       `json:"downloadLocation"`
+	FilesAnalyzed    bool         `json:"filesAnalyzed"`
+	LicenseConcluded string       `json:"licenseConcluded"`
+	LicenseDeclared  string       `json:"licenseDeclared"`
+	LicenseComments  string       `json:"licenseComments,omitempty"`
+	CopyrightText    string       `json:"copyrightText"`
+	ExternalRefs     []spdxExtRef `json:"externalRefs"`
+}
+
+type spdxExtRef struct {
+	Category string `json:"referenceCategory"`
+	Type     string `json:"referenceType"`
+	Locator  string `json:"referenceLocator"`
+}
+
+type spdxRelationship struct {
+	Element string `json:"spdxElementId"`
+	Type    string `json:"relationshipType"`
+	Related string `json:"relatedSpdxElement"`
+}
+
+func spdx(name string, components []SBOMComponent, created time.Time) interface{} {
+	pkgs := []spdxPackage{}
+	rels := []spdxRelationship{}
+	for i, c := range components {
+		id := fmt.Sprintf("SPDXRef-Package-%d", i+1)
+		p := spdxPackage{
+			Name:             c.Importpath,
+			SPDXID:           id,
+			VersionInfo:      c.Revision,
+			DownloadLocation: "git+" + c.Repository + "@" + c.Revision,
+			LicenseConcluded: "NOASSERTION",
+			LicenseDeclared:  "NOASSERTION",
+			CopyrightText:    "NOASSERTION",
+			ExternalRefs:     []spdxExtRef{{"PACKAGE-MANAGER", "purl", c.purl()}},
+		}
+		if len(c.LicenseFiles) > 0 {
+			p.LicenseComments = "license files: " + strings.Join(c.LicenseFiles, ", ")
+		}
+		pkgs = append(pkgs, p)
+		rels = append(rels, spdxRelationship{"SPDXRef-DOCUMENT", "DESCRIBES", id})
+	}
+	return struct {
+		SPDXVersion       string             `json:"spdxVersion"`
+		DataLicense       string             `json:"dataLicense"`
+		SPDXID            string             `json:"SPDXID"`
+		Name              string             `json:"name"`
+		DocumentNamespace string             `json:"documentNamespace"`
+		CreationInfo      interface{}        `json:"creationInfo"`
+		Packages          []spdxPackage      `json:"packages"`
+		Relationships     []spdxRelationship `json:"relationships"`
+	}{
+		SPDXVersion:       "SPDX-2.3",
+		DataLicense:       "CC0-1.0",
+		SPDXID:            "SPDXRef-DOCUMENT",
+		Name:              name,
+		DocumentNamespace: fmt.Sprintf("https://spdx.org/spdxdocs/%s-%d", name, created.Unix()),
+		CreationInfo: struct {
+			Created  string   `json:"created"`
+			Creators []string `json:"creators"`
+		}{created.UTC().Format(time.RFC3339), []string{"Tool: gvt"}},
+		Packages:      pkgs,
+		Relationships: rels,
+	}
+}
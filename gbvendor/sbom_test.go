@@ -0,0 +1,87 @@
+package vendor
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func sbomFixture() []SBOMComponent {
+	return []SBOMComponent{{
+		Dependency: Dependency{
+			Importpath: "github.com/foo/bar",
+			Repository: "https://github.com/foo/bar",
+			Revision:   "cafebabe",
+		},
+		LicenseFiles: []string{"LICENSE"},
+	}, {
+		Dependency: Dependency{
+			Importpath: "github.com/foo/baz",
+			Repository: "https://github.com/foo/baz",
+			Revision:   "deadbeef",
+		},
+	}}
+}
+
+// decodeSBOM writes an SBOM of the fixture and decodes it generically.
+func decodeSBOM(t *testing.T, format string) map[string]interface{} {
+	var buf bytes.Buffer
+	if err := WriteSBOM(&buf, format, "project", sbomFixture(), time.Unix(0, 0)); err != nil {
+		t.Fatal(err)
+	}
+	var doc map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+	return doc
+}
+
+func requireFields(t *testing.T, what string, obj interface{}, fields ...string) {
+	m, ok := obj.(map[string]interface{})
+	if !ok {
+		t.Fatalf("%s: want an object, got %T", what, obj)
+	}
+	for _, f := range fields {
+		if v, ok := m[f]; !ok || v == "" {
+			t.Errorf("%s: required field %q missing", what, f)
+		}
+	}
+}
+
+func TestWriteSBOMCycloneDX(t *testing.T) {
+	doc := decodeSBOM(t, SBOMCycloneDX)
+	requireFields(t, "bom", doc, "bomFormat", "specVersion", "version", "components")
+	if doc["bomFormat"] != "CycloneDX" {
+		t.Errorf("bomFormat: got %v", doc["bomFormat"])
+	}
+	comps := doc["components"].([]interface{})
+	if len(comps) != 2 {
+		t.Fatalf("want 2 components, got %d", len(comps))
+	}
+	for _, c := range comps {
+		requireFields(t, "component", c, "type", "name", "version", "purl")
+	}
+	if got := comps[0].(map[string]interface{})["purl"]; got != "pkg:golang/github.com/foo/bar@cafebabe" {
+		t.Errorf("purl: got %v", got)
+	}
+}
+
+func TestWriteSBOMSPDX(t *testing.T) {
+	doc := decodeSBOM(t, SBOMSPDX)
+	requireFields(t, "document", doc, "spdxVersion", "dataLicense", "SPDXID", "name", "documentNamespace", "creationInfo", "packages")
+	requireFields(t, "creationInfo", doc["creationInfo"], "created", "creators")
+	pkgs := doc["packages"].([]interface{})
+	if len(pkgs) != 2 {
+		t.Fatalf("want 2 packages, got %d", len(pkgs))
+	}
+	for _, p := range pkgs {
+		requireFields(t, "package", p, "name", "SPDXID", "downloadLocation", "licenseConcluded", "licenseDeclared", "copyrightText")
+	}
+	if got := pkgs[0].(map[string]interface{})["licenseComments"]; got != "license files: LICENSE" {
+		t.Errorf("licenseComments: got %v", got)
+	}
+	if err := WriteSBOM(new(bytes.Buffer), "swid", "project", nil, time.Now()); err == nil {
+		t.Errorf("WriteSBOM with an unknown format: expected error")
+	}
+}
@@ -0,0 +1,102 @@
+package vendor
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Status kinds reported by Status.
+const (
+	StatusMissing     = "missing"     // in the manifest, not vendored
+	StatusUntracked   = "untracked"   // vendored, not in the manifest
+	StatusUnconfirmed = "unconfirmed" // vendored, not provably at its revision
+)
+
+// StatusEntry is a difference between the manifest and the vendor
+// directory.
+type StatusEntry struct {
+	Importpath string `json:"importpath"`
+	Kind       string `json:"kind"`
+	Reason     string `json:"reason,omitempty"` // why an entry is unconfirmed
+}
+
+// Status compares the dependencies of m with the trees of vendorDir and
+// returns, sorted by import path, the dependencies without a tree, the
+// top-most directories no dependency covers, and the dependencies whose
+// tree cannot be confirmed to be that of their revision: without a
+// recorded revision or checksum, or not matching the checksum. Hidden
+// files and directories, such as those of gvt, are ignored.
+func Status(vendorDir string, m *Manifest) ([]StatusEntry, error) {
+	var status []StatusEntry
+	deps := make(map[string]bool)
+	for _, d := range m.Dependencies {
+		deps[d.Importpath] = true
+		dir := filepath.Join(vendorDir, filepath.FromSlash(d.Importpath))
+		if _, err := os.Stat(dir); os.IsNotExist(err) {
+			status = append(status, StatusEntry{Importpath: d.Importpath, Kind: StatusMissing})
+			continue
+		}
+		var reason string
+		switch {
+		case d.Revision == "":
+			reason = "no revision recorded"
+		case d.Checksum == "":
+			reason = "no checksum recorded"
+		default:
+			sum, err := TreeChecksum(dir, m.Nested(d))
+			if err != nil {
+				return nil, err
+			}
+			if sum != d.Checksum {
+				reason = "tree does not match its checksum"
+			}
+		}
+		if reason != "" {
+			status = append(status, StatusEntry{Importpath: d.Importpath, Kind: StatusUnconfirmed, Reason: reason})
+		}
+	}
+
+	// parent reports whether dir holds the tree of a dependency.
+	parent := func(dir string) bool {
+		for ip := range deps {
+			if strings.HasPrefix(ip, dir+"/") {
+				return true
+			}
+		}
+		return false
+	}
+	err := filepath.Walk(vendorDir, func(path string, info os.FileInfo, err error) error {
+		if os.IsNotExist(err) && path == vendorDir {
+			return filepath.SkipDir
+		}
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() || path == vendorDir {
+			return nil
+		}
+		if strings.HasPrefix(info.Name(), ".") {
+			return filepath.SkipDir
+		}
+		rel, err := filepath.Rel(vendorDir, path)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		switch {
+		case deps[rel]:
+			return filepath.SkipDir
+		case parent(rel):
+			return nil
+		}
+		status = append(status, StatusEntry{Importpath: rel, Kind: StatusUntracked})
+		return filepath.SkipDir
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(status, func(i, j int) bool { return status[i].Importpath < status[j].Importpath })
+	return status, nil
+}
@@ -0,0 +1,58 @@
+package vendor
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/constabulary/gb/fileutils"
+)
+
+func TestStatus(t *testing.T) {
+	vendorDir := filepath.Join(mktemp(t), "vendor")
+	defer fileutils.RemoveAll(filepath.Dir(vendorDir))
+
+	writeFiles(t, vendorDir, map[string]string{
+		"example.com/ok/ok.go":             "package ok\n",
+		"example.com/ok/sub/sub.go":        "package sub\n",
+		"example.com/edited/edited.go":     "package edited\n",
+		"example.com/nosum/nosum.go":       "package nosum\n",
+		"example.com/stray/stray.go":       "package stray\n",
+		"other.org/x/y/y.go":               "package y\n",
+		".quarantine/example.com/q/q.go":   "package q\n",
+		"example.com/ok/.hidden/ignored":   "",
+		"example.com/edited/vendor/v/v.go": "package v\n",
+	})
+	sum := func(path string) string {
+		s, err := TreeChecksum(filepath.Join(vendorDir, filepath.FromSlash(path)), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+	m := &Manifest{Dependencies: []Dependency{
+		{Importpath: "example.com/ok", Revision: "1", Checksum: sum("example.com/ok")},
+		{Importpath: "example.com/edited", Revision: "1", Checksum: "0000"},
+		{Importpath: "example.com/nosum", Revision: "1"},
+		{Importpath: "example.com/gone", Revision: "1", Checksum: "0000"},
+	}}
+
+	got, err := Status(vendorDir, m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []StatusEntry{
+		{"example.com/edited", StatusUnconfirmed, "tree does not match its checksum"},
+		{"example.com/gone", StatusMissing, ""},
+		{"example.com/nosum", StatusUnconfirmed, "no checksum recorded"},
+		{"example.com/stray", StatusUntracked, ""},
+		{"other.org", StatusUntracked, ""},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("Status: want %v, got %v", want, got)
+	}
+
+	if got, err := Status(filepath.Join(vendorDir, "none"), &Manifest{}); err != nil || len(got) != 0 {
+		t.Fatalf("Status of an empty project: want nothing, got %v, %v", got, err)
+	}
+}
@@ -0,0 +1,67 @@
+package vendor
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Submodule is a git submodule of a dependency, checked out with it.
+type Submodule struct {
+	Path     string `json:"path"`     // slash separated, relative to the root of the repository
+	Revision string `json:"revision"` // commit the repository records for it
+}
+
+// HasSubmodules reports whether the checkout at dir declares git
+// submodules, which its files alone leave out.
+func HasSubmodules(dir string) bool {
+	_, err := os.Stat(filepath.Join(dir, ".gitmodules"))
+	return err == nil
+}
+
+// SubmoduleCheckouter is implemented by the WorkingCopies able to check
+// out the submodules of their repository.
+type SubmoduleCheckouter interface {
+	// CheckoutSubmodules checks out the submodules, and theirs, at the
+	// commits the checked out revision records, and returns them.
+	CheckoutSubmodules() ([]Submodule, error)
+}
+
+// CheckoutSubmodules uses git submodule update --init --recursive; the
+// files of the submodules are then part of the working tree, and copied
+// with it.
+func (g *GitClone) CheckoutSubmodules() ([]Submodule, error) {
+	Debugf("checking out the submodules of %s", g.path)
+	if _, err := runPath(g.path, "git", "submodule", "update", "-q", "--init", "--recursive"); err != nil {
+		return nil, err
+	}
+	out, err := runPath(g.path, "git", "submodule", "status", "--recursive")
+	if err != nil {
+		return nil, err
+	}
+	var subs []Submodule
+	for _, line := range strings.Split(string(out), "\n") {
+		if line == "" {
+			continue
+		}
+		// " <commit> <path> (<describe>)", the first character telling
+		// whether the submodule is out of date.
+		f := strings.Fields(line[1:])
+		if len(f) < 2 || line[0] != ' ' {
+			return nil, fmt.Errorf("submodule not checked out: %s", strings.TrimSpace(line))
+		}
+		subs = append(subs, Submodule{Path: f[1], Revision: f[0]})
+	}
+	return subs, nil
+}
+
+// CheckoutSubmodules checks out the submodules of wc, see
+// SubmoduleCheckouter, or returns an error if its VCS has none.
+func CheckoutSubmodules(wc WorkingCopy) ([]Submodule, error) {
+	sc, ok := wc.(SubmoduleCheckouter)
+	if !ok {
+		return nil, fmt.Errorf("submodules are only supported for git repositories")
+	}
+	return sc.CheckoutSubmodules()
+}
@@ -0,0 +1,59 @@
+package vendor
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/constabulary/gb/fileutils"
+)
+
+func TestGitCheckoutSubmodules(t *testing.T) {
+	// git refuses submodules over file:// unless told otherwise.
+	t.Setenv("GIT_CONFIG_COUNT", "1")
+	t.Setenv("GIT_CONFIG_KEY_0", "protocol.file.allow")
+	t.Setenv("GIT_CONFIG_VALUE_0", "always")
+
+	deep := mkgitrepo(t)
+	defer fileutils.RemoveAll(deep)
+	lib := mkgitrepo(t)
+	defer fileutils.RemoveAll(lib)
+	git(t, lib, "submodule", "add", "-q", "file://"+deep, "deep")
+	libRev := commit(t, lib, "add deep", nil)
+	dir := mkgitrepo(t)
+	defer fileutils.RemoveAll(dir)
+	git(t, dir, "submodule", "add", "-q", "file://"+lib, "third_party/lib")
+	commit(t, dir, "add lib", nil)
+
+	repo := &gitrepo{url: "file://" + dir}
+	wc, err := repo.Checkout("", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer wc.Destroy()
+	if !HasSubmodules(wc.Dir()) {
+		t.Fatal("HasSubmodules: want true")
+	}
+	got, err := CheckoutSubmodules(wc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Submodule{
+		{Path: "third_party/lib", Revision: libRev},
+		{Path: "third_party/lib/deep", Revision: git(t, deep, "rev-parse", "HEAD")},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("CheckoutSubmodules: want %v, got %v", want, got)
+	}
+
+	// the files of the submodules are copied with the tree, their .git
+	// files are not.
+	dst := mktemp(t)
+	defer fileutils.RemoveAll(dst)
+	if err := Copytree(dst, wc.Dir(), nil); err != nil {
+		t.Fatal(err)
+	}
+	assertExists(t, filepath.Join(dst, "third_party", "lib", "foo.go"))
+	assertExists(t, filepath.Join(dst, "third_party", "lib", "deep", "foo.go"))
+	assertNotExists(t, filepath.Join(dst, "third_party", "lib", ".git"))
+}
@@ -0,0 +1,144 @@
+package vendor
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/constabulary/gb/fileutils"
+)
+
+// Symlink policies of a Dependency, see Dependency.Symlinks.
+const (
+	SymlinksSkip  = "skip"  // leave the symlinks out, the default
+	SymlinksDeref = "deref" // copy what the symlinks point to in their place
+)
+
+// DefaultSymlinkDepth is the number of symlinks a chain may hold before
+// ResolveSymlink gives up, unless told otherwise.
+const DefaultSymlinkDepth = 8
+
+// ResolveSymlink returns the path of what the symlink at p, below root,
+// points to, following at most depth symlinks when they point to other
+// ones. It fails if the chain is longer, loops or leads outside of root.
+func ResolveSymlink(root, p string, depth int) (string, error) {
+	root = filepath.Clean(root)
+	seen := make(map[string]bool)
+	for hops := 0; ; hops++ {
+		info, err := os.Lstat(p)
+		if err != nil {
+			return "", err
+		}
+		if info.Mode()&os.ModeSymlink == 0 {
+			break
+		}
+		if seen[p] {
+			return "", fmt.Errorf("symlink loop through %s", p)
+		}
+		if hops == depth {
+			return "", fmt.Errorf("more than %d symlinks to follow, see -symlink-resolve-depth", depth)
+		}
+		seen[p] = true
+		target, err := os.Readlink(p)
+		if err != nil {
+			return "", err
+		}
+		if !filepath.IsAbs(target) {
+			target = filepath.Join(filepath.Dir(p), target)
+		}
+		p = filepath.Clean(target)
+		if !within(root, p) {
+			return "", fmt.Errorf("symlink points outside of the tree, to %s", p)
+		}
+	}
+	// the directories leading to p may be symlinks too.
+	real, err := filepath.EvalSymlinks(p)
+	if err != nil {
+		return "", err
+	}
+	realRoot, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return "", err
+	}
+	if !within(realRoot, real) {
+		return "", fmt.Errorf("symlink points outside of the tree, to %s", real)
+	}
+	return p, nil
+}
+
+// within reports whether p is root or below it.
+func within(root, p string) bool {
+	return p == root || strings.HasPrefix(p, root+string(filepath.Separator))
+}
+
+// CopytreeDeref is Copytree copying, in place of each symlink, what it
+// points to, see ResolveSymlink. A symlink to a directory holding it is a
+// loop, and an error.
+func CopytreeDeref(dst, src string, skip func(rel string, info os.FileInfo) bool, depth int) error {
+	_, statErr := os.Stat(dst)
+	err := copyDeref(dst, src, src, "", skip, depth, nil)
+	if err != nil && os.IsNotExist(statErr) {
+		fileutils.RemoveAll(dst)
+	}
+	return err
+}
+
+// copyDeref copies dir, a directory of the tree at root, to rel, slash
+// separated, of dst. ancestors are the real paths of the directories
+// being copied.
+func copyDeref(dst, root, dir, rel string, skip func(string, os.FileInfo) bool, depth int, ancestors []string) error {
+	real, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return err
+	}
+	for _, a := range ancestors {
+		if a == real {
+			return fmt.Errorf("%s: symlink loop, it points to a directory holding it", rel)
+		}
+	}
+	ancestors = append(ancestors, real)
+
+	f, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	names, err := f.Readdirnames(-1)
+	f.Close()
+	if err != nil {
+		return err
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if strings.HasPrefix(name, ".") {
+			continue
+		}
+		p, r := filepath.Join(dir, name), path.Join(rel, name)
+		info, err := os.Lstat(p)
+		if err != nil {
+			return err
+		}
+		if info.Mode()&os.ModeSymlink != 0 {
+			if p, err = ResolveSymlink(root, p, depth); err != nil {
+				return fmt.Errorf("%s: %v", r, err)
+			}
+			if info, err = os.Stat(p); err != nil {
+				return err
+			}
+		}
+		switch {
+		case info.IsDir():
+			if err := copyDeref(dst, root, p, r, skip, depth, ancestors); err != nil {
+				return err
+			}
+		case !info.Mode().IsRegular(), skip != nil && skip(r, info):
+		default:
+			if err := fileutils.Copyfile(filepath.Join(dst, filepath.FromSlash(r)), p); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+package vendor
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/constabulary/gb/fileutils"
+)
+
+// symlinks creates the symlinks of links, by slash separated path relative
+// to root, pointing to their target.
+func symlinks(t *testing.T, root string, links map[string]string) {
+	for name, target := range links {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Symlink(target, path); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestCopytreeDeref(t *testing.T) {
+	src := mktemp(t)
+	defer fileutils.RemoveAll(src)
+	writeFiles(t, src, map[string]string{
+		"foo.go":      "package foo\n",
+		"data/one.go": "package data\n",
+	})
+	symlinks(t, src, map[string]string{
+		"link.go": "foo.go",
+		"chain":   "link.go",
+		"alias":   "data",
+	})
+
+	dst := filepath.Join(mktemp(t), "dst")
+	defer fileutils.RemoveAll(filepath.Dir(dst))
+	if err := CopytreeDeref(dst, src, nil, DefaultSymlinkDepth); err != nil {
+		t.Fatal(err)
+	}
+	for name, want := range map[string]string{
+		"link.go":      "package foo\n",
+		"chain":        "package foo\n",
+		"alias/one.go": "package data\n",
+	} {
+		path := filepath.Join(dst, filepath.FromSlash(name))
+		info, err := os.Lstat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		buf, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !info.Mode().IsRegular() || string(buf) != want {
+			t.Errorf("%s: want a copy of %q, got mode %v and %q", name, want, info.Mode(), buf)
+		}
+	}
+
+	// chain needs two hops.
+	if err := CopytreeDeref(filepath.Join(dst, "shallow"), src, nil, 1); err == nil || !strings.Contains(err.Error(), "more than 1 symlinks") {
+		t.Errorf("CopytreeDeref with depth 1: want the depth error, got %v", err)
+	}
+}
+
+func TestCopytreeDerefUnsafe(t *testing.T) {
+	outside := mktemp(t)
+	defer fileutils.RemoveAll(outside)
+	writeFiles(t, outside, map[string]string{"secret": "secret\n"})
+
+	for _, tt := range []struct {
+		name  string
+		links map[string]string
+		want  string
+	}{
+		{"loop", map[string]string{"a": "b", "b": "a"}, "symlink loop"},
+		{"self", map[string]string{"self": "self"}, "symlink loop"},
+		{"parent", map[string]string{"sub/up": ".."}, "symlink loop"},
+		{"escape", map[string]string{"out": filepath.Join(outside, "secret")}, "outside of the tree"},
+		{"relative escape", map[string]string{"sub/out": "../../elsewhere"}, "outside of the tree"},
+	} {
+		src := mktemp(t)
+		writeFiles(t, src, map[string]string{"foo.go": "package foo\n"})
+		symlinks(t, src, tt.links)
+
+		done := make(chan error, 1)
+		go func() { done <- CopytreeDeref(filepath.Join(src, ".dst"), src, nil, DefaultSymlinkDepth) }()
+		select {
+		case err := <-done:
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("%s: want an error containing %q, got %v", tt.name, tt.want, err)
+			}
+		case <-time.After(10 * time.Second):
+			t.Fatalf("%s: CopytreeDeref did not return", tt.name)
+		}
+		fileutils.RemoveAll(src)
+	}
+}
@@ -0,0 +1,243 @@
+package vendor
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// TagLister is implemented by the RemoteRepos able to list their tags
+// without a checkout.
+type TagLister interface {
+	// Tags returns the names of the tags of the repository.
+	Tags() ([]string, error)
+}
+
+// BranchLister is implemented by the RemoteRepos able to list their
+// branches without a checkout.
+type BranchLister interface {
+	// Branches returns the names of the branches of the repository.
+	Branches() ([]string, error)
+}
+
+// DefaultBrancher is implemented by the RemoteRepos able to tell their
+// default branch without a checkout.
+type DefaultBrancher interface {
+	// DefaultBranch returns the name of the branch the HEAD of the
+	// repository points to.
+	DefaultBranch() (string, error)
+}
+
+// RevisionResolver is implemented by the RemoteRepos able to tell the
+// revision of a branch or tag without a checkout.
+type RevisionResolver interface {
+	// ResolveRevision returns the revision Checkout would check out
+	// for branch or tag, that of HEAD if both are empty.
+	ResolveRevision(branch, tag string) (string, error)
+}
+
+// Tags lists the tags of the remote with git ls-remote.
+func (g *gitrepo) Tags() ([]string, error) {
+	return g.lsRemote("--tags", "refs/tags/")
+}
+
+// Branches lists the branches of the remote with git ls-remote.
+func (g *gitrepo) Branches() ([]string, error) {
+	return g.lsRemote("--heads", "refs/heads/")
+}
+
+// DefaultBranch reads the branch of HEAD with git ls-remote --symref.
+func (g *gitrepo) DefaultBranch() (string, error) {
+	out, err := run("git", "ls-remote", "--symref", g.url, "HEAD")
+	if err != nil {
+		return "", err
+	}
+	for _, line := range strings.Split(string(out), "\n") {
+		f := strings.Fields(line)
+		if len(f) == 3 && f[0] == "ref:" && f[2] == "HEAD" && strings.HasPrefix(f[1], "refs/heads/") {
+			return strings.TrimPrefix(f[1], "refs/heads/"), nil
+		}
+	}
+	return "", fmt.Errorf("%s does not tell the branch of its HEAD", g.url)
+}
+
+// ResolveRevision resolves branch or tag with git ls-remote, an annotated
+// tag to the commit it points to.
+func (g *gitrepo) ResolveRevision(branch, tag string) (string, error) {
+	ref := "HEAD"
+	switch {
+	case tag != "":
+		ref = "refs/tags/" + tag
+	case branch != "" && branch != "HEAD":
+		ref = "refs/heads/" + branch
+	}
+	out, err := run("git", "ls-remote", g.url, ref, ref+"^{}")
+	if err != nil {
+		return "", err
+	}
+	var rev string
+	for _, line := range strings.Split(string(out), "\n") {
+		f := strings.Fields(line)
+		if len(f) != 2 {
+			continue
+		}
+		switch f[1] {
+		case ref + "^{}":
+			return f[0], nil
+		case ref:
+			rev = f[0]
+		}
+	}
+	if rev == "" {
+		return "", fmt.Errorf("%s not found in %s", strings.TrimPrefix(strings.TrimPrefix(ref, "refs/heads/"), "refs/tags/"), g.url)
+	}
+	return rev, nil
+}
+
+// lsRemote returns the names of the refs git ls-remote lists with flag,
+// stripped of prefix.
+func (g *gitrepo) lsRemote(flag, prefix string) ([]string, error) {
+	out, err := run("git", "ls-remote", flag, "--refs", g.url)
+	if err != nil {
+		return nil, err
+	}
+	var refs []string
+	for _, line := range strings.Split(string(out), "\n") {
+		f := strings.Fields(line)
+		if len(f) == 2 && strings.HasPrefix(f[1], prefix) {
+			refs = append(refs, strings.TrimPrefix(f[1], prefix))
+		}
+	}
+	return refs, nil
+}
+
+// SortTags sorts tags from the highest semantic version to the lowest,
+// the tags of a monorepo component, foo/v1.2.3, grouped after those at
+// the root. The tags that are no semantic version follow, in lexical
+// order.
+func SortTags(tags []string) {
+	split := func(tag string) (string, semver, bool) {
+		i := strings.LastIndex(tag, "/")
+		v, ok := parseSemver(tag[i+1:])
+		return tag[:i+1], v, ok
+	}
+	sort.SliceStable(tags, func(i, j int) bool {
+		pi, vi, oki := split(tags[i])
+		pj, vj, okj := split(tags[j])
+		switch {
+		case oki != okj:
+			return oki
+		case !oki:
+			return tags[i] < tags[j]
+		case pi != pj:
+			return pi < pj
+		}
+		return vj.less(vi)
+	})
+}
+
+// LatestTag returns the tag of tags with the highest semantic version,
+// such as v1.2.3, once prefix is stripped: with prefix "foo/" the tags of
+// a monorepo component, foo/v1.2.3, are compared as v1.2.3, and the other
+// tags ignored. Pre-releases are only selected if there is no release.
+func LatestTag(tags []string, prefix string) (string, bool) {
+	var best, bestPre string
+	var bestV, bestPreV semver
+	for _, tag := range tags {
+		if !strings.HasPrefix(tag, prefix) {
+			continue
+		}
+		v, ok := parseSemver(strings.TrimPrefix(tag, prefix))
+		switch {
+		case !ok:
+		case len(v.pre) > 0:
+			if bestPre == "" || bestPreV.less(v) {
+				bestPre, bestPreV = tag, v
+			}
+		case best == "" || bestV.less(v):
+			best, bestV = tag, v
+		}
+	}
+	if best == "" {
+		best = bestPre
+	}
+	return best, best != ""
+}
+
+// semver is a parsed semantic version, build metadata dropped.
+type semver struct {
+	major, minor, patch int
+	pre                 []string
+}
+
+// parseSemver parses v, which must be of the form vMAJOR.MINOR.PATCH with
+// an optional -pre-release and +build suffix.
+func parseSemver(v string) (semver, bool) {
+	var s semver
+	if !strings.HasPrefix(v, "v") {
+		return s, false
+	}
+	v = v[1:]
+	if i := strings.Index(v, "+"); i >= 0 {
+		v = v[:i]
+	}
+	if i := strings.Index(v, "-"); i >= 0 {
+		if i == len(v)-1 {
+			return s, false
+		}
+		s.pre = strings.Split(v[i+1:], ".")
+		v = v[:i]
+	}
+	parts := strings.Split(v, ".")
+	if len(parts) != 3 {
+		return s, false
+	}
+	nums := []*int{&s.major, &s.minor, &s.patch}
+	for i, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 0 || (len(p) > 1 && p[0] == '0') {
+			return s, false
+		}
+		*nums[i] = n
+	}
+	return s, true
+}
+
+// less reports whether s has a lower precedence than t.
+func (s semver) less(t semver) bool {
+	if s.major != t.major {
+		return s.major < t.major
+	}
+	if s.minor != t.minor {
+		return s.minor < t.minor
+	}
+	if s.patch != t.patch {
+		return s.patch < t.patch
+	}
+	// a release is higher than its pre-releases.
+	switch {
+	case len(s.pre) == 0:
+		return false
+	case len(t.pre) == 0:
+		return true
+	}
+	for i := 0; i < len(s.pre) && i < len(t.pre); i++ {
+		a, b := s.pre[i], t.pre[i]
+		if a == b {
+			continue
+		}
+		na, aerr := strconv.Atoi(a)
+		nb, berr := strconv.Atoi(b)
+		switch {
+		case aerr == nil && berr == nil:
+			return na < nb
+		case aerr == nil:
+			return true // numeric identifiers are lower
+		case berr == nil:
+			return false
+		}
+		return a < b
+	}
+	return len(s.pre) < len(t.pre)
+}
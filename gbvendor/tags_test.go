@@ -0,0 +1,154 @@
+package vendor
+
+import (
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/constabulary/gb/fileutils"
+)
+
+func TestLatestTag(t *testing.T) {
+	tags := []string{
+		"v2.0.0",
+		"v1.9.9",
+		"foo/v1.2.3",
+		"foo/v1.10.0",
+		"foo/v1.11.0-rc.1",
+		"foo/v1.10.0+meta",
+		"foo/bar/v9.0.0",
+		"foo/latest",
+		"bar/v3.0.0",
+	}
+	tests := []struct {
+		tags   []string
+		prefix string
+		want   string
+	}{
+		{tags, "", "v2.0.0"},
+		{tags, "foo/", "foo/v1.10.0"},
+		{tags, "foo/bar/", "foo/bar/v9.0.0"},
+		{tags, "bar/", "bar/v3.0.0"},
+		{[]string{"foo/v1.0.0-rc.2", "foo/v1.0.0-rc.10", "foo/v1.0.0-beta"}, "foo/", "foo/v1.0.0-rc.10"},
+		{[]string{"foo/v1.0.0-alpha", "foo/v1.0.0-alpha.1"}, "foo/", "foo/v1.0.0-alpha.1"},
+		{tags, "baz/", ""},
+		{[]string{"foo/1.2.3", "foo/v1.2", "foo/v01.2.3"}, "foo/", ""},
+	}
+	for _, tt := range tests {
+		got, ok := LatestTag(tt.tags, tt.prefix)
+		if got != tt.want || ok != (tt.want != "") {
+			t.Errorf("LatestTag(%v, %q): want %q, got %q, %v", tt.tags, tt.prefix, tt.want, got, ok)
+		}
+	}
+}
+
+func TestGitTags(t *testing.T) {
+	dir := mkgitrepo(t)
+	defer fileutils.RemoveAll(dir)
+
+	git(t, dir, "tag", "v1.0.0")
+	git(t, dir, "tag", "-a", "-m", "foo", "foo/v1.2.3")
+	commit(t, dir, "foo fix", map[string]string{"foo/foo.go": "package foo\n"})
+	git(t, dir, "tag", "foo/v1.2.4")
+
+	repo := &gitrepo{url: "file://" + dir}
+	got, err := repo.Tags()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(got)
+	if want := []string{"foo/v1.2.3", "foo/v1.2.4", "v1.0.0"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("Tags: want %v, got %v", want, got)
+	}
+
+	tag, _ := LatestTag(got, "foo/")
+	if tag != "foo/v1.2.4" {
+		t.Fatalf("LatestTag: want foo/v1.2.4, got %q", tag)
+	}
+	wc, err := repo.Checkout("", tag, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer wc.Destroy()
+	assertExists(t, filepath.Join(wc.Dir(), "foo", "foo.go"))
+}
+
+func TestGitBranches(t *testing.T) {
+	dir := mkgitrepo(t)
+	defer fileutils.RemoveAll(dir)
+
+	for _, tag := range []string{"v1.2.0", "v1.10.0", "v1.10.0-rc.1", "v2.0.0", "nightly", "foo/v0.1.0", "foo/v0.2.0"} {
+		git(t, dir, "tag", tag)
+	}
+	git(t, dir, "branch", "release-1.x")
+	git(t, dir, "branch", "feature/x")
+
+	repo := &gitrepo{url: "file://" + dir}
+	tags, err := repo.Tags()
+	if err != nil {
+		t.Fatal(err)
+	}
+	SortTags(tags)
+	if want := []string{"v2.0.0", "v1.10.0", "v1.10.0-rc.1", "v1.2.0", "foo/v0.2.0", "foo/v0.1.0", "nightly"}; !reflect.DeepEqual(tags, want) {
+		t.Fatalf("SortTags: want %v, got %v", want, tags)
+	}
+
+	branches, err := repo.Branches()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(branches)
+	if want := []string{"feature/x", "master", "release-1.x"}; !reflect.DeepEqual(branches, want) {
+		t.Fatalf("Branches: want %v, got %v", want, branches)
+	}
+}
+
+func TestGitDefaultBranch(t *testing.T) {
+	dir := mkgitrepo(t)
+	defer fileutils.RemoveAll(dir)
+
+	git(t, dir, "branch", "-m", "master", "main")
+	git(t, dir, "branch", "release-1.x")
+
+	repo := &gitrepo{url: "file://" + dir}
+	got, err := repo.DefaultBranch()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "main" {
+		t.Fatalf("DefaultBranch: want main, got %q", got)
+	}
+}
+
+func TestGitResolveRevision(t *testing.T) {
+	dir := mkgitrepo(t)
+	defer fileutils.RemoveAll(dir)
+
+	old := git(t, dir, "rev-parse", "HEAD")
+	git(t, dir, "tag", "-a", "-m", "foo", "v1.0.0")
+	git(t, dir, "branch", "release-1.x")
+	head := commit(t, dir, "add bar", map[string]string{"bar.go": "package foo\n"})
+
+	repo := &gitrepo{url: "file://" + dir}
+	for _, tt := range []struct {
+		branch, tag, want string
+	}{
+		{"", "", head},
+		{"HEAD", "", head},
+		{"master", "", head},
+		{"release-1.x", "", old},
+		{"", "v1.0.0", old}, // the commit, not the annotated tag
+	} {
+		got, err := repo.ResolveRevision(tt.branch, tt.tag)
+		if err != nil {
+			t.Fatalf("ResolveRevision(%q, %q): %v", tt.branch, tt.tag, err)
+		}
+		if got != tt.want {
+			t.Errorf("ResolveRevision(%q, %q): want %s, got %s", tt.branch, tt.tag, tt.want, got)
+		}
+	}
+	if _, err := repo.ResolveRevision("missing", ""); err == nil {
+		t.Error("ResolveRevision(\"missing\", \"\"): want an error")
+	}
+}
@@ -0,0 +1,139 @@
+package vendor
+
+import (
+	"errors"
+	"regexp"
+	"sync"
+	"time"
+)
+
+// rateLimitRe matches the messages of hosts refusing requests for being
+// too frequent. The 429 status only counts in its HTTP forms, a bare 429
+// may be part of a revision, a tag or a line number.
+var rateLimitRe = regexp.MustCompile(`(?i)\bHTTP(/[0-9.]+)? 429\b|returned error: 429\b|too many requests|rate.?limit`)
+
+// RateLimitError is returned by the VCS commands failing because the host
+// rate limited them.
+type RateLimitError struct {
+	Err error
+}
+
+func (e *RateLimitError) Error() string { return "rate limited by host: " + e.Err.Error() }
+
+// IsRateLimited reports whether err was caused by a host rate limiting
+// requests, even once wrapped into another message.
+func IsRateLimited(err error) bool {
+	if err == nil {
+		return false
+	}
+	var rl *RateLimitError
+	return errors.As(err, &rl) || rateLimitRe.MatchString(err.Error())
+}
+
+// HostThrottle bounds the concurrent clones of each host, adapting to
+// rate limiting: a rate limited clone halves the concurrency allowed for
+// its host and makes it wait Backoff, doubled each time, before the next
+// clone starts. Every RecoverAfter successful clones raise the concurrency
+// back by one, up to Max.
+type HostThrottle struct {
+	Max          int           // concurrency of a host that is not rate limiting
+	Backoff      time.Duration // initial wait after rate limiting
+	MaxBackoff   time.Duration // longest wait after rate limiting
+	RecoverAfter int           // successes raising the concurrency by one
+
+	mu    sync.Mutex
+	cond  *sync.Cond
+	hosts map[string]*hostState
+}
+
+type hostState struct {
+	limit, active, successes int
+	backoff                  time.Duration
+	until                    time.Time // no clone starts before
+}
+
+// NewHostThrottle returns a HostThrottle allowing at most max concurrent
+// clones per host.
+func NewHostThrottle(max int) *HostThrottle {
+	if max < 1 {
+		max = 1
+	}
+	t := &HostThrottle{
+		Max:          max,
+		Backoff:      time.Second,
+		MaxBackoff:   time.Minute,
+		RecoverAfter: 4,
+		hosts:        make(map[string]*hostState),
+	}
+	t.cond = sync.NewCond(&t.mu)
+	return t
+}
+
+func (t *HostThrottle) state(host string) *hostState {
+	s, ok := t.hosts[host]
+	if !ok {
+		s = &hostState{limit: t.Max}
+		t.hosts[host] = s
+	}
+	return s
+}
+
+// Acquire waits until a clone of host may start.
+func (t *HostThrottle) Acquire(host string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for {
+		s := t.state(host)
+		if wait := time.Until(s.until); wait > 0 {
+			t.mu.Unlock()
+			time.Sleep(wait)
+			t.mu.Lock()
+			continue
+		}
+		if s.active < s.limit {
+			s.active++
+			return
+		}
+		t.cond.Wait()
+	}
+}
+
+// Release records the end of a clone of host that returned err. It
+// reports whether the clone was rate limited, and is worth retrying once
+// Acquire lets it.
+func (t *HostThrottle) Release(host string, err error) (rateLimited bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	defer t.cond.Broadcast()
+	s := t.state(host)
+	s.active--
+	switch {
+	case IsRateLimited(err):
+		if s.limit /= 2; s.limit < 1 {
+			s.limit = 1
+		}
+		if s.backoff *= 2; s.backoff == 0 {
+			s.backoff = t.Backoff
+		}
+		if s.backoff > t.MaxBackoff {
+			s.backoff = t.MaxBackoff
+		}
+		s.until = time.Now().Add(s.backoff)
+		s.successes = 0
+		return true
+	case err == nil:
+		s.backoff = 0
+		if s.successes++; s.successes >= t.RecoverAfter && s.limit < t.Max {
+			s.limit++
+			s.successes = 0
+		}
+	}
+	return false
+}
+
+// Limit returns the concurrency currently allowed for host.
+func (t *HostThrottle) Limit(host string) int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.state(host).limit
+}
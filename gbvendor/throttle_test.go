@@ -0,0 +1,85 @@
+package vendor
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"testing"
+	"time"
+)
+
+func TestIsRateLimited(t *testing.T) {
+	for _, tt := range []struct {
+		err  error
+		want bool
+	}{
+		{nil, false},
+		{errors.New("exit status 128"), false},
+		{&RateLimitError{errors.New("exit status 128")}, true},
+		{fmt.Errorf("dependency could not be fetched: %v", &RateLimitError{errors.New("exit status 128")}), true},
+		{errors.New("The requested URL returned error: 429"), true},
+		{errors.New("fatal: unable to access 'https://example.com/a/': HTTP 429"), true},
+		{errors.New("HTTP/1.1 429"), true},
+		{errors.New("error: pathspec 'v1.429' did not match any file(s) known to git"), false},
+		{errors.New("fatal: bad object 429"), false},
+		{errors.New("API rate limit exceeded"), true},
+	} {
+		if got := IsRateLimited(tt.err); got != tt.want {
+			t.Errorf("IsRateLimited(%v): want %v, got %v", tt.err, tt.want, got)
+		}
+	}
+}
+
+func TestHostThrottle(t *testing.T) {
+	const host = "github.com"
+	th := NewHostThrottle(4)
+	th.Backoff, th.MaxBackoff, th.RecoverAfter = 10*time.Millisecond, 20*time.Millisecond, 2
+	rateLimited := errors.New("remote: 429 Too Many Requests")
+
+	th.Acquire(host)
+	if !th.Release(host, rateLimited) {
+		t.Fatal("Release: want a rate limited clone reported")
+	}
+	if got := th.Limit(host); got != 2 {
+		t.Fatalf("Limit after a 429: want 2, got %d", got)
+	}
+	if got := th.Limit("bitbucket.org"); got != 4 {
+		t.Fatalf("Limit of another host: want 4, got %d", got)
+	}
+
+	// the next clone waits for the backoff.
+	start := time.Now()
+	th.Acquire(host)
+	if waited := time.Since(start); waited < 10*time.Millisecond {
+		t.Fatalf("Acquire after a 429: want a wait of 10ms, waited %v", waited)
+	}
+	th.Release(host, rateLimited)
+	if got := th.Limit(host); got != 1 {
+		t.Fatalf("Limit after two 429s: want 1, got %d", got)
+	}
+
+	// while limited, a second concurrent clone waits for the first.
+	th.Acquire(host)
+	started := make(chan struct{})
+	go func() {
+		th.Acquire(host)
+		close(started)
+	}()
+	select {
+	case <-started:
+		t.Fatal("second clone started above the limit")
+	case <-time.After(50 * time.Millisecond):
+	}
+	th.Release(host, nil)
+	<-started
+	th.Release(host, exec.ErrNotFound)
+
+	// successes gradually restore the concurrency.
+	for i := 0; i < 6; i++ {
+		th.Acquire(host)
+		th.Release(host, nil)
+	}
+	if got := th.Limit(host); got != 4 {
+		t.Fatalf("Limit after successes: want 4, got %d", got)
+	}
+}
@@ -0,0 +1,106 @@
+package vendor
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+)
+
+// httpTransport is the transport of the HTTP requests of gvt, set by
+// SetTransport.
+var httpTransport http.RoundTripper = http.DefaultTransport
+
+// commandEnv is added to the environment of the VCS tools by SetTransport.
+var commandEnv []string
+
+// systemCABundles are the usual locations of the certificates trusted by
+// the system, which git trusts along with those given to SetTransport.
+var systemCABundles = []string{
+	"/etc/ssl/certs/ca-certificates.crt", // Debian, Ubuntu, Alpine
+	"/etc/pki/tls/certs/ca-bundle.crt",   // Fedora, RHEL
+	"/etc/ssl/ca-bundle.pem",             // openSUSE
+	"/etc/ssl/cert.pem",                  // macOS, BSDs
+}
+
+// SetTransport makes the HTTP requests of gvt, and the VCS tools it runs,
+// go through the HTTP proxy at the URL proxy and trust the PEM encoded
+// certificates of caCertFile along with those of the system. Either may
+// be empty: without proxy, the HTTP_PROXY, HTTPS_PROXY and NO_PROXY
+// environment applies as usual. The VCS tools are configured through
+// their environment: the proxy variables, and for git GIT_SSL_CAINFO. The
+// returned function removes the files SetTransport created.
+func SetTransport(proxy, caCertFile string) (func(), error) {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	var env []string
+	cleanup := func() {}
+	if proxy != "" {
+		u, err := url.Parse(proxy)
+		if err != nil || u.Host == "" {
+			return cleanup, fmt.Errorf("invalid proxy URL %q", proxy)
+		}
+		t.Proxy = http.ProxyURL(u)
+		for _, v := range []string{"HTTP_PROXY", "HTTPS_PROXY", "http_proxy", "https_proxy"} {
+			env = append(env, v+"="+proxy)
+		}
+	}
+	if caCertFile != "" {
+		pem, err := ioutil.ReadFile(caCertFile)
+		if err != nil {
+			return cleanup, err
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return cleanup, fmt.Errorf("no certificate found in %s", caCertFile)
+		}
+		t.TLSClientConfig = &tls.Config{RootCAs: pool}
+
+		// git trusts a single bundle: that of the system, with the
+		// certificates of caCertFile appended.
+		bundle, err := caBundle(pem)
+		if err != nil {
+			return cleanup, err
+		}
+		cleanup = func() { os.Remove(bundle) }
+		env = append(env, "GIT_SSL_CAINFO="+bundle)
+	}
+	httpTransport = t
+	metadataClient.Transport = t
+	proxyClient.Transport = t
+	commandEnv = env
+	return cleanup, nil
+}
+
+// caBundle writes to a temporary file the first system bundle found,
+// followed by pem, and returns its path.
+func caBundle(pem []byte) (string, error) {
+	var system []byte
+	for _, path := range append([]string{os.Getenv("SSL_CERT_FILE")}, systemCABundles...) {
+		if path == "" {
+			continue
+		}
+		if buf, err := ioutil.ReadFile(path); err == nil {
+			system = append(buf, '\n')
+			break
+		}
+	}
+	f, err := ioutil.TempFile("", "gvt-ca-")
+	if err != nil {
+		return "", err
+	}
+	_, err = f.Write(append(system, pem...))
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	return f.Name(), nil
+}
@@ -0,0 +1,90 @@
+package vendor
+
+import (
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/constabulary/gb/fileutils"
+)
+
+func TestSetTransportCACert(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+	defer SetTransport("", "")
+
+	if _, err := metadataClient.Get(srv.URL); err == nil {
+		t.Fatal("Get from a server of an unknown CA: want an error")
+	}
+
+	root := mktemp(t)
+	defer fileutils.RemoveAll(root)
+	ca := filepath.Join(root, "ca.pem")
+	cert := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+	if err := ioutil.WriteFile(ca, cert, 0644); err != nil {
+		t.Fatal(err)
+	}
+	cleanup, err := SetTransport("", ca)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cleanup()
+	resp, err := metadataClient.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	// git trusts a bundle holding the certificate.
+	out, err := run("sh", "-c", `cat "$GIT_SSL_CAINFO"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasSuffix(string(out), string(cert)) {
+		t.Fatalf("GIT_SSL_CAINFO: want a bundle ending with the certificate, got %s", out)
+	}
+
+	if err := ioutil.WriteFile(ca, []byte("not a certificate"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := SetTransport("", ca); err == nil {
+		t.Fatal("SetTransport of a file without certificate: want an error")
+	}
+}
+
+func TestSetTransportProxy(t *testing.T) {
+	var proxied []string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied = append(proxied, r.URL.String())
+	}))
+	defer proxy.Close()
+	defer SetTransport("", "")
+
+	if _, err := SetTransport(proxy.URL, ""); err != nil {
+		t.Fatal(err)
+	}
+	resp, err := metadataClient.Get("http://example.invalid/repo?go-get=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if len(proxied) != 1 || proxied[0] != "http://example.invalid/repo?go-get=1" {
+		t.Fatalf("Get through the proxy: want the request proxied, got %v", proxied)
+	}
+
+	out, err := run("sh", "-c", "echo $HTTPS_PROXY")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.TrimSpace(string(out)); got != proxy.URL {
+		t.Fatalf("HTTPS_PROXY of the VCS tools: want %s, got %s", proxy.URL, got)
+	}
+
+	if _, err := SetTransport("not a url", ""); err == nil {
+		t.Fatal("SetTransport of an invalid proxy URL: want an error")
+	}
+}
@@ -0,0 +1,216 @@
+package vendor
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// buildExts are the extensions of the files the go tool may need to build
+// a package, regardless of the target platform.
+var buildExts = map[string]bool{
+	".go":      true,
+	".c":       true,
+	".cc":      true,
+	".cpp":     true,
+	".cxx":     true,
+	".h":       true,
+	".hh":      true,
+	".hpp":     true,
+	".hxx":     true,
+	".m":       true,
+	".s":       true,
+	".S":       true,
+	".sx":      true,
+	".f":       true,
+	".F":       true,
+	".for":     true,
+	".f90":     true,
+	".swig":    true,
+	".swigcxx": true,
+	".syso":    true,
+}
+
+// IsBuildFile reports whether name is needed to build the package
+// containing it. Test files are not.
+func IsBuildFile(name string) bool {
+	if strings.HasSuffix(name, "_test.go") {
+		return false
+	}
+	return buildExts[filepath.Ext(name)]
+}
+
+// IsLicenseFile reports whether name looks like a license or copyright notice.
+// Source files, such as licensed.go, never do.
+func IsLicenseFile(name string) bool {
+	if buildExts[filepath.Ext(name)] {
+		return false
+	}
+	n := strings.ToUpper(name)
+	for _, prefix := range []string{"LICENSE", "LICENCE", "COPYING", "COPYRIGHT", "NOTICE", "UNLICENSE"} {
+		if strings.HasPrefix(n, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// FindLicenses returns the names of the license files directly inside dir.
+func FindLicenses(dir string) ([]string, error) {
+	f, err := os.Open(dir)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	fis, err := f.Readdir(-1)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, fi := range fis {
+		if fi.Mode().IsRegular() && IsLicenseFile(fi.Name()) {
+			names = append(names, fi.Name())
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// TrimPackages removes everything below root that is not needed to build
+// the packages in pkgs. pkgs are slash separated directories relative to
+// root, "." being root itself. License files and the files referenced by
+// //go:embed are always kept, and so are the directories leading to a kept
+// package.
+func TrimPackages(root string, pkgs []string) error {
+	return TrimPackagesKeeping(root, pkgs, nil)
+}
+
+// TrimPackagesKeeping is like TrimPackages but also keeps the files in keep,
+// slash separated paths relative to root, such as those of CgoFiles.
+func TrimPackagesKeeping(root string, pkgs []string, keep map[string]bool) error {
+	embedded, err := EmbeddedFiles(root)
+	if err != nil {
+		return err
+	}
+	kept := make(map[string]bool)
+	for _, p := range pkgs {
+		kept[filepath.Clean(filepath.FromSlash(p))] = true
+	}
+
+	var dirs []string
+	err = filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			dirs = append(dirs, path)
+			return nil
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		if IsLicenseFile(info.Name()) || embedded[filepath.ToSlash(rel)] || keep[filepath.ToSlash(rel)] || (kept[filepath.Dir(rel)] && IsBuildFile(info.Name())) {
+			return nil
+		}
+		return os.Remove(path)
+	})
+	if err != nil {
+		return err
+	}
+
+	// remove the directories left empty, deepest first.
+	sort.Sort(sort.Reverse(sort.StringSlice(dirs)))
+	for _, dir := range dirs {
+		if dir == root {
+			continue
+		}
+		if f, err := os.Open(dir); err == nil {
+			names, _ := f.Readdirnames(1)
+			f.Close()
+			if len(names) == 0 {
+				if err := os.Remove(dir); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// PackageClosure returns the packages of the tree at root, whose import
+// path is importpath, imported directly or indirectly by the packages in
+// pkgs, pkgs included, for TrimPackages. pkgs and the result are slash
+// separated directories relative to root, "." being root itself. Imports
+// are followed for every platform, tests excluded.
+func PackageClosure(root, importpath string, pkgs []string) ([]string, error) {
+	d, err := LoadTree(root, filepath.FromSlash(importpath))
+	if err != nil {
+		return nil, err
+	}
+	rel := func(ip string) string {
+		if ip == importpath {
+			return "."
+		}
+		return strings.TrimPrefix(ip, importpath+"/")
+	}
+	seen := make(map[string]bool)
+	var walk func(ip string) error
+	walk = func(ip string) error {
+		if seen[ip] {
+			return nil
+		}
+		seen[ip] = true
+		imports, err := d.Pkgs[ip].AllImports()
+		if err != nil {
+			return err
+		}
+		for _, i := range imports {
+			if _, ok := d.Pkgs[i]; ok {
+				if err := walk(i); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+	for _, p := range pkgs {
+		ip := path.Join(importpath, p)
+		if _, ok := d.Pkgs[ip]; !ok {
+			return nil, fmt.Errorf("no package %s in %s", p, importpath)
+		}
+		if err := walk(ip); err != nil {
+			return nil, err
+		}
+	}
+	var closure []string
+	for ip := range seen {
+		closure = append(closure, rel(ip))
+	}
+	sort.Strings(closure)
+	return closure, nil
+}
+
+// WhitelistPackages returns, sorted, the packages of the tree at root to
+// vendor for the whitelisted pkgs, the tree's import path being
+// importpath: pkgs and the packages of the tree they import, directly or
+// indirectly, which it also returns as added. See PackageClosure.
+func WhitelistPackages(root, importpath string, pkgs []string) (closure, added []string, err error) {
+	closure, err = PackageClosure(root, importpath, pkgs)
+	if err != nil {
+		return nil, nil, err
+	}
+	listed := make(map[string]bool)
+	for _, p := range pkgs {
+		listed[path.Clean(p)] = true
+	}
+	for _, p := range closure {
+		if !listed[p] {
+			added = append(added, p)
+		}
+	}
+	return closure, added, nil
+}
@@ -0,0 +1,156 @@
+package vendor
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/constabulary/gb/fileutils"
+)
+
+func writeFiles(t *testing.T, root string, files map[string]string) {
+	for name, body := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(body), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestTrimPackages(t *testing.T) {
+	root := mktemp(t)
+	defer fileutils.RemoveAll(root)
+
+	writeFiles(t, root, map[string]string{
+		"LICENSE":             "MIT",
+		"README.md":           "# dep",
+		"dep.go":              "package dep\n\nimport \"example.com/dep/a\"\n\nvar X = a.Y\n",
+		"dep_test.go":         "package dep\n",
+		"dep_windows.go":      "package dep\n",
+		"a/a.go":              "package a\n\nconst Y = 1\n",
+		"a/a.h":               "",
+		"a/doc.txt":           "docs",
+		"a/testdata/data.txt": "data",
+		"examples/main.go":    "package main\n\nfunc main() {}\n",
+		"unused/unused.go":    "package unused\n",
+	})
+
+	if err := TrimPackages(root, []string{".", "a"}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"LICENSE", "dep.go", "dep_windows.go", "a/a.go", "a/a.h"} {
+		assertExists(t, filepath.Join(root, filepath.FromSlash(name)))
+	}
+	for _, name := range []string{"README.md", "dep_test.go", "a/doc.txt", "a/testdata", "examples", "unused"} {
+		assertNotExists(t, filepath.Join(root, filepath.FromSlash(name)))
+	}
+
+	// the trimmed tree must still build.
+	writeFiles(t, root, map[string]string{"go.mod": "module example.com/dep\n\ngo 1.16\n"})
+	cmd := exec.Command("go", "build", "./...")
+	cmd.Dir = root
+	cmd.Env = append(os.Environ(), "GO111MODULE=on", "GOFLAGS=-mod=mod", "GOPROXY=off")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("go build after TrimPackages failed: %v\n%s", err, out)
+	}
+}
+
+func TestTrimPackagesKeepsEmbedded(t *testing.T) {
+	root := mktemp(t)
+	defer fileutils.RemoveAll(root)
+
+	writeFiles(t, root, map[string]string{
+		"web/web.go":              "package web\n\nimport \"embed\"\n\n//go:embed static templates/*.tmpl\nvar content embed.FS\n",
+		"web/static/index.html":   "<html></html>",
+		"web/static/.hidden":      "hidden",
+		"web/templates/a.tmpl":    "{{.}}",
+		"web/templates/notes.txt": "notes",
+		"web/README":              "readme",
+	})
+
+	if err := TrimPackages(root, []string{"web"}); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"web/web.go", "web/static/index.html", "web/templates/a.tmpl"} {
+		assertExists(t, filepath.Join(root, filepath.FromSlash(name)))
+	}
+	for _, name := range []string{"web/static/.hidden", "web/templates/notes.txt", "web/README"} {
+		assertNotExists(t, filepath.Join(root, filepath.FromSlash(name)))
+	}
+}
+
+func TestFindLicenses(t *testing.T) {
+	root := mktemp(t)
+	defer fileutils.RemoveAll(root)
+
+	writeFiles(t, root, map[string]string{
+		"with/LICENSE.md":  "MIT",
+		"with/COPYING":     "GPL",
+		"with/licensed.go": "package with\n",
+		"with/sub/LICENSE": "MIT",
+		"without/foo.go":   "package without\n",
+	})
+
+	got, err := FindLicenses(filepath.Join(root, "with"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"COPYING", "LICENSE.md"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("FindLicenses: want %v, got %v", want, got)
+	}
+
+	got, err = FindLicenses(filepath.Join(root, "without"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 0 {
+		t.Fatalf("FindLicenses: want no license, got %v", got)
+	}
+}
+
+func TestPackageClosure(t *testing.T) {
+	root := mktemp(t)
+	defer fileutils.RemoveAll(root)
+
+	writeFiles(t, root, map[string]string{
+		"LICENSE":            "MIT",
+		"bar.go":             "package bar\n",
+		"a/b/b.go":           "package b\n\nimport (\n\t_ \"fmt\"\n\t_ \"example.com/bar/internal/util\"\n)\n",
+		"a/b/b_test.go":      "package b\n\nimport _ \"example.com/bar/testutil\"\n",
+		"a/c/c_windows.go":   "package c\n\nimport _ \"example.com/bar/internal/win\"\n",
+		"a/c/c.go":           "package c\n",
+		"a/d/d.go":           "package d\n",
+		"internal/util/u.go": "package util\n\nimport _ \"example.com/bar\"\n",
+		"internal/win/w.go":  "package win\n",
+		"testutil/t.go":      "package testutil\n",
+	})
+
+	got, err := PackageClosure(root, "example.com/bar", []string{"a/b", "a/c"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{".", "a/b", "a/c", "internal/util", "internal/win"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("PackageClosure: want %v, got %v", want, got)
+	}
+	if err := TrimPackages(root, got); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"LICENSE", "bar.go", "a/b/b.go", "a/c/c.go", "a/c/c_windows.go", "internal/util/u.go", "internal/win/w.go"} {
+		assertExists(t, filepath.Join(root, filepath.FromSlash(name)))
+	}
+	for _, name := range []string{"a/b/b_test.go", "a/d", "testutil"} {
+		assertNotExists(t, filepath.Join(root, filepath.FromSlash(name)))
+	}
+
+	if _, err := PackageClosure(root, "example.com/bar", []string{"missing"}); err == nil {
+		t.Fatal("PackageClosure of a missing package: want an error")
+	}
+}
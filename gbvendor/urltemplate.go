@@ -0,0 +1,124 @@
+package vendor
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// URLTemplate builds the repository URL of the import paths at or below
+// Prefix from Template, in which {host} is replaced by the first element
+// of the import path, and {org} and {repo} by the first and second
+// elements following Prefix, such as git.corp/{org}/{repo}.git.
+type URLTemplate struct {
+	Prefix   string
+	Template string
+}
+
+// URLTemplates is a set of URLTemplate, the most specific Prefix wins.
+type URLTemplates []URLTemplate
+
+// RepoURLTemplates are consulted by DeduceRemoteRepo before any other
+// deduction.
+var RepoURLTemplates URLTemplates
+
+// Expand returns the repository URL of importpath built from the most
+// specific matching template, and the path of importpath inside the
+// repository. It reports false if no template matches, or if importpath
+// has fewer elements than the template placeholders need.
+func (ts URLTemplates) Expand(importpath string) (repourl, extra string, ok bool) {
+	var best URLTemplate
+	for _, t := range ts {
+		if importpath != t.Prefix && !strings.HasPrefix(importpath, t.Prefix+"/") {
+			continue
+		}
+		if !ok || len(t.Prefix) > len(best.Prefix) {
+			best, ok = t, true
+		}
+	}
+	if !ok {
+		return "", "", false
+	}
+
+	var elems []string
+	if rest := strings.TrimPrefix(importpath[len(best.Prefix):], "/"); rest != "" {
+		elems = strings.Split(rest, "/")
+	}
+	need := 0
+	switch {
+	case strings.Contains(best.Template, "{repo}"):
+		need = 2
+	case strings.Contains(best.Template, "{org}"):
+		need = 1
+	}
+	if len(elems) < need {
+		return "", "", false
+	}
+	if len(elems) > need {
+		extra = "/" + strings.Join(elems[need:], "/")
+	}
+	elems = append(elems, "", "")
+	repourl = strings.NewReplacer(
+		"{host}", strings.SplitN(importpath, "/", 2)[0],
+		"{org}", elems[0],
+		"{repo}", elems[1],
+	).Replace(best.Template)
+	return repourl, extra, true
+}
+
+// Set adds a prefix=template template, so URLTemplates can be used as
+// a repeatable flag.
+func (ts *URLTemplates) Set(v string) error {
+	i := strings.Index(v, "=")
+	if i < 1 || i == len(v)-1 {
+		return fmt.Errorf("expected prefix=template, got %q", v)
+	}
+	*ts = append(*ts, URLTemplate{Prefix: strings.TrimSuffix(v[:i], "/"), Template: v[i+1:]})
+	return nil
+}
+
+func (ts *URLTemplates) String() string {
+	var s []string
+	for _, t := range *ts {
+		s = append(s, t.Prefix+"="+t.Template)
+	}
+	return strings.Join(s, ",")
+}
+
+// ReadURLTemplates reads templates from path. Each non blank line has
+// the form
+//
+//	git.corp -> ssh://git@git.corp/{org}/{repo}.git
+//
+// and lines starting with # are ignored.
+func ReadURLTemplates(path string) (URLTemplates, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return readURLTemplates(f)
+}
+
+func readURLTemplates(r io.Reader) (URLTemplates, error) {
+	var ts URLTemplates
+	s := bufio.NewScanner(r)
+	for n := 1; s.Scan(); n++ {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		parts := strings.SplitN(line, "->", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("line %d: expected \"prefix -> template\", got %q", n, line)
+		}
+		prefix, template := strings.TrimSuffix(strings.TrimSpace(parts[0]), "/"), strings.TrimSpace(parts[1])
+		if prefix == "" || template == "" {
+			return nil, fmt.Errorf("line %d: prefix and template must not be empty", n)
+		}
+		ts = append(ts, URLTemplate{Prefix: prefix, Template: template})
+	}
+	return ts, s.Err()
+}
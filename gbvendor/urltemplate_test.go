@@ -0,0 +1,59 @@
+package vendor
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestReadURLTemplates(t *testing.T) {
+	const file = `
+# internal hosts
+git.corp -> ssh://git@git.corp/{org}/{repo}.git
+git.corp/legacy/ -> https://legacy.corp/scm/{org}.git
+`
+	ts, err := readURLTemplates(strings.NewReader(file))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ts) != 2 || ts[1].Prefix != "git.corp/legacy" {
+		t.Fatalf("readURLTemplates: want 2 templates, got %v", ts)
+	}
+	if _, err := readURLTemplates(strings.NewReader("git.corp git.corp/{org}\n")); err == nil {
+		t.Fatal("readURLTemplates: expected error for a line without ->")
+	}
+}
+
+func TestURLTemplatesExpand(t *testing.T) {
+	var ts URLTemplates
+	for _, v := range []string{
+		"git.corp=ssh://git@git.corp/{org}/{repo}.git",
+		"git.corp/legacy=https://legacy.corp/scm/{org}.git",
+		"mirror.corp/=https://{host}/mirrors.git",
+	} {
+		if err := ts.Set(v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tests := []struct {
+		importpath string
+		url, extra string
+		ok         bool
+	}{
+		{"git.corp/team/proj", "ssh://git@git.corp/team/proj.git", "", true},
+		{"git.corp/team/proj/sub/pkg", "ssh://git@git.corp/team/proj.git", "/sub/pkg", true},
+		{"git.corp/team", "", "", false},
+		{"git.corp/legacy/tool/cmd", "https://legacy.corp/scm/tool.git", "/cmd", true},
+		{"mirror.corp/a/b", "https://mirror.corp/mirrors.git", "/a/b", true},
+		{"git.corporate/team/proj", "", "", false},
+		{"github.com/foo/bar", "", "", false},
+	}
+	for _, tt := range tests {
+		url, extra, ok := ts.Expand(tt.importpath)
+		if url != tt.url || extra != tt.extra || ok != tt.ok {
+			t.Errorf("Expand(%q): want %q, %q, %v, got %q, %q, %v", tt.importpath, tt.url, tt.extra, tt.ok, url, extra, ok)
+		}
+	}
+	if err := ts.Set("git.corp"); err == nil {
+		t.Error("Set: expected error without =")
+	}
+}
@@ -0,0 +1,105 @@
+package vendor
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+	"sync"
+)
+
+// Actions taken by HandleMissingVCS when the VCS binary a dependency
+// needs is not installed.
+const (
+	FailOnMissingVCS   = "error"  // fail, naming the missing binary
+	SkipOnMissingVCS   = "skip"   // leave the dependency unresolved
+	PromptOnMissingVCS = "prompt" // ask to install the binary, then retry or skip
+)
+
+// lookPath finds the VCS binaries, tests replace it to simulate a missing one.
+var lookPath = exec.LookPath
+
+// MissingVCSError is returned by DeduceRemoteRepo when the binary of the
+// VCS of the repository, or of every VCS it may be, is not installed.
+type MissingVCSError struct {
+	VCS        string
+	Importpath string // the dependency needing it, if known
+}
+
+func (e *MissingVCSError) Error() string {
+	if e.Importpath == "" {
+		return fmt.Sprintf("%s is not installed, install it and make sure it is in PATH", e.VCS)
+	}
+	return fmt.Sprintf("%s is not installed, install it and make sure it is in PATH to fetch %s", e.VCS, e.Importpath)
+}
+
+// checkVCS returns a MissingVCSError if the binary of vcs is not in PATH.
+func checkVCS(vcs string) error {
+	if _, err := lookPath(vcs); err != nil {
+		return &MissingVCSError{VCS: vcs}
+	}
+	return nil
+}
+
+// missingVCS returns the first MissingVCSError of errs, or err if there
+// is none, so that trying several VCSs still reports a missing binary.
+func missingVCS(err error, errs ...error) error {
+	for _, e := range errs {
+		if _, ok := e.(*MissingVCSError); ok {
+			return e
+		}
+	}
+	return err
+}
+
+// ErrSkippedVCS is returned by HandleMissingVCS for a dependency left
+// unresolved because its VCS binary is not installed.
+var ErrSkippedVCS = errors.New("skipped, VCS not installed")
+
+var promptMu sync.Mutex
+
+// HandleMissingVCS calls deduce, which deduces the repository of
+// importpath, and if it fails with a MissingVCSError acts per action:
+// FailOnMissingVCS returns the error, SkipOnMissingVCS returns
+// ErrSkippedVCS and PromptOnMissingVCS asks on out, reading the answer
+// from in, whether to retry once the binary is installed, skip the
+// dependency or abort.
+func HandleMissingVCS(action, importpath string, in *bufio.Reader, out io.Writer, deduce func() error) error {
+	switch action {
+	case FailOnMissingVCS, SkipOnMissingVCS, PromptOnMissingVCS:
+	default:
+		return fmt.Errorf("unknown missing VCS action %q, expected error, skip or prompt", action)
+	}
+	for {
+		err := deduce()
+		mv, ok := err.(*MissingVCSError)
+		if !ok {
+			return err
+		}
+		mv.Importpath = importpath
+		switch action {
+		case SkipOnMissingVCS:
+			return ErrSkippedVCS
+		case FailOnMissingVCS:
+			return mv
+		}
+
+		promptMu.Lock()
+		fmt.Fprintf(out, "%s is needed to fetch %s but is not installed.\nInstall it and press Enter to retry, s to skip it, or q to abort: ", mv.VCS, importpath)
+		answer, rerr := in.ReadString('\n')
+		promptMu.Unlock()
+		switch strings.TrimSpace(strings.ToLower(answer)) {
+		case "":
+			if rerr != nil {
+				// no more input, nobody to install it.
+				return mv
+			}
+		case "s", "skip":
+			return ErrSkippedVCS
+		default:
+			return mv
+		}
+	}
+}
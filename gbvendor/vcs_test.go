@@ -0,0 +1,104 @@
+package vendor
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+// withoutVCS makes the binaries of vcs missing until the returned function
+// is called.
+func withoutVCS(vcs ...string) (restore func()) {
+	missing := make(map[string]bool)
+	for _, v := range vcs {
+		missing[v] = true
+	}
+	lookPath = func(file string) (string, error) {
+		if missing[file] {
+			return "", &exec.Error{Name: file, Err: exec.ErrNotFound}
+		}
+		return exec.LookPath(file)
+	}
+	return func() { lookPath = exec.LookPath }
+}
+
+func TestDeduceRemoteRepoMissingVCS(t *testing.T) {
+	defer withoutVCS("git", "hg", "bzr")()
+
+	for _, path := range []string{
+		"github.com/pkg/sftp",
+		"example.com/repo.hg/pkg",
+		"launchpad.net/gocheck",
+		"bitbucket.org/user/repo",
+	} {
+		_, _, err := DeduceRemoteRepo(path, false)
+		if _, ok := err.(*MissingVCSError); !ok {
+			t.Errorf("DeduceRemoteRepo(%q): want a MissingVCSError, got %v", path, err)
+		}
+	}
+}
+
+func TestHandleMissingVCS(t *testing.T) {
+	defer withoutVCS("hg")()
+
+	var deduced int
+	deduce := func() error {
+		deduced++
+		_, _, err := DeduceRemoteRepo("example.com/repo.hg", false)
+		return err
+	}
+	handle := func(action, input string) (string, error) {
+		var out bytes.Buffer
+		deduced = 0
+		err := HandleMissingVCS(action, "example.com/repo.hg", bufio.NewReader(strings.NewReader(input)), &out, deduce)
+		return out.String(), err
+	}
+
+	_, err := handle(FailOnMissingVCS, "")
+	mv, ok := err.(*MissingVCSError)
+	if !ok {
+		t.Fatalf("error: want a MissingVCSError, got %v", err)
+	}
+	if mv.VCS != "hg" || !strings.Contains(err.Error(), "hg is not installed") || !strings.Contains(err.Error(), "example.com/repo.hg") {
+		t.Errorf("error: want the missing tool and the dependency named, got %q", err)
+	}
+
+	if _, err := handle(SkipOnMissingVCS, ""); err != ErrSkippedVCS {
+		t.Errorf("skip: want ErrSkippedVCS, got %v", err)
+	}
+
+	out, err := handle(PromptOnMissingVCS, "s\n")
+	if err != ErrSkippedVCS {
+		t.Errorf("prompt, answering s: want ErrSkippedVCS, got %v", err)
+	}
+	if !strings.Contains(out, "hg is needed to fetch example.com/repo.hg") {
+		t.Errorf("prompt: want the missing tool named, got %q", out)
+	}
+	if _, err := handle(PromptOnMissingVCS, "q\n"); err == nil || err == ErrSkippedVCS {
+		t.Errorf("prompt, answering q: want the MissingVCSError, got %v", err)
+	}
+	if _, err := handle(PromptOnMissingVCS, ""); err == nil || err == ErrSkippedVCS {
+		t.Errorf("prompt without input: want the MissingVCSError, got %v", err)
+	}
+
+	// installing the binary and pressing Enter retries the deduction.
+	installed := errors.New("probed")
+	deduce = func() error {
+		deduced++
+		if deduced == 2 {
+			return installed
+		}
+		_, _, err := DeduceRemoteRepo("example.com/repo.hg", false)
+		return err
+	}
+	if _, err := handle(PromptOnMissingVCS, "\n"); err != installed || deduced != 2 {
+		t.Errorf("prompt, answering Enter: want a retry, got %v after %d tries", err, deduced)
+	}
+
+	if _, err := handle("ignore", ""); err == nil {
+		t.Error("unknown action: want an error")
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/themoonbear/gvt/gbvendor"
+)
+
+var graphOutput string
+
+func addGraphFlags(fs *flag.FlagSet) {
+	fs.StringVar(&graphOutput, "o", "", "write the graph to file instead of stdout")
+}
+
+var cmdGraph = &Command{
+	Name:      "graph",
+	UsageLine: "graph [-o file]",
+	Short:     "print the import graph of the vendored packages",
+	Long: `graph prints the import graph of the vendored packages in Graphviz DOT
+format, ready to be rendered, for example with dot -Tsvg.
+
+Each vendored package is a node, filled with a color per dependency and
+grouped with the other packages of its dependency. Each import of another
+vendored package is an edge, red when it is part of an import cycle. The
+standard library is left out.
+
+Flags:
+	-o file
+		write the graph to file instead of stdout.
+
+`,
+	Run: func(args []string) error {
+		if len(args) != 0 {
+			return fmt.Errorf("graph takes no arguments")
+		}
+		return dumpGraph(graphOutput, global)
+	},
+	AddFlags: addGraphFlags,
+}
+
+// dumpGraph writes the DOT import graph of the vendored packages to file,
+// or stdout if file is "".
+func dumpGraph(file string, global bool) error {
+	_, dsm, err := loadVendored(global)
+	if err != nil {
+		return err
+	}
+	var w io.Writer = os.Stdout
+	if file != "" {
+		f, err := os.Create(file)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+	return vendor.WriteDOT(w, dsm)
+}
@@ -66,6 +66,12 @@ The commands are:
 {{range .}}
         {{.Name | printf "%-11s"}} {{.Short}}{{end}}
 
+Every command accepts -vendor-dir dir, also read from $GVT_VENDOR_DIR, to
+vendor into dir instead of ./vendor, such as third_party/vendor in a
+monorepo. A relative dir is relative to the current directory, and the
+manifest is kept next to it, in third_party/manifest. With -g, dependencies
+still go to $GOPATH/src.
+
 Use "gvt help [command]" for more information about a command.
 `
 
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+
+	"github.com/themoonbear/gvt/gbvendor"
+)
+
+var (
+	modulesTxtFile string // vendor/modules.txt to import
+	importVerify   bool   // compare the vendored files with their revisions
+)
+
+func addImportFlags(fs *flag.FlagSet) {
+	fs.StringVar(&modulesTxtFile, "from-modules-txt", "", "modules.txt of go mod vendor to import the modules of")
+	fs.BoolVar(&importVerify, "verify", false, "check the vendored files are those of the revisions recorded")
+	fs.BoolVar(&insecure, "precaire", false, "allow the use of insecure protocols")
+}
+
+var cmdImport = &Command{
+	Name:      "import",
+	UsageLine: "import -from-modules-txt file [-verify] [-precaire]",
+	Short:     "adopt a vendor directory written by go mod vendor",
+	Long: `import records in the manifest the modules vendored by go mod vendor, as
+listed by its vendor/modules.txt, to move a project from module vendoring
+to gvt without fetching its dependencies again.
+
+Each module becomes a dependency trimmed to the packages modules.txt lists,
+see gvt help prune, at the revision its version stands for: that of a
+pseudo-version, or that of the tag of the version, which is checked out
+to resolve it. A replaced module records the module path replacing it.
+The vendored files are left untouched.
+
+The modules that cannot be mapped, like those replaced by a directory or
+whose version cannot be found upstream, are reported and left out of the
+manifest, and import exits non-zero.
+
+Flags:
+	-from-modules-txt file
+		the modules.txt to import, usually vendor/modules.txt.
+	-verify
+		compare the vendored files of each module with those of its
+		revision, and report the modules whose files were modified or
+		are not upstream as unmapped.
+	-precaire
+		allow the use of insecure protocols.
+
+`,
+	Run: func(args []string) error {
+		if len(args) != 0 {
+			return fmt.Errorf("import takes no arguments")
+		}
+		if modulesTxtFile == "" {
+			return fmt.Errorf("import: -from-modules-txt missing")
+		}
+		mods, err := vendor.ReadModulesTxt(modulesTxtFile)
+		if err != nil {
+			return fmt.Errorf("could not load modules.txt: %v", err)
+		}
+		m, err := vendor.ReadManifest(manifestFile())
+		if err != nil {
+			return fmt.Errorf("could not load manifest: %v", err)
+		}
+		deps, unmapped := vendor.ModuleDependencies(mods, vendorDir(false), importVerify, insecure)
+		failed := len(unmapped)
+		for _, dep := range deps {
+			if err := m.AddDependency(dep); err != nil {
+				log.Printf("%s: %v", dep.Importpath, err)
+				failed++
+				continue
+			}
+			log.Printf("imported %s at %s", dep.Importpath, dep.Revision)
+		}
+		if err := writeManifest(m); err != nil {
+			return err
+		}
+		for _, u := range unmapped {
+			log.Printf("%s: %v", u.Module.Path, u.Err)
+		}
+		if failed > 0 {
+			return fmt.Errorf("import: could not map %d of %d modules", failed, len(mods))
+		}
+		return nil
+	},
+	AddFlags: addImportFlags,
+}
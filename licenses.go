@@ -0,0 +1,111 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/themoonbear/gvt/gbvendor"
+)
+
+var (
+	licensesDedup bool // group the dependencies by license text
+	licensesJSON  bool // print the report as JSON
+)
+
+func addLicensesFlags(fs *flag.FlagSet) {
+	fs.BoolVar(&licensesDedup, "report-dedup", false, "group the dependencies sharing byte-identical license files")
+	fs.BoolVar(&licensesJSON, "json", false, "print the report as JSON")
+}
+
+var cmdLicenses = &Command{
+	Name:      "licenses",
+	UsageLine: "licenses [-report-dedup] [-json]",
+	Short:     "list the license files of the dependencies",
+	Long: `licenses lists the license files, such as LICENSE, COPYING or NOTICE, found
+at the root of the vendored tree of each dependency. Nothing is modified.
+
+Flags:
+	-report-dedup
+		group the license files by content instead: each byte-identical
+		text is listed once, by checksum, with the files and dependencies
+		holding it, the texts shared by the most dependencies first, so
+		that a review only reads each text once.
+	-json
+		print the report as JSON.
+
+`,
+	Run: func(args []string) error {
+		if len(args) != 0 {
+			return fmt.Errorf("licenses takes no arguments")
+		}
+		m, err := vendor.ReadManifest(manifestFile())
+		if err != nil {
+			return fmt.Errorf("could not load manifest: %v", err)
+		}
+		if licensesDedup {
+			return printLicenseGroups(m)
+		}
+		type entry struct {
+			Importpath string   `json:"importpath"`
+			Files      []string `json:"files"`
+		}
+		report := []entry{}
+		for _, d := range m.Dependencies {
+			names, err := vendor.FindLicenses(filepath.Join(vendorDir(false), filepath.FromSlash(d.Importpath)))
+			if err != nil && !os.IsNotExist(err) {
+				return err
+			}
+			if names == nil {
+				names = []string{}
+			}
+			report = append(report, entry{d.Importpath, names})
+		}
+		if licensesJSON {
+			return printJSON(report)
+		}
+		for _, e := range report {
+			files := strings.Join(e.Files, ", ")
+			if files == "" {
+				files = "no license file"
+			}
+			fmt.Printf("%s: %s\n", e.Importpath, files)
+		}
+		return nil
+	},
+	AddFlags: addLicensesFlags,
+}
+
+// printLicenseGroups prints the license texts of the dependencies of m
+// grouped by content.
+func printLicenseGroups(m *vendor.Manifest) error {
+	groups, err := vendor.GroupLicenses(vendorDir(false), m)
+	if err != nil {
+		return err
+	}
+	if licensesJSON {
+		if groups == nil {
+			groups = []vendor.LicenseGroup{}
+		}
+		return printJSON(groups)
+	}
+	for _, g := range groups {
+		fmt.Printf("%s: %d dependencies\n", g.Checksum[:12], len(g.Dependencies))
+		for _, f := range g.Files {
+			fmt.Printf("\t%s\n", f)
+		}
+	}
+	return nil
+}
+
+func printJSON(v interface{}) error {
+	buf, err := json.MarshalIndent(v, "", "\t")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Printf("%s\n", buf)
+	return err
+}
@@ -1,33 +1,76 @@
 package main
 
 import (
+	"encoding/json"
 	"flag"
 	"fmt"
-	"html/template"
 	"os"
+	"path/filepath"
+	"sort"
 	"text/tabwriter"
+	"text/template"
 
 	"github.com/themoonbear/gvt/gbvendor"
 )
 
 var (
-	format string
+	format        string
+	listUnused    bool // only list the dependencies the project does not import
+	listJSON      bool // print the manifest, or the -unused report, as JSON
+	listDescribe  bool // print the recorded README excerpts
+	listGo        bool // print the recorded Go versions
+	listFootprint bool // print the recorded file counts and sizes
+	listLicenses  bool // print the recorded licenses
 )
 
 func addListFlags(fs *flag.FlagSet) {
 	fs.StringVar(&format, "f", "{{.Importpath}}\t{{.Repository}}{{.Path}}\t{{.Branch}}\t{{.Revision}}", "format template")
+	fs.BoolVar(&listUnused, "unused", false, "list the dependencies not imported by the project, with their size")
+	fs.BoolVar(&listJSON, "json", false, "print the manifest, or the -unused report, as JSON")
+	fs.BoolVar(&listDescribe, "describe", false, "list the dependencies with their recorded description")
+	fs.BoolVar(&listGo, "go-version", false, "list the dependencies with their recorded Go version, flagging those needing a newer Go")
+	fs.BoolVar(&listFootprint, "footprint", false, "list the dependencies with their recorded number of files and size")
+	fs.BoolVar(&listLicenses, "licenses", false, "list the dependencies with their recorded license")
 }
 
 var cmdList = &Command{
 	Name:      "list",
-	UsageLine: "list [-f format]",
+	UsageLine: "list [-f format | -json | -describe | -go-version | -footprint | -licenses] [-unused [-json]]",
 	Short:     "list dependencies one per line",
-	Long: `list formats the contents of the manifest file.
+	Long: `list formats the contents of the manifest file, one dependency per line
+sorted by import path.
 
 Flags:
 	-f
-		controls the template used for printing each manifest entry. If not supplied
+		controls the text/template used for printing each manifest entry. If not supplied
 		the default value is "{{.Importpath}}\t{{.Repository}}{{.Path}}\t{{.Branch}}\t{{.Revision}}"
+		For example: gvt list -f '{{.Importpath}} {{.Revision}}'
+	-unused
+		only list, with their size in bytes, the dependencies that no package
+		of the project imports, directly or indirectly. Every Go file of the
+		project is considered, whatever its build tags. Nothing is removed,
+		see gvt help prune.
+	-json
+		print the whole manifest as JSON, or with -unused the -unused report.
+	-describe
+		list each dependency with the README excerpt recorded by
+		gvt fetch -record-readme-excerpt. Shorthand for
+		-f "{{.Importpath}}\t{{.Description}}".
+	-go-version
+		list each dependency with the Go version recorded by
+		gvt fetch -record-go-version, followed by "needs newer Go" when
+		it is newer than the go command, see go env GOVERSION.
+	-footprint
+		list each dependency with the number of files and the size in
+		bytes recorded by gvt fetch -record-file-count, "unknown" if
+		none was, followed by the total of those known. The vendor
+		directory is not read.
+	-licenses
+		list each dependency with the SPDX identifier and the file of
+		the license recorded by gvt fetch -record-license, "unknown"
+		when the identifier could not be guessed or nothing was
+		recorded. The columns are separated by tabs, to paste them into
+		a spreadsheet. See also gvt help licenses.
 
 `,
 	Run: func(args []string) error {
@@ -35,6 +78,30 @@ Flags:
 		if err != nil {
 			return fmt.Errorf("could not load manifest: %v", err)
 		}
+		sort.Slice(m.Dependencies, func(i, j int) bool { return m.Dependencies[i].Importpath < m.Dependencies[j].Importpath })
+		if listUnused {
+			return printUnused(m)
+		}
+		if listJSON {
+			buf, err := json.MarshalIndent(m, "", "\t")
+			if err != nil {
+				return err
+			}
+			_, err = fmt.Fprintf(os.Stdout, "%s\n", buf)
+			return err
+		}
+		if listGo {
+			return printGoVersions(m, vendor.ToolchainGoVersion())
+		}
+		if listFootprint {
+			return printFootprint(m)
+		}
+		if listLicenses {
+			return printLicenses(m)
+		}
+		if listDescribe {
+			format = "{{.Importpath}}\t{{.Description}}"
+		}
 		tmpl, err := template.New("list").Parse(format)
 		if err != nil {
 			return fmt.Errorf("unable to parse template %q: %v", format, err)
@@ -50,3 +117,93 @@ Flags:
 	},
 	AddFlags: addListFlags,
 }
+
+// printGoVersions prints the recorded Go version of the dependencies of m,
+// flagging those newer than current.
+func printGoVersions(m *vendor.Manifest, current string) error {
+	w := tabwriter.NewWriter(os.Stdout, 1, 2, 1, ' ', 0)
+	for _, d := range m.Dependencies {
+		note := ""
+		if vendor.GoVersionNewer(d.GoVersion, current) {
+			note = "needs newer Go than " + current
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", d.Importpath, d.GoVersion, note)
+	}
+	return w.Flush()
+}
+
+// printFootprint prints the recorded number of files and size of the
+// dependencies of m, and their total.
+func printFootprint(m *vendor.Manifest) error {
+	w := tabwriter.NewWriter(os.Stdout, 1, 2, 1, ' ', 0)
+	var files int
+	var size int64
+	for _, d := range m.Dependencies {
+		if d.FileCount == 0 {
+			fmt.Fprintf(w, "%s\tunknown\t\n", d.Importpath)
+			continue
+		}
+		fmt.Fprintf(w, "%s\t%d files\t%d bytes\n", d.Importpath, d.FileCount, d.Bytes)
+		files += d.FileCount
+		size += d.Bytes
+	}
+	fmt.Fprintf(w, "total\t%d files\t%d bytes\n", files, size)
+	return w.Flush()
+}
+
+// printLicenses prints the recorded license of the dependencies of m,
+// one tab separated line each.
+func printLicenses(m *vendor.Manifest) error {
+	for _, d := range m.Dependencies {
+		spdx, file := "unknown", "no license recorded"
+		if d.License != nil {
+			file = d.License.File
+			if d.License.SPDX != "" {
+				spdx = d.License.SPDX
+			}
+		}
+		if _, err := fmt.Printf("%s\t%s\t%s\n", d.Importpath, spdx, file); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// unusedDep is a dependency reported by list -unused.
+type unusedDep struct {
+	Importpath string `json:"importpath"`
+	Repository string `json:"repository"`
+	Size       int64  `json:"size"`
+}
+
+// printUnused prints the dependencies of m unreachable from the project.
+func printUnused(m *vendor.Manifest) error {
+	reached, err := reachablePackages(m)
+	if err != nil {
+		return err
+	}
+	unused := []unusedDep{}
+	for _, d := range m.Dependencies {
+		if len(depPackages(d, reached)) > 0 {
+			continue
+		}
+		size, err := treeSize(filepath.Join(vendorDir(false), filepath.FromSlash(d.Importpath)))
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		unused = append(unused, unusedDep{d.Importpath, d.Repository, size})
+	}
+	if listJSON {
+		buf, err := json.MarshalIndent(unused, "", "\t")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(os.Stdout, "%s\n", buf)
+		return err
+	}
+	w := tabwriter.NewWriter(os.Stdout, 1, 2, 1, ' ', 0)
+	for _, u := range unused {
+		fmt.Fprintf(w, "%s\t%s\t%d\n", u.Importpath, u.Repository, u.Size)
+	}
+	return w.Flush()
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/themoonbear/gvt/gbvendor"
+)
+
+// captureStdout returns what f writes to os.Stdout.
+func captureStdout(t *testing.T, f func() error) (string, error) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func(f *os.File) { os.Stdout = f }(os.Stdout)
+	os.Stdout = w
+	done := make(chan []byte)
+	go func() {
+		buf, _ := ioutil.ReadAll(r)
+		done <- buf
+	}()
+	ferr := f()
+	w.Close()
+	return string(<-done), ferr
+}
+
+func TestListGoVersion(t *testing.T) {
+	fetchProject(t, map[string]string{
+		"example.com/a/.fixture-revision": "1111\n",
+		"example.com/a/go.mod":            "module example.com/a\n\ngo 1.18\n",
+		"example.com/a/sub/sub.go":        "package sub\n",
+	})
+
+	// the go.mod is that of the module the fetched directory is part of.
+	if err := runFetch(t, "-record-go-version", "example.com/a/sub"); err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := captureStdout(t, func() error { return runCommand(t, cmdList, "-go-version") })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Fields(out); len(got) != 2 || got[0] != "example.com/a/sub" || got[1] != "1.18" {
+		t.Fatalf("list -go-version: want example.com/a/sub 1.18, got %q", out)
+	}
+}
+
+func TestListUnused(t *testing.T) {
+	project := t.TempDir()
+	writeFixtures(t, project, map[string]string{
+		"main.go":                         "package main\n\nimport _ \"example.com/a\"\n\nfunc main() {}\n",
+		"main_windows.go":                 "//go:build windows\n\npackage main\n\nimport _ \"example.com/b\"\n",
+		"cgo.go":                          "package main\n\n// #include <stdlib.h>\nimport \"C\"\n\nimport _ \"example.com/c/sub\"\n",
+		"vendor/example.com/a/a.go":       "package a\n",
+		"vendor/example.com/b/b.go":       "package b\n",
+		"vendor/example.com/c/sub/sub.go": "package sub\n",
+		"vendor/example.com/d/d.go":       "package d\n",
+		"vendor/example.org/e/e.go":       "package e // unused\n",
+	})
+
+	enterProject(t, project)
+	var deps []vendor.Dependency
+	for _, ip := range []string{"example.com/a", "example.com/b", "example.com/c", "example.com/d", "example.org/e"} {
+		deps = append(deps, vendor.Dependency{Importpath: ip, Repository: "https://" + ip, Revision: "1111"})
+	}
+	if err := vendor.WriteManifest(manifestFile(), &vendor.Manifest{Dependencies: deps}); err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := captureStdout(t, func() error { return runCommand(t, cmdList, "-unused", "-json") })
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []unusedDep
+	if err := json.Unmarshal([]byte(out), &got); err != nil {
+		t.Fatalf("list -unused -json: %v: %q", err, out)
+	}
+	// the build tagged and cgo files of the project import b and c.
+	want := []unusedDep{
+		{Importpath: "example.com/d", Repository: "https://example.com/d", Size: int64(len("package d\n"))},
+		{Importpath: "example.org/e", Repository: "https://example.org/e", Size: int64(len("package e // unused\n"))},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("list -unused -json: want %v, got %v", want, got)
+	}
+}
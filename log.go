@@ -0,0 +1,135 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/themoonbear/gvt/gbvendor"
+)
+
+// logLevel controls how much is logged about a dependency.
+type logLevel int
+
+const (
+	levelError logLevel = iota // only errors
+	levelInfo                  // progress, the default
+	levelDebug                 // every step of the fetch
+)
+
+var levelNames = map[string]logLevel{
+	"error": levelError,
+	"info":  levelInfo,
+	"debug": levelDebug,
+}
+
+// depLogLevels is a repeatable prefix=level flag overriding the log level
+// of the import paths matching prefix.
+type depLogLevels []struct {
+	prefix string
+	level  logLevel
+}
+
+func (d *depLogLevels) String() string {
+	var s []string
+	for _, r := range *d {
+		for name, l := range levelNames {
+			if l == r.level {
+				s = append(s, r.prefix+"="+name)
+			}
+		}
+	}
+	return strings.Join(s, ",")
+}
+
+func (d *depLogLevels) Set(v string) error {
+	i := strings.LastIndex(v, "=")
+	if i < 1 {
+		return fmt.Errorf("expected prefix=level, got %q", v)
+	}
+	l, ok := levelNames[v[i+1:]]
+	if !ok {
+		return fmt.Errorf("unknown log level %q, expected error, info or debug", v[i+1:])
+	}
+	*d = append(*d, struct {
+		prefix string
+		level  logLevel
+	}{v[:i], l})
+	return nil
+}
+
+var (
+	defaultLogLevel = levelInfo
+	perDepLogLevels depLogLevels
+
+	verbose bool // log at levelDebug by default
+	quiet   bool // log at levelError by default
+)
+
+// addVerbosityFlags adds the -v and -q flags, applied by setVerbosity.
+func addVerbosityFlags(fs *flag.FlagSet) {
+	fs.BoolVar(&verbose, "v", false, "verbose: log every step, such as the URLs cloned, the revisions resolved and the bytes copied")
+	fs.BoolVar(&quiet, "q", false, "quiet: only report errors")
+}
+
+// setVerbosity sets the default log level, of gvt and of gbvendor, from
+// the -v and -q flags.
+func setVerbosity() error {
+	if verbose && quiet {
+		return fmt.Errorf("-v and -q cannot be used together")
+	}
+	defaultLogLevel = levelInfo
+	switch {
+	case verbose:
+		defaultLogLevel = levelDebug
+	case quiet:
+		defaultLogLevel = levelError
+	}
+	vendor.Logf = func(format string, args ...interface{}) { infof(format, args...) }
+	vendor.Debugf = func(format string, args ...interface{}) {
+		if defaultLogLevel >= levelDebug {
+			log.Printf(format, args...)
+		}
+	}
+	return nil
+}
+
+// levelFor returns the log level of importpath, that of the longest
+// matching -per-dep-log-level prefix if any.
+func levelFor(importpath string) logLevel {
+	level, match := defaultLogLevel, -1
+	for _, r := range perDepLogLevels {
+		if (importpath == r.prefix || strings.HasPrefix(importpath, strings.TrimSuffix(r.prefix, "/")+"/")) && len(r.prefix) > match {
+			level, match = r.level, len(r.prefix)
+		}
+	}
+	return level
+}
+
+// logf logs progress about the dependency at importpath.
+func logf(importpath, format string, args ...interface{}) {
+	if levelFor(importpath) >= levelInfo {
+		log.Printf(format, args...)
+	}
+}
+
+// infof logs progress not about a single dependency.
+func infof(format string, args ...interface{}) {
+	if defaultLogLevel >= levelInfo {
+		log.Printf(format, args...)
+	}
+}
+
+// errorf logs an error that does not stop the command, whatever the log
+// level: -q only leaves out the progress.
+func errorf(format string, args ...interface{}) {
+	log.Printf(format, args...)
+}
+
+// debugf logs details about the dependency at importpath.
+func debugf(importpath, format string, args ...interface{}) {
+	if levelFor(importpath) >= levelDebug {
+		log.Printf(importpath+": "+format, args...)
+	}
+}
@@ -7,6 +7,8 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+
+	"github.com/themoonbear/gvt/gbvendor"
 )
 
 var fs = flag.NewFlagSet(os.Args[0], flag.ContinueOnError)
@@ -30,6 +32,19 @@ var commands = []*Command{
 	cmdUpdate,
 	cmdList,
 	cmdDelete,
+	cmdPrune,
+	cmdCacheKey,
+	cmdVerify,
+	cmdStatus,
+	cmdImport,
+	cmdFmt,
+	cmdSBOM,
+	cmdLicenses,
+	cmdAlias,
+	cmdRender,
+	cmdGraph,
+	cmdPromote,
+	cmdReject,
 }
 
 func main() {
@@ -50,6 +65,8 @@ func main() {
 	for _, command := range commands {
 		if command.Name == args[0] {
 
+			fs.StringVar(&vendorDirFlag, "vendor-dir", os.Getenv("GVT_VENDOR_DIR"), "vendor directory used instead of ./vendor")
+
 			// add extra flags if necessary
 			if command.AddFlags != nil {
 				command.AddFlags(fs)
@@ -78,6 +95,17 @@ func main() {
 
 const manifestfile = "manifest"
 
+// vendorDirFlag is the vendor directory set by -vendor-dir or
+// $GVT_VENDOR_DIR, relative to the working directory if not absolute.
+var vendorDirFlag string
+
+// flags shared by the commands keeping no fetchOptions of their own, as
+// fetch, update and restore do.
+var (
+	global   bool // install package in go env $GOPATH
+	insecure bool // Allow the use of insecure protocols
+)
+
 func vendorDir(global bool) string {
 	var wd string
 	var err error
@@ -86,12 +114,19 @@ func vendorDir(global bool) string {
 		out := os.Getenv("GOPATH")
 		if out == "" {
 			log.Fatal("GOPATH 未定义")
-		}else{
+		} else {
 			tmp := strings.Split(out, ":")
 			wd = tmp[len(tmp)-1]
 			return filepath.Join(wd, "src")
 		}
 	}
+	if vendorDirFlag != "" {
+		dir, err := filepath.Abs(vendorDirFlag)
+		if err != nil {
+			log.Fatal(err)
+		}
+		return dir
+	}
 	wd, err = os.Getwd()
 	if err != nil {
 		log.Fatal(err)
@@ -99,10 +134,20 @@ func vendorDir(global bool) string {
 	return filepath.Join(wd, "vendor")
 }
 
+// manifestFile returns the path of the manifest, next to the vendor
+// directory.
 func manifestFile() string {
-	wd, err := os.Getwd()
+	return filepath.Join(filepath.Dir(vendorDir(false)), manifestfile)
+}
+
+// writeManifest writes m to the manifest file holding its lock, so it
+// cannot interleave with another gvt writing it, such as a fetch
+// -atomic-manifest-and-tree renaming a staged manifest into place.
+func writeManifest(m *vendor.Manifest) error {
+	unlock, err := vendor.Lock(manifestFile())
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
-	return filepath.Join(wd, manifestfile)
+	defer unlock()
+	return vendor.WriteManifest(manifestFile(), m)
 }
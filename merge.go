@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/constabulary/gb/fileutils"
+	"github.com/themoonbear/gvt/gbvendor"
+)
+
+// mergeManifest adds the dependencies of the manifest at file to the
+// project manifest, resolving conflicts according to -on-conflict, and,
+// with -materialize, vendors the added and replaced dependencies.
+func (o *fetchOptions) mergeManifest(file string) error {
+	other, err := vendor.ReadManifest(file)
+	if err != nil {
+		return fmt.Errorf("could not load manifest %s: %v", file, err)
+	}
+	m, err := vendor.ReadManifest(manifestFile())
+	if err != nil {
+		return fmt.Errorf("could not load manifest: %v", err)
+	}
+	changed, conflicts, err := m.Merge(other, o.onConflict)
+	for _, c := range conflicts {
+		logf(c.Mine.Importpath, "conflict: %s is at %s, %s has %s", c.Mine.Importpath, c.Mine.Revision, file, c.Theirs.Revision)
+	}
+	if err != nil {
+		return err
+	}
+
+	if o.mergeMaterialize {
+		if err := o.materializeMerged(changed); err != nil {
+			return err
+		}
+	}
+	infof("merged %d dependencies from %s", len(changed), file)
+	return writeManifest(m)
+}
+
+// materializeMerged vendors deps, the dependencies a merge added or
+// replaced. They are all downloaded into a staging directory first, so
+// that a failure leaves the vendored trees as they were, then swapped in
+// place of the trees they replace.
+func (o *fetchOptions) materializeMerged(deps []vendor.Dependency) error {
+	vdir := vendorDir(o.global)
+	if err := os.MkdirAll(vdir, 0755); err != nil {
+		return err
+	}
+	stage, err := ioutil.TempDir(vdir, ".gvt-merge-")
+	if err != nil {
+		return err
+	}
+	defer fileutils.RemoveAll(stage)
+
+	var errors uint32
+	for _, dep := range deps {
+		if err := o.downloadDependency(dep, &errors, stage, false); err != nil {
+			return fmt.Errorf("%s: %v", dep.Importpath, err)
+		}
+	}
+	if errors > 0 {
+		return fmt.Errorf("failed to fetch %d recursive dependencies", errors)
+	}
+	// a dependency nested in another is swapped in with it.
+	sort.Slice(deps, func(i, j int) bool { return deps[i].Importpath < deps[j].Importpath })
+	for _, dep := range deps {
+		staged := filepath.Join(stage, filepath.FromSlash(dep.Importpath))
+		if _, err := os.Stat(staged); os.IsNotExist(err) {
+			continue
+		}
+		dst := filepath.Join(vdir, filepath.FromSlash(dep.Importpath))
+		if err := fileutils.RemoveAll(dst); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+			return err
+		}
+		if err := os.Rename(staged, dst); err != nil {
+			return err
+		}
+	}
+	return nil
+}
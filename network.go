@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/themoonbear/gvt/gbvendor"
+)
+
+// networkTest reports whether the hosts involved in fetching path, that
+// of the import path and that of the repository it resolves to, can be
+// reached, without cloning anything.
+func (o *fetchOptions) networkTest(path string) error {
+	schemes := []string{"https"}
+	if o.insecure {
+		schemes = append(schemes, "http")
+	}
+	if u, err := url.Parse(path); err == nil && u.Scheme != "" {
+		schemes = []string{u.Scheme}
+	}
+
+	var unreachable []string
+	check := func(what, rawurl string) {
+		status, err := vendor.Reachable(rawurl, o.networkTestTimeout)
+		if err != nil {
+			fmt.Printf("unreachable %s %s: %v\n", what, rawurl, err)
+			unreachable = append(unreachable, rawurl)
+			return
+		}
+		fmt.Printf("reachable   %s %s (%s)\n", what, rawurl, status)
+	}
+
+	stripped, err := stripscheme(path)
+	if err != nil {
+		return err
+	}
+	host := strings.SplitN(stripped, "/", 2)[0]
+	for _, scheme := range schemes {
+		check("import path host", scheme+"://"+host)
+	}
+
+	log.Printf("resolving %s", path)
+	repo, err := deduceWithin(path, false, o.networkTestTimeout)
+	if err != nil && o.insecure {
+		log.Printf("resolving %s with insecure protocols: %v", path, err)
+		repo, err = deduceWithin(path, true, o.networkTestTimeout)
+	}
+	if err != nil {
+		fmt.Printf("unreachable repository of %s: %v\n", path, err)
+		return fmt.Errorf("network test failed: %v", err)
+	}
+	check("repository", repoURL(repo.URL()))
+
+	if len(unreachable) > 0 {
+		return fmt.Errorf("network test failed, %d unreachable", len(unreachable))
+	}
+	return nil
+}
+
+// deduceWithin is vendor.DeduceRemoteRepo giving up on each of its
+// probes after timeout.
+func deduceWithin(path string, insecure bool, timeout time.Duration) (vendor.RemoteRepo, error) {
+	defer func(d time.Duration) { vendor.ProbeTimeout = d }(vendor.ProbeTimeout)
+	vendor.ProbeTimeout = timeout
+	repo, _, err := vendor.DeduceRemoteRepo(path, insecure)
+	return repo, err
+}
+
+// repoURL returns the URL of a repository given as a URL or in the scp
+// like git syntax, user@host:path.
+func repoURL(repository string) string {
+	if strings.Contains(repository, "://") {
+		return repository
+	}
+	if i := strings.Index(repository, ":"); i > 0 {
+		return "ssh://" + repository[:i] + "/" + repository[i+1:]
+	}
+	return repository
+}
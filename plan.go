@@ -0,0 +1,596 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+
+	"github.com/constabulary/gb/fileutils"
+	"github.com/themoonbear/gvt/gbvendor"
+)
+
+// planStep is a single clone and copy operation of a fetch plan.
+type planStep struct {
+	Importpath    string             `json:"importpath"`
+	Origin        string             `json:"origin,omitempty"`
+	Repository    string             `json:"repository"`
+	Revision      string             `json:"revision"`
+	Branch        string             `json:"branch"`
+	DefaultBranch string             `json:"defaultbranch,omitempty"`
+	Submodules    []vendor.Submodule `json:"submodules,omitempty"`
+	Tag           string             `json:"tag,omitempty"`
+	Path          string             `json:"path,omitempty"`
+	Replacement   string             `json:"replacement,omitempty"`
+	Packages      []string           `json:"packages,omitempty"`
+	Destination   string             `json:"destination"`
+	Recursive     bool               `json:"recursive"`
+	Size          int64              `json:"size"`
+
+	// the copy policy of the flags the plan was made with.
+	copyPolicy
+}
+
+// fetchPlan lists, in order, every operation a fetch would perform.
+type fetchPlan struct {
+	Steps []planStep `json:"steps"`
+}
+
+// planFetch resolves path, and its dependencies if recurse is set, to the
+// exact revisions fetch would vendor, without touching the vendor directory
+// or the manifest.
+func (o *fetchOptions) planFetch(path string, recurse bool) (*fetchPlan, error) {
+	plan, wcs, err := o.resolvePlan(path, recurse)
+	if err != nil {
+		return nil, err
+	}
+	destroyAll(wcs)
+	return plan, nil
+}
+
+// resolvePlan is planFetch returning the working copies of every step
+// along with the plan. On error they are all destroyed.
+func (o *fetchOptions) resolvePlan(path string, recurse bool) (_ *fetchPlan, wcs []vendor.WorkingCopy, err error) {
+	m, err := vendor.ReadManifest(manifestFile())
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not load manifest: %v", err)
+	}
+
+	paths := []struct {
+		Root, Prefix string
+	}{
+		{filepath.Join(runtime.GOROOT(), "src"), ""},
+	}
+	for _, d := range m.Dependencies {
+		paths = append(paths, struct{ Root, Prefix string }{filepath.Join(vendorDir(o.global), filepath.FromSlash(d.Importpath)), filepath.FromSlash(d.Source())})
+	}
+
+	var plan fetchPlan
+	defer func() {
+		if err != nil {
+			destroyAll(wcs)
+		}
+	}()
+
+	// step resolves path at the given branch, tag or revision, or at
+	// those pinned upstream, and appends it to the plan.
+	alts := o.alternateURLs
+	step := func(path, branch, tag, revision string, recursive bool) (string, error) {
+		path, err := stripscheme(path)
+		if err != nil {
+			return "", err
+		}
+		importpath, err := o.vendoredPath(path)
+		if err != nil {
+			return "", err
+		}
+		if m.HasImportpath(importpath) {
+			return "", AlreadyErr
+		}
+		remote := path
+		if len(o.replaceRules) > 0 {
+			if remote, err = vendor.Replacements(o.replaceRules).Resolve(path, o.resolveReplaceChains); err != nil {
+				return "", err
+			}
+		}
+		var (
+			repo  vendor.RemoteRepo
+			extra string
+		)
+		if o.fromLocal != "" && !recursive {
+			repo, err = vendor.LocalRepo(o.fromLocal, o.fromRepository)
+		} else {
+			repo, extra, err = vendor.DeduceRemoteRepo(remote, o.insecure)
+		}
+		alts := alts.lookup(path)
+		if err != nil && len(alts) > 0 {
+			logf(path, "%v, trying the alternate URLs", err)
+			repo, extra, alts, err = o.alternateRepo(path, alts, extra)
+		}
+		if err != nil {
+			return "", err
+		}
+		revision, tag = pinnedRevision(path, extra, branch, tag, revision)
+		if o.tagPrefix != "" && !recursive {
+			if tag, err = o.prefixedTag(path, repo, tag); err != nil {
+				return "", err
+			}
+		}
+		wc, repo, extra, err := o.checkoutAlternates(path, repo, extra, alts, o.trackedBranch(path, branch, tag, revision), tag, revision)
+		if err != nil {
+			return "", err
+		}
+		wcs = append(wcs, wc)
+		if err := o.checkStrictRevision(path, wc); err != nil {
+			return "", err
+		}
+		if !recursive {
+			if err := o.checkoutDate(path, wc); err != nil {
+				return "", err
+			}
+		}
+		rev, err := wc.Revision()
+		if err != nil {
+			return "", err
+		}
+		wcBranch, err := wc.Branch()
+		if err != nil {
+			return "", err
+		}
+		subs, err := submodules(path, wc, o.fetchSubmodules)
+		if err != nil {
+			return "", err
+		}
+		var defBranch string
+		if o.recordDefaultBranch {
+			if defBranch, err = defaultBranch(path, repo); err != nil {
+				return "", err
+			}
+		}
+		src := filepath.Join(wc.Dir(), extra)
+		size, err := treeSize(src)
+		if err != nil {
+			return "", err
+		}
+		var packages []string
+		switch {
+		case recursive:
+		case o.trimToPackages != "":
+			if packages, err = vendor.PackageClosure(src, path, strings.Split(o.trimToPackages, ",")); err != nil {
+				return "", err
+			}
+		case o.packageWhitelist != "":
+			if packages, _, err = vendor.WhitelistPackages(src, path, strings.Split(o.packageWhitelist, ",")); err != nil {
+				return "", err
+			}
+		}
+		if err := o.adoptPins(path, src, wc.Dir()); err != nil {
+			return "", err
+		}
+		if remote, err = stripscheme(remote); err != nil {
+			return "", err
+		}
+		if remote == path {
+			remote = ""
+		}
+		origin := ""
+		if importpath != path {
+			origin = path
+		}
+		plan.Steps = append(plan.Steps, planStep{
+			Importpath:    importpath,
+			Origin:        origin,
+			Repository:    repo.URL(),
+			Revision:      rev,
+			Branch:        wcBranch,
+			DefaultBranch: defBranch,
+			Submodules:    subs,
+			Tag:           tag,
+			Path:          extra,
+			Replacement:   remote,
+			Packages:      packages,
+			Destination:   relPath(filepath.Join(vendorDir(o.global), importpath)),
+			Recursive:     recursive,
+			Size:          size,
+			copyPolicy:    o.copyPolicy(),
+		})
+		paths = append(paths, struct{ Root, Prefix string }{src, filepath.FromSlash(path)})
+		return src, nil
+	}
+
+	root, err := step(path, o.branch, o.tag, o.revision, false)
+	if err != nil {
+		return nil, nil, err
+	}
+	// the alternates without prefix only apply to path.
+	alts.dropUnprefixed()
+
+	planned := make(map[string]bool)
+	for recurse {
+		dsm, err := vendor.LoadPathsContext(buildContext, paths...)
+		if err != nil {
+			return nil, nil, err
+		}
+		is, ok := dsm[root]
+		if !ok {
+			return nil, nil, fmt.Errorf("unable to locate depset for %q", path)
+		}
+		missing, err := findMissing(pkgs(is.Pkgs), dsm)
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(missing) == 0 {
+			break
+		}
+		keys := keys(missing)
+		sort.Strings(keys)
+		pkg := keys[0]
+		if planned[pkg] {
+			return nil, nil, fmt.Errorf("%s is still missing after being planned", pkg)
+		}
+		planned[pkg] = true
+		deps := append([]vendor.Dependency(nil), m.Dependencies...)
+		for _, s := range plan.Steps {
+			deps = append(deps, s.dependency())
+		}
+		setParents(pkg, parentsOf(&vendor.Manifest{Dependencies: deps}, dsm, pkg))
+		if _, err := step(pkg, "", "", "", true); err != nil {
+			if err == AlreadyErr {
+				break
+			}
+			return nil, nil, err
+		}
+	}
+	return &plan, wcs, nil
+}
+
+// twoPhaseFetch fetches path like fetch, but first downloads every
+// dependency and only then installs them all into the vendor directory.
+// A download failure leaves the vendor directory untouched. An install
+// failure removes what was installed, keeps the downloaded working copies
+// and leaves the manifest unchanged.
+func (o *fetchOptions) twoPhaseFetch(path string, recurse bool) error {
+	plan, wcs, err := o.resolvePlan(path, recurse)
+	if err != nil {
+		return fmt.Errorf("download failed, vendor directory untouched: %v", err)
+	}
+	infof("downloaded %d dependencies", len(plan.Steps))
+
+	if err := o.installPlan(plan, wcs); err != nil {
+		for _, wc := range wcs {
+			infof("keeping download in %s", wc.Dir())
+		}
+		return fmt.Errorf("install failed, manifest unchanged: %v", err)
+	}
+	if err := destroyAll(wcs); err != nil {
+		return err
+	}
+	if o.dedupeTransitive {
+		return collapseNested(o.global)
+	}
+	return nil
+}
+
+// installPlan copies the working copies of plan into the vendor directory
+// through a staging directory, then records them in the manifest. With
+// -atomic-manifest-and-tree the manifest is staged and renamed into place
+// in the same transaction as the trees.
+func (o *fetchOptions) installPlan(plan *fetchPlan, wcs []vendor.WorkingCopy) error {
+	m, err := vendor.ReadManifest(manifestFile())
+	if err != nil {
+		return fmt.Errorf("could not load manifest: %v", err)
+	}
+	vdir := vendorDir(o.global)
+	if err := os.MkdirAll(vdir, 0755); err != nil {
+		return err
+	}
+	stage, err := ioutil.TempDir(vdir, ".gvt-stage-")
+	if err != nil {
+		return err
+	}
+	defer fileutils.RemoveAll(stage)
+
+	var tx vendor.Transaction
+	deps := make([]vendor.Dependency, len(plan.Steps))
+	for i, s := range plan.Steps {
+		deps[i] = s.dependency()
+		path := deps[i].Source()
+		src := filepath.Join(wcs[i].Dir(), s.Path)
+		staged := filepath.Join(stage, s.Importpath)
+		logf(path, "installing %s at %s", s.Importpath, s.Revision)
+		if err := o.recordCheckout(path, &deps[i], wcs[i], src); err != nil {
+			return err
+		}
+		if err := copyDependency(staged, src, deps[i]); err != nil {
+			return err
+		}
+		if deps[i].Checksum, err = vendor.TreeChecksum(staged, m.Nested(deps[i])); err != nil {
+			return err
+		}
+		if err := o.checkVendored(path, staged, src, wcs[i].Dir(), &deps[i], m.Nested(deps[i])); err != nil {
+			return err
+		}
+		dst := filepath.Join(vdir, s.Importpath)
+		if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+			return err
+		}
+		tx.Add(staged, dst)
+	}
+	for _, dep := range deps {
+		if err := m.AddDependency(dep); err != nil {
+			return err
+		}
+	}
+	if !o.atomicManifestAndTree {
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+		if err := writeManifest(m); err != nil {
+			// the trees installed are not recorded, remove them.
+			for _, s := range plan.Steps {
+				dst := filepath.Join(vdir, s.Importpath)
+				fileutils.RemoveAll(dst)
+				vendor.CleanPath(filepath.Dir(dst), vdir)
+			}
+			return err
+		}
+		return recordInstalled(deps, vdir)
+	}
+
+	// stage the manifest next to the real one, so both are renamed into
+	// place together.
+	unlock, err := vendor.Lock(manifestFile())
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	f, err := ioutil.TempFile(filepath.Dir(manifestFile()), ".gvt-manifest-")
+	if err != nil {
+		return err
+	}
+	f.Close()
+	defer os.Remove(f.Name())
+	if err := vendor.WriteManifest(f.Name(), m); err != nil {
+		return err
+	}
+	tx.Add(f.Name(), manifestFile())
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	return recordInstalled(deps, vdir)
+}
+
+// recordInstalled records deps, installed in vdir, as fetched by this run
+// and their revisions as asked for.
+func recordInstalled(deps []vendor.Dependency, vdir string) error {
+	manifestMu.Lock()
+	fetched = append(fetched, deps...)
+	manifestMu.Unlock()
+	for _, dep := range deps {
+		if err := recordRequests(dep, filepath.Join(vdir, dep.Importpath), parentsFor(dep.Source())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// dependency returns the manifest entry of the step.
+func (s planStep) dependency() vendor.Dependency {
+	dep := vendor.Dependency{
+		Importpath:    s.Importpath,
+		Origin:        s.Origin,
+		Repository:    s.Repository,
+		Revision:      s.Revision,
+		Branch:        s.Branch,
+		DefaultBranch: s.DefaultBranch,
+		Submodules:    s.Submodules,
+		Tag:           s.Tag,
+		Path:          s.Path,
+		Replacement:   s.Replacement,
+		Packages:      s.Packages,
+	}
+	s.copyPolicy.apply(&dep)
+	return dep
+}
+
+// destroyAll destroys every working copy, returning the first error.
+func destroyAll(wcs []vendor.WorkingCopy) error {
+	var err error
+	for _, wc := range wcs {
+		if e := wc.Destroy(); e != nil && err == nil {
+			err = e
+		}
+	}
+	return err
+}
+
+// applyPlan executes a plan written by fetch -print-plan-json, vendoring
+// exactly the recorded revisions.
+func (o *fetchOptions) applyPlan(file string) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	var plan fetchPlan
+	err = json.NewDecoder(f).Decode(&plan)
+	f.Close()
+	if err != nil {
+		return fmt.Errorf("could not load plan: %v", err)
+	}
+
+	m, err := vendor.ReadManifest(manifestFile())
+	if err != nil {
+		return fmt.Errorf("could not load manifest: %v", err)
+	}
+	for _, s := range plan.Steps {
+		if m.HasImportpath(s.Importpath) {
+			return fmt.Errorf("plan is stale: %s is already vendored", s.Importpath)
+		}
+	}
+
+	for _, s := range plan.Steps {
+		if err := o.applyStep(m, s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// applyStep vendors s, a step of a plan, and records it in m, copying and
+// recording it like fetch does.
+func (o *fetchOptions) applyStep(m *vendor.Manifest, s planStep) error {
+	logf(s.Importpath, "applying %s at %s", s.Importpath, s.Revision)
+	dep := s.dependency()
+	repo, _, err := recordedRepo(dep, o.insecure)
+	if err != nil {
+		return err
+	}
+	wc, err := repo.Checkout("", "", s.Revision)
+	if err != nil {
+		return err
+	}
+	defer wc.Destroy()
+	rev, err := wc.Revision()
+	if err != nil {
+		return err
+	}
+	if rev != s.Revision {
+		return fmt.Errorf("plan is stale: %s resolved to %s, planned %s", s.Importpath, rev, s.Revision)
+	}
+
+	if err := restoreSubmodules(wc, dep); err != nil {
+		return err
+	}
+	path := dep.Source()
+	src := filepath.Join(wc.Dir(), s.Path)
+	dst := filepath.Join(vendorDir(o.global), s.Importpath)
+	if err := o.recordCheckout(path, &dep, wc, src); err != nil {
+		return err
+	}
+	if err := copyDependency(dst, src, dep); err != nil {
+		return err
+	}
+	if dep.Checksum, err = vendor.TreeChecksum(dst, m.Nested(dep)); err != nil {
+		return err
+	}
+	if err := o.checkVendored(path, dst, src, wc.Dir(), &dep, m.Nested(dep)); err != nil {
+		return err
+	}
+	if err := m.AddDependency(dep); err != nil {
+		return err
+	}
+	if err := writeManifest(m); err != nil {
+		return err
+	}
+	return recordInstalled([]vendor.Dependency{dep}, vendorDir(o.global))
+}
+
+// treeSize returns the size of the files fetch would copy from root.
+func treeSize(root string) (int64, error) {
+	var size int64
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if strings.HasPrefix(info.Name(), ".") && path != root {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if info.Mode().IsRegular() {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size, err
+}
+
+// relPath returns path relative to the working directory when possible.
+func relPath(path string) string {
+	wd, err := os.Getwd()
+	if err != nil {
+		return path
+	}
+	if rel, err := filepath.Rel(wd, path); err == nil && !strings.HasPrefix(rel, "..") {
+		return filepath.ToSlash(rel)
+	}
+	return path
+}
+
+// dryRunFetch logs the import paths of paths a fetch would vendor, with
+// the repository deduced for each and the revision it would be fetched at,
+// without touching the vendor directory and the manifest. The revisions of
+// paths are resolved with ls-remote where the VCS allows it. Their
+// dependencies, if recurse is set, are only known once the trees are
+// checked out: they are planned in scratch clones, never copied.
+func (o *fetchOptions) dryRunFetch(paths []string, recurse bool) error {
+	m, err := vendor.ReadManifest(manifestFile())
+	if err != nil {
+		return fmt.Errorf("could not load manifest: %v", err)
+	}
+	for _, path := range paths {
+		stripped, err := stripscheme(path)
+		if err != nil {
+			return err
+		}
+		importpath, err := o.vendoredPath(stripped)
+		if err != nil {
+			return err
+		}
+		if m.HasImportpath(importpath) {
+			logf(stripped, "%s is already vendored", importpath)
+			continue
+		}
+		remote := path
+		if len(o.replaceRules) > 0 {
+			if remote, err = vendor.Replacements(o.replaceRules).Resolve(stripped, o.resolveReplaceChains); err != nil {
+				return err
+			}
+		}
+		repo, extra, err := vendor.DeduceRemoteRepo(remote, o.insecure)
+		if alts := o.alternateURLs.lookup(stripped); err != nil && len(alts) > 0 {
+			repo, extra, _, err = o.alternateRepo(stripped, alts, extra)
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %v", stripped, err)
+		}
+
+		rev, tag := pinnedRevision(stripped, extra, o.branch, o.tag, o.revision)
+		if o.tagPrefix != "" {
+			if tag, err = o.prefixedTag(stripped, repo, o.tag); err != nil {
+				return err
+			}
+		}
+		r, ok := repo.(vendor.RevisionResolver)
+		switch {
+		case rev != "":
+		case !ok:
+			rev = "a revision only known once cloned"
+		default:
+			if rev, err = r.ResolveRevision(o.trackedBranch(stripped, o.branch, tag, rev), tag); err != nil {
+				return fmt.Errorf("%s: %v", stripped, err)
+			}
+		}
+		logf(stripped, "would fetch %s from %s%s at %s", stripped, repo.URL(), extra, rev)
+
+		if !recurse {
+			continue
+		}
+		plan, err := o.planFetch(path, true)
+		if err != nil {
+			return err
+		}
+		for _, s := range plan.Steps {
+			if s.Recursive {
+				source := s.dependency().Source()
+				logf(source, "would fetch recursive %s from %s%s at %s", source, s.Repository, s.Path, s.Revision)
+			}
+		}
+	}
+	return nil
+}
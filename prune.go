@@ -0,0 +1,217 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"go/parser"
+	"go/token"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/constabulary/gb/fileutils"
+
+	"github.com/themoonbear/gvt/gbvendor"
+)
+
+var (
+	pruneKeepMin bool // trim kept dependencies to their build inputs
+	dryRun       bool // only log what would be pruned
+)
+
+func addPruneFlags(fs *flag.FlagSet) {
+	fs.BoolVar(&pruneKeepMin, "keep-min", false, "trim dependencies to the files needed to build")
+	fs.BoolVar(&dryRun, "dry-run", false, "log what would be pruned, without touching the vendor directory")
+}
+
+var cmdPrune = &Command{
+	Name:      "prune",
+	UsageLine: "prune [-keep-min] [-dry-run]",
+	Short:     "trim vendored dependencies",
+	Long: `prune trims the vendor directory down to what the project needs.
+
+The packages of the project, that is everything outside the vendor
+directory, are walked to find which vendored packages they import,
+directly or indirectly. The dependencies none of them reach, such as
+those fetched recursively for an importer since removed, are deleted
+from the vendor directory and the manifest, see gvt help delete. A
+dependency imported only by another dependency the project imports is
+kept.
+
+Flags:
+	-keep-min
+		within each imported dependency, remove the packages that are not
+		reachable and every file that is not needed to build the reachable
+		ones, such as docs, examples and tests. License files are kept.
+		The kept packages are recorded in the manifest, so that restore
+		and update reproduce the minimal tree.
+	-dry-run
+		log the dependencies that would be deleted, and with -keep-min
+		trimmed, leaving the vendor directory and the manifest untouched.
+
+`,
+	Run: func(args []string) error {
+		if len(args) != 0 {
+			return fmt.Errorf("prune takes no arguments")
+		}
+
+		m, err := vendor.ReadManifest(manifestFile())
+		if err != nil {
+			return fmt.Errorf("could not load manifest: %v", err)
+		}
+		reached, err := reachablePackages(m)
+		if err != nil {
+			return err
+		}
+		vdir := vendorDir(false)
+		var unreachable []vendor.Dependency
+		for i, d := range m.Dependencies {
+			pkgs := depPackages(d, reached)
+			switch {
+			case len(pkgs) == 0:
+				unreachable = append(unreachable, d)
+			case !pruneKeepMin:
+			case dryRun:
+				log.Printf("would trim %s to %d packages", d.Importpath, len(pkgs))
+			default:
+				log.Printf("trimming %s to %d packages", d.Importpath, len(pkgs))
+				d.Packages = pkgs
+				if err := trimDependency(filepath.Join(vdir, filepath.FromSlash(d.Importpath)), d); err != nil {
+					return fmt.Errorf("could not trim %s: %v", d.Importpath, err)
+				}
+				m.Dependencies[i].Packages = pkgs
+			}
+		}
+		for _, d := range unreachable {
+			if dryRun {
+				log.Printf("would prune %s", d.Importpath)
+				continue
+			}
+			log.Printf("pruning %s", d.Importpath)
+			if err := m.RemoveDependency(d); err != nil {
+				return fmt.Errorf("could not prune %s: %v", d.Importpath, err)
+			}
+			dir := filepath.Join(vdir, filepath.FromSlash(d.Importpath))
+			if err := fileutils.RemoveAll(dir); err != nil {
+				return fmt.Errorf("could not prune %s: %v", d.Importpath, err)
+			}
+			if err := vendor.CleanPath(filepath.Dir(dir), vdir); err != nil {
+				return fmt.Errorf("could not prune %s: %v", d.Importpath, err)
+			}
+		}
+		if dryRun {
+			return nil
+		}
+		return writeManifest(m)
+	},
+	AddFlags: addPruneFlags,
+}
+
+// depPackages returns the packages of d in reached, relative to d.Importpath.
+func depPackages(d vendor.Dependency, reached map[string]bool) []string {
+	var pkgs []string
+	for ip := range reached {
+		switch {
+		case ip == d.Importpath:
+			pkgs = append(pkgs, ".")
+		case strings.HasPrefix(ip, d.Importpath+"/"):
+			pkgs = append(pkgs, ip[len(d.Importpath)+1:])
+		}
+	}
+	sort.Strings(pkgs)
+	return pkgs
+}
+
+// reachablePackages returns the vendored import paths reachable from the
+// source of the project in the current directory.
+func reachablePackages(m *vendor.Manifest) (map[string]bool, error) {
+	wd, err := os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+	imports, err := projectImports(wd, vendorDir(false))
+	if err != nil {
+		return nil, err
+	}
+
+	var paths []struct{ Root, Prefix string }
+	for _, d := range m.Dependencies {
+		paths = append(paths, struct{ Root, Prefix string }{filepath.Join(vendorDir(false), filepath.FromSlash(d.Importpath)), filepath.FromSlash(d.Importpath)})
+	}
+	dsm, err := vendor.LoadPaths(paths...)
+	if err != nil {
+		return nil, err
+	}
+	pkgs := make(map[string]*vendor.Pkg)
+	for _, s := range dsm {
+		for _, p := range s.Pkgs {
+			pkgs[p.ImportPath] = p
+		}
+	}
+
+	reached := make(map[string]bool)
+	var walk func(string) error
+	walk = func(importpath string) error {
+		p, ok := pkgs[importpath]
+		if !ok || reached[importpath] {
+			return nil
+		}
+		reached[importpath] = true
+		is, err := p.AllImports()
+		if err != nil {
+			return err
+		}
+		for _, i := range is {
+			if err := walk(i); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	for _, i := range imports {
+		if err := walk(i); err != nil {
+			return nil, err
+		}
+	}
+	return reached, nil
+}
+
+// projectImports returns the imports of every Go file under root, test
+// files included, skipping the vendor directories, vendorDir and those
+// named vendor.
+func projectImports(root, vendorDir string) ([]string, error) {
+	seen := make(map[string]bool)
+	var imports []string
+	fset := token.NewFileSet()
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		name := info.Name()
+		if info.IsDir() {
+			if path != root && (name == "vendor" || path == vendorDir || name == "testdata" || strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_")) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if filepath.Ext(name) != ".go" {
+			return nil
+		}
+		f, err := parser.ParseFile(fset, path, nil, parser.ImportsOnly)
+		if err != nil {
+			return err
+		}
+		for _, s := range f.Imports {
+			i := strings.Trim(s.Path.Value, "\"")
+			if !seen[i] {
+				seen[i] = true
+				imports = append(imports, i)
+			}
+		}
+		return nil
+	})
+	sort.Strings(imports)
+	return imports, err
+}
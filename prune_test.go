@@ -0,0 +1,115 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/themoonbear/gvt/gbvendor"
+)
+
+func TestPruneUnreachable(t *testing.T) {
+	project := t.TempDir()
+	writeFixtures(t, project, map[string]string{
+		"main.go":                              "package main\n\nimport _ \"example.com/a\"\n\nfunc main() {}\n",
+		"vendor/example.com/a/a.go":            "package a\n\nimport _ \"example.com/b/sub\"\n",
+		"vendor/example.com/b/b.go":            "package b\n",
+		"vendor/example.com/b/sub/sub.go":      "package sub\n",
+		"vendor/example.com/c/c.go":            "package c\n\nimport _ \"example.org/d\"\n",
+		"vendor/example.org/d/d.go":            "package d\n",
+		"vendor/example.org/d/internal/x/x.go": "package x\n",
+	})
+
+	enterProject(t, project)
+
+	var deps []vendor.Dependency
+	for _, ip := range []string{"example.com/a", "example.com/b", "example.com/c", "example.org/d"} {
+		deps = append(deps, vendor.Dependency{Importpath: ip, Repository: "https://" + ip, Revision: "1111"})
+	}
+	if err := vendor.WriteManifest(manifestFile(), &vendor.Manifest{Dependencies: deps}); err != nil {
+		t.Fatal(err)
+	}
+
+	prune := func(args ...string) {
+		if err := runCommand(t, cmdPrune, args...); err != nil {
+			t.Fatal(err)
+		}
+	}
+	vendored := func() []string {
+		m, err := vendor.ReadManifest(manifestFile())
+		if err != nil {
+			t.Fatal(err)
+		}
+		var ips []string
+		for _, d := range m.Dependencies {
+			ips = append(ips, d.Importpath)
+		}
+		return ips
+	}
+
+	prune("-dry-run")
+	if got, want := vendored(), []string{"example.com/a", "example.com/b", "example.com/c", "example.org/d"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("prune -dry-run: want %v vendored, got %v", want, got)
+	}
+	if _, err := os.Stat(filepath.Join("vendor", "example.com", "c")); err != nil {
+		t.Fatalf("prune -dry-run: %v", err)
+	}
+
+	// c is unreachable, and so is d, which only c imports.
+	prune()
+	if got, want := vendored(), []string{"example.com/a", "example.com/b"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("prune: want %v vendored, got %v", want, got)
+	}
+	for _, dir := range []string{"example.com/c", "example.org"} {
+		if _, err := os.Stat(filepath.Join("vendor", filepath.FromSlash(dir))); !os.IsNotExist(err) {
+			t.Errorf("prune: vendor/%s left behind", dir)
+		}
+	}
+	if _, err := os.Stat(filepath.Join("vendor", "example.com", "b", "sub", "sub.go")); err != nil {
+		t.Errorf("prune: %v", err)
+	}
+}
+
+func TestPruneVendorDir(t *testing.T) {
+	project := t.TempDir()
+	writeFixtures(t, project, map[string]string{
+		"main.go":                                  "package main\n\nimport _ \"example.com/a\"\n\nfunc main() {}\n",
+		"third_party/deps/example.com/a/a.go":      "package a\n",
+		"third_party/deps/example.com/c/c.go":      "package c\n\nimport _ \"example.org/d\"\n",
+		"third_party/deps/example.org/d/d.go":      "package d\n",
+		"third_party/deps/example.org/d/x/x.go":    "package x\n",
+		"third_party/deps/example.com/c/c_test.go": "package c\n",
+	})
+
+	enterProject(t, project)
+	vendorDirFlag = filepath.Join("third_party", "deps")
+
+	var deps []vendor.Dependency
+	for _, ip := range []string{"example.com/a", "example.com/c", "example.org/d"} {
+		deps = append(deps, vendor.Dependency{Importpath: ip, Repository: "https://" + ip, Revision: "1111"})
+	}
+	if err := vendor.WriteManifest(manifestFile(), &vendor.Manifest{Dependencies: deps}); err != nil {
+		t.Fatal(err)
+	}
+
+	// the imports of the vendored trees are not those of the project.
+	if err := runCommand(t, cmdPrune); err != nil {
+		t.Fatal(err)
+	}
+	m, err := vendor.ReadManifest(manifestFile())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m.Dependencies) != 1 || m.Dependencies[0].Importpath != "example.com/a" {
+		t.Fatalf("prune -vendor-dir: want only example.com/a vendored, got %v", m.Dependencies)
+	}
+	for _, dir := range []string{"example.com/c", "example.org"} {
+		if _, err := os.Stat(filepath.Join("third_party", "deps", filepath.FromSlash(dir))); !os.IsNotExist(err) {
+			t.Errorf("prune -vendor-dir: third_party/deps/%s left behind", dir)
+		}
+	}
+	if _, err := os.Stat(filepath.Join("third_party", "deps")); err != nil {
+		t.Errorf("prune -vendor-dir: %v", err)
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"path/filepath"
+
+	"github.com/themoonbear/gvt/gbvendor"
+)
+
+func addQuarantineFlags(fs *flag.FlagSet) {
+	fs.BoolVar(&global, "g", false, "use the dependencies quarantined in go env $GOPATH")
+}
+
+var cmdPromote = &Command{
+	Name:      "promote",
+	UsageLine: "promote [-g] importpath...",
+	Short:     "vendor a quarantined dependency",
+	Long: `promote moves dependencies quarantined by gvt fetch -quarantine into the
+vendor directory and records them in the manifest, once they have been
+reviewed in vendor/.quarantine. The imports they leave missing are recorded
+as unresolved, for gvt restore -resolve-missing to fetch.
+
+Flags:
+	-g
+		use the dependencies quarantined in go env $GOPATH.
+
+`,
+	Run: func(args []string) error {
+		if len(args) == 0 {
+			return fmt.Errorf("promote: import path missing")
+		}
+		m, err := vendor.ReadManifest(manifestFile())
+		if err != nil {
+			return fmt.Errorf("could not load manifest: %v", err)
+		}
+		for _, path := range args {
+			if err := vendor.Promote(vendorDir(global), m, path); err != nil {
+				return fmt.Errorf("promote: %v", err)
+			}
+			// written after each move, so the manifest matches the tree
+			// if a later one fails.
+			if err := writeManifest(m); err != nil {
+				return err
+			}
+			log.Printf("promoted %s", path)
+		}
+		return recordUnresolved(args)
+	},
+	AddFlags: addQuarantineFlags,
+}
+
+var cmdReject = &Command{
+	Name:      "reject",
+	UsageLine: "reject [-g] importpath...",
+	Short:     "discard a quarantined dependency",
+	Long: `reject removes dependencies quarantined by gvt fetch -quarantine, leaving
+the vendor directory and the manifest untouched.
+
+Flags:
+	-g
+		use the dependencies quarantined in go env $GOPATH.
+
+`,
+	Run: func(args []string) error {
+		if len(args) == 0 {
+			return fmt.Errorf("reject: import path missing")
+		}
+		for _, path := range args {
+			if err := vendor.Reject(vendorDir(global), path); err != nil {
+				return fmt.Errorf("reject: %v", err)
+			}
+			log.Printf("rejected %s", path)
+		}
+		return nil
+	},
+	AddFlags: addQuarantineFlags,
+}
+
+// recordUnresolved records in the manifest the imports the dependencies at
+// paths, which were fetched without recursion, leave missing.
+func recordUnresolved(paths []string) error {
+	m, dsm, err := loadVendored(global)
+	if err != nil {
+		return err
+	}
+	for _, path := range paths {
+		ds, ok := dsm[filepath.Join(vendorDir(global), filepath.FromSlash(path))]
+		if !ok {
+			continue
+		}
+		for i, d := range m.Dependencies {
+			if d.Importpath == path {
+				m.Dependencies[i].Unresolved = directMissing(ds, dsm)
+			}
+		}
+	}
+	return writeManifest(m)
+}
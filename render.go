@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/themoonbear/gvt/gbvendor"
+)
+
+var cmdRender = &Command{
+	Name:      "render",
+	UsageLine: "render template [out]",
+	Short:     "render a document from the manifest",
+	Long: `render executes the Go text/template in the template file over the manifest
+and writes the result to out, or stdout if out is not given. It keeps
+documents such as DEPENDENCIES.md in sync with what is vendored.
+
+The template is passed the manifest, whose Dependencies are sorted by import
+path and have the fields recorded in the manifest, such as Importpath,
+Repository, Revision, Branch and Path:
+
+	{{range .Dependencies}}- {{.Importpath}} at {{.Revision}}
+	{{end}}
+
+See also gvt fetch -output-template-file, rendering a template after every
+fetch.
+
+`,
+	Run: func(args []string) error {
+		switch len(args) {
+		case 1:
+			return renderTemplate(args[0], "")
+		case 2:
+			return renderTemplate(args[0], args[1])
+		default:
+			return fmt.Errorf("render: expected template [out]")
+		}
+	},
+}
+
+// renderTemplate renders the template file tmpl over the manifest into
+// out, or stdout if out is "".
+func renderTemplate(tmpl, out string) error {
+	text, err := ioutil.ReadFile(tmpl)
+	if err != nil {
+		return err
+	}
+	m, err := vendor.ReadManifest(manifestFile())
+	if err != nil {
+		return fmt.Errorf("could not load manifest: %v", err)
+	}
+	var buf bytes.Buffer
+	if err := vendor.Render(&buf, filepath.Base(tmpl), string(text), m); err != nil {
+		return fmt.Errorf("could not render %s: %v", tmpl, err)
+	}
+	if out == "" {
+		_, err := os.Stdout.Write(buf.Bytes())
+		return err
+	}
+	return ioutil.WriteFile(out, buf.Bytes(), 0644)
+}
+
+// templateOutputs is a repeatable template:out flag.
+type templateOutputs []string
+
+func (t *templateOutputs) String() string { return strings.Join(*t, ",") }
+
+func (t *templateOutputs) Set(v string) error {
+	if i := strings.LastIndex(v, ":"); i < 1 || i == len(v)-1 {
+		return fmt.Errorf("expected template:out, got %q", v)
+	}
+	*t = append(*t, v)
+	return nil
+}
+
+// render renders each template:out of t.
+func (t templateOutputs) render() error {
+	for _, v := range t {
+		i := strings.LastIndex(v, ":")
+		if err := renderTemplate(v[:i], v[i+1:]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
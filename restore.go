@@ -3,30 +3,53 @@ package main
 import (
 	"flag"
 	"fmt"
-	"log"
+	"net/url"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"strings"
 	"sync"
 	"sync/atomic"
 
-	"github.com/constabulary/gb/fileutils"
 	"github.com/themoonbear/gvt/gbvendor"
 )
 
 var (
-	rbInsecure    bool // Allow the use of insecure protocols
-	rbConnections uint // Count of concurrent download connections
+	restoreOpts    *fetchOptions // the fetch flags restore shares, set by addRestoreFlags
+	rbConnections  uint          // Count of concurrent download connections
+	resolveMissing bool          // fetch the imports left unresolved by fetch -lazy-recursion
+	restoreOnly    string        // only restore the dependencies below this prefix
+
+	maxClonesPerHost uint // concurrent downloads allowed per host
+
+	postRestoreVerify bool // check the restored trees against their checksums
+	verifyBuild       bool // also check that the project builds
+
+	verifyOnlyChanged bool // only restore the entries changed since the last restore
+	restoreFull       bool // restore every entry, recording the state for -verify-only-changed
 )
 
 func addRestoreFlags(fs *flag.FlagSet) {
-	fs.BoolVar(&rbInsecure, "precaire", false, "allow the use of insecure protocols")
+	restoreOpts = newFetchOptions()
+	fs.BoolVar(&restoreOpts.insecure, "precaire", false, "allow the use of insecure protocols")
+	fs.StringVar(&restoreOpts.httpProxy, "proxy", "", "HTTP proxy URL of the clones, defaults to $HTTPS_PROXY and $HTTP_PROXY")
+	fs.StringVar(&restoreOpts.caCertFile, "ca-cert", "", "PEM file of the certificates trusted along with the system ones")
 	fs.UintVar(&rbConnections, "connections", 8, "count of parallel download connections")
-	fs.BoolVar(&global, "g", false, "install package in go env $GOPATH")
+	fs.BoolVar(&restoreOpts.global, "g", false, "install package in go env $GOPATH")
+	fs.BoolVar(&vendor.ShallowRevisionFallback, "allow-shallow-revision-fallback", true, "clone shallow and deepen until the revision is found")
+	fs.UintVar(&maxClonesPerHost, "max-concurrent-clones-per-host", 0, "concurrent downloads from a single host, lowered while it rate limits, 0 for -connections")
+	fs.StringVar(&restoreOnly, "only", "", "only restore the dependencies at or below this import path prefix")
+	fs.BoolVar(&postRestoreVerify, "post-restore-verify", false, "check the restored trees against their recorded checksums")
+	fs.BoolVar(&verifyBuild, "verify-build", false, "with -post-restore-verify, also check that the project builds")
+	fs.BoolVar(&verifyOnlyChanged, "verify-only-changed", false, "only restore and verify the dependencies whose manifest entry changed since the last restore")
+	fs.BoolVar(&restoreFull, "full", false, "restore and verify every dependency, overriding -verify-only-changed")
+	fs.BoolVar(&resolveMissing, "resolve-missing", false, "fetch recursively the imports left unresolved by fetch -lazy-recursion")
+	addVerbosityFlags(fs)
 }
 
 var cmdRestore = &Command{
 	Name:      "restore",
-	UsageLine: "restore [-precaire] [-connections N] [-g]",
+	UsageLine: "restore [-precaire] [-connections N] [-g] [-only prefix] [-verify-only-changed [-full]]",
 	Short:     "restore dependencies from manifest",
 	Long: `restore fetches the dependencies listed in the manifest.
 
@@ -38,18 +61,97 @@ source, for example if .gitignore includes lines like
 Note that such a setup requires "gvt restore" to build the source, relies on
 the availability of the dependencies repositories and breaks "go get".
 
+Each dependency is checked out at the revision recorded in the manifest.
+restore fails if its repository no longer has that revision, for example
+after a force push, rather than vendoring another one.
+
 Flags:
 	-precaire
 		allow the use of insecure protocols.
+	-proxy URL
+		send the HTTP and HTTPS requests, those of the VCS tools
+		included, through the proxy at URL. Defaults to the usual
+		HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment.
+	-ca-cert file
+		trust the PEM encoded certificates in file, such as the private
+		CA of an internal git server, along with those of the system.
+		Not supported by the hg and bzr clones.
+	-v
+		verbose: log every step, such as the URLs cloned, the revisions
+		resolved and the number of files and bytes copied.
+	-q
+		quiet: only report errors.
 	-connections
 		count of parallel download connections.
 	-g global
 		install package in go env $GOPATH
+	-max-concurrent-clones-per-host N
+		download at most N dependencies from the same host at a time.
+		Defaults to -connections. When a host rate limits the downloads,
+		with HTTP 429 or a rate limit error, its concurrency is halved and
+		gvt waits before the next download from it, longer each time, then
+		retries. Successful downloads gradually bring the concurrency back.
+	-allow-shallow-revision-fallback
+		start from shallow git clones, deepened until the recorded revision
+		is found. Enabled by default.
+	-only prefix
+		only restore the dependencies at or below prefix, along with the
+		dependencies recorded by gvt fetch -record-parent as imported by
+		them, leaving the others untouched. The skipped dependencies are
+		logged.
+	-resolve-missing
+		after restoring, fetch recursively the imports that
+		gvt fetch -lazy-recursion or gvt promote recorded as unresolved,
+		completing the dependency graph.
+	-post-restore-verify
+		after restoring, check that every restored tree matches the
+		checksum recorded when it was fetched, failing if any does not.
+		Dependencies fetched before checksums were recorded cannot be
+		verified and are logged.
+	-verify-build
+		with -post-restore-verify, also run go build ./... in the project
+		and fail if it does not build.
+	-verify-only-changed
+		skip the dependencies whose manifest entry is the same as at the
+		last restore run with -verify-only-changed or -full, and whose
+		tree is still vendored: only the others are fetched again and,
+		with -post-restore-verify, checked against their checksums. The
+		entries are recorded in vendor/.restore-state once the restore
+		succeeds. The first run restores everything.
+	-full
+		restore and verify every dependency, as without
+		-verify-only-changed, and record the state it uses.
 `,
 	Run: func(args []string) error {
+		o := restoreOpts
+		if err := setVerbosity(); err != nil {
+			return fmt.Errorf("restore: %v", err)
+		}
+		cleanup, err := vendor.SetTransport(o.httpProxy, o.caCertFile)
+		if err != nil {
+			return fmt.Errorf("restore: %v", err)
+		}
+		defer cleanup()
 		switch len(args) {
 		case 0:
-			return restore(manifestFile(), global)
+			restored, err := o.restore(manifestFile())
+			if err != nil {
+				return err
+			}
+			if resolveMissing {
+				if err := o.resolveUnresolved(); err != nil {
+					return err
+				}
+			}
+			if postRestoreVerify {
+				if err := verifyRestored(o.global, restored); err != nil {
+					return err
+				}
+			}
+			if verifyOnlyChanged || restoreFull {
+				return recordRestoreState(o.global, restored)
+			}
+			return nil
 		default:
 			return fmt.Errorf("restore takes no arguments")
 		}
@@ -57,11 +159,52 @@ Flags:
 	AddFlags: addRestoreFlags,
 }
 
-func restore(manFile string, global bool) error {
+// restore restores the dependencies of the manifest at manFile and returns
+// those it fetched.
+func (o *fetchOptions) restore(manFile string) ([]vendor.Dependency, error) {
 	m, err := vendor.ReadManifest(manFile)
 	if err != nil {
-		return fmt.Errorf("could not load manifest: %v", err)
+		return nil, fmt.Errorf("could not load manifest: %v", err)
+	}
+
+	deps := m.Dependencies
+	if restoreOnly != "" {
+		var skipped []vendor.Dependency
+		deps, skipped = m.Select(restoreOnly)
+		for _, d := range skipped {
+			logf(d.Importpath, "skipping %s", d.Importpath)
+		}
+		if len(deps) == 0 {
+			return nil, fmt.Errorf("no dependency matches %s", restoreOnly)
+		}
+	}
+	if verifyOnlyChanged && !restoreFull {
+		state, err := vendor.ReadRestoreState(vendor.RestoreStateFile(vendorDir(o.global)))
+		if err != nil {
+			return nil, fmt.Errorf("could not load restore state: %v", err)
+		}
+		var unchanged []vendor.Dependency
+		deps, unchanged = state.Changed(vendorDir(o.global), deps)
+		if len(unchanged) > 0 {
+			infof("skipping %d dependencies unchanged since the last restore", len(unchanged))
+		}
+	}
+
+	var recorded []vendor.Dependency
+	for _, d := range deps {
+		if d.Revision == "" {
+			logf(d.Importpath, "skipping %s, recorded from an empty repository", d.Importpath)
+			continue
+		}
+		recorded = append(recorded, d)
 	}
+	deps = recorded
+
+	perHost := int(maxClonesPerHost)
+	if perHost == 0 {
+		perHost = int(rbConnections)
+	}
+	throttle := vendor.NewHostThrottle(perHost)
 
 	var errors uint32
 	var wg sync.WaitGroup
@@ -71,72 +214,100 @@ func restore(manFile string, global bool) error {
 		go func() {
 			defer wg.Done()
 			for d := range depC {
-				if err := downloadDependency(d, &errors, vendorDir(global), false); err != nil {
-					log.Printf("%s: %v", d.Importpath, err)
-					atomic.AddUint32(&errors, 1)
+				host := repoHost(d)
+				for attempt := 1; ; attempt++ {
+					throttle.Acquire(host)
+					err := o.downloadDependency(d, &errors, vendorDir(o.global), false)
+					if throttle.Release(host, err) && attempt < rateLimitAttempts {
+						logf(d.Importpath, "%s: rate limited by %s, now cloning at most %d at a time, retrying", d.Importpath, host, throttle.Limit(host))
+						continue
+					}
+					if err != nil {
+						errorf("%s: %v", d.Importpath, err)
+						atomic.AddUint32(&errors, 1)
+					}
+					break
 				}
 			}
 		}()
 	}
 
-	for _, dep := range m.Dependencies {
+	for _, dep := range deps {
 		depC <- dep
 	}
 	close(depC)
 	wg.Wait()
 
 	if errors > 0 {
-		return fmt.Errorf("failed to fetch %d dependencies", errors)
+		return nil, fmt.Errorf("failed to fetch %d dependencies", errors)
 	}
 
-	return nil
+	return deps, nil
+}
+
+// rateLimitAttempts is how many times a rate limited download is tried.
+const rateLimitAttempts = 5
+
+// repoHost returns the host the repository of d is downloaded from.
+func repoHost(d vendor.Dependency) string {
+	if u, err := url.Parse(repoURL(d.Repository)); err == nil && u.Hostname() != "" {
+		return u.Hostname()
+	}
+	return strings.SplitN(d.Importpath, "/", 2)[0]
 }
 
-func downloadDependency(dep vendor.Dependency, errors *uint32, vendorDir string, recursive bool) error {
+func (o *fetchOptions) downloadDependency(dep vendor.Dependency, errors *uint32, vendorDir string, recursive bool) error {
 	if recursive {
-		log.Printf("fetching recursive %s", dep.Importpath)
+		logf(dep.Importpath, "fetching recursive %s", dep.Importpath)
 	} else {
-		log.Printf("fetching %s", dep.Importpath)
+		logf(dep.Importpath, "fetching %s", dep.Importpath)
 	}
 
-	repo, _, err := vendor.DeduceRemoteRepo(dep.Importpath, rbInsecure, dep.Repository)
+	repo, _, err := recordedRepo(dep, o.insecure)
 	if err != nil {
 		return fmt.Errorf("dependency could not be processed: %s", err)
 	}
 	// We can't pass the branch here, and benefit from narrow clones, as the
 	// revision might not be in the branch tree anymore. Thanks rebase.
 	wc, err := repo.Checkout("", "", dep.Revision)
+	if vendor.IsRevisionNotFound(err) {
+		return fmt.Errorf("dependency could not be fetched: revision %s not found in %s, it may have been removed upstream: %s", dep.Revision, repo.URL(), err)
+	}
 	if err != nil {
 		return fmt.Errorf("dependency could not be fetched: %s", err)
 	}
+	defer wc.Destroy()
+	if err := vendor.CheckRevision(wc, dep.Revision); err != nil {
+		return fmt.Errorf("dependency could not be restored: %v", err)
+	}
+	if err := restoreSubmodules(wc, dep); err != nil {
+		return fmt.Errorf("dependency could not be restored: %v", err)
+	}
 	dst := filepath.Join(vendorDir, dep.Importpath)
 	src := filepath.Join(wc.Dir(), dep.Path)
 
 	if _, err := os.Stat(dst); err == nil {
-		if err := fileutils.RemoveAll(dst); err != nil {
-			return fmt.Errorf("dependency could not be deleted: %v", err)
+		if err := clearDependency(dst, dep); err != nil {
+			return err
 		}
 	}
 
-	if err := fileutils.Copypath(dst, src); err != nil {
-		return err
-	}
-
-	if err := wc.Destroy(); err != nil {
+	if err := copyDependency(dst, src, dep); err != nil {
 		return err
 	}
+	debugCopied(dep.Importpath, dst, nil)
 
 	// Check for for manifests in dependencies
 	man := filepath.Join(dst, "vendor", "manifest")
 	venDir := filepath.Join(dst, "vendor")
 	if _, err := os.Stat(man); err == nil {
-		m, err := vendor.ReadManifest(man)
+		m, err := vendor.ReadVendoredManifest(man)
 		if err != nil {
 			return fmt.Errorf("could not load manifest: %v", err)
 		}
 		for _, d := range m.Dependencies {
-			if err := downloadDependency(d, errors, venDir, true); err != nil {
-				log.Printf("%s: %v", d.Importpath, err)
+			if err := o.downloadDependency(d, errors, venDir, true); err != nil {
+				errorf("%s: %v", d.Importpath, err)
 				atomic.AddUint32(errors, 1)
 			}
 		}
@@ -144,3 +315,98 @@ func downloadDependency(dep vendor.Dependency, errors *uint32, vendorDir string,
 
 	return nil
 }
+
+// resolveUnresolved fetches, recursively, the imports recorded as
+// unresolved in the manifest. The entry of a dependency is cleared once
+// all of its imports are fetched, so a failure leaves the rest recorded.
+func (o *fetchOptions) resolveUnresolved() error {
+	m, err := vendor.ReadManifest(manifestFile())
+	if err != nil {
+		return fmt.Errorf("could not load manifest: %v", err)
+	}
+	for _, d := range m.Dependencies {
+		if len(d.Unresolved) == 0 {
+			continue
+		}
+		for _, path := range d.Unresolved {
+			logf(path, "resolving %s, imported by %s", path, d.Importpath)
+			if err := o.fetch(path, true); err != nil && err != AlreadyErr {
+				return fmt.Errorf("could not resolve %s, imported by %s: %v", path, d.Importpath, err)
+			}
+		}
+		if err := clearUnresolved(d.Importpath); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// clearUnresolved clears the unresolved imports of the dependency at
+// importpath in the manifest.
+func clearUnresolved(importpath string) error {
+	m, err := vendor.ReadManifest(manifestFile())
+	if err != nil {
+		return fmt.Errorf("could not load manifest: %v", err)
+	}
+	for i := range m.Dependencies {
+		if m.Dependencies[i].Importpath == importpath {
+			m.Dependencies[i].Unresolved = nil
+		}
+	}
+	return writeManifest(m)
+}
+
+// verifyRestored checks the trees of deps, restored, against their
+// recorded checksums and, with -verify-build, that the project builds.
+func verifyRestored(global bool, deps []vendor.Dependency) error {
+	m, err := vendor.ReadManifest(manifestFile())
+	if err != nil {
+		return fmt.Errorf("could not load manifest: %v", err)
+	}
+	mismatched, unverifiable, err := vendor.VerifyChecksums(vendorDir(global), m, deps)
+	if err != nil {
+		return err
+	}
+	for _, path := range unverifiable {
+		logf(path, "%s: no checksum recorded, cannot verify it", path)
+	}
+	for _, path := range mismatched {
+		errorf("%s: restored tree does not match its checksum", path)
+	}
+	if len(mismatched) > 0 {
+		return fmt.Errorf("post-restore verification failed: %d dependencies do not match their checksum", len(mismatched))
+	}
+	if verifyBuild {
+		cmd := exec.Command("go", "build", "./...")
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		if err := cmd.Run(); err != nil {
+			return fmt.Errorf("post-restore verification failed: go build: %v", err)
+		}
+	}
+	infof("verified %d dependencies", len(deps)-len(unverifiable))
+	return nil
+}
+
+// recordRestoreState records the current manifest entries of restored as
+// restored successfully, for -verify-only-changed.
+func recordRestoreState(global bool, restored []vendor.Dependency) error {
+	m, err := vendor.ReadManifest(manifestFile())
+	if err != nil {
+		return fmt.Errorf("could not load manifest: %v", err)
+	}
+	file := vendor.RestoreStateFile(vendorDir(global))
+	state, err := vendor.ReadRestoreState(file)
+	if err != nil {
+		return fmt.Errorf("could not load restore state: %v", err)
+	}
+	// -resolve-missing may have changed the entries since they were restored.
+	var deps []vendor.Dependency
+	for _, d := range restored {
+		if d, err := m.GetDependencyForImportpath(d.Importpath); err == nil {
+			deps = append(deps, d)
+		}
+	}
+	state.Record(m, deps)
+	return vendor.WriteRestoreState(file, state)
+}
@@ -0,0 +1,297 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/themoonbear/gvt/gbvendor"
+)
+
+// failingRepo is a RemoteRepo whose checkouts fail with err.
+type failingRepo struct {
+	err error
+}
+
+func (r failingRepo) Checkout(branch, tag, revision string) (vendor.WorkingCopy, error) {
+	return nil, r.err
+}
+
+func (r failingRepo) URL() string { return "https://example.com/a" }
+
+// failingFetcher is a Fetcher of failingRepos.
+type failingFetcher struct {
+	vendor.Fetcher
+	err error
+}
+
+func (f failingFetcher) DeduceRemoteRepo(path string, insecure bool, repository ...string) (vendor.RemoteRepo, string, error) {
+	return failingRepo{f.err}, "", nil
+}
+
+func TestRestoreCheckoutErrors(t *testing.T) {
+	defer func(f vendor.Fetcher) { vendor.DefaultFetcher = f }(vendor.DefaultFetcher)
+	dep := vendor.Dependency{Importpath: "example.com/a", Repository: "https://example.com/a", Revision: "1111"}
+
+	for _, tt := range []struct {
+		err      error
+		notFound bool
+	}{
+		{&vendor.RevisionNotFoundError{Revision: "1111", Err: errors.New("fatal: reference is not a tree: 1111")}, true},
+		{errors.New("fatal: could not create work tree dir: Permission denied"), false},
+		{&vendor.NetworkError{Err: errors.New("Could not resolve host: example.com")}, false},
+	} {
+		vendor.DefaultFetcher = failingFetcher{err: tt.err}
+		var errs uint32
+		err := newFetchOptions().downloadDependency(dep, &errs, t.TempDir(), false)
+		if err == nil {
+			t.Fatalf("downloadDependency with %v: want an error", tt.err)
+		}
+		if got := strings.Contains(err.Error(), "revision 1111 not found"); got != tt.notFound {
+			t.Errorf("downloadDependency with %v: want revision not found %v, got %v", tt.err, tt.notFound, err)
+		}
+		if !strings.Contains(err.Error(), tt.err.Error()) {
+			t.Errorf("downloadDependency with %v: want the cause reported, got %v", tt.err, err)
+		}
+	}
+}
+
+func TestRestoreVendorDir(t *testing.T) {
+	fixtures, project := fetchProject(t, map[string]string{
+		"example.com/a/.fixture-revision": "1111\n",
+		"example.com/a/a.go":              "package a\n\nimport _ \"example.com/b\"\n",
+		"example.com/b/.fixture-revision": "2222\n",
+		"example.com/b/b.go":              "package b\n",
+	})
+
+	defer func(dir string) { vendorDirFlag = dir }(vendorDirFlag)
+	vendorDirFlag = filepath.Join("third_party", "deps")
+
+	if err := runFetch(t, "example.com/a"); err != nil {
+		t.Fatal(err)
+	}
+	deps := filepath.Join(project, "third_party", "deps")
+	for _, path := range []string{"third_party/manifest", "third_party/deps/example.com/a/a.go", "third_party/deps/example.com/b/b.go"} {
+		if _, err := os.Stat(filepath.Join(project, filepath.FromSlash(path))); err != nil {
+			t.Errorf("fetch -vendor-dir: %v", err)
+		}
+	}
+	if _, err := os.Stat("vendor"); !os.IsNotExist(err) {
+		t.Errorf("fetch -vendor-dir: want no ./vendor, got %v", err)
+	}
+
+	// the emptied example.com is removed, the vendor directory is not.
+	if err := os.RemoveAll(filepath.Join(deps, "example.com", "b")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.RemoveAll(filepath.Join(deps, "example.com", "a")); err != nil {
+		t.Fatal(err)
+	}
+	if err := vendor.CleanPath(filepath.Join(deps, "example.com"), vendorDir(false)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(deps); err != nil {
+		t.Fatalf("CleanPath: %v", err)
+	}
+
+	vendor.DefaultFetcher = &vendor.FixtureFetcher{Root: fixtures}
+	if err := runCommand(t, cmdRestore); err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{"example.com/a/a.go", "example.com/b/b.go"} {
+		if _, err := os.Stat(filepath.Join(deps, filepath.FromSlash(path))); err != nil {
+			t.Errorf("restore -vendor-dir: %v", err)
+		}
+	}
+}
+
+func TestRestoreResolveMissing(t *testing.T) {
+	fetchProject(t, map[string]string{
+		"example.com/a/.fixture-revision": "1111\n",
+		"example.com/a/a.go":              "package a\n\nimport _ \"example.com/b\"\n",
+		"example.com/b/.fixture-revision": "2222\n",
+		"example.com/b/b.go":              "package b\n\nimport _ \"example.com/c\"\n",
+		"example.com/c/.fixture-revision": "3333\n",
+		"example.com/c/c.go":              "package c\n",
+	})
+
+	if err := runFetch(t, "-lazy-recursion", "example.com/a"); err != nil {
+		t.Fatal(err)
+	}
+	m, err := vendor.ReadManifest(manifestFile())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.HasImportpath("example.com/c") {
+		t.Fatal("fetch -lazy-recursion: want example.com/c deferred, got it vendored")
+	}
+	b, err := m.GetDependencyForImportpath("example.com/b")
+	if err != nil {
+		t.Fatalf("fetch -lazy-recursion: %v", err)
+	}
+	if want := []string{"example.com/c"}; !reflect.DeepEqual(b.Unresolved, want) {
+		t.Fatalf("fetch -lazy-recursion: want %v unresolved, got %v", want, b.Unresolved)
+	}
+
+	// restore runs in a process of its own, nothing the fetch set carries
+	// over but the fixtures standing for the network.
+	resetFetchState()
+	if err := runCommand(t, cmdRestore, "-resolve-missing"); err != nil {
+		t.Fatal(err)
+	}
+	if m, err = vendor.ReadManifest(manifestFile()); err != nil {
+		t.Fatal(err)
+	}
+	for _, d := range m.Dependencies {
+		if len(d.Unresolved) > 0 {
+			t.Errorf("restore -resolve-missing: %s still has %v unresolved", d.Importpath, d.Unresolved)
+		}
+	}
+	c, err := m.GetDependencyForImportpath("example.com/c")
+	if err != nil {
+		t.Fatalf("restore -resolve-missing: want example.com/c in the manifest: %v", err)
+	}
+	if c.Revision != "3333" {
+		t.Errorf("restore -resolve-missing: want example.com/c at 3333, got %s", c.Revision)
+	}
+	if _, err := os.Stat(filepath.Join(vendorDir(false), "example.com", "c", "c.go")); err != nil {
+		t.Errorf("restore -resolve-missing: %v", err)
+	}
+}
+
+func TestRestoreResolveMissingFailure(t *testing.T) {
+	fetchProject(t, map[string]string{
+		"example.com/a/.fixture-revision": "1111\n",
+		"example.com/a/a.go":              "package a\n\nimport _ \"example.com/b\"\n",
+		"example.com/b/.fixture-revision": "2222\n",
+		"example.com/b/b.go":              "package b\n\nimport _ \"example.com/c\"\n",
+	})
+
+	if err := runFetch(t, "-lazy-recursion", "example.com/a"); err != nil {
+		t.Fatal(err)
+	}
+
+	// example.com/c is not in the fixtures.
+	if err := runCommand(t, cmdRestore, "-resolve-missing"); err == nil || !strings.Contains(err.Error(), "could not resolve example.com/c") {
+		t.Fatalf("restore -resolve-missing: want example.com/c failing to resolve, got %v", err)
+	}
+	m, err := vendor.ReadManifest(manifestFile())
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := m.GetDependencyForImportpath("example.com/b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"example.com/c"}; !reflect.DeepEqual(b.Unresolved, want) {
+		t.Fatalf("restore -resolve-missing: want %v left unresolved, got %v", want, b.Unresolved)
+	}
+}
+
+func TestRestoreOnly(t *testing.T) {
+	fetchProject(t, map[string]string{
+		"example.com/a/.fixture-revision": "1111\n",
+		"example.com/a/a.go":              "package a\n\nimport _ \"example.org/b\"\n",
+		"example.org/b/.fixture-revision": "2222\n",
+		"example.org/b/b.go":              "package b\n",
+		"example.org/x/.fixture-revision": "3333\n",
+		"example.org/x/x.go":              "package x\n",
+	})
+
+	defer log.SetOutput(os.Stderr)
+
+	for _, path := range []string{"example.com/a", "example.org/x"} {
+		if err := runFetch(t, "-record-parent", path); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, dir := range []string{"example.com/a", "example.org/b"} {
+		if err := os.RemoveAll(filepath.Join(vendorDir(false), filepath.FromSlash(dir))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	edited := filepath.Join(vendorDir(false), "example.org", "x", "x.go")
+	if err := ioutil.WriteFile(edited, []byte("package x // edited\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	err := runCommand(t, cmdRestore, "-connections", "2", "-only", "example.com")
+	log.SetOutput(os.Stderr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// b is restored as imported by a, x is left untouched.
+	for _, path := range []string{"example.com/a/a.go", "example.org/b/b.go"} {
+		if _, err := os.Stat(filepath.Join(vendorDir(false), filepath.FromSlash(path))); err != nil {
+			t.Errorf("restore -only example.com: %v", err)
+		}
+	}
+	if buf, err := ioutil.ReadFile(edited); err != nil || string(buf) != "package x // edited\n" {
+		t.Errorf("restore -only example.com: want example.org/x untouched, got %q, %v", buf, err)
+	}
+	if !strings.Contains(buf.String(), "skipping example.org/x") {
+		t.Errorf("restore -only example.com: want example.org/x reported skipped, got %q", buf.String())
+	}
+}
+
+func TestRestorePostRestoreVerify(t *testing.T) {
+	fixtures, _ := fetchProject(t, map[string]string{
+		"example.com/a/.fixture-revision": "1111\n",
+		"example.com/a/a.go":              "package a\n",
+	})
+
+	if err := runFetch(t, "example.com/a"); err != nil {
+		t.Fatal(err)
+	}
+
+	vendor.DefaultFetcher = &vendor.FixtureFetcher{Root: fixtures}
+	restore := func() error {
+		if err := os.RemoveAll(vendorDir(false)); err != nil {
+			t.Fatal(err)
+		}
+		return runCommand(t, cmdRestore, "-post-restore-verify")
+	}
+	if err := restore(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the same revision now serves other files.
+	writeFixtures(t, fixtures, map[string]string{"example.com/a/a.go": "package a // tampered\n"})
+	err := restore()
+	if err == nil || !strings.Contains(err.Error(), "post-restore verification failed") {
+		t.Fatalf("restore -post-restore-verify of a tampered tree: want the verification to fail, got %v", err)
+	}
+}
+
+func TestRestoreRecordedRepository(t *testing.T) {
+	fixtures, _ := fetchProject(t, map[string]string{
+		// example.com/down has no fixture: only its mirror can be reached.
+		"mirror.example.com/down/.fixture-revision": "1111\n",
+		"mirror.example.com/down/sub/sub.go":        "package sub\n",
+	})
+
+	if err := runFetch(t, "-retry-alternate-url", "example.com/down=https://mirror.example.com/down", "example.com/down/sub"); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.RemoveAll(vendorDir(false)); err != nil {
+		t.Fatal(err)
+	}
+
+	resetFetchState()
+	vendor.DefaultFetcher = &vendor.FixtureFetcher{Root: fixtures}
+	if err := runCommand(t, cmdRestore); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(vendorDir(false), "example.com", "down", "sub", "sub.go")); err != nil {
+		t.Fatalf("restore: want example.com/down/sub restored from its recorded repository: %v", err)
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/themoonbear/gvt/gbvendor"
+)
+
+var (
+	sbomFormat string
+	sbomOutput string
+)
+
+func addSBOMFlags(fs *flag.FlagSet) {
+	fs.StringVar(&sbomFormat, "format", vendor.SBOMCycloneDX, "SBOM format, cyclonedx or spdx")
+	fs.StringVar(&sbomOutput, "o", "", "write the SBOM to file instead of stdout")
+}
+
+var cmdSBOM = &Command{
+	Name:      "sbom",
+	UsageLine: "sbom [-format cyclonedx|spdx] [-o file]",
+	Short:     "print a software bill of materials",
+	Long: `sbom prints a software bill of materials listing every vendored dependency
+with its import path, repository, revision and license files.
+
+Flags:
+	-format format
+		the document format, cyclonedx for CycloneDX 1.5 JSON, the default,
+		or spdx for SPDX 2.3 JSON.
+	-o file
+		write the document to file instead of stdout.
+
+`,
+	Run: func(args []string) error {
+		if len(args) != 0 {
+			return fmt.Errorf("sbom takes no arguments")
+		}
+		return emitSBOM(sbomOutput, sbomFormat, global)
+	},
+	AddFlags: addSBOMFlags,
+}
+
+// emitSBOM writes an SBOM of the manifest to file, or stdout if file is "".
+func emitSBOM(file, format string, global bool) error {
+	m, err := vendor.ReadManifest(manifestFile())
+	if err != nil {
+		return fmt.Errorf("could not load manifest: %v", err)
+	}
+	var components []vendor.SBOMComponent
+	for _, d := range m.Dependencies {
+		licenses, err := vendor.FindLicenses(filepath.Join(vendorDir(global), filepath.FromSlash(d.Importpath)))
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		components = append(components, vendor.SBOMComponent{Dependency: d, LicenseFiles: licenses})
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+
+	var w io.Writer = os.Stdout
+	if file != "" {
+		f, err := os.Create(file)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+	return vendor.WriteSBOM(w, format, filepath.Base(wd), components, time.Now())
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+
+	"github.com/themoonbear/gvt/gbvendor"
+)
+
+var statusJSON bool // print the status as JSON
+
+func addStatusFlags(fs *flag.FlagSet) {
+	fs.BoolVar(&global, "g", false, "compare with the dependencies installed in go env $GOPATH")
+	fs.BoolVar(&statusJSON, "json", false, "print the status as JSON")
+}
+
+var cmdStatus = &Command{
+	Name:      "status",
+	UsageLine: "status [-g] [-json]",
+	Short:     "compare the manifest with the vendor directory",
+	Long: `status compares the dependencies listed in the manifest with the vendor
+directory and exits non-zero if they drifted apart, reporting, sorted by
+import path:
+
+	missing      dependencies of the manifest without files on disk
+	untracked    directories on disk no dependency of the manifest covers
+	unconfirmed  dependencies whose tree cannot be confirmed to be that of
+	             their recorded revision: without a revision or a checksum
+	             recorded, or not matching the checksum
+
+Nothing is modified and nothing is fetched: see gvt verify to list the
+files changed in a tree, and gvt restore to fetch the missing ones.
+
+Flags:
+	-g
+		compare with the dependencies installed in go env $GOPATH.
+	-json
+		print the status as JSON.
+
+`,
+	Run: func(args []string) error {
+		if len(args) != 0 {
+			return fmt.Errorf("status takes no arguments")
+		}
+		m, err := vendor.ReadManifest(manifestFile())
+		if err != nil {
+			return fmt.Errorf("could not load manifest: %v", err)
+		}
+		status, err := vendor.Status(vendorDir(global), m)
+		if err != nil {
+			return err
+		}
+		if statusJSON {
+			if status == nil {
+				status = []vendor.StatusEntry{}
+			}
+			buf, err := json.MarshalIndent(status, "", "\t")
+			if err != nil {
+				return err
+			}
+			fmt.Printf("%s\n", buf)
+		} else {
+			for _, s := range status {
+				if s.Reason != "" {
+					fmt.Printf("%s: %s, %s\n", s.Importpath, s.Kind, s.Reason)
+				} else {
+					fmt.Printf("%s: %s\n", s.Importpath, s.Kind)
+				}
+			}
+		}
+		if len(status) > 0 {
+			return fmt.Errorf("status: the vendor directory differs from the manifest in %d places", len(status))
+		}
+		return nil
+	},
+	AddFlags: addStatusFlags,
+}
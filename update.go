@@ -3,26 +3,45 @@ package main
 import (
 	"flag"
 	"fmt"
+	"os"
 	"path/filepath"
-
-	"github.com/constabulary/gb/fileutils"
+	"strings"
 
 	"github.com/themoonbear/gvt/gbvendor"
 )
 
 var (
-	updateAll bool // update all dependencies
+	updateAll     bool   // update all dependencies
+	sinceTag      bool   // print the upstream commits pulled in by the update
+	changelogFile string // write the changelog to a file instead of stdout
+	batchCommit   bool   // commit each updated dependency in the project repository
+
+	toDefaultBranch bool // update to the default branch recorded by fetch -record-default-branch
+
+	updateOpts *fetchOptions // the fetch flags update shares, set by addUpdateFlags
 )
 
 func addUpdateFlags(fs *flag.FlagSet) {
+	updateOpts = newFetchOptions()
 	fs.BoolVar(&updateAll, "all", false, "update all dependencies")
-	fs.BoolVar(&insecure, "precaire", false, "allow the use of insecure protocols")
-	fs.BoolVar(&global, "g", false, "install package in go env $GOPATH")
+	fs.BoolVar(&updateOpts.insecure, "precaire", false, "allow the use of insecure protocols")
+	fs.StringVar(&updateOpts.httpProxy, "proxy", "", "HTTP proxy URL of the requests and clones, defaults to $HTTPS_PROXY and $HTTP_PROXY")
+	fs.StringVar(&updateOpts.caCertFile, "ca-cert", "", "PEM file of the certificates trusted along with the system ones")
+	fs.BoolVar(&updateOpts.global, "g", false, "install package in go env $GOPATH")
+	fs.StringVar(&updateOpts.branchTrackingFile, "branch-tracking-file", "", "file mapping import path prefixes to branches")
+	fs.BoolVar(&sinceTag, "since-tag", false, "print the upstream commits between the old and new revision")
+	fs.Var(&updateOpts.outputTemplates, "output-template-file", "template:out renders template over the manifest into out after updating, repeatable")
+	fs.StringVar(&changelogFile, "changelog", "", "write the -since-tag changelog to file")
+	fs.BoolVar(&updateOpts.refuseDowngrade, "refuse-downgrade", false, "refuse to update a dependency to a revision older than the recorded one")
+	fs.BoolVar(&updateOpts.force, "force", false, "let -refuse-downgrade update to an older revision anyway")
+	fs.BoolVar(&batchCommit, "batch-commit", false, "git commit each updated dependency with the manifest in the project repository")
+	fs.BoolVar(&toDefaultBranch, "to-default-branch", false, "update the dependencies with a recorded default branch to its head")
+	addVerbosityFlags(fs)
 }
 
 var cmdUpdate = &Command{
 	Name:      "update",
-	UsageLine: "update [ -all | -g| importpath ]",
+	UsageLine: "update [ -all | -g| importpath | alias ] [-batch-commit] [-to-default-branch]",
 	Short:     "update a local dependency",
 	Long: `update replaces the source with the latest available from the head of the fetched branch.
 
@@ -39,17 +58,81 @@ Flags:
 		update all dependencies in the manifest.
 	-precaire
 		allow the use of insecure protocols.
+	-proxy URL
+		send the HTTP and HTTPS requests, those of the VCS tools
+		included, through the proxy at URL. Defaults to the usual
+		HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment.
+	-ca-cert file
+		trust the PEM encoded certificates in file, such as the private
+		CA of an internal git server, along with those of the system.
+		Not supported by the hg and bzr clones.
+	-v
+		verbose: log every step, such as the URLs cloned, the revisions
+		resolved and the number of files and bytes copied.
+	-q
+		quiet: only report errors.
 	-g global
 		install package in go env $GOPATH
+	-branch-tracking-file file
+		read branch pins from file, see gvt help fetch. A matching rule
+		replaces the recorded branch of dependencies fetched by branch.
+	-since-tag
+		print the upstream commits between the recorded revision and the
+		new one, one per line, to help documenting the update. Only
+		supported for git repositories.
+	-changelog file
+		append the -since-tag changelog to file instead of printing it.
+	-output-template-file template:out
+		after updating, render template into out, see gvt help render.
+	-refuse-downgrade
+		refuse to update a dependency when the new head of its branch is
+		an ancestor of the recorded revision, as after a force push,
+		printing the commit dates of both. Supported for git repositories.
+	-force
+		with -refuse-downgrade, only warn about a downgrade.
+	-batch-commit
+		after updating each dependency, commit its vendored tree and
+		the manifest in the git repository of the project, as "bump
+		importpath to revision", so that the history has one commit
+		per dependency and can be bisected. The working tree must be
+		clean to start with. Dependencies left unchanged are not
+		committed, and a failing commit stops the update.
+	-to-default-branch
+		update the dependencies fetched with -record-default-branch to
+		the head of the default branch of their repository, asked again
+		so that a renamed one is followed, rather than to their recorded
+		branch, a tag or revision they were fetched at included. The new
+		branch is recorded. The other dependencies are updated as usual.
 
 `,
 	Run: func(args []string) error {
+		o := updateOpts
+		if err := setVerbosity(); err != nil {
+			return fmt.Errorf("update: %v", err)
+		}
+		cleanup, err := vendor.SetTransport(o.httpProxy, o.caCertFile)
+		if err != nil {
+			return fmt.Errorf("update: %v", err)
+		}
+		defer cleanup()
+		if err := o.loadBranchRules(); err != nil {
+			return err
+		}
 		if len(args) != 1 && !updateAll {
 			return fmt.Errorf("update: import path or -all flag is missing")
 		} else if len(args) == 1 && updateAll {
 			return fmt.Errorf("update: you cannot specify path and -all flag at once")
 		}
 
+		if batchCommit {
+			if o.global {
+				return fmt.Errorf("update: -batch-commit cannot be used with -g")
+			}
+			if err := vendor.CheckCleanWorkTree(filepath.Dir(manifestFile())); err != nil {
+				return fmt.Errorf("update: -batch-commit: %v", err)
+			}
+		}
+
 		m, err := vendor.ReadManifest(manifestFile())
 		if err != nil {
 			return fmt.Errorf("could not load manifest: %v", err)
@@ -60,74 +143,229 @@ Flags:
 			dependencies = make([]vendor.Dependency, len(m.Dependencies))
 			copy(dependencies, m.Dependencies)
 		} else {
-			p := args[0]
+			p := m.Resolve(args[0])
 			dependency, err := m.GetDependencyForImportpath(p)
 			if err != nil {
-				return fmt.Errorf("could not get dependency: %v", err)
+				for _, d := range m.Dependencies {
+					if strings.HasPrefix(p, d.Importpath+"/") {
+						return fmt.Errorf("update: %s is not vendored on its own, it is part of %s", args[0], d.Importpath)
+					}
+				}
+				return fmt.Errorf("update: %s is not vendored, see gvt help fetch", args[0])
 			}
 			dependencies = append(dependencies, dependency)
 		}
 
 		for _, d := range dependencies {
-			err = m.RemoveDependency(d)
-			if err != nil {
-				return fmt.Errorf("dependency could not be deleted from manifest: %v", err)
+			if err := o.updateDependency(m, d); err != nil {
+				return err
 			}
+		}
 
-			repo, extra, err := vendor.DeduceRemoteRepo(d.Importpath, insecure, d.Repository)
-			if err != nil {
-				return fmt.Errorf("could not determine repository for import %q", d.Importpath)
+		for _, d := range m.Dependencies {
+			if d.Imports != nil {
+				// fetch -record-direct-imports was used, the edges
+				// of the updated dependencies may have changed.
+				if err := recordImports(o.global); err != nil {
+					return err
+				}
+				break
 			}
+		}
 
-			wc, err := repo.Checkout(d.Branch, "", "")
-			if err != nil {
-				return err
-			}
+		return o.outputTemplates.render()
+	},
+	AddFlags: addUpdateFlags,
+}
 
-			rev, err := wc.Revision()
-			if err != nil {
-				return err
-			}
+// updateDependency updates d, removed from m, to the head of its branch and
+// records the new revision in m.
+func (o *fetchOptions) updateDependency(m *vendor.Manifest, d vendor.Dependency) error {
+	if err := m.RemoveDependency(d); err != nil {
+		return fmt.Errorf("dependency could not be deleted from manifest: %v", err)
+	}
 
-			branch, err := wc.Branch()
-			if err != nil {
-				return err
-			}
+	repo, extra, err := recordedRepo(d, o.insecure)
+	if err != nil {
+		return fmt.Errorf("could not determine repository for import %q", d.Importpath)
+	}
 
-			dep := vendor.Dependency{
-				Importpath: d.Importpath,
-				Repository: repo.URL(),
-				Revision:   rev,
-				Branch:     branch,
-				Path:       extra,
-			}
+	b := d.Branch
+	if tb, ok := o.branchRules.Lookup(d.Importpath); ok && b != "HEAD" {
+		b = tb
+	}
+	defBranch := d.DefaultBranch
+	if toDefaultBranch && defBranch != "" {
+		db, err := defaultBranch(d.Importpath, repo)
+		if err != nil {
+			return err
+		}
+		if db != "" {
+			defBranch = db
+		}
+		b = defBranch
+	}
 
-			if err := fileutils.RemoveAll(filepath.Join(vendorDir(global), filepath.FromSlash(d.Importpath))); err != nil {
-				// TODO(dfc) need to apply vendor.cleanpath here to remove intermediate directories.
-				return fmt.Errorf("dependency could not be deleted: %v", err)
-			}
+	wc, err := repo.Checkout(b, "", "")
+	if err != nil {
+		return err
+	}
+	defer wc.Destroy()
 
-			dst := filepath.Join(vendorDir(global), filepath.FromSlash(dep.Importpath))
-			src := filepath.Join(wc.Dir(), dep.Path)
+	rev, err := wc.Revision()
+	if err == vendor.ErrEmptyRepo && d.Revision == "" {
+		logf(d.Importpath, "%s still has no commit, leaving %s as it is", repo.URL(), d.Importpath)
+		return m.AddDependency(d)
+	}
+	if err != nil {
+		return err
+	}
 
-			if err := fileutils.Copypath(dst, src); err != nil {
-				return err
-			}
+	if o.refuseDowngrade {
+		if err := o.checkDowngrade(d.Importpath, wc, d.Revision); err != nil {
+			return err
+		}
+	}
 
-			if err := m.AddDependency(dep); err != nil {
-				return err
-			}
+	subs, err := submodules(d.Importpath, wc, d.Submodules != nil)
+	if err != nil {
+		return err
+	}
 
-			if err := vendor.WriteManifest(manifestFile(), m); err != nil {
-				return err
-			}
+	if sinceTag && d.Revision != "" && rev != d.Revision {
+		if err := changelog(wc, d.Importpath, d.Revision, rev); err != nil {
+			return err
+		}
+	}
 
-			if err := wc.Destroy(); err != nil {
-				return err
-			}
+	branch, err := wc.Branch()
+	if err != nil {
+		return err
+	}
+
+	// what fetch recorded carries over, the fields depending on
+	// the revision are recomputed.
+	dep := d
+	dep.Repository = repo.URL()
+	dep.Revision = rev
+	dep.Branch = branch
+	dep.DefaultBranch = defBranch
+	dep.Tag = ""
+	dep.Path = extra
+	dep.Submodules = subs
+	if d.CommitDate != "" {
+		if dep.CommitDate, err = commitDate(wc); err != nil {
+			return err
+		}
+	}
+
+	if d.License != nil {
+		if dep.License, err = vendor.DetectLicense(wc.Dir(), filepath.Join(wc.Dir(), dep.Path)); err != nil {
+			return err
+		}
+	}
+
+	if d.Description != "" {
+		if dep.Description, err = readmeExcerpt(filepath.Join(wc.Dir(), dep.Path), wc.Dir()); err != nil {
+			return err
+		}
+	}
+
+	if d.GoVersion != "" {
+		if dep.GoVersion, err = goModVersion(filepath.Join(wc.Dir(), dep.Path), wc.Dir()); err != nil {
+			return err
+		}
+	}
+
+	// TODO(dfc) need to apply vendor.cleanpath here to remove intermediate directories.
+	if err := clearDependency(filepath.Join(vendorDir(o.global), filepath.FromSlash(d.Importpath)), d); err != nil {
+		return err
+	}
+
+	dst := filepath.Join(vendorDir(o.global), filepath.FromSlash(dep.Importpath))
+	src := filepath.Join(wc.Dir(), dep.Path)
+	if !d.StripBinaries && d.Stripped != nil {
+		// recorded before the policy was, with the default size.
+		dep.StripBinaries, dep.StripBinariesSize = true, defaultStripBinariesSize
+	}
+	if dep.StripBinaries {
+		// the binary files of the new revision, not of the old one.
+		if dep.Stripped, err = vendor.BinaryFiles(src, dep.StripBinariesSize); err != nil {
+			return err
+		}
+	}
+
+	if err := copyDependency(dst, src, dep); err != nil {
+		return err
+	}
+	debugCopied(dep.Importpath, dst, m.Nested(dep))
+	if dep.Checksum, err = vendor.TreeChecksum(dst, m.Nested(dep)); err != nil {
+		return err
+	}
+	if d.FileCount != 0 {
+		if dep.FileCount, dep.Bytes, err = vendor.TreeStats(dst, m.Nested(dep)); err != nil {
+			return err
+		}
+	}
+	if d.BuildConstraints != nil {
+		if dep.BuildConstraints, err = vendor.BuildConstraints(dst); err != nil {
+			return err
 		}
+	}
+
+	if err := m.AddDependency(dep); err != nil {
+		return err
+	}
+
+	if err := writeManifest(m); err != nil {
+		return err
+	}
+
+	if batchCommit {
+		return commitUpdate(dst, dep)
+	}
+	return nil
+}
 
+// commitUpdate commits dst, the updated tree of dep, with the manifest.
+func commitUpdate(dst string, dep vendor.Dependency) error {
+	msg := fmt.Sprintf("bump %s to %s", dep.Importpath, dep.Revision)
+	committed, err := vendor.CommitPaths(filepath.Dir(manifestFile()), msg, dst, manifestFile())
+	if err != nil {
+		return fmt.Errorf("update: could not commit %s, stopping: %v", dep.Importpath, err)
+	}
+	if committed {
+		infof("committed %s", msg)
+	}
+	return nil
+}
+
+// changelog prints the commits between the old and new revision of the
+// dependency at importpath, checked out in wc.
+func changelog(wc vendor.WorkingCopy, importpath, old, new string) error {
+	cl, ok := wc.(vendor.Changelogger)
+	if !ok {
+		logf(importpath, "%s: changelog is not supported for this repository type", importpath)
 		return nil
-	},
-	AddFlags: addUpdateFlags,
+	}
+	commits, err := cl.Changelog(old)
+	if err == vendor.ErrNotAncestor {
+		logf(importpath, "%s: %s is not an ancestor of %s, upstream history was probably rewritten", importpath, old, new)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("could not compute changelog for %s: %v", importpath, err)
+	}
+
+	w := os.Stdout
+	if changelogFile != "" {
+		f, err := os.OpenFile(changelogFile, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+	_, err = fmt.Fprintf(w, "%s %s..%s\n%s\n", importpath, old, new, commits)
+	return err
 }
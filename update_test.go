@@ -0,0 +1,187 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/themoonbear/gvt/gbvendor"
+)
+
+// gitIn runs git in dir, failing the test on error.
+func gitIn(t *testing.T, dir string, args ...string) string {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %v: %v\n%s", args, err, out)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+func TestUpdateBatchCommit(t *testing.T) {
+	fixtures, project := t.TempDir(), t.TempDir()
+	writeFixtures(t, fixtures, map[string]string{
+		"example.com/a/.fixture-revision": "2222\n",
+		"example.com/a/a.go":              "package a // updated\n",
+		"example.com/b/.fixture-revision": "2222\n",
+		"example.com/b/b.go":              "package b // updated\n",
+		"example.com/c/.fixture-revision": "1111\n",
+		"example.com/c/c.go":              "package c\n",
+	})
+	writeFixtures(t, project, map[string]string{
+		"vendor/example.com/a/a.go": "package a\n",
+		"vendor/example.com/b/b.go": "package b\n",
+		"vendor/example.com/c/c.go": "package c\n",
+	})
+	m := new(vendor.Manifest)
+	for _, path := range []string{"example.com/a", "example.com/b", "example.com/c"} {
+		m.Dependencies = append(m.Dependencies, vendor.Dependency{Importpath: path, Repository: "https://" + path, Revision: "1111", Branch: "master"})
+	}
+	// as recorded by fetch, so that updating example.com/c changes nothing.
+	sum, err := vendor.TreeChecksum(filepath.Join(project, "vendor", "example.com", "c"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.Dependencies[2].Checksum = sum
+	if err := vendor.WriteManifest(filepath.Join(project, "manifest"), m); err != nil {
+		t.Fatal(err)
+	}
+	gitIn(t, project, "init", "-q")
+	gitIn(t, project, "config", "user.name", "gvt")
+	gitIn(t, project, "config", "user.email", "gvt@example.com")
+	gitIn(t, project, "add", "-A")
+	gitIn(t, project, "commit", "-q", "-m", "initial")
+
+	enterProject(t, project)
+	vendor.DefaultFetcher = &vendor.FixtureFetcher{Root: fixtures}
+
+	update := func() error {
+		return runCommand(t, cmdUpdate, "-all", "-batch-commit")
+	}
+
+	writeFixtures(t, project, map[string]string{"dirty": ""})
+	if err := update(); err == nil {
+		t.Fatal("update -batch-commit of a dirty working tree: want an error")
+	}
+	os.Remove("dirty")
+
+	if err := update(); err != nil {
+		t.Fatal(err)
+	}
+	// example.com/c is unchanged, and not committed.
+	want := "bump example.com/b to 2222\nbump example.com/a to 2222\ninitial"
+	if got := gitIn(t, project, "log", "--format=%s"); got != want {
+		t.Fatalf("update -batch-commit: want commits\n%s\ngot\n%s", want, got)
+	}
+	if got := gitIn(t, project, "show", "--name-only", "--format=", "HEAD"); got != "manifest\nvendor/example.com/b/b.go" {
+		t.Fatalf("update -batch-commit: want the last commit to hold example.com/b and the manifest, got %s", got)
+	}
+	if got := gitIn(t, project, "status", "--porcelain"); got != "" {
+		t.Fatalf("update -batch-commit: want a clean working tree, got %s", got)
+	}
+}
+
+func TestUpdateRecorded(t *testing.T) {
+	fixtures, project := t.TempDir(), t.TempDir()
+	writeFixtures(t, fixtures, map[string]string{
+		"example.com/a/.fixture-revision": "2222\n",
+		"example.com/a/a.go":              "package a\n",
+		"example.com/a/a_linux.go":        "package a\n",
+		"example.com/a/README.md":         "A, second edition.\n",
+		"example.com/a/new.bin":           "\x00" + strings.Repeat("x", 2048),
+	})
+	writeFixtures(t, project, map[string]string{
+		"vendor/example.com/a/a.go":         "package a\n",
+		"vendor/example.com/a/a_windows.go": "package a\n",
+	})
+	old := vendor.Dependency{
+		Importpath:       "example.com/a",
+		Repository:       "https://example.com/a",
+		Revision:         "1111",
+		Branch:           "master",
+		BuildConstraints: []string{"windows"},
+		Description:      "A.",
+		Stripped:         []string{"old.bin"},
+	}
+	if err := vendor.WriteManifest(filepath.Join(project, "manifest"), &vendor.Manifest{Dependencies: []vendor.Dependency{old}}); err != nil {
+		t.Fatal(err)
+	}
+
+	enterProject(t, project)
+	vendor.DefaultFetcher = &vendor.FixtureFetcher{Root: fixtures}
+
+	if err := runCommand(t, cmdUpdate, "example.com/a"); err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := vendor.ReadManifest(manifestFile())
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := m.Dependencies[0]
+	if d.Revision != "2222" {
+		t.Errorf("update: want revision 2222, got %s", d.Revision)
+	}
+	if want := []string{"linux"}; !reflect.DeepEqual(d.BuildConstraints, want) {
+		t.Errorf("update: want the build constraints %v, got %v", want, d.BuildConstraints)
+	}
+	if want := "A, second edition."; d.Description != want {
+		t.Errorf("update: want the description %q, got %q", want, d.Description)
+	}
+	if want := []string{"new.bin"}; !reflect.DeepEqual(d.Stripped, want) {
+		t.Errorf("update: want %v stripped, got %v", want, d.Stripped)
+	}
+	if _, err := os.Stat(filepath.Join(vendorDir(false), "example.com", "a", "new.bin")); !os.IsNotExist(err) {
+		t.Error("update: new.bin vendored")
+	}
+}
+
+func TestUpdateStripBinariesPolicy(t *testing.T) {
+	fixtures, project := t.TempDir(), t.TempDir()
+	writeFixtures(t, fixtures, map[string]string{
+		"example.com/a/.fixture-revision": "2222\n",
+		"example.com/a/a.go":              "package a\n",
+		"example.com/a/small.bin":         "\x00" + strings.Repeat("x", 200),
+	})
+	writeFixtures(t, project, map[string]string{
+		"vendor/example.com/a/a.go": "package a\n",
+	})
+	// fetched with -strip-binaries -strip-binaries-size 100, nothing to strip then.
+	old := vendor.Dependency{
+		Importpath:        "example.com/a",
+		Repository:        "https://example.com/a",
+		Revision:          "1111",
+		Branch:            "master",
+		StripBinaries:     true,
+		StripBinariesSize: 100,
+	}
+	if err := vendor.WriteManifest(filepath.Join(project, "manifest"), &vendor.Manifest{Dependencies: []vendor.Dependency{old}}); err != nil {
+		t.Fatal(err)
+	}
+
+	enterProject(t, project)
+	vendor.DefaultFetcher = &vendor.FixtureFetcher{Root: fixtures}
+
+	if err := runCommand(t, cmdUpdate, "example.com/a"); err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := vendor.ReadManifest(manifestFile())
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := m.Dependencies[0]
+	if want := []string{"small.bin"}; !reflect.DeepEqual(d.Stripped, want) {
+		t.Errorf("update: want %v stripped, got %v", want, d.Stripped)
+	}
+	if !d.StripBinaries || d.StripBinariesSize != 100 {
+		t.Errorf("update: want the strip policy of size 100 kept, got %v of size %d", d.StripBinaries, d.StripBinariesSize)
+	}
+	if _, err := os.Stat(filepath.Join(vendorDir(false), "example.com", "a", "small.bin")); !os.IsNotExist(err) {
+		t.Error("update: small.bin vendored")
+	}
+}
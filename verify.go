@@ -0,0 +1,201 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+
+	"github.com/themoonbear/gvt/gbvendor"
+)
+
+var (
+	verifyAgainst string // reference manifest to compare with
+	verifyJSON    bool
+
+	verifyParallel bool // hash the vendored trees concurrently
+	verifyJobs     int  // number of trees hashed at once with -parallel
+	verifyDiff     bool // fetch pristine copies to name the changed files
+)
+
+func addVerifyFlags(fs *flag.FlagSet) {
+	fs.StringVar(&verifyAgainst, "against", "", "reference manifest to compare the revisions with")
+	fs.BoolVar(&verifyJSON, "json", false, "print the report as JSON")
+	fs.BoolVar(&verifyParallel, "parallel", false, "hash the vendored trees concurrently")
+	fs.IntVar(&verifyJobs, "j", runtime.NumCPU(), "number of trees hashed at once with -parallel")
+	fs.BoolVar(&verifyDiff, "diff", false, "fetch the recorded revision of the mismatched dependencies to report the files changed")
+}
+
+var cmdVerify = &Command{
+	Name:      "verify",
+	UsageLine: "verify [-against manifest] [-parallel [-j N]] [-diff] [-json]",
+	Short:     "check the vendored dependencies",
+	Long: `verify checks the vendored dependencies and exits non-zero on any problem.
+
+Without -against, verify recomputes the checksum of the vendored tree of
+every dependency and reports those differing from the checksum recorded in
+the manifest (mismatch), and those without a recorded checksum
+(unverifiable), which do not fail the command. The report is sorted by
+import path. The comparison is local: nothing is fetched unless -diff is
+given.
+
+Flags:
+	-against manifest
+		compare the manifest with a reference one, such as the manifest of
+		another project kept in lockstep, and report every dependency
+		vendored at another revision, only in the reference (missing) or
+		only in the manifest (extra). Nothing is modified.
+	-parallel
+		hash the vendored trees concurrently, which is faster on multi-core
+		machines with fast disks. The report is the same.
+	-j N
+		the number of trees hashed at once with -parallel. Defaults to the
+		number of CPUs.
+	-diff
+		for each mismatched dependency, fetch the recorded revision again
+		to list the files modified, added or removed since it was
+		vendored, as by a hand edit. A dependency which cannot be fetched
+		is reported with the error, and the others are still listed.
+	-json
+		print the report as JSON.
+
+`,
+	Run: func(args []string) error {
+		if len(args) != 0 {
+			return fmt.Errorf("verify takes no arguments")
+		}
+		m, err := vendor.ReadManifest(manifestFile())
+		if err != nil {
+			return fmt.Errorf("could not load manifest: %v", err)
+		}
+		if verifyAgainst == "" {
+			return verifyChecksums(m)
+		}
+		if _, err := os.Stat(verifyAgainst); err != nil {
+			return fmt.Errorf("could not load reference manifest: %v", err)
+		}
+		ref, err := vendor.ReadManifest(verifyAgainst)
+		if err != nil {
+			return fmt.Errorf("could not load reference manifest: %v", err)
+		}
+
+		drift := vendor.Compare(m, ref)
+		if verifyJSON {
+			if drift == nil {
+				drift = []vendor.Drift{}
+			}
+			buf, err := json.MarshalIndent(drift, "", "\t")
+			if err != nil {
+				return err
+			}
+			fmt.Printf("%s\n", buf)
+		} else {
+			for _, d := range drift {
+				switch d.Kind {
+				case vendor.DriftRevision:
+					fmt.Printf("%s: at %s, reference at %s\n", d.Importpath, d.Local, d.Reference)
+				case vendor.DriftMissing:
+					fmt.Printf("%s: missing, reference at %s\n", d.Importpath, d.Reference)
+				case vendor.DriftExtra:
+					fmt.Printf("%s: extra, at %s\n", d.Importpath, d.Local)
+				}
+			}
+		}
+		if len(drift) > 0 {
+			return fmt.Errorf("verify: %d dependencies differ from %s", len(drift), verifyAgainst)
+		}
+		return nil
+	},
+	AddFlags: addVerifyFlags,
+}
+
+// checksumReport is a dependency reported by verify without -against.
+type checksumReport struct {
+	Importpath string              `json:"importpath"`
+	Status     string              `json:"status"`
+	Files      []vendor.FileChange `json:"files,omitempty"`
+	Error      string              `json:"error,omitempty"` // why the files could not be listed
+}
+
+// verifyChecksums reports the dependencies of m whose vendored tree does
+// not match their recorded checksum.
+func verifyChecksums(m *vendor.Manifest) error {
+	j := 1
+	if verifyParallel {
+		j = verifyJobs
+	}
+	mismatched, unverifiable, err := vendor.VerifyChecksumsParallel(vendorDir(false), m, m.Dependencies, j)
+	if err != nil {
+		return err
+	}
+	report := []checksumReport{}
+	for _, ip := range mismatched {
+		r := checksumReport{Importpath: ip, Status: "mismatch"}
+		if verifyDiff {
+			d, err := m.GetDependencyForImportpath(ip)
+			if err != nil {
+				return err
+			}
+			if r.Files, err = changedFiles(m, d); err != nil {
+				r.Error = fmt.Sprintf("could not list the changed files: %v", err)
+			}
+		}
+		report = append(report, r)
+	}
+	for _, ip := range unverifiable {
+		report = append(report, checksumReport{Importpath: ip, Status: "unverifiable"})
+	}
+	sort.Slice(report, func(i, j int) bool { return report[i].Importpath < report[j].Importpath })
+	if verifyJSON {
+		buf, err := json.MarshalIndent(report, "", "\t")
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%s\n", buf)
+	} else {
+		for _, r := range report {
+			fmt.Printf("%s: %s\n", r.Importpath, r.Status)
+			if r.Error != "" {
+				fmt.Printf("\t%s\n", r.Error)
+			}
+			for _, f := range r.Files {
+				fmt.Printf("\t%s %s\n", f.Change, f.Path)
+			}
+		}
+	}
+	if len(mismatched) > 0 {
+		return fmt.Errorf("verify: %d dependencies do not match their checksum", len(mismatched))
+	}
+	return nil
+}
+
+// changedFiles returns the files of the vendored tree of d, a dependency
+// of m, differing from a pristine copy of its recorded revision.
+func changedFiles(m *vendor.Manifest, d vendor.Dependency) ([]vendor.FileChange, error) {
+	repo, _, err := recordedRepo(d, false)
+	if err != nil {
+		return nil, err
+	}
+	wc, err := repo.Checkout("", "", d.Revision)
+	if err != nil {
+		return nil, err
+	}
+	defer wc.Destroy()
+	if err := restoreSubmodules(wc, d); err != nil {
+		return nil, err
+	}
+	tmp, err := ioutil.TempDir("", "gvt-verify")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmp)
+	pristine := filepath.Join(tmp, "tree")
+	if err := copyDependency(pristine, filepath.Join(wc.Dir(), d.Path), d); err != nil {
+		return nil, err
+	}
+	return vendor.DiffTrees(pristine, filepath.Join(vendorDir(false), filepath.FromSlash(d.Importpath)), m.Nested(d))
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/themoonbear/gvt/gbvendor"
+)
+
+func TestVerifyChecksums(t *testing.T) {
+	fixtures, _ := fetchProject(t, map[string]string{
+		"example.com/a/.fixture-revision": "1111\n",
+		"example.com/a/a.go":              "package a\n",
+	})
+
+	if err := runFetch(t, "example.com/a"); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join("vendor", "example.com", "a", "a.go"), []byte("package a // edited\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	verify := func(args ...string) []checksumReport {
+		out, err := captureStdout(t, func() error { return runCommand(t, cmdVerify, append([]string{"-json"}, args...)...) })
+		if err == nil {
+			t.Fatalf("verify %v: want an error for the edited tree", args)
+		}
+		var report []checksumReport
+		if err := json.Unmarshal([]byte(out), &report); err != nil {
+			t.Fatalf("verify %v: %v: %q", args, err, out)
+		}
+		return report
+	}
+
+	// the comparison is local, the fixtures are not needed.
+	vendor.DefaultFetcher = &vendor.FixtureFetcher{Root: t.TempDir()}
+	if got, want := verify(), []checksumReport{{Importpath: "example.com/a", Status: "mismatch"}}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("verify: want %v, got %v", want, got)
+	}
+	got := verify("-diff")
+	if len(got) != 1 || got[0].Error == "" || got[0].Files != nil {
+		t.Fatalf("verify -diff: want the error of fetching example.com/a reported, got %v", got)
+	}
+
+	vendor.DefaultFetcher = &vendor.FixtureFetcher{Root: fixtures}
+	want := []checksumReport{{
+		Importpath: "example.com/a",
+		Status:     "mismatch",
+		Files:      []vendor.FileChange{{Path: "a.go", Change: vendor.FileModified}},
+	}}
+	if got := verify("-diff"); !reflect.DeepEqual(got, want) {
+		t.Fatalf("verify -diff: want %v, got %v", want, got)
+	}
+}